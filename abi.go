@@ -0,0 +1,38 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// The version of the library's C interface. The major version changes when
+// the layout of a struct the host passes or receives changes, including
+// fields added to ScanConfig, or when a function changes its signature. The
+// minor version changes when functions, ScanResult status codes or
+// configuration keys are added.
+const (
+	abiMajor = 21
+	abiMinor = 1
+)
+
+// abiVersion packs the ABI version into an int, the major version in the
+// high 16 bits.
+func abiVersion() int {
+	return abiMajor<<16 | abiMinor
+}
+
+// abiCompatible reports whether a host built against the given ABI version
+// can use this library: the major versions match and the library is at least
+// as recent.
+func abiCompatible(major, minor int) bool {
+	return major == abiMajor && minor <= abiMinor
+}
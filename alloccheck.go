@@ -0,0 +1,130 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"sync"
+	"unsafe"
+
+	"github.com/google/osv-scalibr/log"
+)
+
+// Kinds of buffers returned to the host, each freed by its own destructor.
+type allocKind string
+
+const (
+	allocString allocKind = "string"
+	allocResult allocKind = "ScanResult"
+	allocData   allocKind = "result_data buffer"
+)
+
+// poisonByte overwrites freed buffers in debug mode, so reads after free
+// return recognizable garbage.
+const poisonByte = 0xdd
+
+// allocTracker records the live buffers returned to the host when
+// SCALIBR_DEBUG_ALLOC is set. Destructors then reject pointers that were
+// already freed, weren't returned by the library or are of another kind,
+// rather than corrupting the host's heap.
+type allocTracker struct {
+	once    sync.Once
+	enabled bool
+
+	mu   sync.Mutex
+	live map[unsafe.Pointer]trackedAlloc
+}
+
+type trackedAlloc struct {
+	kind allocKind
+	size int
+}
+
+var hostAllocs allocTracker
+
+// debug reports whether tracking is enabled. The environment is read on
+// first use, since buffers can be returned before ScalibrInit.
+func (t *allocTracker) debug() bool {
+	t.once.Do(func() {
+		t.enabled = os.Getenv(envDebugAlloc) == "1"
+		t.live = make(map[unsafe.Pointer]trackedAlloc)
+	})
+	return t.enabled
+}
+
+// track records that p, of the given kind and size, was returned.
+func (t *allocTracker) track(p unsafe.Pointer, kind allocKind, size int) {
+	if !t.debug() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.live[p] = trackedAlloc{kind: kind, size: size}
+}
+
+// check reports whether p is a live buffer of the given kind that the
+// destructor fn may read. Misuse is logged.
+func (t *allocTracker) check(p unsafe.Pointer, kind allocKind, fn string) bool {
+	if !t.debug() {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.lookup(p, kind, fn)
+	return ok
+}
+
+// release reports whether the destructor fn may free p, which must be a live
+// buffer of the given kind. Misuse is logged and the buffer left alone.
+func (t *allocTracker) release(p unsafe.Pointer, kind allocKind, fn string) bool {
+	if !t.debug() {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a, ok := t.lookup(p, kind, fn)
+	if !ok {
+		return false
+	}
+	delete(t.live, p)
+	b := unsafe.Slice((*byte)(p), a.size)
+	for i := range b {
+		b[i] = poisonByte
+	}
+	return true
+}
+
+// lookup returns the live buffer p, logging why fn can't use it if it isn't
+// one of the given kind. Must be called with t.mu held.
+func (t *allocTracker) lookup(p unsafe.Pointer, kind allocKind, fn string) (trackedAlloc, bool) {
+	a, ok := t.live[p]
+	switch {
+	case !ok:
+		log.Errorf("%s: %p wasn't returned by the library or was already freed", fn, p)
+		return a, false
+	case a.kind != kind:
+		log.Errorf("%s: %p is a %s, not a %s", fn, p, a.kind, kind)
+		return a, false
+	}
+	return a, true
+}
+
+// misuse logs a destructor call for an unknown handle in debug mode. Handles
+// are never reused, so this catches handles freed twice or used after free.
+func (t *allocTracker) misuse(fn string, handle int64) {
+	if t.debug() {
+		log.Errorf("%s: unknown handle %d, already freed or never created", fn, handle)
+	}
+}
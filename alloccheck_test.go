@@ -0,0 +1,77 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"unsafe"
+)
+
+func TestAllocTrackerDebug(t *testing.T) {
+	t.Setenv(envDebugAlloc, "1")
+	var tracker allocTracker
+	str := []byte("scalibr\x00")
+	data := make([]byte, 16)
+	strPtr, dataPtr := unsafe.Pointer(&str[0]), unsafe.Pointer(&data[0])
+	tracker.track(strPtr, allocString, len(str))
+	tracker.track(dataPtr, allocData, len(data))
+
+	if tracker.check(strPtr, allocData, "ScalibrFreeResultData") {
+		t.Error("check() accepted a string as a result_data buffer")
+	}
+	if tracker.release(strPtr, allocResult, "ScalibrFreeScanResult") {
+		t.Error("release() accepted a string as a ScanResult")
+	}
+	// Rejected calls leave the buffer alone
+	if string(str) != "scalibr\x00" {
+		t.Errorf("string after a rejected release = %q, want it untouched", str)
+	}
+	if !tracker.check(strPtr, allocString, "ScalibrFreeString") {
+		t.Error("check() rejected a live string")
+	}
+	if !tracker.release(strPtr, allocString, "ScalibrFreeString") {
+		t.Fatal("release() rejected a live string")
+	}
+	// Freed buffers are poisoned and rejected from then on
+	if !bytes.Equal(str, bytes.Repeat([]byte{poisonByte}, len(str))) {
+		t.Errorf("freed string = %x, want it poisoned", str)
+	}
+	if tracker.release(strPtr, allocString, "ScalibrFreeString") {
+		t.Error("release() accepted a string freed twice")
+	}
+	var unknown [8]byte
+	if tracker.check(unsafe.Pointer(&unknown[0]), allocData, "ScalibrFreeResultData") {
+		t.Error("check() accepted a pointer the library never returned")
+	}
+}
+
+func TestAllocTrackerDisabled(t *testing.T) {
+	t.Setenv(envDebugAlloc, "")
+	var tracker allocTracker
+	buf := []byte("scalibr\x00")
+	p := unsafe.Pointer(&buf[0])
+	tracker.track(p, allocString, len(buf))
+	// Without SCALIBR_DEBUG_ALLOC nothing is checked or poisoned
+	if !tracker.release(p, allocString, "ScalibrFreeString") || !tracker.release(p, allocString, "ScalibrFreeString") {
+		t.Error("release() rejected a pointer with checks disabled")
+	}
+	if !tracker.check(p, allocResult, "ScalibrFreeScanResult") {
+		t.Error("check() rejected a pointer with checks disabled")
+	}
+	if string(buf) != "scalibr\x00" {
+		t.Errorf("buffer = %q, want it untouched", buf)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	al "github.com/google/osv-scalibr/annotator/list"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// resolveAnnotators returns the annotators selected by names, which may be
+// SCALIBR annotator names or annotator collections such as "vex". Unlike in
+// plugins, "all" and "default" only select annotators.
+func resolveAnnotators(names []string) ([]plugin.Plugin, error) {
+	var plugins []plugin.Plugin
+	for _, name := range names {
+		annotators, err := al.AnnotatorsFromName(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range annotators {
+			plugins = append(plugins, a)
+		}
+	}
+	return plugins, nil
+}
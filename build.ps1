@@ -70,6 +70,18 @@ if ($LASTEXITCODE -ne 0) {
 Write-Host "Creating output directory..." -ForegroundColor Yellow
 New-Item -ItemType Directory -Force -Path "dist" | Out-Null
 
+# Every function exported to C must be part of the Scalibr* API
+$exports = Select-String -Path *.go -Pattern '^//export (\S+)' | ForEach-Object { $_.Matches[0].Groups[1].Value }
+$badExports = $exports | Where-Object { $_ -notlike 'Scalibr*' }
+if ($badExports) {
+    Write-Host "Exported functions must be prefixed with Scalibr:" -ForegroundColor Red
+    $badExports | ForEach-Object { Write-Host "  $_" }
+    exit 1
+}
+
+# Module definition for MSVC import libraries: lib /def:dist\scalibr.def
+@("LIBRARY scalibr.dll", "EXPORTS") + ($exports | Sort-Object | ForEach-Object { "    $_" }) | Set-Content -Path "dist\scalibr.def"
+
 # Build the Go shared library
 Write-Host "Building Go shared library..." -ForegroundColor Yellow
 $env:CGO_ENABLED = "1"
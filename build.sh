@@ -59,9 +59,25 @@ go mod download
 # Create output directory
 mkdir -p dist
 
+# Every function exported to C must be part of the Scalibr* API
+BAD_EXPORTS=$(grep -h '^//export ' *.go | grep -v '^//export Scalibr' || true)
+if [ -n "$BAD_EXPORTS" ]; then
+    echo "Exported functions must be prefixed with Scalibr:"
+    echo "$BAD_EXPORTS"
+    exit 1
+fi
+
+# Export only the Scalibr* API, not the Go runtime and cgo symbols. Windows
+# builds export only the //export functions by default.
+case "$GOOS" in
+    linux)  EXTLDFLAGS="-Wl,--version-script=$PWD/exports.map";;
+    darwin) EXTLDFLAGS="-Wl,-exported_symbols_list,$PWD/exports.darwin";;
+    *)      EXTLDFLAGS="";;
+esac
+
 # Build the Go shared library
 echo "Building Go shared library..."
-CGO_ENABLED=1 GOOS=$GOOS go build -buildmode=c-shared -o "dist/$LIBRARY_NAME"
+CGO_ENABLED=1 GOOS=$GOOS go build -buildmode=c-shared -ldflags="-extldflags=$EXTLDFLAGS" -o "dist/$LIBRARY_NAME"
 
 echo "Build complete!"
 echo "Library: dist/$LIBRARY_NAME"
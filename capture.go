@@ -0,0 +1,382 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/osv-scalibr/annotator"
+	"github.com/google/osv-scalibr/detector"
+	"github.com/google/osv-scalibr/enricher"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	"github.com/google/osv-scalibr/extractor/standalone"
+	scalibrfs "github.com/google/osv-scalibr/fs"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/packageindex"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// pluginOutputLimit caps the output kept per plugin.
+const pluginOutputLimit = 64 << 10
+
+// pluginOutput is what was written to stdout and stderr while a plugin ran.
+type pluginOutput struct {
+	Plugin    string
+	Output    string
+	Truncated bool `json:",omitempty"`
+}
+
+// captureMarker starts the records written into the pipes of the standard
+// streams when a plugin starts ("+") and stops ("-") running, and when a
+// capture is flushed ("="), followed by a token and a NUL byte. Since the
+// records and the output go through the same pipe, output is credited to
+// the plugin that was running when it was written.
+const captureMarker = "\x00scalibr-capture:"
+
+// captureMu guards the redirection of the standard streams, which lasts as
+// long as any scan captures output. It's only held while a capture starts
+// or stops, so capturing scans run beside any other scan.
+var captureMu sync.Mutex
+
+var stdCapture struct {
+	// Capturing scans running
+	users     int
+	streams   []*capturedStream
+	nextToken atomic.Int64
+	// The *captureTarget and pending *sync.WaitGroup flushes by token
+	targets sync.Map
+	flushes sync.Map
+}
+
+// captureTarget is the plugin of a capturing scan that output is credited
+// to while it runs.
+type captureTarget struct {
+	c      *outputCapture
+	plugin string
+	// Guarded by c.mu, nil until the plugin writes something
+	output *pluginOutput
+}
+
+// capturedStream is a standard stream redirected into a pipe. Output
+// written while no capturing plugin runs is passed through to the original
+// stream.
+type capturedStream struct {
+	w       *os.File
+	orig    *os.File
+	restore func()
+	done    chan struct{}
+}
+
+// outputCapture collects what is written to stdout and stderr while the
+// plugins of a scan run.
+type outputCapture struct {
+	streams []*capturedStream
+
+	mu sync.Mutex
+	// Tokens of the plugins by name
+	tokens  map[string]int64
+	outputs []*pluginOutput
+	stopped bool
+}
+
+// startCapture starts capturing the standard streams, redirecting them into
+// pipes unless another capturing scan already did.
+func startCapture() (*outputCapture, error) {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	if stdCapture.users == 0 {
+		for _, fd := range []int{1, 2} {
+			s, err := captureStream(fd)
+			if err != nil {
+				releaseStreams(stdCapture.streams)
+				stdCapture.streams = nil
+				return nil, err
+			}
+			stdCapture.streams = append(stdCapture.streams, s)
+		}
+	}
+	stdCapture.users++
+	return &outputCapture{streams: stdCapture.streams, tokens: make(map[string]int64)}, nil
+}
+
+// captureStream redirects the standard stream fd into a pipe and starts
+// reading it.
+func captureStream(fd int) (*capturedStream, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	orig, restore, err := redirectStdStream(fd, w)
+	if err != nil {
+		r.Close()
+		w.Close()
+		return nil, err
+	}
+	s := &capturedStream{w: w, orig: orig, restore: restore, done: make(chan struct{})}
+	go s.read(r)
+	return s, nil
+}
+
+// releaseStreams restores the standard streams and waits for the output
+// still in their pipes to be read.
+func releaseStreams(streams []*capturedStream) {
+	for _, s := range streams {
+		s.restore()
+		s.w.Close()
+	}
+	for _, s := range streams {
+		<-s.done
+		s.orig.Close()
+	}
+}
+
+// read credits what is written into the pipe r to the plugin that was
+// running, until the pipe is closed.
+func (s *capturedStream) read(r *os.File) {
+	defer close(s.done)
+	defer r.Close()
+	marker := []byte(captureMarker)
+	// Tokens of the running plugins, the last started one is credited
+	var running []int64
+	write := func(data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		if len(running) == 0 {
+			s.orig.Write(data)
+			return
+		}
+		if t, ok := stdCapture.targets.Load(running[len(running)-1]); ok {
+			t.(*captureTarget).record(data)
+		}
+	}
+	var pending []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		pending = append(pending, buf[:n]...)
+		for {
+			i := bytes.Index(pending, marker)
+			if i < 0 {
+				break
+			}
+			end := bytes.IndexByte(pending[i+len(marker):], 0)
+			if end < 0 {
+				break
+			}
+			write(pending[:i])
+			record := string(pending[i+len(marker) : i+len(marker)+end])
+			pending = pending[i+len(marker)+end+1:]
+			if record == "" {
+				continue
+			}
+			token, _ := strconv.ParseInt(record[1:], 10, 64)
+			switch record[0] {
+			case '+':
+				running = append(running, token)
+			case '-':
+				if i := slices.Index(running, token); i >= 0 {
+					running = slices.Delete(running, i, i+1)
+				}
+			case '=':
+				if wg, ok := stdCapture.flushes.Load(token); ok {
+					wg.(*sync.WaitGroup).Done()
+				}
+			}
+		}
+		if err != nil {
+			write(pending)
+			return
+		}
+		// Hold back a record that isn't complete yet
+		keep := bytes.Index(pending, marker)
+		if keep < 0 {
+			keep = bytes.LastIndexByte(pending, 0)
+			if keep >= 0 && !bytes.HasPrefix(marker, pending[keep:]) {
+				keep = -1
+			}
+		}
+		if keep < 0 {
+			keep = len(pending)
+		}
+		write(pending[:keep])
+		pending = slices.Clone(pending[keep:])
+	}
+}
+
+// mark writes a record into the pipes of all streams.
+func (c *outputCapture) mark(kind byte, token int64) {
+	record := []byte(captureMarker + string(kind) + strconv.FormatInt(token, 10) + "\x00")
+	for _, s := range c.streams {
+		s.w.Write(record)
+	}
+}
+
+func (t *captureTarget) record(data []byte) {
+	c := t.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return
+	}
+	out := t.output
+	if out == nil {
+		out = &pluginOutput{Plugin: t.plugin}
+		t.output = out
+		c.outputs = append(c.outputs, out)
+	}
+	if room := pluginOutputLimit - len(out.Output); len(data) > room {
+		data = data[:max(room, 0)]
+		out.Truncated = true
+	}
+	out.Output += string(data)
+}
+
+// stop waits for the output written so far to be read and returns it by
+// plugin, in order of first output. The standard streams are restored once
+// no capturing scan is left. Later calls return nil.
+func (c *outputCapture) stop() []pluginOutput {
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	token := stdCapture.nextToken.Add(1)
+	wg := &sync.WaitGroup{}
+	wg.Add(len(c.streams))
+	stdCapture.flushes.Store(token, wg)
+	c.mark('=', token)
+	wg.Wait()
+	stdCapture.flushes.Delete(token)
+
+	c.mu.Lock()
+	c.stopped = true
+	for _, t := range c.tokens {
+		stdCapture.targets.Delete(t)
+	}
+	c.mu.Unlock()
+
+	captureMu.Lock()
+	stdCapture.users--
+	if stdCapture.users == 0 {
+		releaseStreams(stdCapture.streams)
+		stdCapture.streams = nil
+	}
+	captureMu.Unlock()
+
+	outputs := make([]pluginOutput, len(c.outputs))
+	for i, o := range c.outputs {
+		outputs[i] = *o
+	}
+	return outputs
+}
+
+// running credits the output written until the returned function is called
+// to the named plugin. Output written while plugins of several capturing
+// scans run, or several plugins of one scan, is credited to the one that
+// started last.
+func (c *outputCapture) running(name string) func() {
+	c.mu.Lock()
+	token, ok := c.tokens[name]
+	if !ok {
+		token = stdCapture.nextToken.Add(1)
+		c.tokens[name] = token
+		stdCapture.targets.Store(token, &captureTarget{c: c, plugin: name})
+	}
+	c.mu.Unlock()
+	c.mark('+', token)
+	return func() { c.mark('-', token) }
+}
+
+// wrap returns plugins with every extractor, detector, annotator and enricher
+// instrumented to credit its output.
+func (c *outputCapture) wrap(plugins []plugin.Plugin) []plugin.Plugin {
+	wrapped := make([]plugin.Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		switch p := p.(type) {
+		case filesystem.Extractor:
+			wrapped = append(wrapped, &captureExtractor{Extractor: p, c: c})
+		case standalone.Extractor:
+			wrapped = append(wrapped, &captureStandalone{Extractor: p, c: c})
+		case detector.Detector:
+			wrapped = append(wrapped, &captureDetector{Detector: p, c: c})
+		case annotator.Annotator:
+			wrapped = append(wrapped, &captureAnnotator{Annotator: p, c: c})
+		case enricher.Enricher:
+			wrapped = append(wrapped, &captureEnricher{Enricher: p, c: c})
+		default:
+			wrapped = append(wrapped, p)
+		}
+	}
+	return wrapped
+}
+
+type captureExtractor struct {
+	filesystem.Extractor
+	c *outputCapture
+}
+
+func (e *captureExtractor) Extract(ctx context.Context, input *filesystem.ScanInput) (inventory.Inventory, error) {
+	defer e.c.running(e.Name())()
+	return e.Extractor.Extract(ctx, input)
+}
+
+type captureStandalone struct {
+	standalone.Extractor
+	c *outputCapture
+}
+
+func (e *captureStandalone) Extract(ctx context.Context, input *standalone.ScanInput) (inventory.Inventory, error) {
+	defer e.c.running(e.Name())()
+	return e.Extractor.Extract(ctx, input)
+}
+
+type captureDetector struct {
+	detector.Detector
+	c *outputCapture
+}
+
+func (d *captureDetector) Scan(ctx context.Context, root *scalibrfs.ScanRoot, px *packageindex.PackageIndex) (inventory.Finding, error) {
+	defer d.c.running(d.Name())()
+	return d.Detector.Scan(ctx, root, px)
+}
+
+type captureAnnotator struct {
+	annotator.Annotator
+	c *outputCapture
+}
+
+func (a *captureAnnotator) Annotate(ctx context.Context, input *annotator.ScanInput, inv *inventory.Inventory) error {
+	defer a.c.running(a.Name())()
+	return a.Annotator.Annotate(ctx, input, inv)
+}
+
+type captureEnricher struct {
+	enricher.Enricher
+	c *outputCapture
+}
+
+func (e *captureEnricher) Enrich(ctx context.Context, input *enricher.ScanInput, inv *inventory.Inventory) error {
+	defer e.c.running(e.Name())()
+	return e.Enricher.Enrich(ctx, input, inv)
+}
@@ -0,0 +1,123 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCapturedStreamRead(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	origR, origW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer origR.Close()
+	s := &capturedStream{w: w, orig: origW, done: make(chan struct{})}
+	go s.read(r)
+	c := &outputCapture{streams: []*capturedStream{s}, tokens: make(map[string]int64)}
+	defer func() {
+		for _, token := range c.tokens {
+			stdCapture.targets.Delete(token)
+		}
+	}()
+
+	w.WriteString("before\n")
+	end := c.running("python/wheelegg")
+	w.WriteString("warning: malformed METADATA\n")
+	inner := c.running("go/binary")
+	w.WriteString("not a Go binary\n")
+	inner()
+	w.WriteString("skipping wheel\n")
+	end()
+	w.WriteString("after\n")
+	w.Close()
+	<-s.done
+	origW.Close()
+
+	want := []*pluginOutput{
+		{Plugin: "python/wheelegg", Output: "warning: malformed METADATA\nskipping wheel\n"},
+		{Plugin: "go/binary", Output: "not a Go binary\n"},
+	}
+	if !reflect.DeepEqual(c.outputs, want) {
+		t.Errorf("captured %+v, want %+v", c.outputs, want)
+	}
+	passed, err := io.ReadAll(origR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(passed) != "before\nafter\n" {
+		t.Errorf("passed through %q, want %q", passed, "before\nafter\n")
+	}
+}
+
+func TestCaptureOutputTruncated(t *testing.T) {
+	c := &outputCapture{}
+	target := &captureTarget{c: c, plugin: "noisy"}
+	chunk := make([]byte, pluginOutputLimit/2+1)
+	target.record(chunk)
+	target.record(chunk)
+	if len(c.outputs) != 1 {
+		t.Fatalf("captured %d outputs, want 1", len(c.outputs))
+	}
+	if got := c.outputs[0]; len(got.Output) != pluginOutputLimit || !got.Truncated {
+		t.Errorf("captured %d bytes, truncated %v, want %d bytes, truncated", len(got.Output), got.Truncated, pluginOutputLimit)
+	}
+}
+
+// TestConcurrentCaptures redirects the test binary's stdout while it runs.
+func TestConcurrentCaptures(t *testing.T) {
+	first, err := startCapture()
+	if err != nil {
+		t.Fatalf("startCapture() error: %v", err)
+	}
+	second, err := startCapture()
+	if err != nil {
+		first.stop()
+		t.Fatalf("startCapture() error: %v", err)
+	}
+
+	end := first.running("first/plugin")
+	os.Stdout.WriteString("one\n")
+	end()
+	end = second.running("second/plugin")
+	os.Stdout.WriteString("two\n")
+	end()
+
+	got := first.stop()
+	if want := []pluginOutput{{Plugin: "first/plugin", Output: "one\n"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("first capture = %+v, want %+v", got, want)
+	}
+	// The streams stay redirected for the second scan
+	end = second.running("second/plugin")
+	os.Stdout.WriteString("three\n")
+	end()
+	got = second.stop()
+	if want := []pluginOutput{{Plugin: "second/plugin", Output: "two\nthree\n"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("second capture = %+v, want %+v", got, want)
+	}
+	if stdCapture.users != 0 || stdCapture.streams != nil {
+		t.Errorf("streams still redirected after the last capture stopped")
+	}
+	if got := second.stop(); got != nil {
+		t.Errorf("second stop() = %+v, want nil", got)
+	}
+}
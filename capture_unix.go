@@ -0,0 +1,39 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// redirectStdStream points the file descriptor fd, 1 or 2, at w, so that
+// writes by Go, C code and child processes alike end up in w. It returns a
+// file still writing to the original stream, which the caller closes, and
+// the function restoring the stream.
+func redirectStdStream(fd int, w *os.File) (*os.File, func(), error) {
+	saved, err := unix.Dup(fd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := unix.Dup2(int(w.Fd()), fd); err != nil {
+		unix.Close(saved)
+		return nil, nil, err
+	}
+	return os.NewFile(uintptr(saved), "std"), func() { unix.Dup2(saved, fd) }, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// redirectStdStream points the standard output (fd 1) or error (fd 2)
+// handle, and Go's os.Stdout or os.Stderr, at w. It returns a file still
+// writing to the original stream, which the caller closes, and the function
+// restoring the stream. C runtime streams opened before the redirect keep
+// their handles.
+func redirectStdStream(fd int, w *os.File) (*os.File, func(), error) {
+	std, goFile := uint32(windows.STD_OUTPUT_HANDLE), &os.Stdout
+	if fd == 2 {
+		std, goFile = windows.STD_ERROR_HANDLE, &os.Stderr
+	}
+	h, err := windows.GetStdHandle(std)
+	if err != nil {
+		return nil, nil, err
+	}
+	var dup windows.Handle
+	p := windows.CurrentProcess()
+	if err := windows.DuplicateHandle(p, h, p, &dup, 0, false, windows.DUPLICATE_SAME_ACCESS); err != nil {
+		return nil, nil, err
+	}
+	if err := windows.SetStdHandle(std, windows.Handle(w.Fd())); err != nil {
+		windows.CloseHandle(dup)
+		return nil, nil, err
+	}
+	prev := *goFile
+	*goFile = w
+	restore := func() {
+		windows.SetStdHandle(std, h)
+		*goFile = prev
+	}
+	return os.NewFile(uintptr(dup), "std"), restore, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"slices"
+
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/extractor/standalone"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// carriedName names the plugin that reports carried inventory. Its status
+// is left out of the result, since hosts don't enable it.
+const carriedName = "bindings/carried"
+
+// carriedInventory reports inventory carried forward from an earlier result
+// or scan as a standalone extractor. SCALIBR runs standalone extractors
+// after the filesystem walk and before the detectors and enrichers, so the
+// carried inventory gets the same detection and enrichment as what is
+// extracted anew.
+type carriedInventory struct {
+	inv inventory.Inventory
+}
+
+func (c *carriedInventory) Name() string { return carriedName }
+
+func (c *carriedInventory) Version() int { return 0 }
+
+func (c *carriedInventory) Requirements() *plugin.Capabilities { return &plugin.Capabilities{} }
+
+func (c *carriedInventory) Extract(ctx context.Context, input *standalone.ScanInput) (inventory.Inventory, error) {
+	return c.inv, nil
+}
+
+// carryPackages makes the scan configured by cfg report pkgs along with
+// what it finds.
+func carryPackages(cfg *scalibr.ScanConfig, pkgs []*extractor.Package) {
+	if len(pkgs) == 0 {
+		return
+	}
+	carryInventory(cfg, inventory.Inventory{Packages: pkgs})
+}
+
+// carryInventory makes the scan configured by cfg report inv along with
+// what it finds.
+func carryInventory(cfg *scalibr.ScanConfig, inv inventory.Inventory) {
+	cfg.Plugins = append(slices.Clip(cfg.Plugins), &carriedInventory{inv: inv})
+}
+
+// dropCarriedStatus removes the status of the plugins added by
+// carryInventory from r.
+func dropCarriedStatus(r *scalibr.ScanResult) {
+	r.PluginStatus = slices.DeleteFunc(r.PluginStatus, func(s *plugin.Status) bool {
+		return s != nil && s.Name == carriedName
+	})
+}
@@ -0,0 +1,207 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// CBOR major types, see RFC 8949 section 3.1.
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborSimple = 7 << 5
+)
+
+// marshalCBOR encodes v as CBOR. The document mirrors the JSON encoding of v,
+// so it has the same keys and layout as the JSON output. Map keys are sorted
+// following the core deterministic encoding of RFC 8949.
+func marshalCBOR(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var tree any
+	if err := d.Decode(&tree); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := writeCBOR(&b, tree); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeCBOR(b *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteByte(cborSimple | 22)
+	case bool:
+		if v {
+			b.WriteByte(cborSimple | 21)
+		} else {
+			b.WriteByte(cborSimple | 20)
+		}
+	case string:
+		writeCBORHead(b, cborText, uint64(len(v)))
+		b.WriteString(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			if i < 0 {
+				writeCBORHead(b, cborNegInt, uint64(-(i + 1)))
+			} else {
+				writeCBORHead(b, cborUint, uint64(i))
+			}
+			return nil
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			writeCBORHead(b, cborUint, u)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		b.WriteByte(cborSimple | 27)
+		b.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+	case []any:
+		writeCBORHead(b, cborArray, uint64(len(v)))
+		for _, e := range v {
+			if err := writeCBOR(b, e); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		// Deterministic order: bytewise order of the encoded keys, which for
+		// text keys is shorter keys first
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.SortFunc(keys, func(a, b string) int {
+			if len(a) != len(b) {
+				return len(a) - len(b)
+			}
+			return bytes.Compare([]byte(a), []byte(b))
+		})
+		writeCBORHead(b, cborMap, uint64(len(v)))
+		for _, k := range keys {
+			writeCBORHead(b, cborText, uint64(len(k)))
+			b.WriteString(k)
+			if err := writeCBOR(b, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor: unsupported type %T", v)
+	}
+	return nil
+}
+
+// writeCBORHead writes the initial bytes of a data item with the shortest
+// argument encoding.
+func writeCBORHead(b *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		b.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		b.Write([]byte{major | 24, byte(n)})
+	case n <= math.MaxUint16:
+		b.WriteByte(major | 25)
+		b.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= math.MaxUint32:
+		b.WriteByte(major | 26)
+		b.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		b.WriteByte(major | 27)
+		b.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+// readCBORHead decodes the initial bytes of the data item at b[i:],
+// returning its major type, argument and the offset following the head.
+func readCBORHead(b []byte, i int) (byte, uint64, int, error) {
+	if i >= len(b) {
+		return 0, 0, 0, errors.New("cbor: unexpected end of data")
+	}
+	major, info := b[i]&0xe0, b[i]&0x1f
+	i++
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), i, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, 0, fmt.Errorf("cbor: unsupported additional information %d", info)
+	}
+	if i+size > len(b) {
+		return 0, 0, 0, errors.New("cbor: unexpected end of data")
+	}
+	var n uint64
+	for _, c := range b[i : i+size] {
+		n = n<<8 | uint64(c)
+	}
+	return major, n, i + size, nil
+}
+
+// cborItemEnd returns the offset following the data item at b[i:]. Only the
+// items written by marshalCBOR are supported.
+func cborItemEnd(b []byte, i int) (int, error) {
+	major, n, i, err := readCBORHead(b, i)
+	if err != nil {
+		return 0, err
+	}
+	switch major {
+	case cborUint, cborNegInt, cborSimple:
+		// Floats carry their value in the head
+		return i, nil
+	case cborText:
+		if n > uint64(len(b)-i) {
+			return 0, errors.New("cbor: unexpected end of data")
+		}
+		return i + int(n), nil
+	case cborArray, cborMap:
+		items := n
+		if major == cborMap {
+			items *= 2
+		}
+		for ; items > 0; items-- {
+			if i, err = cborItemEnd(b, i); err != nil {
+				return 0, err
+			}
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("cbor: unsupported major type %d", major>>5)
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// The encodings are those of RFC 8949 appendix A, except that floats are
+// always written in double precision.
+func TestMarshalCBOR(t *testing.T) {
+	for _, tc := range []struct {
+		v    any
+		want string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{1000, "1903e8"},
+		{1000000, "1a000f4240"},
+		{1000000000000, "1b000000e8d4a51000"},
+		{uint64(math.MaxUint64), "1bffffffffffffffff"},
+		{-1, "20"},
+		{-1000, "3903e7"},
+		{1.5, "fb3ff8000000000000"},
+		{"", "60"},
+		{"IETF", "6449455446"},
+		{true, "f5"},
+		{false, "f4"},
+		{nil, "f6"},
+		{[]int{1, 2, 3}, "83010203"},
+		{map[string]any{"b": []int{2, 3}, "a": 1}, "a26161016162820203"},
+		// Shorter keys sort first
+		{map[string]int{"aa": 1, "b": 2}, "a261620262616101"},
+		// Structs are encoded like their JSON
+		{struct {
+			Version int
+			Name    string `json:",omitempty"`
+			Skipped string `json:"-"`
+		}{Version: 1, Skipped: "x"}, "a16756657273696f6e01"},
+	} {
+		got, err := marshalCBOR(tc.v)
+		if err != nil {
+			t.Errorf("marshalCBOR(%#v) error: %v", tc.v, err)
+			continue
+		}
+		if hex.EncodeToString(got) != tc.want {
+			t.Errorf("marshalCBOR(%#v) = %x, want %s", tc.v, got, tc.want)
+		}
+	}
+}
+
+func FuzzCBORItemEnd(f *testing.F) {
+	for _, v := range []any{0, -1000, 1.5, "IETF", []any{1, "a", nil}, map[string]any{"Inventory": map[string]any{"Packages": []any{}}}} {
+		data, err := marshalCBOR(v)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		end, err := cborItemEnd(data, 0)
+		if err != nil {
+			return
+		}
+		if end <= 0 || end > len(data) {
+			t.Fatalf("cborItemEnd(%x) = %d, out of range", data, end)
+		}
+	})
+}
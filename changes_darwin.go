@@ -0,0 +1,239 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/osv-scalibr/log"
+	"golang.org/x/sys/unix"
+)
+
+const kqueueFflags = unix.NOTE_WRITE | unix.NOTE_EXTEND | unix.NOTE_ATTRIB |
+	unix.NOTE_DELETE | unix.NOTE_RENAME | unix.NOTE_REVOKE
+
+// treeWatcher reports changes below a root through kqueue, which watches
+// open files: every directory and regular file of the tree is opened up
+// front. Directories report added and removed entries, which are opened as
+// they appear, and files report changes to their contents.
+type treeWatcher struct {
+	kq   int
+	root string
+	// Watched paths by descriptor and back, and the entry names of the
+	// watched directories; only used by run once started
+	paths   map[int]string
+	fds     map[string]int
+	entries map[string]map[string]bool
+	done    chan struct{}
+}
+
+// watchTree starts reporting the paths below root that change to changes
+// until the watcher is closed. It fails if the tree can't be watched as a
+// whole, e.g. once the process runs out of file descriptors.
+func watchTree(root string, changes chan<- treeChange) (*treeWatcher, error) {
+	kq, err := unix.Kqueue()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kqueue: %w", err)
+	}
+	unix.CloseOnExec(kq)
+	w := &treeWatcher{
+		kq:      kq,
+		root:    root,
+		paths:   make(map[int]string),
+		fds:     make(map[string]int),
+		entries: make(map[string]map[string]bool),
+		done:    make(chan struct{}),
+	}
+	if err := w.addTree(root); err != nil {
+		w.closeAll()
+		return nil, err
+	}
+	go w.run(changes)
+	return w, nil
+}
+
+// addTree watches dir, which may also be a file, and the directories and
+// files below it. Entries that can't be opened are skipped, running out of
+// file descriptors is an error.
+func (w *treeWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != dir {
+			w.entries[filepath.Dir(path)][de.Name()] = true
+		}
+		if !de.IsDir() && !de.Type().IsRegular() {
+			return nil
+		}
+		err = w.add(path, de.IsDir())
+		switch {
+		case errors.Is(err, unix.EMFILE) || errors.Is(err, unix.ENFILE):
+			return fmt.Errorf("failed to watch %s, raise the open file limit: %w", path, err)
+		case err != nil && path == dir:
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		case err != nil && de.IsDir():
+			return fs.SkipDir
+		}
+		return nil
+	})
+}
+
+// add watches the file or directory at path unless it's watched already.
+func (w *treeWatcher) add(path string, dir bool) error {
+	if _, ok := w.fds[path]; ok {
+		return nil
+	}
+	// O_EVTONLY doesn't keep the volume from being unmounted
+	fd, err := unix.Open(path, unix.O_EVTONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	var ev unix.Kevent_t
+	unix.SetKevent(&ev, fd, unix.EVFILT_VNODE, unix.EV_ADD|unix.EV_CLEAR)
+	ev.Fflags = kqueueFflags
+	if _, err := unix.Kevent(w.kq, []unix.Kevent_t{ev}, nil, nil); err != nil {
+		unix.Close(fd)
+		return err
+	}
+	w.paths[fd] = path
+	w.fds[path] = fd
+	if dir {
+		w.entries[path] = make(map[string]bool)
+	}
+	return nil
+}
+
+// remove stops watching path and everything below it. Closing a descriptor
+// removes its kqueue events.
+func (w *treeWatcher) remove(path string) {
+	prefix := path + string(filepath.Separator)
+	for p, fd := range w.fds {
+		if p == path || strings.HasPrefix(p, prefix) {
+			unix.Close(fd)
+			delete(w.fds, p)
+			delete(w.paths, fd)
+			delete(w.entries, p)
+		}
+	}
+}
+
+func (w *treeWatcher) run(changes chan<- treeChange) {
+	defer w.closeAll()
+	events := make([]unix.Kevent_t, 64)
+	// Wake up regularly to notice the watcher being closed
+	timeout := unix.NsecToTimespec(int64(500 * time.Millisecond))
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		n, err := unix.Kevent(w.kq, nil, events, &timeout)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			log.Warnf("failed to watch %s: %v", w.root, err)
+			return
+		}
+		for _, ev := range events[:n] {
+			path, ok := w.paths[int(ev.Ident)]
+			if !ok {
+				continue
+			}
+			changed := []string{path}
+			switch {
+			case ev.Fflags&(unix.NOTE_DELETE|unix.NOTE_RENAME|unix.NOTE_REVOKE) != 0:
+				w.remove(path)
+				// Replaced, e.g. by renaming a new file over it. Entries moved
+				// elsewhere are reported by their new directory.
+				if _, err := os.Lstat(path); err == nil && path != w.root {
+					if err := w.addTree(path); err != nil {
+						log.Warnf("changes below %s may go unnoticed: %v", path, err)
+					}
+				}
+			case w.entries[path] != nil && ev.Fflags&unix.NOTE_WRITE != 0:
+				changed = w.rescan(path)
+			}
+			for _, p := range changed {
+				if !w.send(changes, p) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// rescan compares the entries of the watched directory dir with those it
+// had, watches the new ones and returns the added and removed paths.
+func (w *treeWatcher) rescan(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []string{dir}
+	}
+	before := w.entries[dir]
+	current := make(map[string]bool, len(entries))
+	var changed []string
+	for _, e := range entries {
+		current[e.Name()] = true
+		if before[e.Name()] {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if err := w.addTree(path); err != nil {
+			log.Warnf("changes below %s may go unnoticed: %v", path, err)
+		}
+		changed = append(changed, path)
+	}
+	for name := range before {
+		if !current[name] {
+			path := filepath.Join(dir, name)
+			w.remove(path)
+			changed = append(changed, path)
+		}
+	}
+	w.entries[dir] = current
+	return changed
+}
+
+// send reports a changed path, returning false once the watcher is closed.
+func (w *treeWatcher) send(changes chan<- treeChange, path string) bool {
+	select {
+	case changes <- treeChange{root: w.root, path: path}:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// closeAll releases the kqueue and the watched descriptors.
+func (w *treeWatcher) closeAll() {
+	for fd := range w.paths {
+		unix.Close(fd)
+	}
+	unix.Close(w.kq)
+}
+
+// close stops the watcher. It must be called at most once.
+func (w *treeWatcher) close() {
+	close(w.done)
+}
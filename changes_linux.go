@@ -0,0 +1,152 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/google/osv-scalibr/log"
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_ATTRIB |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ONLYDIR
+
+// treeWatcher reports changes below a root through inotify, which watches
+// one directory at a time: every directory of the tree is added up front,
+// and those created or moved in later as they appear.
+type treeWatcher struct {
+	fd   int
+	root string
+	// Directories by watch descriptor, only used by run once started
+	dirs map[int]string
+	done chan struct{}
+}
+
+// watchTree starts reporting the paths below root that change to changes
+// until the watcher is closed. It fails if the tree can't be watched as a
+// whole, e.g. once the inotify watch limit is reached.
+func watchTree(root string, changes chan<- treeChange) (*treeWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inotify instance: %w", err)
+	}
+	w := &treeWatcher{fd: fd, root: root, dirs: make(map[int]string), done: make(chan struct{})}
+	if err := w.addTree(root); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	go w.run(changes)
+	return w, nil
+}
+
+// addTree watches dir and the directories below it. Directories that can't
+// be read are skipped, running out of watches is an error.
+func (w *treeWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, de fs.DirEntry, err error) error {
+		if err != nil || !de.IsDir() {
+			return nil
+		}
+		wd, err := unix.InotifyAddWatch(w.fd, path, inotifyMask)
+		switch {
+		case errors.Is(err, unix.ENOSPC):
+			return fmt.Errorf("failed to watch %s, raise fs.inotify.max_user_watches: %w", path, err)
+		case err != nil && path == dir:
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		case err == nil:
+			w.dirs[wd] = path
+		}
+		return nil
+	})
+}
+
+func (w *treeWatcher) run(changes chan<- treeChange) {
+	defer unix.Close(w.fd)
+	buf := make([]byte, 64*1024)
+	fds := []unix.PollFd{{Fd: int32(w.fd), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		// Wake up regularly to notice the watcher being closed
+		if n, err := unix.Poll(fds, 500); n <= 0 {
+			if err != nil && !errors.Is(err, unix.EINTR) {
+				log.Warnf("failed to watch %s: %v", w.root, err)
+				return
+			}
+			continue
+		}
+		n, err := unix.Read(w.fd, buf)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			log.Warnf("failed to watch %s: %v", w.root, err)
+			return
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(ev.Len)]
+			off += unix.SizeofInotifyEvent + int(ev.Len)
+
+			if ev.Mask&unix.IN_Q_OVERFLOW != 0 {
+				// Events were lost, so the whole tree has to be compared again
+				if !w.send(changes, w.root) {
+					return
+				}
+				continue
+			}
+			dir, ok := w.dirs[int(ev.Wd)]
+			if !ok {
+				continue
+			}
+			if ev.Mask&unix.IN_IGNORED != 0 {
+				delete(w.dirs, int(ev.Wd))
+				continue
+			}
+			path := filepath.Join(dir, string(bytes.TrimRight(name, "\x00")))
+			if ev.Mask&unix.IN_ISDIR != 0 && ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				if err := w.addTree(path); err != nil {
+					log.Warnf("changes below %s may go unnoticed: %v", path, err)
+				}
+			}
+			if !w.send(changes, path) {
+				return
+			}
+		}
+	}
+}
+
+// send reports a changed path, returning false once the watcher is closed.
+func (w *treeWatcher) send(changes chan<- treeChange, path string) bool {
+	select {
+	case changes <- treeChange{root: w.root, path: path}:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// close stops the watcher. It must be called at most once.
+func (w *treeWatcher) close() {
+	close(w.done)
+}
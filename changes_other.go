@@ -0,0 +1,30 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux && !windows && !darwin
+
+package main
+
+import "errors"
+
+// treeWatcher is unavailable outside Linux, Windows and macOS, where daemons
+// poll their roots instead.
+type treeWatcher struct{}
+
+// watchTree fails outside Linux, Windows and macOS.
+func watchTree(root string, changes chan<- treeChange) (*treeWatcher, error) {
+	return nil, errors.New("change notifications are not supported on this platform")
+}
+
+func (w *treeWatcher) close() {}
@@ -0,0 +1,109 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/google/osv-scalibr/log"
+	"golang.org/x/sys/windows"
+)
+
+const notifyFilter = windows.FILE_NOTIFY_CHANGE_FILE_NAME | windows.FILE_NOTIFY_CHANGE_DIR_NAME |
+	windows.FILE_NOTIFY_CHANGE_SIZE | windows.FILE_NOTIFY_CHANGE_LAST_WRITE
+
+// treeWatcher reports changes below a root through ReadDirectoryChangesW,
+// which watches the whole tree with one handle.
+type treeWatcher struct {
+	handle windows.Handle
+	root   string
+	done   chan struct{}
+}
+
+// watchTree starts reporting the paths below root that change to changes
+// until the watcher is closed.
+func watchTree(root string, changes chan<- treeChange) (*treeWatcher, error) {
+	p, err := windows.UTF16PtrFromString(root)
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.CreateFile(p, windows.FILE_LIST_DIRECTORY,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+	w := &treeWatcher{handle: h, root: root, done: make(chan struct{})}
+	go w.run(changes)
+	return w, nil
+}
+
+func (w *treeWatcher) run(changes chan<- treeChange) {
+	defer windows.CloseHandle(w.handle)
+	// FILE_NOTIFY_INFORMATION records are DWORD-aligned
+	buf := make([]uint32, 16*1024)
+	for {
+		var n uint32
+		err := windows.ReadDirectoryChanges(w.handle, (*byte)(unsafe.Pointer(&buf[0])), uint32(len(buf)*4), true, notifyFilter, &n, nil, 0)
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		if err != nil {
+			log.Warnf("failed to watch %s: %v", w.root, err)
+			return
+		}
+		if n == 0 {
+			// The buffer overflowed, so the whole tree has to be compared
+			// again
+			if !w.send(changes, w.root) {
+				return
+			}
+			continue
+		}
+		data := unsafe.Slice((*byte)(unsafe.Pointer(&buf[0])), n)
+		for off := uint32(0); ; {
+			info := (*windows.FileNotifyInformation)(unsafe.Pointer(&data[off]))
+			name := windows.UTF16ToString(unsafe.Slice(&info.FileName, info.FileNameLength/2))
+			if !w.send(changes, filepath.Join(w.root, name)) {
+				return
+			}
+			if info.NextEntryOffset == 0 {
+				break
+			}
+			off += info.NextEntryOffset
+		}
+	}
+}
+
+// send reports a changed path, returning false once the watcher is closed.
+func (w *treeWatcher) send(changes chan<- treeChange, path string) bool {
+	select {
+	case changes <- treeChange{root: w.root, path: path}:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// close stops the watcher. It must be called at most once.
+func (w *treeWatcher) close() {
+	close(w.done)
+	// Wakes up the pending ReadDirectoryChangesW
+	windows.CancelIoEx(w.handle, nil)
+}
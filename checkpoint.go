@@ -0,0 +1,309 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"time"
+
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/detector"
+	"github.com/google/osv-scalibr/enricher"
+	"github.com/google/osv-scalibr/extractor/standalone"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/log"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// checkpointVersion is the version of the checkpoint format. Checkpoints of
+// other versions are ignored.
+const checkpointVersion = 1
+
+// defaultCheckpointInterval is how often a checkpoint is written without
+// checkpoint_interval_ms.
+const defaultCheckpointInterval = time.Minute
+
+// rootFilesPart names the part of a scan root holding the files directly in
+// it, as opposed to its top-level directories.
+const rootFilesPart = "."
+
+// checkpointFile is the on-disk form of a checkpoint.
+type checkpointFile struct {
+	Version int
+	Roots   []*checkpointRoot
+}
+
+// checkpointRoot records how far the walk of a scan root got.
+type checkpointRoot struct {
+	Path string
+	// Top-level directories whose walk is complete, and rootFilesPart once
+	// the files directly in the root are
+	Done []string `json:",omitempty"`
+	// Whether the whole root is
+	Complete bool `json:",omitempty"`
+	// What the completed parts found
+	Result *checkpointResult `json:",omitempty"`
+}
+
+// checkpointResult is the scan result of the completed parts of a root.
+type checkpointResult struct {
+	Version      string
+	StartTime    time.Time
+	EndTime      time.Time
+	Status       *plugin.ScanStatus
+	PluginStatus []*plugin.Status
+	// Encoded by marshalInventory, so that the package metadata, secrets
+	// and findings survive the restart
+	Inventory []byte
+}
+
+// newCheckpointResult returns the checkpoint form of r.
+func newCheckpointResult(r *scalibr.ScanResult) (*checkpointResult, error) {
+	inv, err := marshalInventory(&r.Inventory)
+	if err != nil {
+		return nil, err
+	}
+	return &checkpointResult{
+		Version:      r.Version,
+		StartTime:    r.StartTime,
+		EndTime:      r.EndTime,
+		Status:       r.Status,
+		PluginStatus: r.PluginStatus,
+		Inventory:    inv,
+	}, nil
+}
+
+// scanResult rebuilds the scan result recorded by newCheckpointResult.
+func (r *checkpointResult) scanResult() (*scalibr.ScanResult, error) {
+	inv, err := unmarshalInventory(r.Inventory)
+	if err != nil {
+		return nil, err
+	}
+	return &scalibr.ScanResult{
+		Version:      r.Version,
+		StartTime:    r.StartTime,
+		EndTime:      r.EndTime,
+		Status:       r.Status,
+		PluginStatus: r.PluginStatus,
+		Inventory:    *inv,
+	}, nil
+}
+
+// checkpointer walks the directory roots of a scan one top-level directory
+// at a time and periodically records the completed ones and what they
+// in the file at checkpoint_path, which a later scan of the same roots
+// resumes from.
+type checkpointer struct {
+	path     string
+	interval time.Duration
+	// When the checkpoint was last written
+	last    time.Time
+	file    checkpointFile
+	resumed bool
+}
+
+// newCheckpointer returns the checkpointer of a scan of roots, or nil
+// without checkpoint_path. A checkpoint left at the path by a scan of the
+// same roots is resumed; one of a different scan is overwritten.
+func newCheckpointer(opts *scanOptions, roots []string) (*checkpointer, error) {
+	if opts.CheckpointPath == "" {
+		return nil, nil
+	}
+	c := &checkpointer{
+		path:     cleanHostPath(opts.CheckpointPath),
+		interval: defaultCheckpointInterval,
+		last:     time.Now(),
+		file:     checkpointFile{Version: checkpointVersion},
+	}
+	if opts.CheckpointIntervalMs > 0 {
+		c.interval = time.Duration(opts.CheckpointIntervalMs) * time.Millisecond
+	}
+	for _, root := range roots {
+		c.file.Roots = append(c.file.Roots, &checkpointRoot{Path: root})
+	}
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	var f checkpointFile
+	if err := json.Unmarshal(data, &f); err != nil || f.Version != checkpointVersion || !c.sameRoots(f.Roots) {
+		log.Warnf("ignoring checkpoint %s, which isn't of this scan", c.path)
+		return c, nil
+	}
+	c.file = f
+	c.resumed = true
+	return c, nil
+}
+
+func (c *checkpointer) sameRoots(roots []*checkpointRoot) bool {
+	return slices.EqualFunc(c.file.Roots, roots, func(a, b *checkpointRoot) bool {
+		return b != nil && a.Path == b.Path
+	})
+}
+
+// scan walks the i-th root as configured by cfg, skipping the parts the
+// checkpoint records as complete and returning their results instead.
+// The parts are walked in turn, the files directly in the root last, with
+// the filesystem extractors only. The detectors, standalone extractors and
+// enrichers then run once over the inventory of all parts, as they would in
+// a scan of the whole root. A cancelled scan returns what it found so far
+// and leaves the part it was walking, or the detection, to be done again.
+func (c *checkpointer) scan(ctx context.Context, scanner *scalibr.Scanner, cfg *scalibr.ScanConfig, i int) (*scalibr.ScanResult, error) {
+	r := c.file.Roots[i]
+	var acc *scalibr.ScanResult
+	if r.Result != nil {
+		var err error
+		if acc, err = r.Result.scanResult(); err != nil {
+			return nil, fmt.Errorf("failed to restore checkpoint: %w", err)
+		}
+	}
+	if r.Complete {
+		return acc, nil
+	}
+	entries, err := cfg.ScanRoots[0].FS.ReadDir(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", r.Path, err)
+	}
+	parts := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			parts = append(parts, e.Name())
+		}
+	}
+	parts = append(parts, rootFilesPart)
+	for _, part := range parts {
+		if slices.Contains(r.Done, part) {
+			continue
+		}
+		partCfg := *cfg
+		partCfg.ScanRoots = editListings(cfg.ScanRoots, partListing(part))
+		partCfg.Plugins = walkPlugins(cfg.Plugins)
+		result := scanner.Scan(ctx, &partCfg)
+		if ctx.Err() != nil {
+			// The walk of the part was cut short, record the ones before it
+			c.save(i, acc)
+			return mergeScanResults(acc, result), nil
+		}
+		acc = mergeScanResults(acc, result)
+		r.Done = append(r.Done, part)
+		if time.Since(c.last) >= c.interval {
+			c.save(i, acc)
+		}
+	}
+	c.save(i, acc)
+
+	// Run the rest of the plugins without walking anything, reporting the
+	// inventory of the parts to them
+	detectCfg := *cfg
+	detectCfg.ScanRoots = editListings(cfg.ScanRoots, partListing(""))
+	detectCfg.Plugins = slices.DeleteFunc(slices.Clone(cfg.Plugins), isWalkPlugin)
+	var walked inventory.Inventory
+	if acc != nil {
+		walked = acc.Inventory
+	}
+	carryInventory(&detectCfg, walked)
+	result := scanner.Scan(ctx, &detectCfg)
+	if ctx.Err() != nil {
+		// The parts stay recorded and the detection runs again on resume
+		if acc == nil {
+			return result, nil
+		}
+		return acc, nil
+	}
+	if acc != nil {
+		// The result reports the walked inventory again
+		acc.Inventory = inventory.Inventory{}
+	}
+	acc = mergeScanResults(acc, result)
+	r.Complete = true
+	c.save(i, acc)
+	return acc, nil
+}
+
+// save records the result of the completed parts of the i-th root and
+// writes the checkpoint, logging failures since the scan goes on
+// regardless.
+func (c *checkpointer) save(i int, acc *scalibr.ScanResult) {
+	c.last = time.Now()
+	var err error
+	if acc != nil {
+		c.file.Roots[i].Result, err = newCheckpointResult(acc)
+	}
+	var data []byte
+	if err == nil {
+		data, err = json.Marshal(&c.file)
+	}
+	if err == nil {
+		// The inventory holds the secrets found so far in the clear
+		err = writeFileAtomicMode(c.path, data, 0o600)
+	}
+	if err != nil {
+		log.Warnf("failed to write checkpoint %s: %v", c.path, err)
+	}
+}
+
+// finish removes the checkpoint once the scan it records is complete, so
+// that the next scan starts over.
+func (c *checkpointer) finish(complete bool) {
+	if !complete {
+		return
+	}
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		log.Warnf("failed to remove checkpoint %s: %v", c.path, err)
+	}
+}
+
+// partListing limits the top-level listing of a scan root to the directory
+// named part, to the files with rootFilesPart, or to nothing with "".
+func partListing(part string) listingEditor {
+	return func(dir string, entries []fs.DirEntry) []fs.DirEntry {
+		if dir != "." {
+			return entries
+		}
+		return slices.DeleteFunc(slices.Clone(entries), func(e fs.DirEntry) bool {
+			if part == rootFilesPart {
+				return e.IsDir()
+			}
+			return e.Name() != part
+		})
+	}
+}
+
+// walkPlugins returns the plugins of plugins that extract the walked files.
+func walkPlugins(plugins []plugin.Plugin) []plugin.Plugin {
+	return slices.DeleteFunc(slices.Clone(plugins), func(p plugin.Plugin) bool {
+		return !isWalkPlugin(p)
+	})
+}
+
+// isWalkPlugin reports whether p extracts the walked files, as opposed to
+// the detectors, standalone extractors and enrichers, which work on the
+// whole root or its inventory.
+func isWalkPlugin(p plugin.Plugin) bool {
+	switch p.(type) {
+	case detector.Detector, standalone.Extractor, enricher.Enricher:
+		return false
+	}
+	return true
+}
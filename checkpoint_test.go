@@ -0,0 +1,206 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/binary/platform"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	scalibrfs "github.com/google/osv-scalibr/fs"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/packageindex"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// busyboxDetector reports every BusyBox package older than 1.36 as
+// vulnerable, so that scans can be compared by what their detectors see.
+type busyboxDetector struct{}
+
+func (busyboxDetector) Name() string { return "test/busybox" }
+
+func (busyboxDetector) Version() int { return 0 }
+
+func (busyboxDetector) Requirements() *plugin.Capabilities { return &plugin.Capabilities{} }
+
+func (busyboxDetector) RequiredExtractors() []string { return nil }
+
+func (busyboxDetector) DetectedFinding() inventory.Finding { return inventory.Finding{} }
+
+func (busyboxDetector) Scan(ctx context.Context, root *scalibrfs.ScanRoot, px *packageindex.PackageIndex) (inventory.Finding, error) {
+	var f inventory.Finding
+	for _, p := range px.GetAll() {
+		if p.Name == "busybox" && p.Version < "1.36" {
+			f.PackageVulns = append(f.PackageVulns, &inventory.PackageVuln{Package: p})
+		}
+	}
+	return f, nil
+}
+
+// recordingExtractor is the BusyBox extractor, recording the files it
+// extracts and calling onExtract before each.
+type recordingExtractor struct {
+	busyboxExtractor
+	onExtract func(path string)
+
+	mu    sync.Mutex
+	paths []string
+}
+
+func (e *recordingExtractor) Extract(ctx context.Context, input *filesystem.ScanInput) (inventory.Inventory, error) {
+	e.mu.Lock()
+	e.paths = append(e.paths, input.Path)
+	e.mu.Unlock()
+	if e.onExtract != nil {
+		e.onExtract(input.Path)
+	}
+	return e.busyboxExtractor.Extract(ctx, input)
+}
+
+// writeCheckpointRoot writes a root with BusyBox binaries in two top-level
+// directories and directly in the root.
+func writeCheckpointRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, version := range map[string]string{
+		"a/bin/busybox": "1.35.0",
+		"b/busybox":     "1.36.1",
+		"busybox":       "1.34.1",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("BusyBox v"+version+" (2023-01-01)"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func checkpointScanConfig(root string, e *recordingExtractor) *scalibr.ScanConfig {
+	return &scalibr.ScanConfig{
+		Plugins:      []plugin.Plugin{e, busyboxDetector{}},
+		ScanRoots:    scalibrfs.RealFSScanRoots(root),
+		Capabilities: &plugin.Capabilities{OS: platform.OS(), DirectFS: true},
+	}
+}
+
+// summary lists the packages and vulnerable packages of r in a comparable
+// form.
+func summary(r *scalibr.ScanResult) (pkgs, vulns []string) {
+	for _, p := range r.Inventory.Packages {
+		pkgs = append(pkgs, fmt.Sprintf("%s@%s %v", p.Name, p.Version, p.Locations))
+	}
+	for _, v := range r.Inventory.PackageVulns {
+		vulns = append(vulns, fmt.Sprintf("%s@%s %v", v.Package.Name, v.Package.Version, v.Package.Locations))
+	}
+	slices.Sort(pkgs)
+	slices.Sort(vulns)
+	return pkgs, vulns
+}
+
+func TestCheckpointScanDetectsAcrossParts(t *testing.T) {
+	root := writeCheckpointRoot(t)
+	cp, err := newCheckpointer(&scanOptions{CheckpointPath: filepath.Join(t.TempDir(), "scan.checkpoint")}, []string{root})
+	if err != nil {
+		t.Fatalf("newCheckpointer() error: %v", err)
+	}
+	got, err := cp.scan(context.Background(), scalibr.New(), checkpointScanConfig(root, &recordingExtractor{}), 0)
+	if err != nil {
+		t.Fatalf("scan() error: %v", err)
+	}
+	dropCarriedStatus(got)
+	want := scalibr.New().Scan(context.Background(), checkpointScanConfig(root, &recordingExtractor{}))
+
+	gotPkgs, gotVulns := summary(got)
+	wantPkgs, wantVulns := summary(want)
+	if !slices.Equal(gotPkgs, wantPkgs) {
+		t.Errorf("checkpointed scan packages = %v, want %v", gotPkgs, wantPkgs)
+	}
+	// The detector sees a/bin/busybox although it isn't directly in the root
+	if !slices.Equal(gotVulns, wantVulns) || len(gotVulns) != 2 {
+		t.Errorf("checkpointed scan vulnerabilities = %v, want %v", gotVulns, wantVulns)
+	}
+	if !cp.file.Roots[0].Complete {
+		t.Errorf("root not recorded as complete")
+	}
+}
+
+func TestCheckpointScanResumes(t *testing.T) {
+	root := writeCheckpointRoot(t)
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+	opts := &scanOptions{CheckpointPath: path, CheckpointIntervalMs: 1}
+
+	// Interrupt the first scan in the walk of b
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first, err := newCheckpointer(opts, []string{root})
+	if err != nil {
+		t.Fatalf("newCheckpointer() error: %v", err)
+	}
+	interrupting := &recordingExtractor{onExtract: func(p string) {
+		if p == "b/busybox" {
+			cancel()
+		}
+	}}
+	if _, err := first.scan(ctx, scalibr.New(), checkpointScanConfig(root, interrupting), 0); err != nil {
+		t.Fatalf("interrupted scan() error: %v", err)
+	}
+	first.finish(false)
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("no checkpoint after the interrupted scan: %v", err)
+	}
+
+	second, err := newCheckpointer(opts, []string{root})
+	if err != nil {
+		t.Fatalf("newCheckpointer() error: %v", err)
+	}
+	if !second.resumed {
+		t.Fatalf("checkpoint of the interrupted scan not resumed")
+	}
+	if done := second.file.Roots[0].Done; !slices.Equal(done, []string{"a"}) {
+		t.Errorf("resumed checkpoint has %v done, want [a]", done)
+	}
+	resuming := &recordingExtractor{}
+	got, err := second.scan(context.Background(), scalibr.New(), checkpointScanConfig(root, resuming), 0)
+	if err != nil {
+		t.Fatalf("resumed scan() error: %v", err)
+	}
+	second.finish(true)
+
+	if slices.Contains(resuming.paths, "a/bin/busybox") {
+		t.Errorf("resumed scan extracted %v, want a skipped", resuming.paths)
+	}
+	want := scalibr.New().Scan(context.Background(), checkpointScanConfig(root, &recordingExtractor{}))
+	gotPkgs, gotVulns := summary(got)
+	wantPkgs, wantVulns := summary(want)
+	if !slices.Equal(gotPkgs, wantPkgs) {
+		t.Errorf("resumed scan packages = %v, want %v", gotPkgs, wantPkgs)
+	}
+	if !slices.Equal(gotVulns, wantVulns) {
+		t.Errorf("resumed scan vulnerabilities = %v, want %v", gotVulns, wantVulns)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint left after the completed scan: %v", err)
+	}
+}
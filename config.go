@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfigFile reads scan options from a YAML, TOML or JSON file, chosen by
+// the file extension. Unknown keys are rejected so that typos don't silently
+// fall back to defaults.
+func loadConfigFile(path string) (*scanOptions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fileError(codeNotFound, "failed to read config file: %v", err)
+	}
+	opts := &scanOptions{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(opts); err != nil {
+			return nil, fileError(codeInvalidFile, "invalid config file %s: %v", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), opts)
+		if err != nil {
+			return nil, fileError(codeInvalidFile, "invalid config file %s: %v", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			var errs validationErrors
+			for _, key := range undecoded {
+				errs = append(errs, fieldError{Field: key.String(), Code: codeUnknownField, Message: "unknown key"})
+			}
+			return nil, errs
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(opts); err != nil {
+			return nil, fileError(codeInvalidFile, "invalid config file %s: %v", path, err)
+		}
+	default:
+		return nil, fileError(codeInvalidFile, "unsupported config file extension %q, expected .yaml, .yml, .toml or .json", ext)
+	}
+	return opts, nil
+}
+
+// fileError reports a problem with the config file as a whole.
+func fileError(code, format string, args ...any) validationErrors {
+	return validationErrors{{Code: code, Message: fmt.Sprintf(format, args...)}}
+}
+
+// parseConfigJSON reads scan options from a JSON document with the keys of
+// the config files.
+func parseConfigJSON(s string) (*scanOptions, error) {
+	opts := &scanOptions{}
+	if err := decodeOptions([]byte(s), opts); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return nil, validationErrors{{Field: strings.Trim(field, `"`), Code: codeUnknownField, Message: "unknown key"}}
+		}
+		return nil, fileError(codeInvalidFile, "invalid config JSON: %v", err)
+	}
+	return opts, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// configBuilder accumulates the scan options set through a config handle.
+// Options are keyed by their config file names, e.g. "max_file_size".
+type configBuilder struct {
+	mu       sync.Mutex
+	fields   map[string]json.RawMessage
+	progress progressFunc
+	findings findingFunc
+	lastErr  error
+}
+
+type configRegistry struct {
+	mu      sync.Mutex
+	nextID  int64
+	configs map[int64]*configBuilder
+}
+
+var configs = &configRegistry{configs: make(map[int64]*configBuilder)}
+
+func (r *configRegistry) add(b *configBuilder) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	r.configs[r.nextID] = b
+	return r.nextID
+}
+
+func (r *configRegistry) lookup(id int64) *configBuilder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.configs[id]
+}
+
+func (r *configRegistry) remove(id int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.configs[id]
+	delete(r.configs, id)
+	return ok
+}
+
+func newConfigBuilder() *configBuilder {
+	return &configBuilder{fields: make(map[string]json.RawMessage)}
+}
+
+// set sets option key to the JSON-encoded value.
+func (b *configBuilder) set(key string, value json.RawMessage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.check(key, value); err != nil {
+		return err
+	}
+	b.fields[key] = value
+	return nil
+}
+
+// add appends the JSON-encoded value to the list option key.
+func (b *configBuilder) add(key string, value json.RawMessage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var list []json.RawMessage
+	if cur, ok := b.fields[key]; ok {
+		// Checked to be a list when it was set
+		json.Unmarshal(cur, &list)
+	}
+	data, err := json.Marshal(append(list, value))
+	if err == nil {
+		err = b.check(key, data)
+	}
+	if err != nil {
+		return err
+	}
+	b.fields[key] = data
+	return nil
+}
+
+// check verifies that key is a known option and value has its type, and
+// records the outcome as the builder's last error.
+func (b *configBuilder) check(key string, value json.RawMessage) error {
+	b.lastErr = checkOption(key, value)
+	return b.lastErr
+}
+
+// err returns the error of the last failed call, or nil if it succeeded.
+func (b *configBuilder) err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastErr
+}
+
+// setProgress sets the receiver of the progress updates of the scans, or
+// removes it if progress is nil.
+func (b *configBuilder) setProgress(progress progressFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.progress = progress
+}
+
+// setFindings sets the receiver of the inventory streamed by the scans, or
+// removes it if findings is nil.
+func (b *configBuilder) setFindings(findings findingFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.findings = findings
+}
+
+// options decodes the options set so far.
+func (b *configBuilder) options() (*scanOptions, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, err := json.Marshal(b.fields)
+	if err != nil {
+		return nil, err
+	}
+	opts := &scanOptions{}
+	if err := decodeOptions(data, opts); err != nil {
+		return nil, validationErrors{{Code: codeInvalidValue, Message: err.Error()}}
+	}
+	opts.progress = b.progress
+	opts.findings = b.findings
+	return opts, nil
+}
+
+// checkOption checks that value is valid for option key.
+func checkOption(key string, value json.RawMessage) error {
+	data, err := json.Marshal(map[string]json.RawMessage{key: value})
+	if err != nil {
+		return validationErrors{{Field: key, Code: codeInvalidValue, Message: "value is not valid JSON"}}
+	}
+	if err := decodeOptions(data, &scanOptions{}); err != nil {
+		if strings.HasPrefix(err.Error(), "json: unknown field") {
+			return validationErrors{{Field: key, Code: codeUnknownField, Message: "unknown option"}}
+		}
+		return validationErrors{{Field: key, Code: codeInvalidValue, Message: err.Error()}}
+	}
+	return nil
+}
+
+// decodeOptions decodes JSON scan options, rejecting unknown keys.
+func decodeOptions(data []byte, opts *scanOptions) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(opts)
+}
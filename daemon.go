@@ -0,0 +1,563 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/log"
+)
+
+const defaultWatchInterval = 2 * time.Second
+
+// defaultNotifyDelay is how long a daemon watching change notifications
+// waits for more changes before re-extracting.
+const defaultNotifyDelay = 200 * time.Millisecond
+
+// daemonEvent is delivered to the daemon's callback as JSON.
+type daemonEvent struct {
+	// "inventory_delta", "scan_result", "watch_fallback" or "error".
+	Type    string
+	Root    string
+	Added   []*extractor.Package `json:",omitempty"`
+	Removed []*extractor.Package `json:",omitempty"`
+	Result  *scanOutput          `json:",omitempty"`
+	Error   string               `json:",omitempty"`
+}
+
+// fileSnapshot is the state of a file used to detect changes.
+type fileSnapshot struct {
+	size    int64
+	modTime time.Time
+}
+
+// rootState is what a daemon knows about one of its watched roots.
+type rootState struct {
+	files map[string]fileSnapshot
+	// Packages by the root-relative path of the file they were extracted from.
+	packages map[string][]*extractor.Package
+}
+
+// daemon is a long-running scanner that delivers results through a callback.
+// Its background activities run until the done channel is closed.
+type daemon struct {
+	id   int64
+	emit func([]byte)
+
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	mu   sync.Mutex
+	opts *scanOptions
+	// gen is bumped on every reload to invalidate in-flight refreshes.
+	gen      int
+	roots    map[string]*rootState
+	watching bool
+}
+
+type daemonRegistry struct {
+	mu      sync.Mutex
+	nextID  int64
+	daemons map[int64]*daemon
+}
+
+var daemons = &daemonRegistry{daemons: make(map[int64]*daemon)}
+
+func (r *daemonRegistry) add(d *daemon) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	d.id = r.nextID
+	r.daemons[d.id] = d
+	return d.id
+}
+
+func (r *daemonRegistry) lookup(id int64) *daemon {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.daemons[id]
+}
+
+func (r *daemonRegistry) remove(id int64) *daemon {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.daemons[id]
+	delete(r.daemons, id)
+	return d
+}
+
+func newDaemon(opts *scanOptions, emit func([]byte)) *daemon {
+	return &daemon{
+		opts:  opts,
+		emit:  emit,
+		done:  make(chan struct{}),
+		roots: make(map[string]*rootState),
+	}
+}
+
+// send serializes and delivers an event to the callback.
+func (d *daemon) send(ev *daemonEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Errorf("failed to marshal daemon event: %v", err)
+		return
+	}
+	d.emit(data)
+}
+
+// startWatch begins polling the daemon's roots every interval. The first
+// poll runs a full scan and reports all packages as added.
+func (d *daemon) startWatch(interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.watching {
+		return errors.New("daemon is already watching")
+	}
+	d.watching = true
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			d.refreshAll()
+			select {
+			case <-d.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// treeChange is a path below a watched root reported as changed by a
+// treeWatcher. The root itself stands for any path below it.
+type treeChange struct {
+	root string
+	path string
+}
+
+// startNotifyWatch begins watching the daemon's roots for the changes the
+// OS reports and re-extracts the changed files once no more changes came in
+// for delay. Roots that can't be watched, e.g. on platforms without
+// change notifications, are polled as by startWatch. The first refresh runs
+// a full scan and reports all packages as added.
+func (d *daemon) startNotifyWatch(delay time.Duration) error {
+	if delay <= 0 {
+		delay = defaultNotifyDelay
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.watching {
+		return errors.New("daemon is already watching")
+	}
+	d.watching = true
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.notifyLoop(delay)
+	}()
+	return nil
+}
+
+func (d *daemon) notifyLoop(delay time.Duration) {
+	changes := make(chan treeChange, 1024)
+	// Watchers by root, nil for the roots that are polled
+	watchers := make(map[string]*treeWatcher)
+	defer func() {
+		for _, w := range watchers {
+			if w != nil {
+				w.close()
+			}
+		}
+	}()
+	// Changed paths by root, nil for a full refresh
+	pending := make(map[string]map[string]bool)
+	var flush <-chan time.Time
+	ticker := time.NewTicker(defaultWatchInterval)
+	defer ticker.Stop()
+
+	// Watching starts before the first refresh so that no change is missed
+	d.syncWatchers(watchers, changes)
+	d.refreshAll()
+	for {
+		select {
+		case <-d.done:
+			return
+		case c := <-changes:
+			paths, seen := pending[c.root]
+			switch rel := normalizeLocation(c.root, c.path); {
+			case rel == "":
+				pending[c.root] = nil
+			case !seen:
+				pending[c.root] = map[string]bool{rel: true}
+			case paths != nil:
+				paths[rel] = true
+			}
+			if flush == nil {
+				flush = time.After(delay)
+			}
+		case <-flush:
+			flush = nil
+			for root, paths := range pending {
+				if err := d.refreshPaths(root, slices.Collect(maps.Keys(paths))); err != nil {
+					d.send(&daemonEvent{Type: "error", Root: root, Error: err.Error()})
+				}
+			}
+			clear(pending)
+		case <-ticker.C:
+			// Pick up reloaded roots, poll those without a watcher and fully
+			// refresh those a reload reset
+			for _, root := range d.syncWatchers(watchers, changes) {
+				if err := d.refresh(root); err != nil {
+					d.send(&daemonEvent{Type: "error", Root: root, Error: err.Error()})
+				}
+			}
+		}
+	}
+}
+
+// syncWatchers starts watching the daemon's current roots and stops watching
+// those it no longer has. It returns the roots to refresh in full: those
+// that can't be watched, and those without a snapshot.
+func (d *daemon) syncWatchers(watchers map[string]*treeWatcher, changes chan<- treeChange) []string {
+	d.mu.Lock()
+	roots := d.opts.roots()
+	var unsnapshotted []string
+	for _, root := range roots {
+		if state := d.roots[root]; state == nil || state.files == nil {
+			unsnapshotted = append(unsnapshotted, root)
+		}
+	}
+	d.mu.Unlock()
+
+	current := make(map[string]bool, len(roots))
+	var refresh []string
+	for _, root := range roots {
+		current[root] = true
+		w, ok := watchers[root]
+		if !ok {
+			var err error
+			if w, err = watchTree(root, changes); err != nil {
+				log.Warnf("polling %s for changes: %v", root, err)
+				d.send(&daemonEvent{Type: "watch_fallback", Root: root, Error: err.Error()})
+			}
+			watchers[root] = w
+		}
+		if w == nil || slices.Contains(unsnapshotted, root) {
+			refresh = append(refresh, root)
+		}
+	}
+	for root, w := range watchers {
+		if !current[root] {
+			if w != nil {
+				w.close()
+			}
+			delete(watchers, root)
+		}
+	}
+	return refresh
+}
+
+// addSchedule runs a full scan of root whenever the schedule is due and
+// delivers the result as a "scan_result" event. Scheduled scans run with
+// background priority so that on-demand scans take precedence.
+func (d *daemon) addSchedule(root string, sched schedule) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for {
+			due := sched.next(time.Now())
+			if due.IsZero() {
+				return
+			}
+			timer := time.NewTimer(time.Until(due))
+			select {
+			case <-d.done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			d.mu.Lock()
+			opts := *d.opts
+			d.mu.Unlock()
+			opts.RootPaths = []string{root}
+			opts.Priority = priorityBackground
+			output, err := scans.wait(scans.submit(&opts))
+			if err != nil {
+				d.send(&daemonEvent{Type: "error", Root: root, Error: err.Error()})
+				continue
+			}
+			d.send(&daemonEvent{Type: "scan_result", Root: root, Result: output})
+		}
+	}()
+}
+
+// reload replaces the daemon's configuration. Scans started afterwards use
+// the new configuration; watched roots are fully re-extracted on the next
+// poll so that plugin changes are reflected in the reported inventory.
+func (d *daemon) reload(opts *scanOptions) error {
+	if err := validateOptions(opts); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.opts = opts
+	d.gen++
+	roots := make(map[string]bool)
+	for _, r := range opts.RootPaths {
+		roots[cleanHostPath(r)] = true
+	}
+	for r, state := range d.roots {
+		if len(roots) > 0 && !roots[r] {
+			delete(d.roots, r)
+			continue
+		}
+		d.roots[r] = &rootState{packages: state.packages}
+	}
+	return nil
+}
+
+// stop ends all background activity of the daemon and waits for it to exit.
+// Scans that are already running are allowed to finish.
+func (d *daemon) stop() {
+	d.stopOnce.Do(func() { close(d.done) })
+	d.wg.Wait()
+}
+
+func (d *daemon) refreshAll() {
+	d.mu.Lock()
+	roots := d.opts.roots()
+	d.mu.Unlock()
+	for _, root := range roots {
+		if err := d.refresh(root); err != nil {
+			d.send(&daemonEvent{Type: "error", Root: root, Error: err.Error()})
+		}
+	}
+}
+
+// refresh re-extracts the files below root that changed since the last
+// refresh and reports the resulting inventory delta.
+func (d *daemon) refresh(root string) error {
+	return d.refreshPaths(root, nil)
+}
+
+// refreshPaths is refresh looking only at the given root-relative paths
+// rather than at the whole tree, or at the whole tree without paths or a
+// previous snapshot.
+func (d *daemon) refreshPaths(root string, paths []string) error {
+	d.mu.Lock()
+	state := d.roots[root]
+	opts := *d.opts
+	gen := d.gen
+	d.mu.Unlock()
+
+	if state == nil {
+		state = &rootState{packages: make(map[string][]*extractor.Package)}
+	}
+	var files map[string]fileSnapshot
+	if paths == nil || state.files == nil {
+		files = snapshotTree(root)
+	} else {
+		files = updateSnapshot(root, state.files, paths)
+	}
+	// Without a previous snapshot, e.g. on the first run or after a reload,
+	// everything is re-extracted and compared to the known inventory.
+	full := state.files == nil
+	changed, removed := diffSnapshots(state.files, files)
+	if !full && len(changed) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	// Re-extract only the changed files, or everything on a full refresh.
+	opts.RootPaths = []string{root}
+	opts.RootRelativePaths = false
+	if !full {
+		opts.PathsToExtract = make([]string, 0, len(changed))
+		for _, f := range changed {
+			opts.PathsToExtract = append(opts.PathsToExtract, filepath.Join(root, filepath.FromSlash(f)))
+		}
+	}
+	var extracted map[string][]*extractor.Package
+	if full || len(changed) > 0 {
+		output, err := scans.wait(scans.submit(&opts))
+		if err != nil {
+			return err
+		}
+		extracted = packagesByFile(root, output.Inventory.Packages)
+	}
+
+	touched := append(changed, removed...)
+	if full {
+		touched = touched[:0]
+		for f := range state.packages {
+			touched = append(touched, f)
+		}
+		for f := range extracted {
+			if _, ok := state.packages[f]; !ok {
+				touched = append(touched, f)
+			}
+		}
+	}
+	ev := &daemonEvent{Type: "inventory_delta", Root: root}
+	for _, f := range touched {
+		before := state.packages[f]
+		after := extracted[f]
+		ev.Added = append(ev.Added, packageDifference(after, before)...)
+		ev.Removed = append(ev.Removed, packageDifference(before, after)...)
+		if len(after) > 0 {
+			state.packages[f] = after
+		} else {
+			delete(state.packages, f)
+		}
+	}
+	state.files = files
+
+	d.mu.Lock()
+	if d.gen != gen {
+		// Reloaded while extracting, start over with the new configuration.
+		state.files = nil
+	}
+	d.roots[root] = state
+	d.mu.Unlock()
+
+	if len(ev.Added) > 0 || len(ev.Removed) > 0 {
+		d.send(ev)
+	}
+	return nil
+}
+
+// snapshotTree records the size and modification time of every regular file
+// below root, keyed by slash-separated path relative to root. Unreadable
+// entries are skipped.
+func snapshotTree(root string) map[string]fileSnapshot {
+	files := make(map[string]fileSnapshot)
+	_ = filepath.WalkDir(root, func(path string, de fs.DirEntry, err error) error {
+		if err != nil || !de.Type().IsRegular() {
+			return nil
+		}
+		info, err := de.Info()
+		if err != nil {
+			return nil
+		}
+		files[normalizeLocation(root, path)] = fileSnapshot{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files
+}
+
+// updateSnapshot returns a copy of the snapshot of root with the given
+// root-relative paths, and the files below them, recorded again.
+func updateSnapshot(root string, files map[string]fileSnapshot, paths []string) map[string]fileSnapshot {
+	changed := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		changed[p] = true
+	}
+	updated := make(map[string]fileSnapshot, len(files))
+	for f, s := range files {
+		if !belowAny(f, changed) {
+			updated[f] = s
+		}
+	}
+	for p := range changed {
+		for f, s := range snapshotTree(filepath.Join(root, filepath.FromSlash(p))) {
+			if f == "" {
+				// p itself is a file
+				updated[p] = s
+			} else {
+				updated[p+"/"+f] = s
+			}
+		}
+	}
+	return updated
+}
+
+// belowAny reports whether the slash-separated path f or one of its parent
+// directories is in dirs.
+func belowAny(f string, dirs map[string]bool) bool {
+	for ; f != "." && f != "/"; f = path.Dir(f) {
+		if dirs[f] {
+			return true
+		}
+	}
+	return false
+}
+
+// diffSnapshots returns the files that were added or modified and the files
+// that were removed between two snapshots.
+func diffSnapshots(before, after map[string]fileSnapshot) (changed, removed []string) {
+	for f, s := range after {
+		if prev, ok := before[f]; !ok || prev.size != s.size || !prev.modTime.Equal(s.modTime) {
+			changed = append(changed, f)
+		}
+	}
+	for f := range before {
+		if _, ok := after[f]; !ok {
+			removed = append(removed, f)
+		}
+	}
+	return changed, removed
+}
+
+// packagesByFile groups packages by the root-relative file they were found
+// in. Locations inside archives ("app.jar:inner/pom.properties") are
+// attributed to the outer file.
+func packagesByFile(root string, pkgs []*extractor.Package) map[string][]*extractor.Package {
+	byFile := make(map[string][]*extractor.Package)
+	for _, p := range pkgs {
+		file := ""
+		if len(p.Locations) > 0 {
+			file, _, _ = strings.Cut(normalizeLocation(root, p.Locations[0]), ":")
+		}
+		byFile[file] = append(byFile[file], p)
+	}
+	return byFile
+}
+
+// packageDifference returns the packages in a that have no equivalent in b.
+func packageDifference(a, b []*extractor.Package) []*extractor.Package {
+	keys := make(map[string]bool, len(b))
+	for _, p := range b {
+		keys[packageKey(p)] = true
+	}
+	var diff []*extractor.Package
+	for _, p := range a {
+		if !keys[packageKey(p)] {
+			diff = append(diff, p)
+		}
+	}
+	return diff
+}
+
+// packageKey identifies a package by its type, name, version and locations.
+func packageKey(p *extractor.Package) string {
+	return strings.Join(append([]string{p.PURLType, p.Name, p.Version}, p.Locations...), "\x00")
+}
@@ -0,0 +1,110 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/google/osv-scalibr/detector"
+	dl "github.com/google/osv-scalibr/detector/list"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// resolveDetectors returns the detectors selected by names, which may be
+// SCALIBR detector names or detector collections such as "cis" and
+// "weakcredentials". Unlike in plugins, "all" and "default" only select
+// detectors.
+func resolveDetectors(names []string) ([]plugin.Plugin, error) {
+	var plugins []plugin.Plugin
+	for _, name := range names {
+		detectors, err := dl.DetectorsFromName(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range detectors {
+			plugins = append(plugins, d)
+		}
+	}
+	return plugins, nil
+}
+
+// mergePlugins appends the plugins of more not in plugins yet by name.
+func mergePlugins(plugins, more []plugin.Plugin) []plugin.Plugin {
+	for _, p := range more {
+		if !slices.ContainsFunc(plugins, func(q plugin.Plugin) bool { return q.Name() == p.Name() }) {
+			plugins = append(plugins, p)
+		}
+	}
+	return plugins
+}
+
+// detectorReport describes how a selected detector took part in the scan.
+type detectorReport struct {
+	Detector string
+	Version  int
+	// The extractors SCALIBR enables for the detector
+	RequiredExtractors []string `json:",omitempty"`
+	// Whether the scan's capabilities allowed running the detector, and why
+	// not otherwise, e.g. for the running system's detectors in image scans
+	Ran    bool
+	Reason string `json:",omitempty"`
+	// Package vulnerabilities and generic findings the detector reported
+	Findings int
+}
+
+// reportDetectors returns a report for each detector among plugins, the
+// plugins selected before filtering them by capab, sorted by name.
+func reportDetectors(plugins []plugin.Plugin, capab *plugin.Capabilities) []detectorReport {
+	var reports []detectorReport
+	for _, p := range plugins {
+		d, ok := p.(detector.Detector)
+		if !ok {
+			continue
+		}
+		r := detectorReport{
+			Detector:           d.Name(),
+			Version:            d.Version(),
+			RequiredExtractors: d.RequiredExtractors(),
+			Ran:                true,
+		}
+		if err := plugin.ValidateRequirements(d, capab); err != nil {
+			r.Ran, r.Reason = false, err.Error()
+		}
+		reports = append(reports, r)
+	}
+	slices.SortFunc(reports, func(a, b detectorReport) int { return cmp.Compare(a.Detector, b.Detector) })
+	return reports
+}
+
+// countDetectorFindings sets the number of findings of inv reported by each
+// detector of reports.
+func countDetectorFindings(reports []detectorReport, inv *inventory.Inventory) {
+	counts := map[string]int{}
+	for _, v := range inv.PackageVulns {
+		for _, name := range v.Plugins {
+			counts[name]++
+		}
+	}
+	for _, f := range inv.GenericFindings {
+		for _, name := range f.Plugins {
+			counts[name]++
+		}
+	}
+	for i := range reports {
+		reports[i].Findings = counts[reports[i].Detector]
+	}
+}
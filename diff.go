@@ -0,0 +1,255 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// resultDiff is the document returned by ScalibrDiffResults.
+type resultDiff struct {
+	Packages        packageDiff
+	PackageVulns    entryDiff
+	GenericFindings entryDiff
+}
+
+// packageDiff lists the packages only found by one of the scans, and those
+// found at the same locations by both but with a different version.
+type packageDiff struct {
+	Added   []json.RawMessage `json:",omitempty"`
+	Removed []json.RawMessage `json:",omitempty"`
+	Changed []packageChange   `json:",omitempty"`
+}
+
+// packageChange describes a package whose version changed.
+type packageChange struct {
+	Name       string
+	PURLType   string
+	Locations  []string
+	OldVersion string
+	NewVersion string
+}
+
+// entryDiff lists the findings only reported by one of the scans.
+type entryDiff struct {
+	Added   []json.RawMessage `json:",omitempty"`
+	Removed []json.RawMessage `json:",omitempty"`
+}
+
+// diffEntry is an inventory entry with the key identifying it across scans.
+type diffEntry struct {
+	key  string
+	data json.RawMessage
+}
+
+// diffResults compares two JSON scan results. Packages are matched by type,
+// name and locations, package vulnerabilities by advisory ID and package,
+// and generic findings by advisory and target.
+func diffResults(oldJSON, newJSON string) ([]byte, error) {
+	var before, after mergeInput
+	if err := json.Unmarshal([]byte(oldJSON), &before); err != nil {
+		return nil, fmt.Errorf("old result: failed to parse result: %w", err)
+	}
+	if err := json.Unmarshal([]byte(newJSON), &after); err != nil {
+		return nil, fmt.Errorf("new result: failed to parse result: %w", err)
+	}
+
+	d := &resultDiff{}
+	oldPkgs, err := packageEntries(before.Inventory["Packages"])
+	if err != nil {
+		return nil, fmt.Errorf("old result: %w", err)
+	}
+	newPkgs, err := packageEntries(after.Inventory["Packages"])
+	if err != nil {
+		return nil, fmt.Errorf("new result: %w", err)
+	}
+	d.Packages = diffPackages(oldPkgs, newPkgs)
+
+	for _, s := range []struct {
+		section string
+		key     func(json.RawMessage) string
+		diff    *entryDiff
+	}{
+		{"PackageVulns", packageVulnKey, &d.PackageVulns},
+		{"GenericFindings", genericFindingKey, &d.GenericFindings},
+	} {
+		oldEntries := keyedEntries(before.Inventory[s.section], s.key)
+		newEntries := keyedEntries(after.Inventory[s.section], s.key)
+		s.diff.Added = entriesMissing(newEntries, oldEntries)
+		s.diff.Removed = entriesMissing(oldEntries, newEntries)
+	}
+	return json.Marshal(d)
+}
+
+// identifiedPackage is a package of a JSON result with the fields matched
+// across scans.
+type identifiedPackage struct {
+	storedPackage
+	data json.RawMessage
+}
+
+// packageEntries decodes the Packages section of a JSON result.
+func packageEntries(section json.RawMessage) ([]*identifiedPackage, error) {
+	var entries []json.RawMessage
+	if len(section) > 0 {
+		if err := json.Unmarshal(section, &entries); err != nil {
+			return nil, fmt.Errorf("invalid packages: %w", err)
+		}
+	}
+	pkgs := make([]*identifiedPackage, 0, len(entries))
+	for _, e := range entries {
+		p := &identifiedPackage{data: e}
+		if err := json.Unmarshal(e, &p.storedPackage); err != nil {
+			return nil, fmt.Errorf("invalid package: %w", err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+// diffPackages matches the packages of both scans by type, name and
+// locations. A package found once at the same locations by each scan but
+// with another version is reported as changed.
+func diffPackages(oldPkgs, newPkgs []*identifiedPackage) packageDiff {
+	locationKey := func(p *identifiedPackage) string {
+		locs := slices.Sorted(slices.Values(p.Locations))
+		return strings.Join(append([]string{p.PURLType, p.Name}, locs...), "\x00")
+	}
+	group := func(pkgs []*identifiedPackage) (map[string][]*identifiedPackage, []string) {
+		groups := make(map[string][]*identifiedPackage)
+		var keys []string
+		for _, p := range pkgs {
+			k := locationKey(p)
+			if _, ok := groups[k]; !ok {
+				keys = append(keys, k)
+			}
+			groups[k] = append(groups[k], p)
+		}
+		return groups, keys
+	}
+	oldGroups, oldKeys := group(oldPkgs)
+	newGroups, newKeys := group(newPkgs)
+
+	var d packageDiff
+	for _, k := range newKeys {
+		added := versionsMissing(newGroups[k], oldGroups[k])
+		removed := versionsMissing(oldGroups[k], newGroups[k])
+		if len(added) == 1 && len(removed) == 1 {
+			d.Changed = append(d.Changed, packageChange{
+				Name:       added[0].Name,
+				PURLType:   added[0].PURLType,
+				Locations:  added[0].Locations,
+				OldVersion: removed[0].Version,
+				NewVersion: added[0].Version,
+			})
+			continue
+		}
+		for _, p := range added {
+			d.Added = append(d.Added, p.data)
+		}
+		for _, p := range removed {
+			d.Removed = append(d.Removed, p.data)
+		}
+	}
+	for _, k := range oldKeys {
+		if _, ok := newGroups[k]; !ok {
+			for _, p := range oldGroups[k] {
+				d.Removed = append(d.Removed, p.data)
+			}
+		}
+	}
+	return d
+}
+
+// versionsMissing returns the packages of a whose version none of b has.
+func versionsMissing(a, b []*identifiedPackage) []*identifiedPackage {
+	var missing []*identifiedPackage
+	for _, p := range a {
+		if !slices.ContainsFunc(b, func(q *identifiedPackage) bool { return q.Version == p.Version }) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// keyedEntries decodes an inventory section of a JSON result and keys its
+// entries. Sections that aren't lists yield no entries.
+func keyedEntries(section json.RawMessage, key func(json.RawMessage) string) []diffEntry {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(section, &entries); err != nil {
+		return nil
+	}
+	keyed := make([]diffEntry, 0, len(entries))
+	for _, e := range entries {
+		keyed = append(keyed, diffEntry{key: key(e), data: e})
+	}
+	return keyed
+}
+
+// entriesMissing returns the entries of a whose key none of b has.
+func entriesMissing(a, b []diffEntry) []json.RawMessage {
+	keys := make(map[string]bool, len(b))
+	for _, e := range b {
+		keys[e.key] = true
+	}
+	var missing []json.RawMessage
+	for _, e := range a {
+		if !keys[e.key] {
+			missing = append(missing, e.data)
+		}
+	}
+	return missing
+}
+
+// packageVulnKey identifies a JSON package vulnerability by its advisory ID
+// and the type, name and version of the package. The record is embedded
+// differently across SCALIBR versions, see parseOSVRecord.
+func packageVulnKey(data json.RawMessage) string {
+	var v struct {
+		ID            string
+		Vulnerability *struct{ ID string }
+		Package       *storedPackage
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return compactJSON(data)
+	}
+	id := v.ID
+	if id == "" && v.Vulnerability != nil {
+		id = v.Vulnerability.ID
+	}
+	key := []string{id}
+	if v.Package != nil {
+		key = append(key, v.Package.PURLType, v.Package.Name, v.Package.Version)
+	}
+	return strings.Join(key, "\x00")
+}
+
+// genericFindingKey identifies a JSON generic finding by its advisory and
+// target.
+func genericFindingKey(data json.RawMessage) string {
+	var f struct {
+		Adv struct {
+			ID json.RawMessage
+		}
+		Target json.RawMessage
+	}
+	if err := json.Unmarshal(data, &f); err != nil || len(f.Adv.ID) == 0 {
+		return compactJSON(data)
+	}
+	return compactJSON(f.Adv.ID) + "\x00" + compactJSON(f.Target)
+}
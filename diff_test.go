@@ -0,0 +1,137 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// diffResult wraps inventory sections into a JSON scan result.
+func diffResult(inventory string) string {
+	return `{"Version": "1", "Inventory": ` + inventory + `}`
+}
+
+func TestDiffResults(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		// Names and versions of the added and removed packages
+		wantAdded, wantRemoved []string
+		wantChanged            []packageChange
+		wantVulns              [2]int
+		wantFindings           [2]int
+	}{
+		{
+			name: "unchanged",
+			old:  diffResult(`{"Packages": [{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": ["p"]}]}`),
+			new:  diffResult(`{"Packages": [{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": ["p"]}]}`),
+		},
+		{
+			name:        "added and removed",
+			old:         diffResult(`{"Packages": [{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": ["p"]}]}`),
+			new:         diffResult(`{"Packages": [{"Name": "b", "Version": "1", "PURLType": "npm", "Locations": ["p"]}]}`),
+			wantAdded:   []string{"b@1"},
+			wantRemoved: []string{"a@1"},
+		},
+		{
+			name: "version changed",
+			old:  diffResult(`{"Packages": [{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": ["p", "q"]}]}`),
+			new:  diffResult(`{"Packages": [{"Name": "a", "Version": "2", "PURLType": "npm", "Locations": ["q", "p"]}]}`),
+			wantChanged: []packageChange{
+				{Name: "a", PURLType: "npm", Locations: []string{"q", "p"}, OldVersion: "1", NewVersion: "2"},
+			},
+		},
+		{
+			name:        "moved package",
+			old:         diffResult(`{"Packages": [{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": ["p"]}]}`),
+			new:         diffResult(`{"Packages": [{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": ["q"]}]}`),
+			wantAdded:   []string{"a@1"},
+			wantRemoved: []string{"a@1"},
+		},
+		{
+			name:        "several versions at one location",
+			old:         diffResult(`{"Packages": [{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": ["p"]}]}`),
+			new:         diffResult(`{"Packages": [{"Name": "a", "Version": "2", "PURLType": "npm", "Locations": ["p"]}, {"Name": "a", "Version": "3", "PURLType": "npm", "Locations": ["p"]}]}`),
+			wantAdded:   []string{"a@2", "a@3"},
+			wantRemoved: []string{"a@1"},
+		},
+		{
+			name: "findings",
+			old: diffResult(`{"PackageVulns": [{"Vulnerability": {"ID": "GHSA-1"}, "Package": {"Name": "a", "Version": "1"}}],
+				"GenericFindings": [{"Adv": {"ID": {"Reference": "CVE-1"}}, "Target": {"Extra": "x"}}]}`),
+			new: diffResult(`{"PackageVulns": [{"ID": "GHSA-1", "Package": {"Name": "a", "Version": "1"}}, {"ID": "GHSA-2", "Package": {"Name": "a", "Version": "1"}}],
+				"GenericFindings": [{"Adv": {"ID": {"Reference": "CVE-2"}}, "Target": {"Extra": "x"}}]}`),
+			wantVulns:    [2]int{1, 0},
+			wantFindings: [2]int{1, 1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := diffResults(tc.old, tc.new)
+			if err != nil {
+				t.Fatalf("diffResults() = %v", err)
+			}
+			var got resultDiff
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("failed to parse diff: %v", err)
+			}
+			if added := packageIDs(t, got.Packages.Added); !reflect.DeepEqual(added, tc.wantAdded) {
+				t.Errorf("Added = %v, want %v", added, tc.wantAdded)
+			}
+			if removed := packageIDs(t, got.Packages.Removed); !reflect.DeepEqual(removed, tc.wantRemoved) {
+				t.Errorf("Removed = %v, want %v", removed, tc.wantRemoved)
+			}
+			if !reflect.DeepEqual(got.Packages.Changed, tc.wantChanged) {
+				t.Errorf("Changed = %+v, want %+v", got.Packages.Changed, tc.wantChanged)
+			}
+			if v := [2]int{len(got.PackageVulns.Added), len(got.PackageVulns.Removed)}; v != tc.wantVulns {
+				t.Errorf("PackageVulns added, removed = %v, want %v", v, tc.wantVulns)
+			}
+			if f := [2]int{len(got.GenericFindings.Added), len(got.GenericFindings.Removed)}; f != tc.wantFindings {
+				t.Errorf("GenericFindings added, removed = %v, want %v", f, tc.wantFindings)
+			}
+		})
+	}
+}
+
+func TestDiffResultsErrors(t *testing.T) {
+	valid := diffResult(`{}`)
+	for _, results := range [][2]string{
+		{"{", valid},
+		{valid, "[]"},
+		{diffResult(`{"Packages": {}}`), valid},
+		{valid, diffResult(`{"Packages": [1]}`)},
+	} {
+		if _, err := diffResults(results[0], results[1]); err == nil {
+			t.Errorf("diffResults(%s, %s) succeeded, want error", results[0], results[1])
+		}
+	}
+}
+
+// packageIDs returns the name@version of the JSON packages.
+func packageIDs(t *testing.T, pkgs []json.RawMessage) []string {
+	t.Helper()
+	var ids []string
+	for _, data := range pkgs {
+		var p storedPackage
+		if err := json.Unmarshal(data, &p); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, p.Name+"@"+p.Version)
+	}
+	return ids
+}
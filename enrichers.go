@@ -0,0 +1,107 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	cpb "github.com/google/osv-scalibr/binary/proto/config_go_proto"
+	"github.com/google/osv-scalibr/enricher"
+	"github.com/google/osv-scalibr/plugin"
+	pl "github.com/google/osv-scalibr/plugin/list"
+)
+
+// enricherOptions is the enrichers section of the configuration. When it is
+// set, it alone selects the enrichers: those selected through plugins, e.g.
+// by a group, are dropped.
+type enricherOptions struct {
+	// Enrichers or enricher groups, e.g. "vulnmatch/osvdev". Empty to run
+	// no enricher.
+	Names []string `json:"names" yaml:"names" toml:"names"`
+	// Settings of the individual enrichers.
+	Settings enricherSettings `json:"settings" yaml:"settings" toml:"settings"`
+}
+
+// enricherSettings holds the settings of each configurable enricher, keyed
+// by its name.
+type enricherSettings struct {
+	OSVDev *osvDevSettings `json:"vulnmatch/osvdev" yaml:"vulnmatch/osvdev" toml:"vulnmatch/osvdev"`
+}
+
+// osvDevSettings configures vulnmatch/osvdev. They take precedence over
+// osv_base_url and osv_batch_size.
+type osvDevSettings struct {
+	BaseURL   string `json:"base_url" yaml:"base_url" toml:"base_url"`
+	BatchSize int    `json:"batch_size" yaml:"batch_size" toml:"batch_size"`
+}
+
+// setEnrichersJSON sets the enrichers section from its JSON text. Parse
+// errors are reported when the options are validated.
+func (o *scanOptions) setEnrichersJSON(s string) {
+	if s == "" {
+		return
+	}
+	o.Enrichers = &enricherOptions{}
+	if err := json.Unmarshal([]byte(s), o.Enrichers); err != nil {
+		o.enrichersErr = fmt.Errorf("invalid enrichers JSON: %w", err)
+	}
+}
+
+// vulnFeedURL returns the base URL of the feed queried by vulnmatch/osvdev,
+// or "" for OSV.dev.
+func (o *scanOptions) vulnFeedURL() string {
+	if o.Enrichers != nil && o.Enrichers.Settings.OSVDev != nil && o.Enrichers.Settings.OSVDev.BaseURL != "" {
+		return o.Enrichers.Settings.OSVDev.BaseURL
+	}
+	return o.OSVBaseURL
+}
+
+// osvQueryBatchSize returns the maximum queries per OSV batch query, or 0
+// for OSV.dev's limit.
+func (o *scanOptions) osvQueryBatchSize() int {
+	if o.Enrichers != nil && o.Enrichers.Settings.OSVDev != nil && o.Enrichers.Settings.OSVDev.BatchSize != 0 {
+		return o.Enrichers.Settings.OSVDev.BatchSize
+	}
+	return o.OSVBatchSize
+}
+
+// resolveEnrichers returns the enrichers selected by names, constructed with
+// cfg, which may be nil. Names selecting other kinds of plugins are
+// rejected.
+func resolveEnrichers(names []string, cfg *cpb.PluginConfig) ([]plugin.Plugin, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	plugins, err := pl.FromNames(names, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plugins {
+		if _, ok := p.(enricher.Enricher); !ok {
+			return nil, fmt.Errorf("%s is not an enricher", p.Name())
+		}
+	}
+	return plugins, nil
+}
+
+// withoutEnrichers returns plugins without the enrichers.
+func withoutEnrichers(plugins []plugin.Plugin) []plugin.Plugin {
+	return slices.DeleteFunc(plugins, func(p plugin.Plugin) bool {
+		_, ok := p.(enricher.Enricher)
+		return ok
+	})
+}
@@ -0,0 +1,191 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/osv-scalibr/log"
+)
+
+// Environment variables that override the library defaults. They are read
+// once, before the first scan.
+const (
+	envLogLevel = "SCALIBR_LOG_LEVEL"
+	envTempDir  = "SCALIBR_TEMP_DIR"
+	envProxy    = "SCALIBR_PROXY"
+	envCacheDir = "SCALIBR_CACHE_DIR"
+	envNetRate  = "SCALIBR_NETWORK_RPS"
+	envNetConns = "SCALIBR_NETWORK_CONCURRENCY"
+	envWorkers  = "SCALIBR_MAX_WORKERS"
+	envMaxProcs = "SCALIBR_MAX_PROCS"
+	// Read on first use rather than before the first scan, see allocTracker
+	envDebugAlloc = "SCALIBR_DEBUG_ALLOC"
+)
+
+var applyEnvOnce sync.Once
+
+// tempDirOverride is SCALIBR_TEMP_DIR. It's kept here rather than exported
+// to TMPDIR, which would change the temp dir of the whole host process.
+var tempDirOverride string
+
+// applyEnv applies the SCALIBR_* environment overrides. Invalid values are
+// logged and ignored.
+func applyEnv() {
+	applyEnvOnce.Do(func() {
+		if v := os.Getenv(envLogLevel); v != "" {
+			level, ok := parseLogLevel(v)
+			if !ok {
+				log.Warnf("ignoring invalid %s %q", envLogLevel, v)
+			} else {
+				logLevel = level
+			}
+		}
+		// SCALIBR's logger isn't safe to replace while scans log, so it's set
+		// once and setLogSink only swaps the sink behind it
+		log.SetLogger(&levelLogger{level: logLevel})
+		if v := os.Getenv(envTempDir); v != "" {
+			tempDirOverride = v
+		}
+		if v := os.Getenv(envProxy); v != "" {
+			u, err := url.Parse(v)
+			if err != nil || u.Host == "" {
+				log.Warnf("ignoring invalid %s %q", envProxy, v)
+			} else if t, ok := http.DefaultTransport.(*http.Transport); ok {
+				t.Proxy = http.ProxyURL(u)
+			}
+		}
+		// Applied after the proxy, which configures the wrapped transport
+		var rps float64
+		var conns int
+		if v := os.Getenv(envNetRate); v != "" {
+			if f, err := strconv.ParseFloat(v, 64); err != nil || f < 0 {
+				log.Warnf("ignoring invalid %s %q", envNetRate, v)
+			} else {
+				rps = f
+			}
+		}
+		if v := os.Getenv(envNetConns); v != "" {
+			if n, err := strconv.Atoi(v); err != nil || n < 0 {
+				log.Warnf("ignoring invalid %s %q", envNetConns, v)
+			} else {
+				conns = n
+			}
+		}
+		if rps > 0 || conns > 0 {
+			storeNetworkLimits(newNetworkLimits(rps, conns))
+		}
+		var maxWorkers, maxProcs int
+		if v := os.Getenv(envWorkers); v != "" {
+			if n, err := strconv.Atoi(v); err != nil || n < 0 {
+				log.Warnf("ignoring invalid %s %q", envWorkers, v)
+			} else {
+				maxWorkers = n
+			}
+		}
+		if v := os.Getenv(envMaxProcs); v != "" {
+			if n, err := strconv.Atoi(v); err != nil || n < 0 {
+				log.Warnf("ignoring invalid %s %q", envMaxProcs, v)
+			} else {
+				maxProcs = n
+			}
+		}
+		if maxWorkers > 0 || maxProcs > 0 {
+			storeCPULimits(maxWorkers, maxProcs)
+		}
+	})
+}
+
+// tempDir returns the directory below which the bindings create their
+// scratch files: SCALIBR_TEMP_DIR if set, otherwise os.TempDir.
+func tempDir() string {
+	if tempDirOverride != "" {
+		return tempDirOverride
+	}
+	return os.TempDir()
+}
+
+// cacheDir returns the directory for data the bindings keep across scans:
+// SCALIBR_CACHE_DIR if set, otherwise "scalibr" in the user's cache dir.
+func cacheDir() (string, error) {
+	if v := os.Getenv(envCacheDir); v != "" {
+		return v, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "scalibr"), nil
+}
+
+// Log levels, from most to least verbose, mirrored by ScalibrLogLevel in the
+// C header.
+const (
+	logLevelDebug = iota
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+	logLevelOff
+)
+
+func parseLogLevel(s string) (int, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return logLevelDebug, true
+	case "info":
+		return logLevelInfo, true
+	case "warn", "warning":
+		return logLevelWarn, true
+	case "error":
+		return logLevelError, true
+	case "off", "none":
+		return logLevelOff, true
+	}
+	return 0, false
+}
+
+// levelLogger is a log.Logger writing to the log sink, or stderr, that drops
+// messages below its level.
+type levelLogger struct {
+	level int
+}
+
+func (l *levelLogger) logf(level int, format string, args ...any) {
+	if level >= l.level {
+		writeLog(level, fmt.Sprintf(format, args...))
+	}
+}
+
+func (l *levelLogger) log(level int, args ...any) {
+	if level >= l.level {
+		writeLog(level, fmt.Sprintln(args...))
+	}
+}
+
+func (l *levelLogger) Errorf(format string, args ...any) { l.logf(logLevelError, format, args...) }
+func (l *levelLogger) Error(args ...any)                 { l.log(logLevelError, args...) }
+func (l *levelLogger) Warnf(format string, args ...any)  { l.logf(logLevelWarn, format, args...) }
+func (l *levelLogger) Warn(args ...any)                  { l.log(logLevelWarn, args...) }
+func (l *levelLogger) Infof(format string, args ...any)  { l.logf(logLevelInfo, format, args...) }
+func (l *levelLogger) Info(args ...any)                  { l.log(logLevelInfo, args...) }
+func (l *levelLogger) Debugf(format string, args ...any) { l.logf(logLevelDebug, format, args...) }
+func (l *levelLogger) Debug(args ...any)                 { l.log(logLevelDebug, args...) }
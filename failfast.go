@@ -0,0 +1,172 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/google/osv-scalibr/detector"
+	"github.com/google/osv-scalibr/enricher"
+	scalibrfs "github.com/google/osv-scalibr/fs"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/packageindex"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// errStoppedOnFinding is the cancellation cause of a scan aborted by the
+// stop_on_first_finding option.
+var errStoppedOnFinding = errors.New("scan stopped on first matching finding")
+
+// severityNames maps the stop_min_severity values to finding severities.
+var severityNames = map[string]inventory.SeverityEnum{
+	"minimal":  inventory.SeverityMinimal,
+	"low":      inventory.SeverityLow,
+	"medium":   inventory.SeverityMedium,
+	"high":     inventory.SeverityHigh,
+	"critical": inventory.SeverityCritical,
+}
+
+// stopInfo describes the finding that stopped a fail-fast scan.
+type stopInfo struct {
+	Plugin    string
+	FindingID string `json:",omitempty"`
+	Severity  string `json:",omitempty"`
+}
+
+// failFast watches detector and enricher output and cancels the scan on the
+// first finding that passes its severity and plugin filters.
+type failFast struct {
+	minSeverity inventory.SeverityEnum
+	plugins     map[string]bool
+	cancel      context.CancelCauseFunc
+
+	mu  sync.Mutex
+	hit *stopInfo
+}
+
+func newFailFast(opts *scanOptions, cancel context.CancelCauseFunc) *failFast {
+	f := &failFast{
+		minSeverity: severityNames[strings.ToLower(opts.StopMinSeverity)],
+		cancel:      cancel,
+	}
+	if len(opts.StopPlugins) > 0 {
+		f.plugins = make(map[string]bool, len(opts.StopPlugins))
+		for _, name := range opts.StopPlugins {
+			f.plugins[name] = true
+		}
+	}
+	return f
+}
+
+// wrap returns plugins with every detector and enricher instrumented to
+// report its findings to f.
+func (f *failFast) wrap(plugins []plugin.Plugin) []plugin.Plugin {
+	wrapped := make([]plugin.Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		switch p := p.(type) {
+		case detector.Detector:
+			wrapped = append(wrapped, &failFastDetector{Detector: p, ff: f})
+		case enricher.Enricher:
+			wrapped = append(wrapped, &failFastEnricher{Enricher: p, ff: f})
+		default:
+			wrapped = append(wrapped, p)
+		}
+	}
+	return wrapped
+}
+
+// stopped returns the finding that stopped the scan, or nil.
+func (f *failFast) stopped() *stopInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hit
+}
+
+// check stops the scan if one of the given findings reported by the named
+// plugin matches.
+func (f *failFast) check(name string, vulns []*inventory.PackageVuln, findings []*inventory.GenericFinding) {
+	if f.plugins != nil && !f.plugins[name] {
+		return
+	}
+	var hit *stopInfo
+	for _, gf := range findings {
+		if gf.Adv == nil || gf.Adv.Sev < f.minSeverity {
+			continue
+		}
+		hit = &stopInfo{Plugin: name, Severity: severityName(gf.Adv.Sev)}
+		if gf.Adv.ID != nil {
+			hit.FindingID = gf.Adv.ID.Reference
+		}
+		break
+	}
+	// Package vulnerabilities carry no normalized severity, so they only
+	// match when no minimum severity is requested.
+	if hit == nil && len(vulns) > 0 && f.minSeverity == inventory.SeverityUnspecified {
+		hit = &stopInfo{Plugin: name, FindingID: vulnID(vulns[0])}
+	}
+	if hit == nil {
+		return
+	}
+
+	f.mu.Lock()
+	if f.hit == nil {
+		f.hit = hit
+	}
+	f.mu.Unlock()
+	f.cancel(errStoppedOnFinding)
+}
+
+func severityName(sev inventory.SeverityEnum) string {
+	for name, s := range severityNames {
+		if s == sev {
+			return name
+		}
+	}
+	return ""
+}
+
+type failFastDetector struct {
+	detector.Detector
+	ff *failFast
+}
+
+func (d *failFastDetector) Scan(ctx context.Context, root *scalibrfs.ScanRoot, px *packageindex.PackageIndex) (inventory.Finding, error) {
+	finding, err := d.Detector.Scan(ctx, root, px)
+	d.ff.check(d.Name(), finding.PackageVulns, finding.GenericFindings)
+	return finding, err
+}
+
+type failFastEnricher struct {
+	enricher.Enricher
+	ff *failFast
+}
+
+func (e *failFastEnricher) Enrich(ctx context.Context, input *enricher.ScanInput, inv *inventory.Inventory) error {
+	vulns, findings := len(inv.PackageVulns), len(inv.GenericFindings)
+	err := e.Enricher.Enrich(ctx, input, inv)
+	e.ff.check(e.Name(), tail(inv.PackageVulns, vulns), tail(inv.GenericFindings, findings))
+	return err
+}
+
+// tail returns the elements an enricher appended past the first n.
+func tail[T any](s []T, n int) []T {
+	if len(s) <= n {
+		return nil
+	}
+	return s[n:]
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/osv-scalibr/enricher/vulnmatch/osvdev"
+	"github.com/google/osv-scalibr/plugin"
+	scalibrversion "github.com/google/osv-scalibr/version"
+	osvclient "osv.dev/bindings/go/osvdev"
+)
+
+// osvInitialQueryTimeout matches the timeout of SCALIBR's default OSV
+// enricher.
+const osvInitialQueryTimeout = 5 * time.Minute
+
+// feedAuthHeaderSize is the size of the buffer the host's feed auth callback
+// writes the header into.
+const feedAuthHeaderSize = 8192
+
+// feedAuthorizer returns the header to add to a request for url, as a
+// "Name: value" line, or "" to send it without one.
+type feedAuthorizer func(url string) (string, error)
+
+var feedAuthHook struct {
+	mu         sync.RWMutex
+	authorizer feedAuthorizer
+}
+
+// setFeedAuthorizer installs the authorizer consulted for requests to custom
+// vulnerability feeds, or removes it if a is nil.
+func setFeedAuthorizer(a feedAuthorizer) {
+	feedAuthHook.mu.Lock()
+	defer feedAuthHook.mu.Unlock()
+	feedAuthHook.authorizer = a
+}
+
+// validateFeedURL checks the base URL of a custom vulnerability feed.
+func validateFeedURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return errors.New("want an absolute http or https URL")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("must not have a query or fragment")
+	}
+	return nil
+}
+
+// applyVulnFeed points the OSV enricher at the feed served under baseURL, an
+// OSV.dev mirror or an internal advisory service implementing the OSV API.
+func applyVulnFeed(plugins []plugin.Plugin, baseURL string) []plugin.Plugin {
+	if baseURL == "" {
+		return plugins
+	}
+	i := slices.IndexFunc(plugins, func(p plugin.Plugin) bool { return p.Name() == osvdev.Name })
+	if i < 0 {
+		return plugins
+	}
+	client := osvclient.DefaultClient()
+	client.BaseHostURL = strings.TrimSuffix(baseURL, "/")
+	client.Config.UserAgent = "osv-scanner_scan/" + scalibrversion.ScannerVersion
+	plugins = slices.Clone(plugins)
+	plugins[i] = osvdev.NewWithClient(client, osvInitialQueryTimeout)
+	return plugins
+}
+
+type vulnFeedKey struct{}
+
+// withVulnFeed makes the requests issued under ctx to baseURL go through the
+// feed authorizer.
+func withVulnFeed(ctx context.Context, baseURL string) context.Context {
+	return context.WithValue(ctx, vulnFeedKey{}, strings.TrimSuffix(baseURL, "/"))
+}
+
+// authorizeFeedRequest returns req with the header asked for by the feed
+// authorizer if it is a request to the custom feed of its scan. Other
+// requests, including those to the public OSV.dev, are returned unchanged so
+// that credentials don't leak.
+func authorizeFeedRequest(req *http.Request) (*http.Request, error) {
+	base, _ := req.Context().Value(vulnFeedKey{}).(string)
+	if base == "" {
+		return req, nil
+	}
+	u := req.URL.String()
+	if u != base && !strings.HasPrefix(u, base+"/") {
+		return req, nil
+	}
+	feedAuthHook.mu.RLock()
+	authorize := feedAuthHook.authorizer
+	feedAuthHook.mu.RUnlock()
+	if authorize == nil {
+		return req, nil
+	}
+	header, err := authorize(u)
+	if err != nil || header == "" {
+		return req, err
+	}
+	name, value, ok := strings.Cut(header, ":")
+	if name = strings.TrimSpace(name); !ok || name == "" {
+		// The header isn't quoted since it likely carries a credential
+		return nil, errors.New(`invalid feed auth header, want "Name: value"`)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(name, strings.TrimSpace(value))
+	return req, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io/fs"
+	"time"
+
+	"github.com/google/osv-scalibr/inventory"
+)
+
+// fileMetadata describes a file that produced inventory, for the
+// include_file_metadata option.
+type fileMetadata struct {
+	Location string
+	Size     int64
+	ModTime  time.Time
+	// Type and permission bits, e.g. "-rw-r--r--"
+	Mode string
+}
+
+// statArtifacts returns the metadata of the files at the locations of the
+// packages and secrets of inv, read from fsys, the filesystem of root.
+// Locations that can't be found, e.g. entries of archives, are left out.
+func statArtifacts(ctx context.Context, fsys fs.FS, inv *inventory.Inventory, root string) []fileMetadata {
+	var files []fileMetadata
+	for _, loc := range inventoryLocations(inv) {
+		if ctx.Err() != nil {
+			break
+		}
+		name, ok := locationPath(root, loc)
+		if !ok {
+			continue
+		}
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			continue
+		}
+		files = append(files, fileMetadata{
+			Location: loc,
+			Size:     info.Size(),
+			ModTime:  info.ModTime().UTC(),
+			Mode:     info.Mode().String(),
+		})
+	}
+	return files
+}
+
+// includeFileMetadata reports whether the result describes the files that
+// produced inventory, as it always does in incremental scans so that it can
+// be the previous result of the next one.
+func (o *scanOptions) includeFileMetadata() bool {
+	return o.IncludeFileMetadata || o.PreviousResult != ""
+}
@@ -0,0 +1,39 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "path/filepath"
+
+// fileRoots groups the files of a file list scan by the root of their
+// volume, "/" outside of Windows, and returns the roots in order of first
+// use along with the absolute paths of their files. The files are then
+// extracted as paths_to_extract of their root, so no directory is walked.
+func fileRoots(files []string) ([]string, map[string][]string) {
+	var roots []string
+	byRoot := make(map[string][]string)
+	for _, f := range files {
+		abs, err := filepath.Abs(cleanHostPath(f))
+		if err != nil {
+			// Rejected by validateOptions
+			continue
+		}
+		root := filepath.VolumeName(abs) + string(filepath.Separator)
+		if _, ok := byRoot[root]; !ok {
+			roots = append(roots, root)
+		}
+		byRoot[root] = append(byRoot[root], abs)
+	}
+	return roots, byRoot
+}
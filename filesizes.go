@@ -0,0 +1,164 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+
+	cpb "github.com/google/osv-scalibr/binary/proto/config_go_proto"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// pluginMaxFileSizeKey is the plugin_options key overriding max_file_size
+// for the extractors of a plugin or preset. The bindings apply it, SCALIBR
+// never sees it.
+const pluginMaxFileSizeKey = "max_file_size"
+
+// scalibrPluginOptions returns the options of a plugin that are passed on to
+// SCALIBR and whether there are any, i.e. the options without max_file_size.
+// Empty options are passed on as they are.
+func scalibrPluginOptions(options map[string]any) (map[string]any, bool) {
+	if _, ok := options[pluginMaxFileSizeKey]; !ok {
+		return options, true
+	}
+	rest := maps.Clone(options)
+	delete(rest, pluginMaxFileSizeKey)
+	return rest, len(rest) > 0
+}
+
+// pluginMaxFileSize returns the max_file_size of a plugin's options, if set.
+// Numbers decode as float64 from JSON and as integers from YAML and TOML.
+func pluginMaxFileSize(options map[string]any) (int, bool, error) {
+	v, ok := options[pluginMaxFileSizeKey]
+	if !ok {
+		return 0, false, nil
+	}
+	var size int64
+	switch v := v.(type) {
+	case float64:
+		if v != math.Trunc(v) || v > math.MaxInt64 {
+			return 0, true, fmt.Errorf("must be a whole number of bytes, got %v", v)
+		}
+		size = int64(v)
+	case int:
+		size = int64(v)
+	case int64:
+		size = v
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, true, fmt.Errorf("too large, got %d", v)
+		}
+		size = int64(v)
+	default:
+		return 0, true, fmt.Errorf("must be a number of bytes, got %v", v)
+	}
+	if size < 0 {
+		return 0, true, fmt.Errorf("must not be negative, got %d", size)
+	}
+	return int(size), true, nil
+}
+
+// extractorMaxFileSizes resolves the max_file_size overrides of
+// plugin_options to the extractors they apply to. An override set for a
+// single extractor wins over one for a preset containing it, and a smaller
+// preset over a larger one.
+func extractorMaxFileSizes(opts *scanOptions, cfg *cpb.PluginConfig) (map[string]int, error) {
+	type override struct {
+		size    int
+		plugins []plugin.Plugin
+	}
+	var overrides []override
+	for _, name := range slices.Sorted(maps.Keys(opts.PluginOptions)) {
+		size, ok, err := pluginMaxFileSize(opts.PluginOptions[name])
+		if err != nil {
+			return nil, fmt.Errorf("plugin_options.%s.%s %w", name, pluginMaxFileSizeKey, err)
+		}
+		if !ok {
+			continue
+		}
+		plugins, err := resolvePlugins([]string{name}, opts, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("plugin_options: unknown plugin %q: %w", name, err)
+		}
+		overrides = append(overrides, override{size: size, plugins: plugins})
+	}
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+	slices.SortStableFunc(overrides, func(a, b override) int { return cmp.Compare(len(b.plugins), len(a.plugins)) })
+	sizes := map[string]int{}
+	for _, o := range overrides {
+		for _, p := range o.plugins {
+			sizes[p.Name()] = o.size
+		}
+	}
+	return sizes, nil
+}
+
+// withMaxFileSizes applies the per-extractor max file sizes on top of the
+// global limit, 0 meaning unlimited for both. SCALIBR's walk only knows one
+// limit, so it's raised to the largest one and the extractors with a lower
+// limit skip larger files in FileRequired. It returns the plugins and the
+// limit for the walk.
+func withMaxFileSizes(plugins []plugin.Plugin, global int, sizes map[string]int) ([]plugin.Plugin, int) {
+	if len(sizes) == 0 {
+		return plugins, global
+	}
+	walk := global
+	for _, size := range sizes {
+		if walk == 0 || size == 0 {
+			walk = 0
+			continue
+		}
+		walk = max(walk, size)
+	}
+	wrapped := make([]plugin.Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		if e, ok := p.(filesystem.Extractor); ok {
+			limit, ok := sizes[e.Name()]
+			if !ok {
+				limit = global
+			}
+			if limit > 0 && (walk == 0 || limit < walk) {
+				p = &sizeLimitedExtractor{Extractor: e, maxSize: int64(limit)}
+			}
+		}
+		wrapped = append(wrapped, p)
+	}
+	return wrapped, walk
+}
+
+// sizeLimitedExtractor skips files above its own max file size.
+type sizeLimitedExtractor struct {
+	filesystem.Extractor
+	maxSize int64
+}
+
+func (e *sizeLimitedExtractor) FileRequired(api filesystem.FileAPI) bool {
+	if !e.Extractor.FileRequired(api) {
+		return false
+	}
+	info, err := api.Stat()
+	if err != nil {
+		// Left to the walk, which reports the error
+		return true
+	}
+	return info.Size() <= e.maxSize
+}
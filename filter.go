@@ -0,0 +1,224 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/inventory"
+)
+
+// filterByPathPrefix scopes the inventory to locations that are below one of
+// the include prefixes (if any are given) and not below any exclude prefix.
+// Prefixes may be given either relative to the scan root or as absolute paths
+// inside it. Packages left without locations are dropped together with the
+// vulnerabilities that reference them.
+func filterByPathPrefix(inv *inventory.Inventory, root string, include, exclude []string) {
+	include = normalizePrefixes(root, include)
+	exclude = normalizePrefixes(root, exclude)
+	keep := func(location string) bool {
+		location = normalizeLocation(root, location)
+		if len(include) > 0 && !matchesAnyPrefix(location, include) {
+			return false
+		}
+		return !matchesAnyPrefix(location, exclude)
+	}
+
+	dropped := make(map[*extractor.Package]bool)
+	packages := inv.Packages[:0]
+	for _, pkg := range inv.Packages {
+		var locations []string
+		for _, loc := range pkg.Locations {
+			if keep(loc) {
+				locations = append(locations, loc)
+			}
+		}
+		if len(locations) == 0 {
+			dropped[pkg] = true
+			continue
+		}
+		pkg.Locations = locations
+		packages = append(packages, pkg)
+	}
+	inv.Packages = packages
+
+	vulns := inv.PackageVulns[:0]
+	for _, v := range inv.PackageVulns {
+		if v.Package != nil && dropped[v.Package] {
+			continue
+		}
+		vulns = append(vulns, v)
+	}
+	inv.PackageVulns = vulns
+
+	secrets := inv.Secrets[:0]
+	for _, s := range inv.Secrets {
+		if keep(s.Location) {
+			secrets = append(secrets, s)
+		}
+	}
+	inv.Secrets = secrets
+}
+
+// resultFilters are the include_plugins, exclude_plugins,
+// include_ecosystems and exclude_ecosystems options.
+type resultFilters struct {
+	includePlugins, excludePlugins       []string
+	includeEcosystems, excludeEcosystems []string
+}
+
+// resultFilters returns the plugin and ecosystem filters of o, or nil if
+// none is set.
+func (o *scanOptions) resultFilters() *resultFilters {
+	if len(o.IncludePlugins)+len(o.ExcludePlugins)+len(o.IncludeEcosystems)+len(o.ExcludeEcosystems) == 0 {
+		return nil
+	}
+	return &resultFilters{
+		includePlugins:    o.IncludePlugins,
+		excludePlugins:    o.ExcludePlugins,
+		includeEcosystems: o.IncludeEcosystems,
+		excludeEcosystems: o.ExcludeEcosystems,
+	}
+}
+
+// keepPlugins reports whether the plugins that reported a package or
+// finding pass the plugin filters.
+func (f *resultFilters) keepPlugins(plugins []string) bool {
+	if len(f.includePlugins) > 0 && !slices.ContainsFunc(plugins, func(p string) bool { return slices.Contains(f.includePlugins, p) }) {
+		return false
+	}
+	return !slices.ContainsFunc(plugins, func(p string) bool { return slices.Contains(f.excludePlugins, p) })
+}
+
+// keepEcosystem reports whether a package of the given PURL type passes the
+// ecosystem filters, which are matched case-insensitively.
+func (f *resultFilters) keepEcosystem(purlType string) bool {
+	matches := func(e string) bool { return strings.EqualFold(e, purlType) }
+	if len(f.includeEcosystems) > 0 && !slices.ContainsFunc(f.includeEcosystems, matches) {
+		return false
+	}
+	return !slices.ContainsFunc(f.excludeEcosystems, matches)
+}
+
+// filterByPluginAndEcosystem drops the packages whose plugins or PURL type
+// don't pass f, along with their vulnerabilities, and the generic findings
+// whose plugins don't pass it.
+func filterByPluginAndEcosystem(inv *inventory.Inventory, f *resultFilters) {
+	dropped := make(map[*extractor.Package]bool)
+	packages := inv.Packages[:0]
+	for _, pkg := range inv.Packages {
+		if !f.keepPlugins(pkg.Plugins) || !f.keepEcosystem(pkg.PURLType) {
+			dropped[pkg] = true
+			continue
+		}
+		packages = append(packages, pkg)
+	}
+	inv.Packages = packages
+
+	vulns := inv.PackageVulns[:0]
+	for _, v := range inv.PackageVulns {
+		if v.Package != nil && dropped[v.Package] {
+			continue
+		}
+		vulns = append(vulns, v)
+	}
+	inv.PackageVulns = vulns
+
+	findings := inv.GenericFindings[:0]
+	for _, gf := range inv.GenericFindings {
+		if f.keepPlugins(gf.Plugins) {
+			findings = append(findings, gf)
+		}
+	}
+	inv.GenericFindings = findings
+}
+
+// dropGoStdlib removes the Go toolchain packages ("stdlib", or "go" in older
+// SCALIBR versions) reported by the Go binary extractor, along with their
+// vulnerabilities.
+func dropGoStdlib(inv *inventory.Inventory) {
+	dropped := make(map[*extractor.Package]bool)
+	packages := inv.Packages[:0]
+	for _, pkg := range inv.Packages {
+		if pkg.PURLType == "golang" && (pkg.Name == "stdlib" || pkg.Name == "go") && slices.Contains(pkg.Plugins, "go/binary") {
+			dropped[pkg] = true
+			continue
+		}
+		packages = append(packages, pkg)
+	}
+	inv.Packages = packages
+
+	vulns := inv.PackageVulns[:0]
+	for _, v := range inv.PackageVulns {
+		if v.Package != nil && dropped[v.Package] {
+			continue
+		}
+		vulns = append(vulns, v)
+	}
+	inv.PackageVulns = vulns
+}
+
+// normalizePrefixes converts the prefixes to slash-separated paths relative
+// to the scan root.
+func normalizePrefixes(root string, prefixes []string) []string {
+	normalized := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		normalized = append(normalized, normalizeLocation(root, p))
+	}
+	return normalized
+}
+
+// normalizeLocation converts an inventory location or a user-supplied path to
+// a slash-separated path relative to the scan root.
+func normalizeLocation(root, location string) string {
+	loc := filepath.ToSlash(cleanHostPath(location))
+	r := strings.TrimSuffix(filepath.ToSlash(cleanHostPath(root)), "/")
+	if r != "" && hasPathPrefix(loc, r) {
+		loc = loc[len(r):]
+	}
+	return strings.Trim(loc, "/")
+}
+
+// hasPathPrefix reports whether the slash-separated path is equal to or
+// below the directory prefix.
+func hasPathPrefix(path, prefix string) bool {
+	if len(path) > len(prefix) && path[len(prefix)] != '/' {
+		return false
+	}
+	return len(path) >= len(prefix) && samePath(path[:len(prefix)], prefix)
+}
+
+// samePath reports whether the paths are equal, ignoring case where the
+// platform's filesystems do.
+func samePath(a, b string) bool {
+	if caseInsensitivePaths {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// matchesAnyPrefix returns true if the location is equal to or below one of
+// the given directory prefixes.
+func matchesAnyPrefix(location string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if p == "" || hasPathPrefix(location, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// Names of the bindings' extractors for embedded Linux root filesystems.
+const (
+	busyboxName  = "native/busybox"
+	squashfsName = "native/squashfs"
+)
+
+// busyboxMaxBytes bounds how much of a BusyBox binary is searched for its
+// version string.
+const busyboxMaxBytes = 8 << 20
+
+var busyboxVersion = regexp.MustCompile(`BusyBox v([0-9][0-9A-Za-z._-]*)`)
+
+// busyboxExtractor reports the version of BusyBox binaries, whose applets
+// replace most userland packages on embedded systems.
+type busyboxExtractor struct{}
+
+func (busyboxExtractor) Name() string { return busyboxName }
+
+func (busyboxExtractor) Version() int { return 0 }
+
+func (busyboxExtractor) Requirements() *plugin.Capabilities { return &plugin.Capabilities{} }
+
+func (busyboxExtractor) FileRequired(api filesystem.FileAPI) bool {
+	return path.Base(api.Path()) == "busybox"
+}
+
+func (busyboxExtractor) Extract(ctx context.Context, input *filesystem.ScanInput) (inventory.Inventory, error) {
+	data, err := io.ReadAll(io.LimitReader(input.Reader, busyboxMaxBytes))
+	if err != nil {
+		return inventory.Inventory{}, fmt.Errorf("failed to read %s: %w", input.Path, err)
+	}
+	m := busyboxVersion.FindSubmatch(data)
+	if m == nil {
+		return inventory.Inventory{}, nil
+	}
+	return inventory.Inventory{Packages: []*extractor.Package{{
+		Name:      "busybox",
+		Version:   strings.TrimRight(string(m[1]), ".-_"),
+		PURLType:  "generic",
+		Locations: []string{input.Path},
+	}}}, nil
+}
+
+// firmwareImage is a filesystem image found inside a scanned root. Its
+// contents aren't scanned; callers can unpack it and scan the result.
+type firmwareImage struct {
+	Path        string
+	Format      string
+	Version     string
+	Compression string `json:",omitempty"`
+	// Size of the filesystem in bytes
+	Size    uint64
+	Created time.Time
+}
+
+var squashfsCompressions = map[uint16]string{
+	1: "gzip",
+	2: "lzma",
+	3: "lzo",
+	4: "xz",
+	5: "lz4",
+	6: "zstd",
+}
+
+var squashfsExtensions = map[string]bool{
+	".squashfs": true,
+	".sqsh":     true,
+	".sfs":      true,
+	".img":      true,
+	".bin":      true,
+}
+
+// squashfsExtractor records the SquashFS images in the scanned tree, which
+// embedded root filesystems are commonly shipped as.
+type squashfsExtractor struct {
+	mu     sync.Mutex
+	images []firmwareImage
+}
+
+func (e *squashfsExtractor) Name() string { return squashfsName }
+
+func (e *squashfsExtractor) Version() int { return 0 }
+
+func (e *squashfsExtractor) Requirements() *plugin.Capabilities { return &plugin.Capabilities{} }
+
+func (e *squashfsExtractor) FileRequired(api filesystem.FileAPI) bool {
+	return squashfsExtensions[strings.ToLower(path.Ext(api.Path()))]
+}
+
+func (e *squashfsExtractor) Extract(ctx context.Context, input *filesystem.ScanInput) (inventory.Inventory, error) {
+	var sb [48]byte
+	if _, err := io.ReadFull(input.Reader, sb[:]); err != nil {
+		// Too small to be an image
+		return inventory.Inventory{}, nil
+	}
+	var order binary.ByteOrder
+	switch string(sb[:4]) {
+	case "hsqs":
+		order = binary.LittleEndian
+	case "sqsh":
+		order = binary.BigEndian
+	default:
+		return inventory.Inventory{}, nil
+	}
+
+	img := firmwareImage{
+		Path:        input.Path,
+		Format:      "squashfs",
+		Version:     fmt.Sprintf("%d.%d", order.Uint16(sb[28:30]), order.Uint16(sb[30:32])),
+		Compression: squashfsCompressions[order.Uint16(sb[20:22])],
+		Size:        order.Uint64(sb[40:48]),
+		Created:     time.Unix(int64(order.Uint32(sb[8:12])), 0).UTC(),
+	}
+	e.mu.Lock()
+	e.images = append(e.images, img)
+	e.mu.Unlock()
+	return inventory.Inventory{}, nil
+}
+
+// takeImages returns the images recorded since the last call.
+func (e *squashfsExtractor) takeImages() []firmwareImage {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	images := e.images
+	e.images = nil
+	return images
+}
+
+// firmwareImages collects the images recorded by the squashfs extractors
+// among plugins.
+func firmwareImages(plugins []plugin.Plugin) []firmwareImage {
+	var images []firmwareImage
+	for _, p := range plugins {
+		if e, ok := p.(*squashfsExtractor); ok {
+			images = append(images, e.takeImages()...)
+		}
+	}
+	return images
+}
+
+var (
+	_ filesystem.Extractor = busyboxExtractor{}
+	_ filesystem.Extractor = &squashfsExtractor{}
+)
@@ -0,0 +1,43 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// fsErrorReason returns why the scan behind r couldn't read part of its root
+// with error_on_fs_errors set, or "" if it read everything. SCALIBR fails the
+// walk itself on entries it can't list or stat, while files an extractor
+// can't open are only recorded in the extractor's status.
+func fsErrorReason(r *scalibr.ScanResult) string {
+	if r.Status != nil && r.Status.Status == plugin.ScanStatusFailed && strings.Contains(r.Status.FailureReason, " fserr: ") {
+		return r.Status.FailureReason
+	}
+	for _, s := range r.PluginStatus {
+		if s.Status == nil {
+			continue
+		}
+		for _, e := range s.Status.FileErrors {
+			if strings.HasPrefix(e.ErrorMessage, "Open(") || strings.HasPrefix(e.ErrorMessage, "stat(") {
+				return s.Name + ": " + e.ErrorMessage
+			}
+		}
+	}
+	return ""
+}
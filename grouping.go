@@ -0,0 +1,170 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/inventory"
+)
+
+// Values of the group_findings option.
+const (
+	groupByPackage       = "package"
+	groupByVulnerability = "vulnerability"
+)
+
+var findingGroupings = []string{groupByPackage, groupByVulnerability}
+
+// findingGroups rolls up the findings of a scan.
+type findingGroups struct {
+	ByPackage       []packageGroup `json:",omitempty"`
+	ByVulnerability []vulnGroup    `json:",omitempty"`
+}
+
+// packageGroup counts the vulnerabilities found in one package. Packages
+// found in several locations are rolled up into one group.
+type packageGroup struct {
+	Name    string
+	Version string
+	PURL    string `json:",omitempty"`
+	Count   int
+	VulnIDs []string
+}
+
+// vulnGroup counts the findings of one vulnerability or advisory across
+// packages.
+type vulnGroup struct {
+	ID    string
+	Count int
+	// PURLs, or name@version, of the affected packages
+	Packages []string `json:",omitempty"`
+	Plugins  []string
+	// Highest severity of the generic findings of the group
+	Severity string `json:",omitempty"`
+}
+
+// groupFindings returns the requested roll-ups of the findings in inv, or
+// nil if none is requested.
+func groupFindings(inv *inventory.Inventory, groupings []string) *findingGroups {
+	if len(groupings) == 0 {
+		return nil
+	}
+	g := &findingGroups{}
+	if slices.Contains(groupings, groupByPackage) {
+		g.ByPackage = groupByPackages(inv)
+	}
+	if slices.Contains(groupings, groupByVulnerability) {
+		g.ByVulnerability = groupByVulns(inv)
+	}
+	return g
+}
+
+func groupByPackages(inv *inventory.Inventory) []packageGroup {
+	groups := map[string]*packageGroup{}
+	for _, v := range inv.PackageVulns {
+		if v.Package == nil {
+			continue
+		}
+		key := componentKey(v.Package)
+		grp, ok := groups[key]
+		if !ok {
+			grp = &packageGroup{Name: v.Package.Name, Version: v.Package.Version}
+			if p := v.Package.PURL(); p != nil {
+				grp.PURL = p.String()
+			}
+			groups[key] = grp
+		}
+		grp.Count++
+		if id := vulnID(v); id != "" && !slices.Contains(grp.VulnIDs, id) {
+			grp.VulnIDs = append(grp.VulnIDs, id)
+		}
+	}
+	out := make([]packageGroup, 0, len(groups))
+	for _, grp := range groups {
+		slices.Sort(grp.VulnIDs)
+		out = append(out, *grp)
+	}
+	slices.SortFunc(out, func(a, b packageGroup) int {
+		return cmp.Or(b.Count-a.Count, cmp.Compare(a.PURL, b.PURL), cmp.Compare(a.Name, b.Name), cmp.Compare(a.Version, b.Version))
+	})
+	return out
+}
+
+func groupByVulns(inv *inventory.Inventory) []vulnGroup {
+	groups := map[string]*vulnGroup{}
+	severities := map[string]inventory.SeverityEnum{}
+	get := func(id string) *vulnGroup {
+		grp, ok := groups[id]
+		if !ok {
+			grp = &vulnGroup{ID: id}
+			groups[id] = grp
+		}
+		grp.Count++
+		return grp
+	}
+	addPlugins := func(grp *vulnGroup, plugins []string) {
+		for _, p := range plugins {
+			if !slices.Contains(grp.Plugins, p) {
+				grp.Plugins = append(grp.Plugins, p)
+			}
+		}
+	}
+	for _, v := range inv.PackageVulns {
+		id := vulnID(v)
+		if id == "" {
+			continue
+		}
+		grp := get(id)
+		if v.Package != nil {
+			if key := componentKey(v.Package); !slices.Contains(grp.Packages, key) {
+				grp.Packages = append(grp.Packages, key)
+			}
+		}
+		addPlugins(grp, v.Plugins)
+	}
+	for _, f := range inv.GenericFindings {
+		if f.Adv == nil || f.Adv.ID == nil || f.Adv.ID.Reference == "" {
+			continue
+		}
+		grp := get(f.Adv.ID.Reference)
+		addPlugins(grp, f.Plugins)
+		if f.Adv.Sev > severities[grp.ID] {
+			severities[grp.ID] = f.Adv.Sev
+			grp.Severity = severityName(f.Adv.Sev)
+		}
+	}
+	out := make([]vulnGroup, 0, len(groups))
+	for _, grp := range groups {
+		slices.Sort(grp.Packages)
+		slices.Sort(grp.Plugins)
+		out = append(out, *grp)
+	}
+	slices.SortFunc(out, func(a, b vulnGroup) int {
+		return cmp.Or(b.Count-a.Count, cmp.Compare(a.ID, b.ID))
+	})
+	return out
+}
+
+// componentKey identifies a package across locations: its PURL, or
+// name@version if it has none.
+func componentKey(pkg *extractor.Package) string {
+	if p := pkg.PURL(); p != nil {
+		return p.String()
+	}
+	return pkg.Name + "@" + pkg.Version
+}
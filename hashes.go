@@ -0,0 +1,93 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+
+	"github.com/google/osv-scalibr/inventory"
+)
+
+// artifactHash is the content hash of a file that produced inventory, for
+// the hash_artifacts option.
+type artifactHash struct {
+	Location string
+	SHA256   string
+}
+
+// hashArtifacts returns the SHA-256 of the files at the locations of the
+// packages and secrets of inv, read from fsys, the filesystem of root.
+// Locations that aren't regular files, e.g. entries of archives, are left
+// out. Hashing stops early when ctx is done.
+func hashArtifacts(ctx context.Context, fsys fs.FS, inv *inventory.Inventory, root string) []artifactHash {
+	var hashes []artifactHash
+	for _, loc := range inventoryLocations(inv) {
+		if ctx.Err() != nil {
+			break
+		}
+		name, ok := locationPath(root, loc)
+		if !ok {
+			continue
+		}
+		if sum, ok := hashFile(fsys, name); ok {
+			hashes = append(hashes, artifactHash{Location: loc, SHA256: sum})
+		}
+	}
+	return hashes
+}
+
+// inventoryLocations returns the distinct locations of the packages and
+// secrets of inv, sorted.
+func inventoryLocations(inv *inventory.Inventory) []string {
+	var locations []string
+	for _, pkg := range inv.Packages {
+		locations = append(locations, pkg.Locations...)
+	}
+	for _, s := range inv.Secrets {
+		locations = append(locations, s.Location)
+	}
+	slices.Sort(locations)
+	return slices.Compact(locations)
+}
+
+// locationPath returns the path of a location in the filesystem of root, in
+// the form fs.FS takes.
+func locationPath(root, location string) (string, bool) {
+	name := path.Clean(normalizeLocation(root, location))
+	return name, fs.ValidPath(name)
+}
+
+// hashFile returns the hex-encoded SHA-256 of a regular file.
+func hashFile(fsys fs.FS, name string) (string, bool) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", false
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
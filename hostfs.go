@@ -0,0 +1,336 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	scalibrfs "github.com/google/osv-scalibr/fs"
+)
+
+// hostFSName names a host filesystem in errors and progress updates.
+const hostFSName = "host filesystem"
+
+// vfsReadDirBufferSize is the size of the first buffer the host's read_dir
+// writes a directory listing into.
+const vfsReadDirBufferSize = 4096
+
+// hostFS is a read-only filesystem served by the host's callbacks. Paths
+// are slash-separated and relative to its root, which is ".". The callbacks
+// return negative errno values on failure.
+type hostFS struct {
+	stat    func(name string) (hostFileInfo, int)
+	readDir func(name string) ([]string, int)
+	open    func(name string) (int64, int)
+	// Reads at off, like pread; 0 bytes means the end of the file
+	read  func(handle, off int64, b []byte) int
+	close func(handle int64)
+
+	// Guards detached and the callbacks in flight, see detach
+	mu       sync.Mutex
+	detached bool
+	inFlight int
+	// Closed once no callback is in flight after detaching, nil before
+	drained chan struct{}
+	// Handles opened and not closed yet
+	handlesMu sync.Mutex
+	handles   map[int64]bool
+}
+
+// errHostFSDetached is returned by a hostFS once the scan using it returned.
+var errHostFSDetached = errors.New("the host filesystem is no longer available")
+
+// use runs call, which invokes callbacks, unless the filesystem was detached
+// from the host.
+func (h *hostFS) use(call func()) error {
+	h.mu.Lock()
+	if h.detached {
+		h.mu.Unlock()
+		return errHostFSDetached
+	}
+	h.inFlight++
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.inFlight--
+		if h.detached && h.inFlight == 0 {
+			close(h.drained)
+		}
+	}()
+	call()
+	return nil
+}
+
+// detach cuts the filesystem off from the host's callbacks: calls made
+// afterwards, e.g. by a plugin call abandoned by its timeout, fail. It waits
+// up to grace for the callbacks in flight to return and closes the handles
+// still open. If a callback is still running by then, detach gives up on it
+// and leaves the handles open, as closing them could pull them from under
+// the stuck call, and reports false.
+func (h *hostFS) detach(grace time.Duration) bool {
+	h.mu.Lock()
+	if h.detached {
+		h.mu.Unlock()
+		return true
+	}
+	h.detached = true
+	h.drained = make(chan struct{})
+	if h.inFlight == 0 {
+		close(h.drained)
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-h.drained:
+	case <-time.After(grace):
+		return false
+	}
+	h.handlesMu.Lock()
+	defer h.handlesMu.Unlock()
+	for handle := range h.handles {
+		h.close(handle)
+	}
+	h.handles = nil
+	return true
+}
+
+// hostFileInfo is the metadata the host reports for a file.
+type hostFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i hostFileInfo) Name() string       { return i.name }
+func (i hostFileInfo) Size() int64        { return i.size }
+func (i hostFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i hostFileInfo) ModTime() time.Time { return i.modTime }
+func (i hostFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i hostFileInfo) Sys() any           { return nil }
+
+// errno values the callbacks may return, negated. They are the same on
+// POSIX systems and in the Windows C runtime.
+const (
+	hostEPERM  = 1
+	hostENOENT = 2
+	hostEACCES = 13
+)
+
+// hostErr converts a negative errno returned by a callback.
+func hostErr(op, name string, errno int) error {
+	var err error
+	switch -errno {
+	case hostENOENT:
+		err = fs.ErrNotExist
+	case hostEACCES, hostEPERM:
+		err = fs.ErrPermission
+	default:
+		err = fmt.Errorf("failed with %d", errno)
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (h *hostFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	var info hostFileInfo
+	var errno int
+	if err := h.use(func() { info, errno = h.stat(name) }); err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	if errno < 0 {
+		return nil, hostErr("stat", name, errno)
+	}
+	info.name = path.Base(name)
+	return info, nil
+}
+
+func (h *hostFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	var names []string
+	var errno int
+	if err := h.use(func() { names, errno = h.readDir(name) }); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if errno < 0 {
+		return nil, hostErr("readdir", name, errno)
+	}
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, n := range names {
+		if n == "" || n == "." || n == ".." || strings.Contains(n, "/") {
+			continue
+		}
+		// Entries that vanished since the listing are left out
+		info, err := h.Stat(path.Join(name, n))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (h *hostFS) Open(name string) (fs.File, error) {
+	info, err := h.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	f := &hostFile{fs: h, name: name, info: info, handle: -1}
+	if info.IsDir() {
+		// Directories are listed through readDir, the host doesn't open them
+		return f, nil
+	}
+	var handle int64
+	var errno int
+	err = h.use(func() {
+		if handle, errno = h.open(name); errno >= 0 {
+			// Before the call counts as returned, so detach closes it
+			h.trackHandle(handle, true)
+		}
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if errno < 0 {
+		return nil, hostErr("open", name, errno)
+	}
+	f.handle = handle
+	return f, nil
+}
+
+// trackHandle records handle as open or closed.
+func (h *hostFS) trackHandle(handle int64, open bool) {
+	h.handlesMu.Lock()
+	defer h.handlesMu.Unlock()
+	if !open {
+		delete(h.handles, handle)
+		return
+	}
+	if h.handles == nil {
+		h.handles = make(map[int64]bool)
+	}
+	h.handles[handle] = true
+}
+
+var _ scalibrfs.FS = &hostFS{}
+
+// hostFile is a file or directory opened through a hostFS.
+type hostFile struct {
+	fs     *hostFS
+	name   string
+	info   fs.FileInfo
+	handle int64
+	off    int64
+	// Entries of a directory not returned by ReadDir yet, nil before the
+	// first call
+	unread []fs.DirEntry
+}
+
+func (f *hostFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *hostFile) Read(b []byte) (int, error) {
+	n, err := f.ReadAt(b, f.off)
+	f.off += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *hostFile) ReadAt(b []byte, off int64) (int, error) {
+	if f.handle < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.New("is a directory")}
+	}
+	total := 0
+	for total < len(b) {
+		var n int
+		if err := f.fs.use(func() { n = f.fs.read(f.handle, off+int64(total), b[total:]) }); err != nil {
+			return total, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		if n < 0 {
+			return total, hostErr("read", f.name, n)
+		}
+		if n == 0 {
+			return total, io.EOF
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (f *hostFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.info.Size()
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *hostFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.handle >= 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+	}
+	if f.unread == nil {
+		entries, err := f.fs.ReadDir(f.name)
+		if err != nil {
+			return nil, err
+		}
+		f.unread = entries
+	}
+	if n <= 0 || n >= len(f.unread) {
+		entries := f.unread
+		f.unread = entries[len(entries):]
+		if n > 0 && len(entries) == 0 {
+			return nil, io.EOF
+		}
+		return entries, nil
+	}
+	entries := f.unread[:n]
+	f.unread = f.unread[n:]
+	return entries, nil
+}
+
+func (f *hostFile) Close() error {
+	if f.handle >= 0 {
+		handle := f.handle
+		f.handle = -1
+		// Once detached, the handle was closed along with the others
+		_ = f.fs.use(func() {
+			f.fs.close(handle)
+			f.fs.trackHandle(handle, false)
+		})
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakeHost serves files through the callbacks of a hostFS the way a host
+// would, keeping track of the handles it opened.
+type fakeHost struct {
+	files fstest.MapFS
+
+	mu     sync.Mutex
+	next   int64
+	open   map[int64][]byte
+	closed []int64
+}
+
+func newFakeHost(files fstest.MapFS) (*fakeHost, *hostFS) {
+	host := &fakeHost{files: files, open: make(map[int64][]byte)}
+	return host, &hostFS{
+		stat: func(name string) (hostFileInfo, int) {
+			info, err := fs.Stat(host.files, name)
+			if err != nil {
+				return hostFileInfo{}, -hostENOENT
+			}
+			return hostFileInfo{size: info.Size(), mode: info.Mode(), modTime: info.ModTime()}, 0
+		},
+		readDir: func(name string) ([]string, int) {
+			entries, err := fs.ReadDir(host.files, name)
+			if err != nil {
+				return nil, -hostENOENT
+			}
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			return names, 0
+		},
+		open: func(name string) (int64, int) {
+			data, err := fs.ReadFile(host.files, name)
+			if err != nil {
+				return 0, -hostENOENT
+			}
+			host.mu.Lock()
+			defer host.mu.Unlock()
+			host.next++
+			host.open[host.next] = data
+			return host.next, 0
+		},
+		read: func(handle, off int64, b []byte) int {
+			host.mu.Lock()
+			defer host.mu.Unlock()
+			data := host.open[handle]
+			if off >= int64(len(data)) {
+				return 0
+			}
+			return copy(b, data[off:])
+		},
+		close: func(handle int64) {
+			host.mu.Lock()
+			defer host.mu.Unlock()
+			delete(host.open, handle)
+			host.closed = append(host.closed, handle)
+		},
+	}
+}
+
+func TestHostFS(t *testing.T) {
+	_, hfs := newFakeHost(fstest.MapFS{
+		"package-lock.json":           {Data: []byte(`{"lockfileVersion": 3}`)},
+		"app/requirements.txt":        {Data: []byte("requests==2.31.0\n")},
+		"app/vendor/go.mod":           {Data: []byte("module example.com/app\n")},
+		"app/vendor/modules/empty.go": {},
+	})
+	if err := fstest.TestFS(hfs, "package-lock.json", "app/requirements.txt", "app/vendor/go.mod", "app/vendor/modules/empty.go"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHostFSDetachClosesHandles(t *testing.T) {
+	host, hfs := newFakeHost(fstest.MapFS{
+		"a.txt": {Data: []byte("a")},
+		"b.txt": {Data: []byte("b")},
+	})
+	a, err := hfs.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := hfs.Open("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hfs.detach(time.Second) {
+		t.Fatal("detach() = false with no callback running")
+	}
+	host.mu.Lock()
+	if len(host.open) != 0 || len(host.closed) != 2 {
+		t.Errorf("after detach the host has %d open handles and closed %v, want none open and 2 closed", len(host.open), host.closed)
+	}
+	host.mu.Unlock()
+
+	if _, err := a.Read(make([]byte, 1)); !errors.Is(err, errHostFSDetached) {
+		t.Errorf("Read() after detach error = %v, want %v", err, errHostFSDetached)
+	}
+	if _, err := hfs.Stat("a.txt"); !errors.Is(err, errHostFSDetached) {
+		t.Errorf("Stat() after detach error = %v, want %v", err, errHostFSDetached)
+	}
+	// The handle was closed by detach already
+	if err := a.Close(); err != nil {
+		t.Errorf("Close() after detach error = %v", err)
+	}
+	if len(host.closed) != 2 {
+		t.Errorf("host closed %v, want each handle closed once", host.closed)
+	}
+}
+
+func TestHostFSDetachWithStuckCallback(t *testing.T) {
+	host, hfs := newFakeHost(fstest.MapFS{"stuck.bin": {Data: []byte("data")}})
+	f, err := hfs.Open("stuck.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	read := hfs.read
+	hfs.read = func(handle, off int64, b []byte) int {
+		close(entered)
+		<-unblock
+		return read(handle, off, b)
+	}
+	readDone := make(chan error)
+	go func() {
+		_, err := io.ReadAll(f)
+		readDone <- err
+	}()
+	<-entered
+
+	start := time.Now()
+	if hfs.detach(50 * time.Millisecond) {
+		t.Error("detach() = true while a callback is stuck")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("detach() took %v with a stuck callback", elapsed)
+	}
+	host.mu.Lock()
+	if len(host.closed) != 0 {
+		t.Errorf("detach() closed %v under the stuck callback", host.closed)
+	}
+	host.mu.Unlock()
+
+	// Once the callback returns, the reader gets no further callback
+	close(unblock)
+	if err := <-readDone; !errors.Is(err, errHostFSDetached) {
+		t.Errorf("ReadAll() error = %v, want %v", err, errHostFSDetached)
+	}
+}
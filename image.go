@@ -0,0 +1,148 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/osv-scalibr/artifact/image/layerscanning/image"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/log"
+)
+
+// registryAuth holds the credentials for pulling an image from a private
+// registry. Token is an identity or registry token, used instead of, or
+// along with, the user name.
+type registryAuth struct {
+	Username string `json:"username" yaml:"username" toml:"username"`
+	Password string `json:"password" yaml:"password" toml:"password"`
+	Token    string `json:"token" yaml:"token" toml:"token"`
+}
+
+// authenticator returns the credentials of a, or the host's docker
+// credentials if a is nil.
+func (a *registryAuth) authenticator() remote.Option {
+	if a == nil {
+		return remote.WithAuthFromKeychain(authn.DefaultKeychain)
+	}
+	return remote.WithAuth(authn.FromConfig(authn.AuthConfig{
+		Username:      a.Username,
+		Password:      a.Password,
+		RegistryToken: a.Token,
+	}))
+}
+
+// scanImage reports whether the scan is of a container image rather than
+// the host's filesystem.
+func (o *scanOptions) scanImage() bool {
+	return o.ImageTarball != "" || o.Image != ""
+}
+
+// imageName names the scanned image in errors and progress updates.
+func (o *scanOptions) imageName() string {
+	return cmp.Or(o.Image, o.ImageTarball)
+}
+
+// loadImage unpacks the container image selected by opts, pulling it from
+// its registry if it's given by reference. Pulled layers are read through the
+// cache in image_cache_dir, if set. The caller releases it with
+// cleanUpImage.
+func loadImage(ctx context.Context, opts *scanOptions) (*image.Image, error) {
+	if opts.ImageTarball != "" {
+		return image.FromTarball(opts.ImageTarball, image.DefaultConfig())
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(opts.Image, "/"))
+	if err != nil {
+		return nil, err
+	}
+	// Pulls go through the proxy and network limits like the plugins'
+	// requests
+	v1img, err := remote.Image(ref,
+		remote.WithContext(ctx),
+		remote.WithTransport(http.DefaultTransport),
+		opts.RegistryAuth.authenticator(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ImageCacheDir == "" {
+		return image.FromV1Image(v1img, image.DefaultConfig())
+	}
+	if v1img, err = cacheImage(v1img, opts.ImageCacheDir); err != nil {
+		return nil, err
+	}
+	// The layers are written to the cache while they're unpacked
+	imageCacheMu.RLock()
+	img, err := image.FromV1Image(v1img, image.DefaultConfig())
+	imageCacheMu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	keep, err := imageCacheFiles(v1img, opts.ImageCacheDir)
+	if err == nil {
+		err = evictImageCache(opts.ImageCacheDir, opts.imageCacheBytes(), keep)
+	}
+	if err != nil {
+		log.Warnf("failed to evict image layers from %s: %v", opts.ImageCacheDir, err)
+	}
+	return img, nil
+}
+
+// cleanUpImage removes the files of img unpacked by loadImage.
+func cleanUpImage(img *image.Image) {
+	if err := img.CleanUp(); err != nil {
+		log.Warnf("failed to clean up image: %v", err)
+	}
+}
+
+// virtualPaths makes host-style absolute paths relative to the root of an
+// image or virtual filesystem, the form the walk of its filesystem uses.
+func virtualPaths(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		out = append(out, strings.Trim(filepath.ToSlash(filepath.Clean(p)), "/"))
+	}
+	return out
+}
+
+// detachLayerParents clears the references of the image layers to their
+// image, a cycle the JSON and CBOR encoders can't follow. The image stays
+// reachable through the inventory's ContainerImageMetadata.
+func detachLayerParents(inv *inventory.Inventory) {
+	for _, cim := range inv.ContainerImageMetadata {
+		for _, l := range cim.LayerMetadata {
+			l.ParentContainer = nil
+		}
+	}
+}
+
+// linkLayerParents restores the references cleared by detachLayerParents.
+func linkLayerParents(inv *inventory.Inventory) {
+	for _, cim := range inv.ContainerImageMetadata {
+		for _, l := range cim.LayerMetadata {
+			l.ParentContainer = cim
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
+	"github.com/google/osv-scalibr/log"
+)
+
+// defaultImageCacheBytes caps the size of image_cache_dir unless
+// image_cache_bytes is set.
+const defaultImageCacheBytes = 10 << 30
+
+// imageCacheMu guards the image layer cache. Pulls writing layers to it
+// hold it for reading, so that they run side by side, and evictions hold it
+// for writing, so that they never remove a layer being written.
+var imageCacheMu sync.RWMutex
+
+// imageCacheBytes returns the cap on the size of the image layer cache.
+func (o *scanOptions) imageCacheBytes() int64 {
+	if o.ImageCacheBytes > 0 {
+		return o.ImageCacheBytes
+	}
+	return defaultImageCacheBytes
+}
+
+// layerCache is the filesystem layer cache of go-containerregistry, which
+// stores each layer blob under its digest. Layers it serves are marked as
+// used so that evictImageCache removes the least recently used ones first.
+type layerCache struct {
+	cache.Cache
+	dir string
+}
+
+func newLayerCache(dir string) *layerCache {
+	return &layerCache{Cache: cache.NewFilesystemCache(dir), dir: dir}
+}
+
+func (c *layerCache) Get(h v1.Hash) (v1.Layer, error) {
+	l, err := c.Cache.Get(h)
+	if err == nil {
+		now := time.Now()
+		if err := os.Chtimes(layerCachePath(c.dir, h), now, now); err != nil {
+			log.Debugf("failed to mark cached layer %s as used: %v", h, err)
+		}
+	}
+	return l, err
+}
+
+// layerCachePath mirrors the file names of the filesystem cache, which
+// avoids colons on Windows.
+func layerCachePath(dir string, h v1.Hash) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(dir, fmt.Sprintf("%s-%s", h.Algorithm, h.Hex))
+	}
+	return filepath.Join(dir, h.String())
+}
+
+// evictImageCache removes the least recently used layers of dir until the
+// cache holds at most limit bytes, keeping the files in keep, the layers of
+// the image being scanned. Layers that can't be removed, e.g. ones still
+// open on Windows, are skipped.
+func evictImageCache(dir string, limit int64, keep map[string]bool) error {
+	imageCacheMu.Lock()
+	defer imageCacheMu.Unlock()
+	type entry struct {
+		path  string
+		size  int64
+		mtime time.Time
+	}
+	var entries []entry
+	var total int64
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, de := range dirEntries {
+		if !de.Type().IsRegular() || keep[filepath.Join(dir, de.Name())] {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{filepath.Join(dir, de.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return a.mtime.Compare(b.mtime) })
+	for _, e := range entries {
+		if total <= limit {
+			break
+		}
+		if err := os.Remove(e.path); err != nil {
+			log.Warnf("failed to evict cached layer %s: %v", e.path, err)
+			continue
+		}
+		total -= e.size
+	}
+	return nil
+}
+
+// cacheImage makes the layers of img read through the cache in dir.
+func cacheImage(img v1.Image, dir string) (v1.Image, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return cache.Image(img, newLayerCache(dir)), nil
+}
+
+// imageCacheFiles returns the files in the cache in dir that hold the layers
+// of img, compressed or not.
+func imageCacheFiles(img v1.Image, dir string) (map[string]bool, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]bool{}
+	for _, l := range layers {
+		digest, err := l.Digest()
+		if err != nil {
+			return nil, err
+		}
+		diffID, err := l.DiffID()
+		if err != nil {
+			return nil, err
+		}
+		files[layerCachePath(dir, digest)] = true
+		files[layerCachePath(dir, diffID)] = true
+	}
+	return files, nil
+}
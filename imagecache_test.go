@@ -0,0 +1,80 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEvictImageCache(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int64
+		keep  []string
+		want  []string
+	}{
+		{name: "under the limit", limit: 30, want: []string{"a", "b", "c"}},
+		{name: "oldest evicted first", limit: 25, want: []string{"b", "c"}},
+		{name: "down to the limit", limit: 10, want: []string{"c"}},
+		{name: "everything", limit: 0, want: nil},
+		{name: "layers of the scanned image kept", limit: 0, keep: []string{"a"}, want: []string{"a"}},
+		{name: "kept layers not counted", limit: 10, keep: []string{"a"}, want: []string{"a", "c"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			now := time.Now()
+			// a is the least and c the most recently used
+			for i, name := range []string{"a", "b", "c"} {
+				path := filepath.Join(dir, name)
+				if err := os.WriteFile(path, make([]byte, 10), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				mtime := now.Add(time.Duration(i-3) * time.Hour)
+				if err := os.Chtimes(path, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			keep := map[string]bool{}
+			for _, name := range tc.keep {
+				keep[filepath.Join(dir, name)] = true
+			}
+			if err := evictImageCache(dir, tc.limit, keep); err != nil {
+				t.Fatalf("evictImageCache() error: %v", err)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("evictImageCache() left %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvictImageCacheMissingDir(t *testing.T) {
+	if err := evictImageCache(filepath.Join(t.TempDir(), "missing"), 0, nil); err != nil {
+		t.Errorf("evictImageCache() error: %v", err)
+	}
+}
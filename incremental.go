@@ -0,0 +1,263 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/osv-scalibr/extractor"
+)
+
+// previousScan mirrors the parts of an earlier JSON result that an
+// incremental scan builds on.
+type previousScan struct {
+	Inventory struct {
+		Packages []*previousPackage
+		Secrets  []struct{ Location string }
+	}
+	FileMetadata []fileMetadata
+	// The packages with their metadata, see scanOutput.IncrementalState
+	IncrementalState []byte
+}
+
+// previousPackage is a package of an earlier JSON result. Its metadata is
+// only checked for presence, since its type isn't recorded in the JSON.
+type previousPackage struct {
+	storedPackage
+	Metadata json.RawMessage
+}
+
+// reusablePackage is a package of the previous result and whether it can be
+// carried forward as is.
+type reusablePackage struct {
+	pkg      *extractor.Package
+	reusable bool
+}
+
+// incrementalInfo summarizes what an incremental scan reused.
+type incrementalInfo struct {
+	// Files left out of the walk since they didn't change
+	UnchangedFiles int
+	// Packages carried forward from the previous result
+	ReusedPackages int
+}
+
+// incrementalScan rescans the roots of the previous_result option, leaving
+// out the files whose size and modification time haven't changed and
+// carrying their packages forward instead.
+type incrementalScan struct {
+	prev         *previousScan
+	packages     []reusablePackage
+	roots        []string
+	rootRelative bool
+}
+
+// loadPreviousResult returns the incremental scan of roots building on the
+// result at opts.PreviousResult, or nil without one.
+func loadPreviousResult(opts *scanOptions, roots []string) (*incrementalScan, error) {
+	if opts.PreviousResult == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(cleanHostPath(opts.PreviousResult))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous result: %w", err)
+	}
+	prev := &previousScan{}
+	if err := json.Unmarshal(data, prev); err != nil {
+		return nil, fmt.Errorf("failed to parse previous result: %w", err)
+	}
+	s := &incrementalScan{prev: prev, roots: roots, rootRelative: opts.RootRelativePaths}
+	if len(prev.IncrementalState) > 0 {
+		inv, err := unmarshalInventory(prev.IncrementalState)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse previous result: %w", err)
+		}
+		for _, p := range inv.Packages {
+			s.packages = append(s.packages, reusablePackage{pkg: p, reusable: true})
+		}
+		return s, nil
+	}
+	// Without the state, packages with metadata can't be restored faithfully
+	// and their files are extracted again
+	for _, p := range prev.Inventory.Packages {
+		if p == nil {
+			continue
+		}
+		s.packages = append(s.packages, reusablePackage{
+			pkg: &extractor.Package{
+				Name:       p.Name,
+				Version:    p.Version,
+				SourceCode: p.SourceCode,
+				Locations:  p.Locations,
+				PURLType:   p.PURLType,
+				Plugins:    p.Plugins,
+				Licenses:   p.Licenses,
+			},
+			reusable: len(p.Metadata) == 0 || string(p.Metadata) == "null",
+		})
+	}
+	return s, nil
+}
+
+// locate returns the index of the root a location of the previous result
+// is in and its path relative to that root. Relative locations of scans
+// of several roots can't be told apart without root_relative_paths, and
+// are left out.
+func (s *incrementalScan) locate(location string) (int, string, bool) {
+	if s.rootRelative {
+		id, rel, ok := strings.Cut(location, ":")
+		if !ok {
+			return 0, "", false
+		}
+		i, err := strconv.Atoi(strings.TrimPrefix(id, "root"))
+		if err != nil || i < 0 || i >= len(s.roots) || !fs.ValidPath(rel) {
+			return 0, "", false
+		}
+		return i, rel, true
+	}
+	loc := cleanHostPath(location)
+	if !filepath.IsAbs(loc) {
+		if len(s.roots) != 1 {
+			return 0, "", false
+		}
+		rel, ok := locationPath(s.roots[0], location)
+		return 0, rel, ok
+	}
+	for i, root := range s.roots {
+		if hasPathPrefix(filepath.ToSlash(loc), strings.TrimSuffix(filepath.ToSlash(cleanHostPath(root)), "/")) {
+			rel, ok := locationPath(root, location)
+			return i, rel, ok
+		}
+	}
+	return 0, "", false
+}
+
+// root compares the files of the i-th root, read from fsys, with the
+// previous result. It returns the unchanged files to leave out of the walk,
+// by slash-separated path relative to the root, and the packages found in
+// them. Files that also produced secrets, packages found in their contents,
+// e.g. in the entries of an archive, or packages that aren't reusable are
+// extracted again, since those can't be carried forward.
+func (s *incrementalScan) root(i int, fsys fs.FS) (map[string]bool, []*extractor.Package) {
+	skip := map[string]bool{}
+	for _, f := range s.prev.FileMetadata {
+		j, rel, ok := s.locate(f.Location)
+		if !ok || j != i || !strings.HasPrefix(f.Mode, "-") {
+			continue
+		}
+		info, err := fs.Stat(fsys, rel)
+		if err == nil && info.Mode().IsRegular() && info.Size() == f.Size && info.ModTime().Equal(f.ModTime) {
+			skip[rel] = true
+		}
+	}
+
+	// Locations of the root's packages and whether they are carried forward
+	type prevPackage struct {
+		reusablePackage
+		locations []string
+	}
+	var pkgs []prevPackage
+	for _, rp := range s.packages {
+		p := rp.pkg
+		if len(p.Locations) == 0 {
+			continue
+		}
+		pp := prevPackage{reusablePackage: rp}
+		for _, loc := range p.Locations {
+			if j, rel, ok := s.locate(loc); ok && j == i {
+				pp.locations = append(pp.locations, rel)
+			}
+		}
+		if len(pp.locations) == len(p.Locations) {
+			pkgs = append(pkgs, pp)
+		}
+	}
+	carried := func(pp prevPackage) bool {
+		if !pp.reusable {
+			return false
+		}
+		for _, loc := range pp.locations {
+			if !skip[loc] {
+				return false
+			}
+		}
+		return true
+	}
+	// Extract the files again that anything not carried forward came from
+	unskip := func(loc string) bool {
+		changed := false
+		for q := loc; q != "."; q = path.Dir(q) {
+			if skip[q] {
+				delete(skip, q)
+				changed = true
+			}
+		}
+		return changed
+	}
+	for _, secret := range s.prev.Inventory.Secrets {
+		if j, rel, ok := s.locate(secret.Location); ok && j == i {
+			unskip(rel)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, pp := range pkgs {
+			if carried(pp) {
+				continue
+			}
+			for _, loc := range pp.locations {
+				if unskip(loc) {
+					changed = true
+				}
+			}
+		}
+	}
+
+	var reused []*extractor.Package
+	for _, pp := range pkgs {
+		if !carried(pp) {
+			continue
+		}
+		p := *pp.pkg
+		if s.rootRelative {
+			// Tagged again along with the new inventory
+			p.Locations = slices.Clone(pp.locations)
+		}
+		reused = append(reused, &p)
+	}
+	return skip, reused
+}
+
+// skipFiles leaves the files of skip out of the directory listings.
+func skipFiles(skip map[string]bool) listingEditor {
+	return func(dir string, entries []fs.DirEntry) []fs.DirEntry {
+		kept := make([]fs.DirEntry, 0, len(entries))
+		for _, e := range entries {
+			if !e.IsDir() && skip[path.Join(dir, e.Name())] {
+				continue
+			}
+			kept = append(kept, e)
+		}
+		return kept
+	}
+}
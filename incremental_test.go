@@ -0,0 +1,125 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/binary/platform"
+	scalibrfs "github.com/google/osv-scalibr/fs"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// scanBusybox scans root for BusyBox binaries, carrying forward what inc
+// finds unchanged if it isn't nil, as runScan does.
+func scanBusybox(t *testing.T, root string, inc *incrementalScan) (*scalibr.ScanResult, int) {
+	t.Helper()
+	cfg := scalibr.ScanConfig{
+		Plugins:      []plugin.Plugin{busyboxExtractor{}, busyboxDetector{}},
+		ScanRoots:    scalibrfs.RealFSScanRoots(root),
+		Capabilities: &plugin.Capabilities{OS: platform.OS(), DirectFS: true},
+	}
+	reusedPackages := 0
+	if inc != nil {
+		unchanged, reused := inc.root(0, os.DirFS(root))
+		cfg.ScanRoots = editListings(cfg.ScanRoots, skipFiles(unchanged))
+		carryPackages(&cfg, reused)
+		reusedPackages = len(reused)
+	}
+	r := scalibr.New().Scan(context.Background(), &cfg)
+	if r.Status.Status != plugin.ScanStatusSucceeded {
+		t.Fatalf("scan of %s failed: %s", root, r.Status.FailureReason)
+	}
+	dropCarriedStatus(r)
+	return r, reusedPackages
+}
+
+func TestIncrementalScanMatchesFullScan(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string, mtime time.Time) {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := time.Now().Add(-time.Hour)
+	write("bin/busybox", "BusyBox v1.35.0 (2022-01-17)", before)
+	write("sbin/busybox", "BusyBox v1.36.1 (2023-05-18)", before)
+	write("opt/tools/busybox", "BusyBox v1.34.1 (2021-09-30)", before)
+
+	first, _ := scanBusybox(t, root, nil)
+	prev := map[string]any{
+		"FileMetadata": statArtifacts(context.Background(), os.DirFS(root), &first.Inventory, root),
+	}
+	state, err := marshalInventory(&inventory.Inventory{Packages: first.Inventory.Packages})
+	if err != nil {
+		t.Fatalf("marshalInventory() error: %v", err)
+	}
+	prev["IncrementalState"] = state
+	data, err := json.Marshal(prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevPath := filepath.Join(t.TempDir(), "previous.json")
+	if err := os.WriteFile(prevPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upgrade one binary, downgrade another
+	write("bin/busybox", "BusyBox v1.36.1 (2023-05-18)", time.Now())
+	write("sbin/busybox", "BusyBox v1.33.2 (2021-12-01)", time.Now())
+
+	inc, err := loadPreviousResult(&scanOptions{PreviousResult: prevPath}, []string{root})
+	if err != nil {
+		t.Fatalf("loadPreviousResult() error: %v", err)
+	}
+	incremental, reused := scanBusybox(t, root, inc)
+	full, _ := scanBusybox(t, root, nil)
+
+	if reused != 1 {
+		t.Errorf("incremental scan reused %d packages, want 1", reused)
+	}
+	gotPkgs, gotVulns := summary(incremental)
+	wantPkgs, wantVulns := summary(full)
+	if !slices.Equal(gotPkgs, wantPkgs) {
+		t.Errorf("incremental scan packages = %v, want %v", gotPkgs, wantPkgs)
+	}
+	if !slices.Equal(gotVulns, wantVulns) {
+		t.Errorf("incremental scan vulnerabilities = %v, want %v", gotVulns, wantVulns)
+	}
+	// The carried package is the vulnerable opt/tools/busybox
+	if len(gotVulns) != 2 {
+		t.Errorf("incremental scan found %d vulnerable packages, want 2", len(gotVulns))
+	}
+	for _, s := range incremental.PluginStatus {
+		if s.Name == carriedName {
+			t.Errorf("result has the status of %s", carriedName)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/osv-scalibr/enricher"
+)
+
+// explicitInitRequired is set where hosts must call ScalibrInit before the
+// first scan. Windows runs DLL initialization under the loader lock, so
+// hosts that scan from DllMain or a thread it starts could deadlock if the
+// setup ran implicitly on first use.
+const explicitInitRequired = runtime.GOOS == "windows"
+
+var errNotInitialized = newScanError(statusNotInitialized, "ScalibrInit must be called before the first scan")
+
+var (
+	initOnce sync.Once
+	// Set once ScalibrInit has been called.
+	initialized atomic.Bool
+)
+
+// initialize performs the process-wide setup. It runs once, from ScalibrInit
+// or before the first scan, rather than from package initializers, which run
+// while the library is being loaded.
+func initialize() {
+	initOnce.Do(func() {
+		applyEnv()
+		// Installed before the first scan, since replacing
+		// http.DefaultTransport while other scans make requests is a data race
+		installTransport()
+		// Rewrite the packages before any enricher, vulnerability matching
+		// in particular, looks at them
+		enricher.EnricherOrder = slices.Insert(slices.Clone(enricher.EnricherOrder), 0, normalizeName)
+	})
+}
+
+// ensureInitialized runs the setup ahead of a scan. It fails if the host
+// must have called ScalibrInit but didn't.
+func ensureInitialized() error {
+	if explicitInitRequired && !initialized.Load() {
+		return errNotInitialized
+	}
+	initialize()
+	return nil
+}
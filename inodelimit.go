@@ -0,0 +1,39 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// inodeLimitInfo reports the scan root whose walk exceeded max_inodes.
+type inodeLimitInfo struct {
+	MaxInodes int
+	Root      string
+}
+
+// inodeLimitExceeded reports whether the walk behind r stopped at the
+// max_inodes limit. SCALIBR doesn't return a typed error for it, so the
+// failure reason is matched against the message of its filesystem walk.
+func inodeLimitExceeded(r *scalibr.ScanResult, maxInodes int) bool {
+	if maxInodes <= 0 || r.Status == nil || r.Status.Status != plugin.ScanStatusFailed {
+		return false
+	}
+	return strings.Contains(r.Status.FailureReason, fmt.Sprintf("maxInodes (%d) exceeded", maxInodes))
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/inventory"
+)
+
+// Values of the java_shaded_jars option.
+const (
+	// Report every artifact bundled into a shaded JAR (SCALIBR's behavior)
+	shadedJarsReport = "report"
+	// Only report the artifact a shaded JAR is named after
+	shadedJarsOwnerOnly = "owner_only"
+)
+
+// javaArchiveName is the name of SCALIBR's Java archive extractor.
+const javaArchiveName = "java/archive"
+
+// collapseShadedJars keeps only the owning artifact of every shaded JAR in
+// inv. A JAR counts as shaded when it directly contains the pom.properties
+// of several artifacts, and the owner is the artifact whose ID prefixes the
+// JAR's file name. JARs without a recognizable owner are left untouched.
+func collapseShadedJars(inv *inventory.Inventory) {
+	// Packages read from a pom.properties located directly in a JAR, by JAR
+	byJar := make(map[string][]*extractor.Package)
+	for _, pkg := range inv.Packages {
+		if isDirectPomPackage(pkg) {
+			byJar[pkg.Locations[0]] = append(byJar[pkg.Locations[0]], pkg)
+		}
+	}
+
+	dropped := make(map[*extractor.Package]bool)
+	for jar, pkgs := range byJar {
+		if len(pkgs) < 2 {
+			continue
+		}
+		base := strings.TrimSuffix(path.Base(filepath.ToSlash(jar)), path.Ext(jar))
+		var owner *extractor.Package
+		for _, pkg := range pkgs {
+			_, artifactID, _ := strings.Cut(pkg.Name, ":")
+			if artifactID != "" && strings.HasPrefix(base, artifactID) && (owner == nil || len(pkg.Name) > len(owner.Name)) {
+				owner = pkg
+			}
+		}
+		if owner == nil {
+			continue
+		}
+		for _, pkg := range pkgs {
+			if pkg != owner {
+				dropped[pkg] = true
+			}
+		}
+	}
+	if len(dropped) == 0 {
+		return
+	}
+
+	packages := inv.Packages[:0]
+	for _, pkg := range inv.Packages {
+		if !dropped[pkg] {
+			packages = append(packages, pkg)
+		}
+	}
+	inv.Packages = packages
+
+	vulns := inv.PackageVulns[:0]
+	for _, v := range inv.PackageVulns {
+		if v.Package != nil && dropped[v.Package] {
+			continue
+		}
+		vulns = append(vulns, v)
+	}
+	inv.PackageVulns = vulns
+}
+
+// isDirectPomPackage reports whether pkg was read by the Java archive
+// extractor from a pom.properties file directly inside the JAR at
+// Locations[0], rather than from a nested JAR.
+func isDirectPomPackage(pkg *extractor.Package) bool {
+	if len(pkg.Locations) != 2 || path.Base(filepath.ToSlash(pkg.Locations[1])) != "pom.properties" {
+		return false
+	}
+	return slices.Contains(pkg.Plugins, javaArchiveName)
+}
@@ -0,0 +1,123 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/osv-scalibr/log"
+)
+
+// persistedJob is the on-disk record of a job queued with ScalibrScanStart
+// while queue persistence is enabled. It is also the entry type of
+// ScalibrListPersistedJobs.
+type persistedJob struct {
+	ID int64
+	// "queued" or "running"
+	State string
+	// Whether the job was restored from a previous process
+	Resumed   bool `json:",omitempty"`
+	Submitted time.Time
+	Options   *scanOptions
+}
+
+// jobStore keeps one JSON file per persisted job in dir.
+type jobStore struct {
+	dir string
+}
+
+// defaultQueueDir returns the persisted queue directory used when the host
+// doesn't pick one.
+func defaultQueueDir() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "queue"), nil
+}
+
+func (st *jobStore) path(id int64) string {
+	return filepath.Join(st.dir, fmt.Sprintf("%d.json", id))
+}
+
+// save writes the record of j, logging failures since the job itself runs
+// regardless.
+func (st *jobStore) save(j *job) {
+	data, err := json.Marshal(j.record())
+	if err == nil {
+		err = writeFileAtomic(st.path(j.id), data)
+	}
+	if err != nil {
+		log.Warnf("failed to persist scan job %d: %v", j.id, err)
+	}
+}
+
+func (st *jobStore) remove(id int64) {
+	if err := os.Remove(st.path(id)); err != nil && !os.IsNotExist(err) {
+		log.Warnf("failed to remove persisted scan job %d: %v", id, err)
+	}
+}
+
+// load reads the records left by a previous process, oldest first, and
+// removes their files. Unreadable records are dropped.
+func (st *jobStore) load() ([]*persistedJob, error) {
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return nil, err
+	}
+	var records []*persistedJob
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(st.dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Warnf("failed to read persisted scan job %s: %v", e.Name(), err)
+			continue
+		}
+		os.Remove(path)
+		rec := &persistedJob{}
+		if err := json.Unmarshal(data, rec); err != nil || rec.Options == nil {
+			log.Warnf("dropping invalid persisted scan job %s", e.Name())
+			continue
+		}
+		records = append(records, rec)
+	}
+	slices.SortFunc(records, func(a, b *persistedJob) int {
+		return cmp.Or(a.Submitted.Compare(b.Submitted), cmp.Compare(a.ID, b.ID))
+	})
+	return records, nil
+}
+
+// clear removes every record in the store.
+func (st *jobStore) clear() {
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			os.Remove(filepath.Join(st.dir, e.Name()))
+		}
+	}
+}
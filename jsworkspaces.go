@@ -0,0 +1,326 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Values of the js_workspaces option.
+const (
+	// Report each package once per lockfile (SCALIBR's behavior)
+	jsWorkspacesHoisted = "hoisted"
+	// Additionally attribute each package to the workspaces depending on it
+	jsWorkspacesPerWorkspace = "per_workspace"
+)
+
+// jsWorkspaceInfo describes a workspace found in a monorepo lockfile.
+type jsWorkspaceInfo struct {
+	// Lockfile location relative to the scan root
+	Lockfile string
+	// Workspace directory relative to the scan root
+	Path string
+	Name string `json:",omitempty"`
+}
+
+// jsLockfileExtractors maps the lockfile extractors supporting workspace
+// attribution to the function computing package ownership.
+var jsLockfileExtractors = map[string]func(data []byte) (map[string][]string, map[string]string, error){
+	"javascript/packagelockjson": npmLockOwners,
+	"javascript/pnpmlock":        pnpmLockOwners,
+}
+
+// attributeJSWorkspaces adds the package.json of every workspace depending
+// on a package to the package's locations, so results can be split by
+// workspace. It returns the workspaces found.
+func attributeJSWorkspaces(inv *inventory.Inventory, root string) []jsWorkspaceInfo {
+	// Lockfiles by location, with the extractor that read them
+	lockfiles := map[string]string{}
+	for _, pkg := range inv.Packages {
+		for _, p := range pkg.Plugins {
+			if jsLockfileExtractors[p] != nil && len(pkg.Locations) > 0 {
+				lockfiles[pkg.Locations[0]] = p
+			}
+		}
+	}
+
+	var workspaces []jsWorkspaceInfo
+	for _, loc := range slices.Sorted(maps.Keys(lockfiles)) {
+		lockfile := normalizeLocation(root, loc)
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(lockfile)))
+		if err != nil {
+			log.Warnf("workspace attribution: %v", err)
+			continue
+		}
+		owners, names, err := jsLockfileExtractors[lockfiles[loc]](data)
+		if err != nil {
+			log.Warnf("workspace attribution: failed to parse %s: %v", lockfile, err)
+			continue
+		}
+		if len(names) < 2 {
+			// Not a monorepo
+			continue
+		}
+
+		dir := path.Dir(lockfile)
+		manifest := func(ws string) string {
+			return path.Join(dir, ws, "package.json")
+		}
+		for _, ws := range slices.Sorted(maps.Keys(names)) {
+			workspaces = append(workspaces, jsWorkspaceInfo{Lockfile: lockfile, Path: path.Join(dir, ws), Name: names[ws]})
+		}
+		for _, pkg := range inv.Packages {
+			if len(pkg.Locations) == 0 || pkg.Locations[0] != loc {
+				continue
+			}
+			for _, ws := range owners[pkg.Name+"@"+pkg.Version] {
+				pkg.Locations = append(pkg.Locations, manifest(ws))
+			}
+		}
+	}
+	return workspaces
+}
+
+// npmLockEntry is an entry of the "packages" section of a package-lock.json.
+type npmLockEntry struct {
+	Name                 string
+	Version              string
+	Resolved             string
+	Link                 bool
+	Dependencies         map[string]string
+	DevDependencies      map[string]string
+	OptionalDependencies map[string]string
+	PeerDependencies     map[string]string
+}
+
+func (e *npmLockEntry) dependencyNames() []string {
+	var names []string
+	for _, deps := range []map[string]string{e.Dependencies, e.DevDependencies, e.OptionalDependencies, e.PeerDependencies} {
+		for name := range deps {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// npmLockOwners computes, for a v2/v3 package-lock.json, the workspaces
+// ("." for the root) owning each "name@version" as well as the workspace
+// names. Dependencies are resolved the way Node does, from the nearest
+// node_modules directory upwards.
+func npmLockOwners(data []byte) (map[string][]string, map[string]string, error) {
+	var lock struct {
+		Packages map[string]*npmLockEntry
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, nil, err
+	}
+
+	names := map[string]string{}
+	for key, e := range lock.Packages {
+		if e != nil && !e.Link && !strings.Contains(key, "node_modules/") {
+			ws := key
+			if ws == "" {
+				ws = "."
+			}
+			names[ws] = e.Name
+		}
+	}
+
+	resolve := func(from, dep string) string {
+		for p := from; ; p = npmParentDir(p) {
+			key := strings.TrimPrefix(p+"/node_modules/"+dep, "/")
+			if e, ok := lock.Packages[key]; ok && e != nil {
+				if e.Link {
+					return e.Resolved
+				}
+				return key
+			}
+			if p == "" {
+				return ""
+			}
+		}
+	}
+
+	owners := map[string][]string{}
+	for _, ws := range slices.Sorted(maps.Keys(names)) {
+		start := ws
+		if start == "." {
+			start = ""
+		}
+		seen := map[string]bool{start: true}
+		queue := []string{start}
+		for len(queue) > 0 {
+			key := queue[0]
+			queue = queue[1:]
+			e := lock.Packages[key]
+			if e == nil {
+				continue
+			}
+			for _, dep := range e.dependencyNames() {
+				next := resolve(key, dep)
+				if next == "" || seen[next] {
+					continue
+				}
+				seen[next] = true
+				queue = append(queue, next)
+				if d := lock.Packages[next]; d != nil && strings.Contains(next, "node_modules/") {
+					id := npmPackageName(next, d) + "@" + d.Version
+					if !slices.Contains(owners[id], ws) {
+						owners[id] = append(owners[id], ws)
+					}
+				}
+			}
+		}
+	}
+	return owners, names, nil
+}
+
+// npmParentDir returns the directory whose node_modules is searched after
+// the one of the package at key.
+func npmParentDir(key string) string {
+	if i := strings.LastIndex(key, "/node_modules/"); i >= 0 {
+		return key[:i]
+	}
+	// Top-level packages and workspace directories resolve from the root
+	return ""
+}
+
+// npmPackageName returns the name of the package installed at key.
+func npmPackageName(key string, e *npmLockEntry) string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return key[strings.LastIndex(key, "node_modules/")+len("node_modules/"):]
+}
+
+// pnpmDependency is a dependency of a pnpm importer or package, written
+// either as a version or, since lockfile v6, as a specifier and version.
+type pnpmDependency struct {
+	Version string
+}
+
+func (d *pnpmDependency) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		d.Version = node.Value
+		return nil
+	}
+	var v struct {
+		Version string `yaml:"version"`
+	}
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	d.Version = v.Version
+	return nil
+}
+
+type pnpmDependencies struct {
+	Dependencies         map[string]pnpmDependency `yaml:"dependencies"`
+	DevDependencies      map[string]pnpmDependency `yaml:"devDependencies"`
+	OptionalDependencies map[string]pnpmDependency `yaml:"optionalDependencies"`
+}
+
+func (d *pnpmDependencies) all() map[string]string {
+	all := map[string]string{}
+	for _, deps := range []map[string]pnpmDependency{d.Dependencies, d.DevDependencies, d.OptionalDependencies} {
+		for name, dep := range deps {
+			all[name] = dep.Version
+		}
+	}
+	return all
+}
+
+// pnpmLockOwners computes the workspace ownership of packages for a
+// pnpm-lock.yaml from its importers. Package dependencies are read from the
+// "snapshots" section (lockfile v9) or the "packages" section (older
+// versions).
+func pnpmLockOwners(data []byte) (map[string][]string, map[string]string, error) {
+	var lock struct {
+		Importers map[string]*pnpmDependencies `yaml:"importers"`
+		Packages  map[string]*pnpmDependencies `yaml:"packages"`
+		Snapshots map[string]*pnpmDependencies `yaml:"snapshots"`
+	}
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, nil, err
+	}
+
+	names := map[string]string{}
+	for ws := range lock.Importers {
+		names[ws] = ""
+	}
+	lookup := func(name, version string) *pnpmDependencies {
+		for _, key := range []string{name + "@" + version, "/" + name + "@" + version, "/" + name + "/" + version} {
+			if d := lock.Snapshots[key]; d != nil {
+				return d
+			}
+			if d := lock.Packages[key]; d != nil {
+				return d
+			}
+		}
+		return nil
+	}
+
+	owners := map[string][]string{}
+	for _, ws := range slices.Sorted(maps.Keys(lock.Importers)) {
+		type pending struct{ importer, name, version string }
+		var queue []pending
+		enqueue := func(importer string, deps map[string]string) {
+			for _, name := range slices.Sorted(maps.Keys(deps)) {
+				queue = append(queue, pending{importer, name, deps[name]})
+			}
+		}
+		enqueue(ws, lock.Importers[ws].all())
+		seen := map[string]bool{}
+		for len(queue) > 0 {
+			p := queue[0]
+			queue = queue[1:]
+			if target, ok := strings.CutPrefix(p.version, "link:"); ok {
+				// Dependency on another workspace
+				other := path.Clean(path.Join(p.importer, target))
+				if d := lock.Importers[other]; d != nil && !seen["link:"+other] {
+					seen["link:"+other] = true
+					enqueue(other, d.all())
+				}
+				continue
+			}
+			id := p.name + "@" + p.version
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			// Peer dependency suffixes like "1.0.0(react@18.2.0)" aren't
+			// part of the reported version
+			version, _, _ := strings.Cut(p.version, "(")
+			if pkgID := p.name + "@" + version; !slices.Contains(owners[pkgID], ws) {
+				owners[pkgID] = append(owners[pkgID], ws)
+			}
+			if d := lookup(p.name, p.version); d != nil {
+				enqueue(p.importer, d.all())
+			}
+		}
+	}
+	return owners, names, nil
+}
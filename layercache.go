@@ -0,0 +1,594 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/artifact/image/layerscanning/image"
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/log"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// layerCacheVersion is bumped when the layout of layerCacheEntry changes.
+// Entries of other versions are ignored.
+const layerCacheVersion = 1
+
+// layerCacheEntry is the cached extraction result of an image's filesystem
+// up to one of its layers, stored as JSON in layerCacheDir.
+type layerCacheEntry struct {
+	Version int
+	ChainID string
+	// The packages found in the files that no later layer of the scanned
+	// image changed, in marshalInventory form, and the ChainID of the layer
+	// each was attributed to
+	Inventory []byte
+	Layers    []string
+	// Files that later layers changed or that produced secrets, whose
+	// packages the entry doesn't hold
+	Holes []string
+}
+
+// layerCacheInfo describes an entry for ScalibrListLayerCache.
+type layerCacheInfo struct {
+	ChainID  string
+	Packages int
+	Size     int64
+	LastUsed time.Time
+}
+
+// layerCacheUsage tells what a scan reused from the layer cache.
+type layerCacheUsage struct {
+	// The highest layer of the image whose result was cached
+	ChainID string
+	// Packages carried forward from its entry
+	ReusedPackages int
+	// Files extracted again since later layers changed them
+	ExtractedFiles int
+}
+
+// layerCacheDir returns the directory of the layer result cache.
+func layerCacheDir() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "layers"), nil
+}
+
+// imageLayer is a layer of a container image and the paths it changes,
+// slash-separated and relative to the image root.
+type imageLayer struct {
+	chainID string
+	// Regular files the layer adds or replaces
+	files []string
+	// Paths the layer deletes or replaces with something other than a
+	// regular file, including everything below them
+	removed []string
+}
+
+// readImageLayers lists the changes of every layer of img, comparing the
+// filesystem each layer leaves with the one before it. img is unpacked
+// already, so no layer is read from its blob again.
+func readImageLayers(img *image.Image) ([]imageLayer, error) {
+	chain, err := img.ChainLayers()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]imageLayer, 0, len(chain))
+	prev := map[string]bool{}
+	for _, cl := range chain {
+		files, err := regularFiles(cl.FS())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the files of layer %s: %w", cl.ChainID(), err)
+		}
+		own, err := regularFiles(cl.Layer().FS())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the files of layer %s: %w", cl.ChainID(), err)
+		}
+		l := layerChanges(prev, files, own)
+		l.chainID = cl.ChainID().String()
+		out = append(out, l)
+		prev = files
+	}
+	return out, nil
+}
+
+// regularFiles returns the regular files of fsys by slash-separated path.
+func regularFiles(fsys fs.FS) (map[string]bool, error) {
+	files := map[string]bool{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files[p] = true
+		}
+		return nil
+	})
+	return files, err
+}
+
+// layerChanges returns the changes of a layer from the regular files of the
+// filesystems before and after it, and own, the files of the layer itself.
+// Whiteout files are among own but not among after, and are left out.
+func layerChanges(before, after, own map[string]bool) imageLayer {
+	var l imageLayer
+	for f := range own {
+		if after[f] {
+			l.files = append(l.files, f)
+		}
+	}
+	for f := range before {
+		if !after[f] {
+			l.removed = append(l.removed, f)
+		}
+	}
+	slices.Sort(l.files)
+	slices.Sort(l.removed)
+	return l
+}
+
+// pathSet is a set of slash-separated paths that also covers everything
+// below them.
+type pathSet map[string]bool
+
+func (s pathSet) covers(p string) bool {
+	for ; ; p = path.Dir(p) {
+		if s[p] {
+			return true
+		}
+		if p == "." || p == "/" {
+			return false
+		}
+	}
+}
+
+// changedAbove returns the paths the layers above layers[top] change.
+func changedAbove(layers []imageLayer, top int) pathSet {
+	s := pathSet{}
+	for _, l := range layers[top+1:] {
+		for _, p := range l.files {
+			s[p] = true
+		}
+		for _, p := range l.removed {
+			s[p] = true
+		}
+	}
+	return s
+}
+
+// applyLayer updates the regular files of a filesystem, files, with the
+// changes of l.
+func applyLayer(files map[string]bool, l imageLayer) {
+	if len(l.removed) > 0 {
+		removed := pathSet{}
+		for _, p := range l.removed {
+			removed[p] = true
+		}
+		for f := range files {
+			if removed.covers(f) {
+				delete(files, f)
+			}
+		}
+	}
+	for _, f := range l.files {
+		files[f] = true
+	}
+}
+
+// cachedPackage is a package of an entry and the locations of the files it
+// was found in.
+type cachedPackage struct {
+	pkg     *extractor.Package
+	chainID string
+	files   []string
+}
+
+// packageFiles maps the locations of p to files of the image. A location
+// not naming a file, e.g. that of a package inside an archive, is mapped to
+// the file before its first colon if there is one.
+func packageFiles(p *extractor.Package, files map[string]bool) []string {
+	out := make([]string, 0, len(p.Locations))
+	for _, loc := range p.Locations {
+		loc = cmp.Or(strings.Trim(loc, "/"), ".")
+		if !files[loc] {
+			if before, _, ok := strings.Cut(loc, ":"); ok && files[before] {
+				loc = before
+			}
+		}
+		out = append(out, loc)
+	}
+	return out
+}
+
+// settleHoles drops the packages of pkgs found in a file covered by holes,
+// adding their other files to holes, since the files are extracted again and
+// report them anew. It returns the packages left.
+func settleHoles(pkgs []cachedPackage, holes pathSet) []cachedPackage {
+	for changed := true; changed; {
+		changed = false
+		kept := pkgs[:0]
+		for _, cp := range pkgs {
+			if !slices.ContainsFunc(cp.files, holes.covers) {
+				kept = append(kept, cp)
+				continue
+			}
+			for _, f := range cp.files {
+				if !holes[f] {
+					holes[f] = true
+					changed = true
+				}
+			}
+		}
+		pkgs = kept
+	}
+	return pkgs
+}
+
+// layerResultCache reuses and records the extraction results of the layers
+// of the scanned image.
+type layerResultCache struct {
+	dir    string
+	key    string
+	layers []imageLayer
+	// The packages reuse carried forward
+	reused []cachedPackage
+	usage  *layerCacheUsage
+}
+
+// newLayerResultCache returns the layer cache of a scan of img with the
+// given plugins. Entries are only shared by scans whose extraction results
+// can't differ: the library version, plugins and the options affecting
+// extraction must match.
+func newLayerResultCache(img *image.Image, opts *scanOptions, plugins []plugin.Plugin, fileSizes map[string]int) (*layerResultCache, error) {
+	dir, err := layerCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := readImageLayers(img)
+	if err != nil {
+		return nil, err
+	}
+	v := currentVersion()
+	cfg := struct {
+		Scalibr, Bindings, Revision string
+		Plugins                     []string
+		MaxFileSize                 int
+		FileSizes                   map[string]int
+		DirsToSkip                  []string
+		PluginConfig                map[string]any
+		PluginOptions               map[string]map[string]any
+		BinaryAnalyses              []string
+		PythonRequirements          string
+		PackageRewrites             []packageRewrite
+		Offline                     bool
+	}{
+		Scalibr:            v.Scalibr,
+		Bindings:           v.Bindings,
+		Revision:           v.Revision,
+		MaxFileSize:        opts.MaxFileSize,
+		FileSizes:          fileSizes,
+		DirsToSkip:         opts.DirsToSkip,
+		PluginConfig:       opts.PluginConfig,
+		PluginOptions:      opts.PluginOptions,
+		BinaryAnalyses:     opts.BinaryAnalyses,
+		PythonRequirements: opts.PythonRequirements,
+		PackageRewrites:    opts.PackageRewrites,
+		Offline:            opts.Offline,
+	}
+	for _, p := range plugins {
+		cfg.Plugins = append(cfg.Plugins, fmt.Sprintf("%s@%d", p.Name(), p.Version()))
+	}
+	slices.Sort(cfg.Plugins)
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return &layerResultCache{dir: dir, key: hex.EncodeToString(sum[:8]), layers: layers}, nil
+}
+
+// entryPath returns the file of the entry of the layer with chainID.
+func (c *layerResultCache) entryPath(chainID string) string {
+	return filepath.Join(c.dir, c.key+"-"+strings.ReplaceAll(chainID, ":", "-")+".json")
+}
+
+// load reads the entry of the layer with chainID and marks it as used.
+func (c *layerResultCache) load(chainID string) (*layerCacheEntry, *inventory.Inventory, error) {
+	p := c.entryPath(chainID)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	e := &layerCacheEntry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, nil, err
+	}
+	if e.Version != layerCacheVersion || e.ChainID != chainID {
+		return nil, nil, fmt.Errorf("entry %s is of another version or layer", p)
+	}
+	inv, err := unmarshalInventory(e.Inventory)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(inv.Packages) != len(e.Layers) {
+		return nil, nil, fmt.Errorf("entry %s is inconsistent", p)
+	}
+	now := time.Now()
+	if err := os.Chtimes(p, now, now); err != nil {
+		log.Debugf("failed to mark layer cache entry %s as used: %v", p, err)
+	}
+	return e, inv, nil
+}
+
+// reuse looks up the highest layer of the image with a cached entry and
+// returns the files to extract: those that later layers change and the
+// entry's holes. The packages of the other files are carried forward. It
+// returns nil, for a full scan, if no layer is cached. skipDirs are the
+// image's dirs_to_skip.
+func (c *layerResultCache) reuse(skipDirs []string) []string {
+	for top := len(c.layers) - 1; top >= 0; top-- {
+		e, inv, err := c.load(c.layers[top].chainID)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			log.Warnf("ignoring layer cache entry: %v", err)
+			continue
+		}
+		files := map[string]bool{}
+		for _, l := range c.layers {
+			applyLayer(files, l)
+		}
+		holes := changedAbove(c.layers, top)
+		for _, h := range e.Holes {
+			holes[h] = true
+		}
+		pkgs := make([]cachedPackage, 0, len(inv.Packages))
+		for i, p := range inv.Packages {
+			pkgs = append(pkgs, cachedPackage{pkg: p, chainID: e.Layers[i], files: packageFiles(p, files)})
+		}
+		pkgs = settleHoles(pkgs, holes)
+		extract := filesIn(files, holes, skipDirs)
+		if len(extract) == 0 && len(pkgs) > 0 {
+			// Without paths to extract, SCALIBR walks the whole image, so
+			// at least one file is extracted again
+			for _, f := range pkgs[0].files {
+				holes[f] = true
+			}
+			pkgs = settleHoles(pkgs, holes)
+			extract = filesIn(files, holes, skipDirs)
+		}
+		if len(extract) == 0 {
+			return nil
+		}
+		c.reused = pkgs
+		c.usage = &layerCacheUsage{ChainID: e.ChainID, ReusedPackages: len(pkgs), ExtractedFiles: len(extract)}
+		return extract
+	}
+	return nil
+}
+
+// filesIn returns the sorted files of files covered by holes, leaving out
+// those in skipDirs.
+func filesIn(files map[string]bool, holes pathSet, skipDirs []string) []string {
+	skip := pathSet{}
+	for _, d := range skipDirs {
+		skip[cmp.Or(d, ".")] = true
+	}
+	var out []string
+	for f := range files {
+		if holes.covers(f) && !skip.covers(f) {
+			out = append(out, f)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// reusedPackages returns the packages reuse carried forward, for the scan
+// to report along with the packages it extracts.
+func (c *layerResultCache) reusedPackages() []*extractor.Package {
+	out := make([]*extractor.Package, 0, len(c.reused))
+	for _, cp := range c.reused {
+		out = append(out, cp.pkg)
+	}
+	return out
+}
+
+// attributeReused attributes the packages reuse carried forward to the
+// layers of inv, the inventory of the image's scan, as the entry records
+// them.
+func (c *layerResultCache) attributeReused(inv *inventory.Inventory) {
+	layers := map[string]*extractor.LayerMetadata{}
+	for _, cim := range inv.ContainerImageMetadata {
+		for _, l := range cim.LayerMetadata {
+			layers[l.ChainID.String()] = l
+		}
+	}
+	for _, cp := range c.reused {
+		cp.pkg.LayerMetadata = layers[cp.chainID]
+	}
+}
+
+// store records an entry for every layer of the image from r, the result of
+// its scan. Results of scans that didn't finish, or in which a plugin
+// failed, could lack packages and aren't recorded.
+func (c *layerResultCache) store(ctx context.Context, r *scalibr.ScanResult) {
+	if ctx.Err() != nil || r.Status == nil || r.Status.Status != plugin.ScanStatusSucceeded {
+		return
+	}
+	for _, s := range r.PluginStatus {
+		if s.Status == nil || s.Status.Status != plugin.ScanStatusSucceeded {
+			return
+		}
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		log.Warnf("failed to create the layer cache: %v", err)
+		return
+	}
+	files := map[string]bool{}
+	for _, l := range c.layers {
+		applyLayer(files, l)
+	}
+	all := make([]cachedPackage, 0, len(r.Inventory.Packages))
+	for _, p := range r.Inventory.Packages {
+		if len(p.Locations) == 0 {
+			continue
+		}
+		cp := cachedPackage{pkg: p, files: packageFiles(p, files)}
+		if p.LayerMetadata != nil {
+			cp.chainID = p.LayerMetadata.ChainID.String()
+		}
+		all = append(all, cp)
+	}
+	present := map[string]bool{}
+	for top, l := range c.layers {
+		applyLayer(present, l)
+		p := c.entryPath(l.chainID)
+		if _, err := os.Stat(p); err == nil {
+			continue
+		}
+		// Files that later layers change are extracted again, along with
+		// the files that produced secrets, which aren't cached
+		holes := changedAbove(c.layers, top)
+		for _, s := range r.Inventory.Secrets {
+			holes[cmp.Or(strings.Trim(s.Location, "/"), ".")] = true
+		}
+		pkgs := settleHoles(slices.Clone(all), holes)
+		e := layerCacheEntry{Version: layerCacheVersion, ChainID: l.chainID, Holes: []string{}}
+		entryInv := &inventory.Inventory{}
+		for _, cp := range pkgs {
+			pkg := *cp.pkg
+			pkg.LayerMetadata = nil
+			entryInv.Packages = append(entryInv.Packages, &pkg)
+			e.Layers = append(e.Layers, cp.chainID)
+		}
+		// Only the files the layer's filesystem has matter to other images
+		for f := range present {
+			if holes.covers(f) {
+				e.Holes = append(e.Holes, f)
+			}
+		}
+		slices.Sort(e.Holes)
+		var err error
+		if e.Inventory, err = marshalInventory(entryInv); err != nil {
+			log.Warnf("failed to encode layer cache entry: %v", err)
+			return
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			log.Warnf("failed to encode layer cache entry: %v", err)
+			return
+		}
+		if err := writeFileAtomicMode(p, data, 0o600); err != nil {
+			log.Warnf("failed to write layer cache entry: %v", err)
+			return
+		}
+	}
+}
+
+// layerCacheEntries returns the entries in the layer cache, each with the
+// path of its file.
+func layerCacheEntries() (map[string]layerCacheInfo, error) {
+	dir, err := layerCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]layerCacheInfo{}
+	for _, de := range dirEntries {
+		if !de.Type().IsRegular() || strings.HasPrefix(de.Name(), ".") || !strings.HasSuffix(de.Name(), ".json") {
+			continue
+		}
+		p := filepath.Join(dir, de.Name())
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		var e layerCacheEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			log.Warnf("ignoring unreadable layer cache entry %s: %v", p, err)
+			continue
+		}
+		out[p] = layerCacheInfo{ChainID: e.ChainID, Packages: len(e.Layers), Size: info.Size(), LastUsed: info.ModTime()}
+	}
+	return out, nil
+}
+
+// listLayerCache returns the entries of the layer cache, most recently used
+// first.
+func listLayerCache() []layerCacheInfo {
+	entries, err := layerCacheEntries()
+	if err != nil {
+		log.Warnf("failed to list the layer cache: %v", err)
+	}
+	out := make([]layerCacheInfo, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e)
+	}
+	slices.SortFunc(out, func(a, b layerCacheInfo) int {
+		return cmp.Or(b.LastUsed.Compare(a.LastUsed), strings.Compare(a.ChainID, b.ChainID))
+	})
+	return out
+}
+
+// purgeLayerCache deletes the entries of the layer with chainID, or all
+// entries if chainID is empty, and returns how many it deleted.
+func purgeLayerCache(chainID string) int {
+	entries, err := layerCacheEntries()
+	if err != nil {
+		log.Warnf("failed to list the layer cache: %v", err)
+	}
+	n := 0
+	for p, e := range entries {
+		if chainID != "" && e.ChainID != chainID {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			log.Warnf("failed to delete layer cache entry %s: %v", p, err)
+			continue
+		}
+		n++
+	}
+	return n
+}
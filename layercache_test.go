@@ -0,0 +1,216 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+func TestLayerChanges(t *testing.T) {
+	before := map[string]bool{"etc/os-release": true, "opt/app/old.jar": true, "usr/bin/app": true}
+	after := map[string]bool{"etc/os-release": true, "usr/bin/app": true, "opt/app/app.jar": true}
+	// The layer replaces usr/bin/app, deletes opt/app/old.jar through a
+	// whiteout and adds opt/app/app.jar
+	own := map[string]bool{"usr/bin/app": true, "opt/app/.wh.old.jar": true, "opt/app/app.jar": true}
+	l := layerChanges(before, after, own)
+	if want := []string{"opt/app/app.jar", "usr/bin/app"}; !slices.Equal(l.files, want) {
+		t.Errorf("layerChanges() files = %v, want %v", l.files, want)
+	}
+	if want := []string{"opt/app/old.jar"}; !slices.Equal(l.removed, want) {
+		t.Errorf("layerChanges() removed = %v, want %v", l.removed, want)
+	}
+}
+
+func TestRegularFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/os-release":  {},
+		"usr/bin/app":     {},
+		"usr/lib/libc.so": {Mode: fs.ModeSymlink},
+		"var/empty":       {Mode: fs.ModeDir},
+	}
+	files, err := regularFiles(fsys)
+	if err != nil {
+		t.Fatalf("regularFiles() error: %v", err)
+	}
+	want := map[string]bool{"etc/os-release": true, "usr/bin/app": true}
+	if !maps.Equal(files, want) {
+		t.Errorf("regularFiles() = %v, want %v", files, want)
+	}
+}
+
+func TestPathSetCovers(t *testing.T) {
+	s := pathSet{"usr/lib": true, "etc/passwd": true}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "usr/lib", want: true},
+		{path: "usr/lib/python3/site.py", want: true},
+		{path: "usr/libexec/x", want: false},
+		{path: "etc/passwd", want: true},
+		{path: "etc", want: false},
+		{path: ".", want: false},
+	}
+	for _, tc := range tests {
+		if got := s.covers(tc.path); got != tc.want {
+			t.Errorf("covers(%q) = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+	if !(pathSet{".": true}).covers("usr/bin/app") {
+		t.Errorf("covers() of the root = false, want true")
+	}
+}
+
+func TestLayerFiles(t *testing.T) {
+	layers := []imageLayer{
+		{files: []string{"etc/os-release", "var/lib/dpkg/status", "opt/app/old.jar"}},
+		{files: []string{"var/lib/dpkg/status", "usr/bin/curl"}},
+		{files: []string{"opt/app/app.jar"}, removed: []string{"opt/app"}},
+	}
+	files := map[string]bool{}
+	for _, l := range layers {
+		applyLayer(files, l)
+	}
+	want := []string{"etc/os-release", "opt/app/app.jar", "usr/bin/curl", "var/lib/dpkg/status"}
+	if got := filesIn(files, pathSet{".": true}, nil); !slices.Equal(got, want) {
+		t.Errorf("files after all layers = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		top      int
+		skipDirs []string
+		want     []string
+	}{
+		{name: "top layer", top: 2, want: nil},
+		{name: "below the top layer", top: 1, want: []string{"opt/app/app.jar"}},
+		{name: "base layer", top: 0, want: []string{"opt/app/app.jar", "usr/bin/curl", "var/lib/dpkg/status"}},
+		{name: "skipped dir", top: 0, skipDirs: []string{"usr"}, want: []string{"opt/app/app.jar", "var/lib/dpkg/status"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := filesIn(files, changedAbove(layers, tc.top), tc.skipDirs)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("files changed above layer %d = %v, want %v", tc.top, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSettleHoles(t *testing.T) {
+	pkgs := []cachedPackage{
+		{pkg: &extractor.Package{Name: "a"}, files: []string{"x"}},
+		// Found in x and y, so y is extracted again too, and with it c
+		{pkg: &extractor.Package{Name: "b"}, files: []string{"x", "y"}},
+		{pkg: &extractor.Package{Name: "c"}, files: []string{"y"}},
+		{pkg: &extractor.Package{Name: "d"}, files: []string{"dir/z"}},
+		{pkg: &extractor.Package{Name: "e"}, files: []string{"w"}},
+	}
+	holes := pathSet{"x": true, "dir": true}
+	var got []string
+	for _, cp := range settleHoles(pkgs, holes) {
+		got = append(got, cp.pkg.Name)
+	}
+	if want := []string{"e"}; !slices.Equal(got, want) {
+		t.Errorf("settleHoles() kept %v, want %v", got, want)
+	}
+	want := pathSet{"x": true, "y": true, "dir": true, "dir/z": true}
+	if !maps.Equal(holes, want) {
+		t.Errorf("holes after settleHoles() = %v, want %v", holes, want)
+	}
+}
+
+// succeeded returns a successful scan result with the given packages.
+func succeeded(pkgs ...*extractor.Package) *scalibr.ScanResult {
+	r := &scalibr.ScanResult{Status: &plugin.ScanStatus{Status: plugin.ScanStatusSucceeded}}
+	r.Inventory.Packages = pkgs
+	return r
+}
+
+func TestLayerResultCacheStoreAndReuse(t *testing.T) {
+	dir := t.TempDir()
+	base := imageLayer{chainID: "sha256:0a", files: []string{"var/lib/dpkg/status", "usr/lib/python3/six.py"}}
+	app := imageLayer{chainID: "sha256:1b", files: []string{"opt/app/app.jar"}}
+
+	first := &layerResultCache{dir: dir, key: "k", layers: []imageLayer{base, app}}
+	first.store(context.Background(), succeeded(
+		&extractor.Package{Name: "libc6", PURLType: "deb", Locations: []string{"var/lib/dpkg/status"}},
+		&extractor.Package{Name: "six", PURLType: "pypi", Locations: []string{"usr/lib/python3/six.py"}},
+		&extractor.Package{Name: "app", PURLType: "maven", Locations: []string{"opt/app/app.jar"}},
+	))
+	for _, l := range first.layers {
+		if _, err := os.Stat(first.entryPath(l.chainID)); err != nil {
+			t.Errorf("entry of layer %s not stored: %v", l.chainID, err)
+		}
+	}
+
+	// Another image on the same base that upgrades a Debian package
+	upgrade := imageLayer{chainID: "sha256:2c", files: []string{"var/lib/dpkg/status", "usr/bin/curl"}}
+	second := &layerResultCache{dir: dir, key: "k", layers: []imageLayer{base, upgrade}}
+	extract := second.reuse(nil)
+	if want := []string{"usr/bin/curl", "var/lib/dpkg/status"}; !slices.Equal(extract, want) {
+		t.Errorf("reuse() = %v, want %v", extract, want)
+	}
+	var names []string
+	for _, p := range second.reusedPackages() {
+		names = append(names, p.Name)
+	}
+	if want := []string{"six"}; !slices.Equal(names, want) {
+		t.Errorf("reusedPackages() = %v, want %v", names, want)
+	}
+	wantUsage := layerCacheUsage{ChainID: base.chainID, ReusedPackages: 1, ExtractedFiles: 2}
+	if second.usage == nil || *second.usage != wantUsage {
+		t.Errorf("reuse() usage = %+v, want %+v", second.usage, wantUsage)
+	}
+
+	// A different configuration doesn't share entries
+	other := &layerResultCache{dir: dir, key: "other", layers: []imageLayer{base, upgrade}}
+	if extract := other.reuse(nil); extract != nil {
+		t.Errorf("reuse() with another key = %v, want a full scan", extract)
+	}
+}
+
+func TestLayerResultCacheStoreSkipsFailedScans(t *testing.T) {
+	dir := t.TempDir()
+	c := &layerResultCache{dir: dir, key: "k", layers: []imageLayer{{chainID: "sha256:0a", files: []string{"a"}}}}
+	pkg := &extractor.Package{Name: "a", PURLType: "generic", Locations: []string{"a"}}
+
+	failed := succeeded(pkg)
+	failed.Status.Status = plugin.ScanStatusFailed
+	c.store(context.Background(), failed)
+
+	pluginFailed := succeeded(pkg)
+	pluginFailed.PluginStatus = []*plugin.Status{{Name: "x", Status: &plugin.ScanStatus{Status: plugin.ScanStatusFailed}}}
+	c.store(context.Background(), pluginFailed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.store(ctx, succeeded(pkg))
+
+	if entries, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(entries) != 0 {
+		t.Errorf("store() wrote %v for incomplete scans, want nothing", entries)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/inventory"
+)
+
+// packageLayer attributes a package found in a container image to the layer
+// that introduced it.
+type packageLayer struct {
+	Package   string
+	Version   string
+	PURL      string `json:",omitempty"`
+	Locations []string
+	// Position of the layer in the image, 0 for the bottom layer
+	LayerIndex int
+	DiffID     string
+	ChainID    string
+	// Instruction the layer was built by, e.g. "RUN apt-get install ..."
+	Command string `json:",omitempty"`
+	// Repository of the base image the layer is part of
+	BaseImage string `json:",omitempty"`
+	// Vulnerabilities found in the package
+	VulnIDs []string `json:",omitempty"`
+}
+
+// attributeLayers returns the layer of every package of inv that SCALIBR
+// traced to one, in layer order.
+func attributeLayers(inv *inventory.Inventory) []packageLayer {
+	vulns := map[*extractor.Package][]string{}
+	for _, v := range inv.PackageVulns {
+		if id := vulnID(v); id != "" && v.Package != nil && !slices.Contains(vulns[v.Package], id) {
+			vulns[v.Package] = append(vulns[v.Package], id)
+		}
+	}
+	var layers []packageLayer
+	for _, pkg := range inv.Packages {
+		l := pkg.LayerMetadata
+		if l == nil {
+			continue
+		}
+		pl := packageLayer{
+			Package:    pkg.Name,
+			Version:    pkg.Version,
+			Locations:  pkg.Locations,
+			LayerIndex: l.Index,
+			DiffID:     l.DiffID.String(),
+			ChainID:    l.ChainID.String(),
+			Command:    l.Command,
+			BaseImage:  baseImageName(inv, l),
+			VulnIDs:    vulns[pkg],
+		}
+		if p := pkg.PURL(); p != nil {
+			pl.PURL = p.String()
+		}
+		slices.Sort(pl.VulnIDs)
+		layers = append(layers, pl)
+	}
+	slices.SortStableFunc(layers, func(a, b packageLayer) int {
+		return cmp.Or(a.LayerIndex-b.LayerIndex, cmp.Compare(a.Package, b.Package))
+	})
+	return layers
+}
+
+// baseImageName returns the repository of the base image layer l belongs to,
+// or "" if it isn't part of a known base image. The layers no longer refer
+// to their image, see detachLayerParents, so it's looked up by the layer.
+func baseImageName(inv *inventory.Inventory, l *extractor.LayerMetadata) string {
+	if l.BaseImageIndex <= 0 {
+		return ""
+	}
+	for _, cim := range inv.ContainerImageMetadata {
+		if !slices.Contains(cim.LayerMetadata, l) || l.BaseImageIndex >= len(cim.BaseImages) {
+			continue
+		}
+		for _, b := range cim.BaseImages[l.BaseImageIndex] {
+			if b.Repository != "" {
+				return b.Repository
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/inventory"
+)
+
+// packageLicense lists the licenses known for a package, for the
+// include_licenses option.
+type packageLicense struct {
+	Name     string
+	Version  string
+	PURLType string
+	PURL     string `json:",omitempty"`
+	// Location the package was found at
+	Location string `json:",omitempty"`
+	// License names or SPDX expressions as the package declares them; empty
+	// if none is known
+	Licenses []string
+}
+
+// packageLicenses returns the licenses of every package of inv, in
+// inventory order.
+func packageLicenses(inv *inventory.Inventory) []packageLicense {
+	licenses := make([]packageLicense, 0, len(inv.Packages))
+	for _, pkg := range inv.Packages {
+		l := packageLicense{Name: pkg.Name, Version: pkg.Version, PURLType: pkg.PURLType, Licenses: licensesOf(pkg)}
+		if p := pkg.PURL(); p != nil {
+			l.PURL = p.String()
+		}
+		if len(pkg.Locations) > 0 {
+			l.Location = pkg.Locations[0]
+		}
+		licenses = append(licenses, l)
+	}
+	return licenses
+}
+
+// licensesOf collects the licenses of a package from the places extractors
+// and enrichers record them: a License or Licenses field of the package
+// itself or of its metadata, e.g. of APK, RPM or Python packages. The fields
+// are looked up by name since they differ from one metadata type to the
+// next.
+func licensesOf(pkg *extractor.Package) []string {
+	licenses := licenseFields(reflect.ValueOf(pkg))
+	licenses = append(licenses, licenseFields(reflect.ValueOf(pkg.Metadata))...)
+	slices.Sort(licenses)
+	return slices.Compact(licenses)
+}
+
+// licenseFields returns the non-empty values of the License and Licenses
+// fields of the struct v points to.
+func licenseFields(v reflect.Value) []string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return []string{}
+		}
+		v = v.Elem()
+	}
+	licenses := []string{}
+	if v.Kind() != reflect.Struct {
+		return licenses
+	}
+	for _, name := range []string{"License", "Licenses"} {
+		f := v.FieldByName(name)
+		switch {
+		case !f.IsValid():
+		case f.Kind() == reflect.String:
+			if s := f.String(); s != "" {
+				licenses = append(licenses, s)
+			}
+		case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+			for i := range f.Len() {
+				if s := f.Index(i).String(); s != "" {
+					licenses = append(licenses, s)
+				}
+			}
+		}
+	}
+	return licenses
+}
@@ -0,0 +1,81 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	golog "log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// logSink receives the library's log messages, without trailing newline.
+type logSink func(level int, message string)
+
+var logHook struct {
+	mu   sync.RWMutex
+	sink logSink
+	// Held while the sink runs so it is never called concurrently
+	calls sync.Mutex
+}
+
+// logLevel is the level set by SCALIBR_LOG_LEVEL.
+var logLevel = logLevelInfo
+
+// setLogSink routes SCALIBR's log output, and anything else logged through
+// the standard log package, to sink, or back to stderr if sink is nil.
+func setLogSink(sink logSink) {
+	applyEnv()
+	logHook.mu.Lock()
+	logHook.sink = sink
+	logHook.mu.Unlock()
+	if sink != nil {
+		golog.SetOutput(stdLogWriter{})
+		golog.SetFlags(0)
+	} else {
+		golog.SetOutput(os.Stderr)
+		golog.SetFlags(golog.LstdFlags)
+	}
+}
+
+// writeLog passes message to the installed sink, or writes it to stderr if
+// there is none.
+func writeLog(level int, message string) {
+	logHook.mu.RLock()
+	sink := logHook.sink
+	logHook.mu.RUnlock()
+	if sink == nil {
+		golog.Print(message)
+		return
+	}
+	logHook.calls.Lock()
+	defer logHook.calls.Unlock()
+	sink(level, strings.TrimSuffix(message, "\n"))
+}
+
+// stdLogWriter forwards the output of the standard log package, used by some
+// dependencies, to the sink at info level.
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	logHook.mu.RLock()
+	sink := logHook.sink
+	logHook.mu.RUnlock()
+	if sink == nil {
+		return os.Stderr.Write(p)
+	}
+	writeLog(logLevelInfo, string(p))
+	return len(p), nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// memoryCheckInterval is how often a scan with max_rss_bytes samples the
+// memory use of the process.
+const memoryCheckInterval = 200 * time.Millisecond
+
+// errMemoryLimit is the cancellation cause of a scan aborted by the
+// max_rss_bytes option.
+var errMemoryLimit = errors.New("scan aborted at the memory limit")
+
+// memoryLimitInfo describes the memory use that aborted a scan.
+type memoryLimitInfo struct {
+	MaxRSSBytes uint64
+	RSSBytes    uint64
+}
+
+// memoryGuard samples the memory use of the process while a scan runs and
+// cancels the scan once it exceeds the limit.
+type memoryGuard struct {
+	limit  uint64
+	cancel context.CancelCauseFunc
+	quit   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	mu  sync.Mutex
+	hit *memoryLimitInfo
+}
+
+func startMemoryGuard(limit uint64, cancel context.CancelCauseFunc) *memoryGuard {
+	g := &memoryGuard{limit: limit, cancel: cancel, quit: make(chan struct{}), done: make(chan struct{})}
+	go g.watch()
+	return g
+}
+
+func (g *memoryGuard) watch() {
+	defer close(g.done)
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.quit:
+			return
+		case <-ticker.C:
+		}
+		rss := processMemory()
+		if rss <= g.limit {
+			continue
+		}
+		// Garbage the runtime hasn't returned to the OS yet counts too, so
+		// reclaim it before giving up
+		debug.FreeOSMemory()
+		if rss = processMemory(); rss <= g.limit {
+			continue
+		}
+		g.mu.Lock()
+		g.hit = &memoryLimitInfo{MaxRSSBytes: g.limit, RSSBytes: rss}
+		g.mu.Unlock()
+		g.cancel(fmt.Errorf("%w: %d bytes in use, limit %d", errMemoryLimit, rss, g.limit))
+		return
+	}
+}
+
+// exceeded returns the memory use that aborted the scan, or nil.
+func (g *memoryGuard) exceeded() *memoryLimitInfo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.hit
+}
+
+// stop stops sampling.
+func (g *memoryGuard) stop() {
+	g.once.Do(func() { close(g.quit) })
+	<-g.done
+}
+
+// processMemory returns the resident set size of the process, or where the OS
+// doesn't expose it through /proc, the memory mapped by the Go runtime.
+func processMemory() uint64 {
+	if data, err := os.ReadFile("/proc/self/statm"); err == nil {
+		var size, resident uint64
+		if _, err := fmt.Sscan(string(data), &size, &resident); err == nil {
+			return resident * uint64(os.Getpagesize())
+		}
+	}
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// mergedResult is the document returned by ScalibrMergeResults. It has the
+// layout of a scan result, so it can be passed on to ScalibrResultToSBOM.
+type mergedResult struct {
+	Version      string
+	StartTime    time.Time
+	EndTime      time.Time
+	PluginStatus []json.RawMessage
+	// Inventory sections by name, e.g. "Packages" or "PackageVulns".
+	Inventory map[string][]json.RawMessage
+	// Number of results merged.
+	MergedResults int
+}
+
+// mergeInput holds the parts of a JSON scan result that are merged.
+type mergeInput struct {
+	Version      string
+	StartTime    time.Time
+	EndTime      time.Time
+	PluginStatus []json.RawMessage
+	Inventory    map[string]json.RawMessage
+}
+
+// mergedPackage is a package together with the locations and plugins of its
+// duplicates.
+type mergedPackage struct {
+	fields    map[string]json.RawMessage
+	locations []string
+	plugins   []string
+}
+
+// mergeResults merges JSON scan results, e.g. of several roots or images,
+// into one. Packages with the same type, name and version are reported once
+// with the locations and plugins of all of them. Entries of the other
+// inventory sections and plugin statuses are deduplicated if they are
+// identical. Sections describing a single scan, such as Stats, are left
+// out.
+func mergeResults(results []string) ([]byte, error) {
+	merged := &mergedResult{Inventory: make(map[string][]json.RawMessage)}
+	var packageKeys []string
+	packages := make(map[string]*mergedPackage)
+	seen := make(map[string]bool)
+	for i, data := range results {
+		var in mergeInput
+		if err := json.Unmarshal([]byte(data), &in); err != nil {
+			return nil, fmt.Errorf("results[%d]: failed to parse result: %w", i, err)
+		}
+		if merged.Version == "" {
+			merged.Version = in.Version
+		}
+		if merged.StartTime.IsZero() || !in.StartTime.IsZero() && in.StartTime.Before(merged.StartTime) {
+			merged.StartTime = in.StartTime
+		}
+		if in.EndTime.After(merged.EndTime) {
+			merged.EndTime = in.EndTime
+		}
+		for _, s := range in.PluginStatus {
+			if key := "status\x00" + compactJSON(s); !seen[key] {
+				seen[key] = true
+				merged.PluginStatus = append(merged.PluginStatus, s)
+			}
+		}
+		for section, raw := range in.Inventory {
+			var entries []json.RawMessage
+			if err := json.Unmarshal(raw, &entries); err != nil {
+				// Not a list, e.g. null
+				continue
+			}
+			for _, e := range entries {
+				if section == "Packages" {
+					key, err := addMergedPackage(packages, e)
+					if err != nil {
+						return nil, fmt.Errorf("results[%d]: invalid package: %w", i, err)
+					}
+					if key != "" {
+						packageKeys = append(packageKeys, key)
+					}
+					continue
+				}
+				if key := section + "\x00" + compactJSON(e); !seen[key] {
+					seen[key] = true
+					merged.Inventory[section] = append(merged.Inventory[section], e)
+				}
+			}
+		}
+	}
+	for _, key := range packageKeys {
+		p := packages[key]
+		p.fields["Locations"], _ = json.Marshal(p.locations)
+		p.fields["Plugins"], _ = json.Marshal(p.plugins)
+		data, err := json.Marshal(p.fields)
+		if err != nil {
+			return nil, err
+		}
+		merged.Inventory["Packages"] = append(merged.Inventory["Packages"], data)
+	}
+	merged.MergedResults = len(results)
+	return json.Marshal(merged)
+}
+
+// addMergedPackage adds the JSON package to packages, or its locations and
+// plugins to the package it duplicates. It returns the key of a new package
+// and "" for a duplicate.
+func addMergedPackage(packages map[string]*mergedPackage, data json.RawMessage) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+	var id storedPackage
+	if err := json.Unmarshal(data, &id); err != nil {
+		return "", err
+	}
+	key := strings.Join([]string{id.PURLType, id.Name, id.Version}, "\x00")
+	p, ok := packages[key]
+	if !ok {
+		packages[key] = &mergedPackage{fields: fields, locations: id.Locations, plugins: id.Plugins}
+		return key, nil
+	}
+	for _, l := range id.Locations {
+		if !slices.Contains(p.locations, l) {
+			p.locations = append(p.locations, l)
+		}
+	}
+	for _, name := range id.Plugins {
+		if !slices.Contains(p.plugins, name) {
+			p.plugins = append(p.plugins, name)
+		}
+	}
+	return "", nil
+}
+
+// compactJSON returns data without insignificant whitespace, so entries
+// formatted differently compare equal.
+func compactJSON(data json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return string(data)
+	}
+	return buf.String()
+}
@@ -0,0 +1,141 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []string
+		// Compared with the corresponding fields of the merged result
+		wantPackages []map[string]any
+		wantSecrets  int
+		wantStatuses int
+		wantErr      bool
+	}{
+		{
+			name: "duplicate packages merged",
+			results: []string{
+				`{"Inventory": {"Packages": [{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": ["x/package.json"], "Plugins": ["javascript/packagejson"]}]}}`,
+				`{"Inventory": {"Packages": [{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": ["y/package.json"], "Plugins": ["javascript/packagejson"]}]}}`,
+			},
+			wantPackages: []map[string]any{
+				{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": []any{"x/package.json", "y/package.json"}, "Plugins": []any{"javascript/packagejson"}},
+			},
+		},
+		{
+			name: "different versions kept apart",
+			results: []string{
+				`{"Inventory": {"Packages": [{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": ["p"], "Plugins": ["x"]}]}}`,
+				`{"Inventory": {"Packages": [{"Name": "a", "Version": "2", "PURLType": "npm", "Locations": ["p"], "Plugins": ["x"]}]}}`,
+			},
+			wantPackages: []map[string]any{
+				{"Name": "a", "Version": "1", "PURLType": "npm", "Locations": []any{"p"}, "Plugins": []any{"x"}},
+				{"Name": "a", "Version": "2", "PURLType": "npm", "Locations": []any{"p"}, "Plugins": []any{"x"}},
+			},
+		},
+		{
+			name: "identical entries deduplicated",
+			results: []string{
+				`{"PluginStatus": [{"Name": "x", "Status": {"Status": 2}}], "Inventory": {"Secrets": [{"Location": "a"}, {"Location": "b"}]}}`,
+				`{"PluginStatus": [ {"Name": "x", "Status": {"Status": 2}} ], "Inventory": {"Secrets": [{ "Location": "a" }]}}`,
+			},
+			wantSecrets:  2,
+			wantStatuses: 1,
+		},
+		{
+			name:    "invalid result",
+			results: []string{`{"Inventory": {"Packages": [{"Name": "a"}]}}`, `not json`},
+			wantErr: true,
+		},
+		{
+			name:    "invalid package",
+			results: []string{`{"Inventory": {"Packages": ["a"]}}`},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := mergeResults(tc.results)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("mergeResults() error = %v, want error: %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got struct {
+				PluginStatus []any
+				Inventory    struct {
+					Packages []map[string]any
+					Secrets  []any
+				}
+				MergedResults int
+			}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("failed to parse merged result: %v", err)
+			}
+			if got.MergedResults != len(tc.results) {
+				t.Errorf("MergedResults = %d, want %d", got.MergedResults, len(tc.results))
+			}
+			if !reflect.DeepEqual(got.Inventory.Packages, tc.wantPackages) {
+				t.Errorf("Packages = %v, want %v", got.Inventory.Packages, tc.wantPackages)
+			}
+			if len(got.Inventory.Secrets) != tc.wantSecrets {
+				t.Errorf("got %d secrets, want %d", len(got.Inventory.Secrets), tc.wantSecrets)
+			}
+			if len(got.PluginStatus) != tc.wantStatuses {
+				t.Errorf("got %d plugin statuses, want %d", len(got.PluginStatus), tc.wantStatuses)
+			}
+		})
+	}
+}
+
+func TestMergeResultsTimes(t *testing.T) {
+	times := func(results ...string) (start, end time.Time) {
+		t.Helper()
+		data, err := mergeResults(results)
+		if err != nil {
+			t.Fatalf("mergeResults() = %v", err)
+		}
+		var got mergedResult
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		return got.StartTime, got.EndTime
+	}
+
+	start, end := times(
+		`{"StartTime": "2024-01-01T10:00:00Z", "EndTime": "2024-01-01T10:05:00Z"}`,
+		`{"StartTime": "2024-01-01T09:00:00Z", "EndTime": "2024-01-01T11:00:00Z"}`,
+	)
+	if want := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("StartTime = %v, want the earliest %v", start, want)
+	}
+	if want := time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Errorf("EndTime = %v, want the latest %v", end, want)
+	}
+
+	// Results without times don't count
+	start, end = times(`{}`, `{"StartTime": "2024-01-01T10:00:00Z"}`)
+	if want := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC); !start.Equal(want) || !end.IsZero() {
+		t.Errorf("times = %v - %v, want %v and no end", start, end, want)
+	}
+}
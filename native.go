@@ -0,0 +1,213 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	"github.com/google/osv-scalibr/extractor/filesystem/language/python/condameta"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/log"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// Names of the bindings' extractors for C/C++ and Conda package managers.
+const (
+	vcpkgName     = "native/vcpkg"
+	condaBaseName = "native/condabase"
+)
+
+// vcpkgMetadata is attached to the ports read from a vcpkg installed tree.
+type vcpkgMetadata struct {
+	// Target triplet, e.g. "x64-linux"
+	Triplet     string
+	PortVersion string   `json:",omitempty"`
+	Features    []string `json:",omitempty"`
+}
+
+// vcpkgExtractor reads the ports installed by vcpkg from the database of an
+// installed tree (vcpkg_installed/ in manifest mode, installed/ in classic
+// mode).
+type vcpkgExtractor struct{}
+
+func (vcpkgExtractor) Name() string { return vcpkgName }
+
+func (vcpkgExtractor) Version() int { return 0 }
+
+func (vcpkgExtractor) Requirements() *plugin.Capabilities { return &plugin.Capabilities{} }
+
+func (vcpkgExtractor) FileRequired(api filesystem.FileAPI) bool {
+	p := api.Path()
+	return path.Base(p) == "status" && path.Base(path.Dir(p)) == "vcpkg"
+}
+
+func (vcpkgExtractor) Extract(ctx context.Context, input *filesystem.ScanInput) (inventory.Inventory, error) {
+	paragraphs, err := parseControlFile(input.Reader)
+	if err != nil {
+		return inventory.Inventory{}, fmt.Errorf("failed to read %s: %w", input.Path, err)
+	}
+	// vcpkg appends changes to the updates directory and only folds them into
+	// the status file from time to time, so apply them in order
+	updates := path.Join(path.Dir(input.Path), "updates")
+	if entries, err := fs.ReadDir(input.FS, updates); err == nil {
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			f, err := input.FS.Open(path.Join(updates, e.Name()))
+			if err != nil {
+				log.Warnf("vcpkg: %v", err)
+				continue
+			}
+			p, err := parseControlFile(f)
+			f.Close()
+			if err != nil {
+				log.Warnf("vcpkg: failed to read %s: %v", e.Name(), err)
+				continue
+			}
+			paragraphs = append(paragraphs, p...)
+		}
+	}
+
+	// The last paragraph of each port or feature wins
+	type key struct{ name, triplet, feature string }
+	state := map[key]map[string]string{}
+	var order []key
+	for _, p := range paragraphs {
+		k := key{p["Package"], p["Architecture"], p["Feature"]}
+		if k.name == "" {
+			continue
+		}
+		if _, ok := state[k]; !ok {
+			order = append(order, k)
+		}
+		state[k] = p
+	}
+
+	var inv inventory.Inventory
+	ports := map[key]*extractor.Package{}
+	for _, k := range order {
+		p := state[k]
+		if k.feature != "" || !strings.HasSuffix(p["Status"], " installed") {
+			continue
+		}
+		m := &vcpkgMetadata{Triplet: k.triplet}
+		if pv := p["Port-Version"]; pv != "0" {
+			m.PortVersion = pv
+		}
+		pkg := &extractor.Package{
+			Name:      k.name,
+			Version:   p["Version"],
+			PURLType:  "generic",
+			Locations: []string{input.Path},
+			Metadata:  m,
+		}
+		ports[key{k.name, k.triplet, ""}] = pkg
+		inv.Packages = append(inv.Packages, pkg)
+	}
+	for _, k := range order {
+		if k.feature == "" || !strings.HasSuffix(state[k]["Status"], " installed") {
+			continue
+		}
+		if pkg := ports[key{k.name, k.triplet, ""}]; pkg != nil {
+			m := pkg.Metadata.(*vcpkgMetadata)
+			m.Features = append(m.Features, k.feature)
+			slices.Sort(m.Features)
+		}
+	}
+	return inv, nil
+}
+
+// parseControlFile parses a file made of Debian control style paragraphs,
+// as used by the vcpkg database.
+func parseControlFile(r io.Reader) ([]map[string]string, error) {
+	var paragraphs []map[string]string
+	fields := map[string]string{}
+	last := ""
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(fields) > 0 {
+				paragraphs = append(paragraphs, fields)
+			}
+			fields = map[string]string{}
+			last = ""
+		case line[0] == ' ' || line[0] == '\t':
+			// Continuation of a multi-line field
+			if last != "" {
+				fields[last] += "\n" + strings.TrimSpace(line)
+			}
+		default:
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			last = strings.TrimSpace(key)
+			fields[last] = strings.TrimSpace(value)
+		}
+	}
+	if len(fields) > 0 {
+		paragraphs = append(paragraphs, fields)
+	}
+	return paragraphs, s.Err()
+}
+
+// condaBaseExtractor extends SCALIBR's python/condameta, which only covers
+// named environments below envs/, to the conda-meta directory of the base
+// environment, e.g. /opt/conda/conda-meta.
+type condaBaseExtractor struct {
+	*condameta.Extractor
+}
+
+func newCondaBaseExtractor() *condaBaseExtractor {
+	return &condaBaseExtractor{condameta.New(condameta.DefaultConfig())}
+}
+
+func (e *condaBaseExtractor) Name() string { return condaBaseName }
+
+func (e *condaBaseExtractor) FileRequired(api filesystem.FileAPI) bool {
+	p := api.Path()
+	if path.Ext(p) != ".json" || path.Base(path.Dir(p)) != "conda-meta" {
+		return false
+	}
+	// Named environments are left to python/condameta
+	return !strings.HasPrefix(p, "envs/") && !strings.Contains(p, "/envs/")
+}
+
+func (e *condaBaseExtractor) Extract(ctx context.Context, input *filesystem.ScanInput) (inventory.Inventory, error) {
+	inv, err := e.Extractor.Extract(ctx, input)
+	if err != nil {
+		// History and other non-package files live next to the metadata
+		log.Debugf("%s: skipping %s: %v", condaBaseName, input.Path, err)
+		return inventory.Inventory{}, nil
+	}
+	return inv, nil
+}
+
+var (
+	_ filesystem.Extractor = vcpkgExtractor{}
+	_ filesystem.Extractor = &condaBaseExtractor{}
+)
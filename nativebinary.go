@@ -0,0 +1,210 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/json"
+	"io"
+	"slices"
+
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// nativeBinaryName is the name of the bindings' native binary extractor.
+const nativeBinaryName = "native/binary"
+
+// Analyses performed by the native binary extractor.
+const (
+	binarySharedLibraries = "shared_libraries"
+	binaryPackageNotes    = "package_notes"
+)
+
+var binaryAnalyses = []string{binarySharedLibraries, binaryPackageNotes}
+
+// elfPackageNoteType is the type of the FDO packaging metadata note, see
+// https://systemd.io/ELF_PACKAGE_METADATA/.
+const elfPackageNoteType = 0xcafe1a7e
+
+// nativeBinaryMetadata is attached to the packages reported by the native
+// binary extractor.
+type nativeBinaryMetadata struct {
+	// Executable format: "elf", "pe" or "macho"
+	Format string
+	// "shared_library" for a library the binary links against, or
+	// "package_note" for the package the binary declares it belongs to
+	Kind string
+}
+
+// nativeBinaryExtractor parses ELF, PE and Mach-O executables and reports
+// the shared libraries they import and the package metadata they embed.
+type nativeBinaryExtractor struct {
+	analyses []string
+}
+
+// newNativeBinaryExtractor returns an extractor running the given analyses,
+// or all of them if none are given.
+func newNativeBinaryExtractor(analyses []string) *nativeBinaryExtractor {
+	if len(analyses) == 0 {
+		analyses = binaryAnalyses
+	}
+	return &nativeBinaryExtractor{analyses: analyses}
+}
+
+func (e *nativeBinaryExtractor) Name() string { return nativeBinaryName }
+
+func (e *nativeBinaryExtractor) Version() int { return 0 }
+
+func (e *nativeBinaryExtractor) Requirements() *plugin.Capabilities {
+	return &plugin.Capabilities{}
+}
+
+func (e *nativeBinaryExtractor) FileRequired(api filesystem.FileAPI) bool {
+	return filesystem.IsInterestingExecutable(api)
+}
+
+func (e *nativeBinaryExtractor) Extract(ctx context.Context, input *filesystem.ScanInput) (inventory.Inventory, error) {
+	r, ok := input.Reader.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(input.Reader)
+		if err != nil {
+			return inventory.Inventory{}, err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	format, libs, notes := parseNativeBinary(r)
+	if format == "" {
+		// Not a native executable
+		return inventory.Inventory{}, nil
+	}
+
+	var inv inventory.Inventory
+	if slices.Contains(e.analyses, binarySharedLibraries) {
+		for _, lib := range libs {
+			inv.Packages = append(inv.Packages, &extractor.Package{
+				Name:      lib,
+				PURLType:  "generic",
+				Locations: []string{input.Path},
+				Metadata:  &nativeBinaryMetadata{Format: format, Kind: "shared_library"},
+			})
+		}
+	}
+	if slices.Contains(e.analyses, binaryPackageNotes) {
+		for _, n := range notes {
+			inv.Packages = append(inv.Packages, &extractor.Package{
+				Name:      n.Name,
+				Version:   n.Version,
+				PURLType:  packageNotePURLType(n.Type),
+				Locations: []string{input.Path},
+				Metadata:  &nativeBinaryMetadata{Format: format, Kind: "package_note"},
+			})
+		}
+	}
+	return inv, nil
+}
+
+// packageNote is the JSON payload of an ELF packaging metadata note.
+type packageNote struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func packageNotePURLType(t string) string {
+	switch t {
+	case "rpm", "deb", "apk", "alpm":
+		return t
+	}
+	return "generic"
+}
+
+// parseNativeBinary returns the format of the executable in r along with its
+// imported libraries and package notes. The format is empty if r isn't an
+// ELF, PE or Mach-O file.
+func parseNativeBinary(r io.ReaderAt) (string, []string, []packageNote) {
+	if f, err := elf.NewFile(r); err == nil {
+		defer f.Close()
+		libs, _ := f.ImportedLibraries()
+		return "elf", libs, elfPackageNotes(f)
+	}
+	if f, err := pe.NewFile(r); err == nil {
+		defer f.Close()
+		libs, _ := f.ImportedLibraries()
+		return "pe", libs, nil
+	}
+	if f, err := macho.NewFile(r); err == nil {
+		defer f.Close()
+		libs, _ := f.ImportedLibraries()
+		return "macho", libs, nil
+	}
+	if f, err := macho.NewFatFile(r); err == nil {
+		defer f.Close()
+		var libs []string
+		for _, arch := range f.Arches {
+			l, _ := arch.ImportedLibraries()
+			libs = append(libs, l...)
+		}
+		slices.Sort(libs)
+		return "macho", slices.Compact(libs), nil
+	}
+	return "", nil, nil
+}
+
+// elfPackageNotes decodes the .note.package section of f.
+func elfPackageNotes(f *elf.File) []packageNote {
+	s := f.Section(".note.package")
+	if s == nil {
+		return nil
+	}
+	data, err := s.Data()
+	if err != nil {
+		return nil
+	}
+
+	var notes []packageNote
+	align := func(n uint32) int { return int((n + 3) &^ 3) }
+	for len(data) >= 12 {
+		nameSize := f.ByteOrder.Uint32(data[0:4])
+		descSize := f.ByteOrder.Uint32(data[4:8])
+		noteType := f.ByteOrder.Uint32(data[8:12])
+		data = data[12:]
+		if int(nameSize) > len(data) || int(descSize) > len(data) || align(nameSize)+align(descSize) > len(data) {
+			break
+		}
+		name := string(bytes.TrimRight(data[:nameSize], "\x00"))
+		desc := bytes.TrimRight(data[align(nameSize):align(nameSize)+int(descSize)], "\x00")
+		data = data[align(nameSize)+align(descSize):]
+
+		if name != "FDO" || noteType != elfPackageNoteType {
+			continue
+		}
+		var n packageNote
+		if err := json.Unmarshal(desc, &n); err != nil || n.Name == "" {
+			continue
+		}
+		notes = append(notes, n)
+	}
+	return notes
+}
+
+var _ filesystem.Extractor = &nativeBinaryExtractor{}
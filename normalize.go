@@ -0,0 +1,254 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/google/osv-scalibr/enricher"
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/log"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// normalizeName is the enricher applying the package rewrites. It is
+// ordered first among the enrichers by initialize.
+const normalizeName = "native/normalize"
+
+// packageRewrite is a declarative normalization rule. A package matches if
+// all the given patterns, regular expressions matching the whole field,
+// match. The replacements may refer to the groups of the name pattern, e.g.
+// "$1".
+type packageRewrite struct {
+	Name     string `json:"name" yaml:"name" toml:"name"`
+	Version  string `json:"version" yaml:"version" toml:"version"`
+	PURLType string `json:"purl_type" yaml:"purl_type" toml:"purl_type"`
+
+	SetName     string `json:"set_name" yaml:"set_name" toml:"set_name"`
+	SetVersion  string `json:"set_version" yaml:"set_version" toml:"set_version"`
+	SetPURLType string `json:"set_purl_type" yaml:"set_purl_type" toml:"set_purl_type"`
+}
+
+// compiledRewrite is a packageRewrite with its patterns compiled.
+type compiledRewrite struct {
+	*packageRewrite
+	name, version, purlType *regexp.Regexp
+}
+
+// compileRewrites compiles the package_rewrites rules.
+func compileRewrites(rules []packageRewrite) ([]compiledRewrite, error) {
+	compiled := make([]compiledRewrite, 0, len(rules))
+	for i := range rules {
+		r := compiledRewrite{packageRewrite: &rules[i]}
+		for _, p := range []struct {
+			field, pattern string
+			re             **regexp.Regexp
+		}{
+			{"name", r.Name, &r.name},
+			{"version", r.Version, &r.version},
+			{"purl_type", r.PURLType, &r.purlType},
+		} {
+			if p.pattern == "" {
+				continue
+			}
+			re, err := regexp.Compile("^(?:" + p.pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid %s pattern: %w", i, p.field, err)
+			}
+			*p.re = re
+		}
+		if r.SetName == "" && r.SetVersion == "" && r.SetPURLType == "" {
+			return nil, fmt.Errorf("rule %d: sets nothing", i)
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+// apply rewrites pkg if it matches r and reports whether it did.
+func (r *compiledRewrite) apply(pkg *extractor.Package) bool {
+	var groups []int
+	if r.name != nil {
+		if groups = r.name.FindStringSubmatchIndex(pkg.Name); groups == nil {
+			return false
+		}
+	}
+	if r.version != nil && !r.version.MatchString(pkg.Version) {
+		return false
+	}
+	if r.purlType != nil && !r.purlType.MatchString(pkg.PURLType) {
+		return false
+	}
+	expand := func(template string) string {
+		if r.name == nil {
+			return template
+		}
+		return string(r.name.ExpandString(nil, template, pkg.Name, groups))
+	}
+	// The replacements are computed before any field changes
+	name, version := expand(r.SetName), expand(r.SetVersion)
+	if name != "" {
+		pkg.Name = name
+	}
+	if version != "" {
+		pkg.Version = version
+	}
+	if r.SetPURLType != "" {
+		pkg.PURLType = r.SetPURLType
+	}
+	return true
+}
+
+// normalizerBufferSize is the size of the buffer the host's normalizer
+// writes its response into.
+const normalizerBufferSize = 4096
+
+// packageNormalizer rewrites the package described by the JSON-encoded
+// normalizerPackage. It returns the JSON-encoded packageIdentity to apply,
+// or nil to keep the package unchanged.
+type packageNormalizer func(pkg []byte) ([]byte, error)
+
+// normalizerPackage is the document passed to the normalizer for each
+// package.
+type normalizerPackage struct {
+	packageIdentity
+	PURL      string `json:",omitempty"`
+	Locations []string
+	Plugins   []string
+}
+
+// packageIdentity holds the fields a normalizer may rewrite. Empty fields
+// are left unchanged.
+type packageIdentity struct {
+	Name     string
+	Version  string
+	PURLType string
+}
+
+var normalizerHook struct {
+	mu         sync.RWMutex
+	normalizer packageNormalizer
+}
+
+// setPackageNormalizer installs the normalizer consulted by every scan, or
+// removes it if n is nil.
+func setPackageNormalizer(n packageNormalizer) {
+	normalizerHook.mu.Lock()
+	defer normalizerHook.mu.Unlock()
+	normalizerHook.normalizer = n
+}
+
+// normalizeEnricher applies the rewrite rules, then the installed
+// normalizer, to the extracted packages. It runs ahead of the other
+// enrichers so that vulnerabilities are matched against the rewritten names.
+type normalizeEnricher struct {
+	rules     []compiledRewrite
+	normalize packageNormalizer
+}
+
+// withNormalizer adds the normalize enricher to plugins if there are rules or
+// a normalizer is installed.
+func withNormalizer(plugins []plugin.Plugin, rules []compiledRewrite) []plugin.Plugin {
+	normalizerHook.mu.RLock()
+	normalize := normalizerHook.normalizer
+	normalizerHook.mu.RUnlock()
+	if len(rules) == 0 && normalize == nil {
+		return plugins
+	}
+	return append(slices.Clip(plugins), &normalizeEnricher{rules: rules, normalize: normalize})
+}
+
+func (*normalizeEnricher) Name() string { return normalizeName }
+
+func (*normalizeEnricher) Version() int { return 0 }
+
+func (*normalizeEnricher) Requirements() *plugin.Capabilities { return &plugin.Capabilities{} }
+
+func (*normalizeEnricher) RequiredPlugins() []string { return nil }
+
+func (e *normalizeEnricher) Enrich(ctx context.Context, _ *enricher.ScanInput, inv *inventory.Inventory) error {
+	for _, pkg := range inv.Packages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i := range e.rules {
+			if e.rules[i].apply(pkg) {
+				// The first matching rule wins
+				break
+			}
+		}
+		if e.normalize != nil {
+			normalizePackage(pkg, e.normalize)
+		}
+	}
+	return nil
+}
+
+var _ enricher.Enricher = &normalizeEnricher{}
+
+func normalizePackage(pkg *extractor.Package, normalize packageNormalizer) {
+	doc := normalizerPackage{
+		packageIdentity: packageIdentity{Name: pkg.Name, Version: pkg.Version, PURLType: pkg.PURLType},
+		Locations:       pkg.Locations,
+		Plugins:         pkg.Plugins,
+	}
+	if p := pkg.PURL(); p != nil {
+		doc.PURL = p.String()
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		log.Warnf("normalizer: failed to marshal package %s: %v", pkg.Name, err)
+		return
+	}
+	resp, err := normalize(data)
+	if err != nil {
+		log.Warnf("normalizer: package %s: %v", pkg.Name, err)
+		return
+	}
+	if len(resp) == 0 {
+		return
+	}
+	var id packageIdentity
+	if err := json.Unmarshal(resp, &id); err != nil {
+		log.Warnf("normalizer: invalid response for package %s: %v", pkg.Name, err)
+		return
+	}
+	if id.Name != "" {
+		pkg.Name = id.Name
+	}
+	if id.Version != "" {
+		pkg.Version = id.Version
+	}
+	if id.PURLType != "" {
+		pkg.PURLType = id.PURLType
+	}
+}
+
+// setPackageRewritesJSON sets package_rewrites from its JSON text. Parse
+// errors are reported when the options are validated.
+func (o *scanOptions) setPackageRewritesJSON(s string) {
+	if s == "" {
+		return
+	}
+	if err := json.Unmarshal([]byte(s), &o.PackageRewrites); err != nil {
+		o.packageRewritesErr = fmt.Errorf("invalid package rewrites JSON: %w", err)
+	}
+}
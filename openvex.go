@@ -0,0 +1,161 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/inventory/vex"
+)
+
+// outputOpenVEX is the output_format rendering the findings as an OpenVEX
+// document.
+const outputOpenVEX = "openvex"
+
+const openVEXContext = "https://openvex.dev/ns/v0.2.0"
+
+// openVEXDocument is an OpenVEX v0.2.0 document, see
+// https://github.com/openvex/spec/blob/main/OPENVEX-SPEC.md.
+type openVEXDocument struct {
+	Context    string             `json:"@context"`
+	ID         string             `json:"@id"`
+	Author     string             `json:"author"`
+	Timestamp  string             `json:"timestamp"`
+	Version    int                `json:"version"`
+	Tooling    string             `json:"tooling"`
+	Statements []openVEXStatement `json:"statements"`
+}
+
+type openVEXStatement struct {
+	Vulnerability   openVEXVulnerability `json:"vulnerability"`
+	Products        []openVEXProduct     `json:"products"`
+	Status          string               `json:"status"`
+	Justification   string               `json:"justification,omitempty"`
+	ImpactStatement string               `json:"impact_statement,omitempty"`
+	ActionStatement string               `json:"action_statement,omitempty"`
+}
+
+type openVEXVulnerability struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type openVEXProduct struct {
+	ID string `json:"@id"`
+}
+
+// openVEXJustifications maps the exploitability signals of SCALIBR's VEX
+// annotators to OpenVEX justifications.
+var openVEXJustifications = map[vex.Justification]string{
+	vex.ComponentNotPresent:                         "component_not_present",
+	vex.VulnerableCodeNotPresent:                    "vulnerable_code_not_present",
+	vex.VulnerableCodeNotInExecutePath:              "vulnerable_code_not_in_execute_path",
+	vex.VulnerableCodeCannotBeControlledByAdversary: "vulnerable_code_cannot_be_controlled_by_adversary",
+	vex.InlineMitigationAlreadyExists:               "inline_mitigations_already_exist",
+}
+
+// marshalOpenVEX renders the findings of r as an OpenVEX document with a
+// statement per finding. Findings with an exploitability signal are
+// not_affected, the others affected. Detector findings aren't tied to a
+// package, so their product is the component of opts, or else the host.
+func marshalOpenVEX(r *scalibr.ScanResult, opts *sbomOptions) ([]byte, error) {
+	host := opts.ComponentName
+	if host == "" {
+		host, _ = os.Hostname()
+	} else if opts.ComponentVersion != "" {
+		host += "@" + opts.ComponentVersion
+	}
+	statements := []openVEXStatement{}
+	for _, f := range r.Inventory.GenericFindings {
+		if f.Adv == nil || f.Adv.ID == nil || f.Adv.ID.Reference == "" {
+			continue
+		}
+		s := openVEXStatement{
+			Vulnerability:   openVEXVulnerability{Name: f.Adv.ID.Reference, Description: f.Adv.Title},
+			Products:        []openVEXProduct{{ID: host}},
+			ActionStatement: f.Adv.Recommendation,
+		}
+		setOpenVEXStatus(&s, f.ExploitabilitySignals)
+		statements = append(statements, s)
+	}
+	for _, v := range r.Inventory.PackageVulns {
+		rec := parseOSVRecord(v)
+		if rec == nil || rec.ID == "" || v.Package == nil {
+			continue
+		}
+		s := openVEXStatement{
+			Vulnerability: openVEXVulnerability{Name: rec.ID},
+			Products:      []openVEXProduct{{ID: componentKey(v.Package)}},
+		}
+		if fixed := rec.fixedVersion(v.Package.Name, v.Package.Version); fixed != "" {
+			s.ActionStatement = "Upgrade " + v.Package.Name + " to version " + fixed + " or later"
+		}
+		setOpenVEXStatus(&s, v.ExploitabilitySignals)
+		statements = append(statements, s)
+	}
+	slices.SortFunc(statements, func(a, b openVEXStatement) int {
+		return cmp.Or(cmp.Compare(a.Vulnerability.Name, b.Vulnerability.Name), cmp.Compare(a.Products[0].ID, b.Products[0].ID))
+	})
+
+	doc := openVEXDocument{
+		Context:    openVEXContext,
+		ID:         opts.DocumentNamespace,
+		Author:     strings.Join(opts.Authors, ", "),
+		Timestamp:  r.EndTime.UTC().Format(time.RFC3339),
+		Version:    1,
+		Tooling:    "SCALIBR " + r.Version,
+		Statements: statements,
+	}
+	if doc.Author == "" {
+		doc.Author = "Unknown Author"
+	}
+	if doc.ID == "" {
+		// Identical findings yield the same document ID
+		data, err := json.Marshal(statements)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		doc.ID = "https://openvex.dev/docs/public/vex-" + hex.EncodeToString(sum[:])
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// setOpenVEXStatus sets the status of s from the exploitability signals of
+// its finding. An action statement is required for affected findings.
+func setOpenVEXStatus(s *openVEXStatement, signals []*vex.FindingExploitabilitySignal) {
+	if len(signals) == 0 {
+		s.Status = "affected"
+		if s.ActionStatement == "" {
+			s.ActionStatement = "No remediation is known"
+		}
+		return
+	}
+	s.Status = "not_affected"
+	s.ActionStatement = ""
+	if j, ok := openVEXJustifications[signals[0].Justification]; ok {
+		s.Justification = j
+	} else {
+		s.ImpactStatement = "Marked not exploitable by " + signals[0].Plugin
+	}
+}
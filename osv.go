@@ -0,0 +1,164 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/google/osv-scalibr/inventory"
+)
+
+// osvRecord holds the parts of an OSV vulnerability used by the bindings.
+type osvRecord struct {
+	ID       string
+	Affected []osvAffected
+}
+
+type osvAffected struct {
+	Package struct {
+		Name string
+	}
+	Ranges []struct {
+		Type   string
+		Events []osvEvent
+	}
+}
+
+type osvEvent struct {
+	Introduced string
+	Fixed      string
+}
+
+// parseOSVRecord extracts the OSV record of a package vulnerability. It goes
+// through the JSON form since the record is embedded differently across
+// SCALIBR versions.
+func parseOSVRecord(v *inventory.PackageVuln) *osvRecord {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var doc struct {
+		osvRecord
+		Vulnerability *osvRecord
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	if doc.ID == "" && doc.Vulnerability != nil {
+		return doc.Vulnerability
+	}
+	return &doc.osvRecord
+}
+
+// vulnID returns the advisory ID of a package vulnerability.
+func vulnID(v *inventory.PackageVuln) string {
+	if r := parseOSVRecord(v); r != nil {
+		return r.ID
+	}
+	return ""
+}
+
+// fixedVersion returns the version that fixes r for the given package
+// version: the fix of the range containing it, or else the lowest fix above
+// it. It returns "" if there is no known fix.
+func (r *osvRecord) fixedVersion(name, version string) string {
+	var lowest string
+	for _, a := range r.Affected {
+		if a.Package.Name != "" && a.Package.Name != name {
+			continue
+		}
+		for _, rng := range a.Ranges {
+			if rng.Type == "GIT" {
+				continue
+			}
+			introduced := ""
+			for _, e := range rng.Events {
+				if e.Introduced != "" {
+					introduced = e.Introduced
+				}
+				if e.Fixed == "" {
+					continue
+				}
+				if compareVersions(introduced, version) <= 0 && compareVersions(version, e.Fixed) < 0 {
+					return e.Fixed
+				}
+				if compareVersions(version, e.Fixed) < 0 && (lowest == "" || compareVersions(e.Fixed, lowest) < 0) {
+					lowest = e.Fixed
+				}
+			}
+		}
+	}
+	return lowest
+}
+
+// compareVersions orders dotted version strings such as semver and most
+// Maven versions, returning -1, 0 or 1. Numeric components compare
+// numerically and a suffix after "-" sorts before the plain release. The
+// empty string and "0" sort before everything.
+func compareVersions(a, b string) int {
+	coreA, preA, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	coreB, preB, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+	if c := compareComponents(strings.Split(coreA, "."), strings.Split(coreB, ".")); c != 0 {
+		return c
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return compareComponents(strings.Split(preA, "."), strings.Split(preB, "."))
+}
+
+func compareComponents(a, b []string) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y string
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		nx, errX := strconv.Atoi(orZero(x))
+		ny, errY := strconv.Atoi(orZero(y))
+		switch {
+		case errX == nil && errY == nil:
+			if nx != ny {
+				return cmp.Compare(nx, ny)
+			}
+		case errX == nil:
+			return 1
+		case errY == nil:
+			return -1
+		default:
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+func orZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// osvMaxBatchSize is the most queries OSV.dev accepts in one batch query,
+// which is also the batch size SCALIBR's OSV client uses.
+const osvMaxBatchSize = 1000
+
+type osvBatchSizeKey struct{}
+
+// withOSVBatchSize makes the OSV batch queries issued under ctx use batches
+// of at most size queries.
+func withOSVBatchSize(ctx context.Context, size int) context.Context {
+	return context.WithValue(ctx, osvBatchSizeKey{}, size)
+}
+
+func osvBatchSize(ctx context.Context) int {
+	size, _ := ctx.Value(osvBatchSizeKey{}).(int)
+	return size
+}
+
+// isOSVBatchQuery reports whether req is a call to the OSV querybatch
+// endpoint, of OSV.dev or a mirror.
+func isOSVBatchQuery(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/v1/querybatch")
+}
+
+// splitOSVBatch sends the queries of an OSV batch query in batches of at
+// most size queries, concurrently, and merges the results in query order.
+func (t *limitedTransport) splitOSVBatch(req *http.Request, size int) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var batch struct {
+		Queries []json.RawMessage `json:"queries"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil || len(batch.Queries) <= size {
+		// Not ours to interpret, or small enough already
+		return t.send(withBody(req, body))
+	}
+
+	var chunks [][]json.RawMessage
+	for q := batch.Queries; len(q) > 0; q = q[min(size, len(q)):] {
+		chunks = append(chunks, q[:min(size, len(q))])
+	}
+	results := make([][]json.RawMessage, len(chunks))
+	var (
+		mu     sync.Mutex
+		failed *http.Response
+	)
+	g, ctx := errgroup.WithContext(req.Context())
+	for i, chunk := range chunks {
+		g.Go(func() error {
+			data, err := json.Marshal(map[string][]json.RawMessage{"queries": chunk})
+			if err != nil {
+				return err
+			}
+			resp, err := t.send(withBody(req.WithContext(ctx), data))
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				// Hand the first error response to the client, which retries
+				// the whole batch
+				respBody, _ := io.ReadAll(resp.Body)
+				resp.Body = io.NopCloser(bytes.NewReader(respBody))
+				mu.Lock()
+				if failed == nil {
+					failed = resp
+				}
+				mu.Unlock()
+				return fmt.Errorf("OSV batch query failed: %s", resp.Status)
+			}
+			var r struct {
+				Results []json.RawMessage `json:"results"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+				return err
+			}
+			if len(r.Results) != len(chunk) {
+				return fmt.Errorf("OSV batch query returned %d results for %d queries", len(r.Results), len(chunk))
+			}
+			results[i] = r.Results
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		if failed != nil {
+			return failed, nil
+		}
+		return nil, err
+	}
+
+	var merged []json.RawMessage
+	for _, r := range results {
+		merged = append(merged, r...)
+	}
+	data, err := json.Marshal(map[string][]json.RawMessage{"results": merged})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}, "Content-Length": {strconv.Itoa(len(data))}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// withBody returns a copy of req sending body.
+func withBody(req *http.Request, body []byte) *http.Request {
+	r := req.Clone(req.Context())
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	return r
+}
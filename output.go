@@ -0,0 +1,280 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	scalibrproto "github.com/google/osv-scalibr/binary/proto"
+	"github.com/google/osv-scalibr/inventory"
+	"google.golang.org/protobuf/proto"
+)
+
+// Values of the output_format option.
+const (
+	outputJSON  = "json"
+	outputCBOR  = "cbor"
+	outputProto = "proto"
+	// A JSON array of the distinct package URLs, with or without versions.
+	outputPURLs          = "purls"
+	outputPURLsNoVersion = "purls-no-version"
+)
+
+// sbomFormats are the output formats rendering the result as an SBOM, in
+// the format names of ScalibrResultToSBOM.
+var sbomFormats = []string{"spdx23-json", "spdx23-tag-value", "spdx23-yaml", "cdx-json", "cdx-xml"}
+
+var outputFormats = append([]string{outputJSON, outputCBOR, outputProto, outputOpenVEX, outputPURLs, outputPURLsNoVersion}, sbomFormats...)
+
+// outputSettings are the options controlling how a scan result is returned.
+type outputSettings struct {
+	format   string
+	path     string
+	checksum bool
+	sections bool
+	// Parsed output_fields, nil for the whole result
+	fields fieldTree
+	// Document metadata of the SBOM formats
+	sbom *sbomOptions
+}
+
+// inline reports whether the encoded result is returned as a C string.
+func (s outputSettings) inline() bool {
+	return s.format != outputCBOR && s.format != outputProto
+}
+
+// outputSummary is returned in place of the result when it is written to
+// output_path.
+type outputSummary struct {
+	OutputPath string
+	Format     string
+	// Size of the result file in bytes
+	Size int
+	// Hex SHA-256 digest of the result file and the sidecar holding it, set
+	// when output_checksum is on
+	SHA256       string `json:",omitempty"`
+	ChecksumFile string `json:",omitempty"`
+	// Byte ranges of the result's sections, set when output_sections is on
+	Sections []outputSection `json:",omitempty"`
+	// Set when stop_on_first_finding aborted the scan.
+	StoppedOnFinding *stopInfo `json:",omitempty"`
+}
+
+// outputSection is the byte range of a value in a result file, so hosts can
+// map the file and decode only the sections they need.
+type outputSection struct {
+	// Path of the value's key from the top of the document, e.g.
+	// "Inventory.Packages"
+	Name   string
+	Offset int64
+	Length int64
+}
+
+// sectionDepth is how deep the section table reaches into the document.
+const sectionDepth = 2
+
+// encodeOutput serializes v in the output format of s.
+func encodeOutput(v any, s outputSettings) ([]byte, error) {
+	switch s.format {
+	case "", outputJSON:
+		return json.MarshalIndent(v, "", "  ")
+	case outputCBOR:
+		return marshalCBOR(v)
+	}
+	// Projected results are rejected by validation for the other formats
+	out, ok := v.(*scanOutput)
+	if !ok {
+		return nil, fmt.Errorf("output format %q requires the whole result", s.format)
+	}
+	opts := s.sbom
+	if opts == nil {
+		opts = &sbomOptions{}
+	}
+	switch {
+	case s.format == outputProto:
+		return marshalProto(out)
+	case s.format == outputOpenVEX:
+		return marshalOpenVEX(out.ScanResult, opts)
+	case s.format == outputPURLs, s.format == outputPURLsNoVersion:
+		return marshalPURLs(&out.Inventory, s.format == outputPURLs)
+	case slices.Contains(sbomFormats, s.format):
+		return convertToSBOM(out.ScanResult, s.format, opts)
+	}
+	return nil, fmt.Errorf("unknown output format %q", s.format)
+}
+
+// marshalProto encodes the SCALIBR result of o as a ScanResult message of
+// SCALIBR's binary/proto/scan_result.proto. The bindings' additions, such as
+// ScanRoots or FindingGroups, have no place in the message and are left out.
+func marshalProto(o *scanOutput) ([]byte, error) {
+	// The message refers to the image of a package through its layer
+	linkLayerParents(&o.Inventory)
+	defer detachLayerParents(&o.Inventory)
+	msg, err := scalibrproto.ScanResultToProto(o.ScanResult)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// marshalPURLs encodes the distinct package URLs of the packages of inv as a
+// sorted JSON array, leaving out the versions unless withVersion is set.
+// Packages without a package URL are left out.
+func marshalPURLs(inv *inventory.Inventory, withVersion bool) ([]byte, error) {
+	purls := []string{}
+	for _, pkg := range inv.Packages {
+		p := pkg.PURL()
+		if p == nil {
+			continue
+		}
+		if !withVersion {
+			p.Version = ""
+		}
+		purls = append(purls, p.String())
+	}
+	slices.Sort(purls)
+	return json.Marshal(slices.Compact(purls))
+}
+
+// writeOutputFile writes the encoded result to the configured path, along
+// with a sha256sum compatible sidecar if requested. The file is replaced
+// atomically so readers never see a partial result.
+func writeOutputFile(data []byte, s outputSettings) (*outputSummary, error) {
+	summary := &outputSummary{OutputPath: s.path, Format: s.format, Size: len(data)}
+	if summary.Format == "" {
+		summary.Format = outputJSON
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return nil, err
+	}
+	if s.checksum {
+		sum := sha256.Sum256(data)
+		summary.SHA256 = hex.EncodeToString(sum[:])
+		summary.ChecksumFile = s.path + ".sha256"
+		line := summary.SHA256 + "  " + filepath.Base(s.path) + "\n"
+		if err := writeFileAtomic(summary.ChecksumFile, []byte(line)); err != nil {
+			return nil, err
+		}
+	}
+	if s.sections {
+		var err error
+		if s.inline() {
+			summary.Sections, err = jsonSections(data, 0, "", 1)
+		} else {
+			summary.Sections, err = cborSections(data, 0, "", 1)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to index result: %w", err)
+		}
+	}
+	return summary, nil
+}
+
+// jsonSections lists the ranges of the members of the JSON object in data,
+// which starts at offset base of the file, and of their nested members.
+func jsonSections(data []byte, base int64, prefix string, depth int) ([]outputSection, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	if t, err := d.Token(); err != nil || t != json.Delim('{') {
+		return nil, err
+	}
+	var sections []outputSection
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := prefix + t.(string)
+		var raw json.RawMessage
+		if err := d.Decode(&raw); err != nil {
+			return nil, err
+		}
+		start := base + d.InputOffset() - int64(len(raw))
+		sections = append(sections, outputSection{Name: name, Offset: start, Length: int64(len(raw))})
+		if depth < sectionDepth && len(raw) > 0 && raw[0] == '{' {
+			nested, err := jsonSections(raw, start, name+".", depth+1)
+			if err != nil {
+				return nil, err
+			}
+			sections = append(sections, nested...)
+		}
+	}
+	return sections, nil
+}
+
+// cborSections is the CBOR counterpart of jsonSections.
+func cborSections(data []byte, base int64, prefix string, depth int) ([]outputSection, error) {
+	major, n, i, err := readCBORHead(data, 0)
+	if err != nil || major != cborMap {
+		return nil, err
+	}
+	var sections []outputSection
+	for ; n > 0; n-- {
+		major, kn, ki, err := readCBORHead(data, i)
+		if err != nil {
+			return nil, err
+		}
+		if major != cborText || kn > uint64(len(data)-ki) {
+			return nil, errors.New("cbor: invalid map key")
+		}
+		name := prefix + string(data[ki:ki+int(kn)])
+		start := ki + int(kn)
+		end, err := cborItemEnd(data, start)
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, outputSection{Name: name, Offset: base + int64(start), Length: int64(end - start)})
+		if depth < sectionDepth && data[start]&0xe0 == cborMap {
+			nested, err := cborSections(data[start:end], base+int64(start), name+".", depth+1)
+			if err != nil {
+				return nil, err
+			}
+			sections = append(sections, nested...)
+		}
+		i = end
+	}
+	return sections, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	return writeFileAtomicMode(path, data, 0o644)
+}
+
+// writeFileAtomicMode is writeFileAtomic creating the file with perm.
+func writeFileAtomicMode(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
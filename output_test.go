@@ -0,0 +1,124 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestOutputSections(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  any
+		// Section names in JSON document order. CBOR sorts keys by their
+		// encoding, so the order is only compared for JSON.
+		want []string
+	}{
+		{
+			name: "flat",
+			doc:  map[string]any{"A": 1, "B": "text"},
+			want: []string{"A", "B"},
+		},
+		{
+			name: "nested to the section depth",
+			doc: map[string]any{
+				"Inventory": map[string]any{
+					"Packages": []any{map[string]any{"Name": "a"}},
+					"Secrets":  map[string]any{"Deep": map[string]any{"Deeper": true}},
+				},
+				"Version": "1",
+			},
+			want: []string{"Inventory", "Inventory.Packages", "Inventory.Secrets", "Version"},
+		},
+		{
+			name: "empty",
+			doc:  map[string]any{},
+		},
+		{
+			name: "numbers and nulls",
+			doc:  map[string]any{"F": 1.5, "N": nil, "Neg": -300, "Big": uint64(1) << 40},
+			want: []string{"Big", "F", "N", "Neg"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			jsonData, err := json.MarshalIndent(tc.doc, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sections, err := jsonSections(jsonData, 0, "", 1)
+			if err != nil {
+				t.Fatalf("jsonSections() = %v", err)
+			}
+			if got := sectionNames(sections); !slices.Equal(got, tc.want) {
+				t.Errorf("JSON sections = %v, want %v", got, tc.want)
+			}
+			for _, s := range sections {
+				if v := jsonData[s.Offset : s.Offset+s.Length]; !json.Valid(v) {
+					t.Errorf("JSON section %s = %q, not a JSON value", s.Name, v)
+				}
+			}
+
+			cborData, err := marshalCBOR(tc.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sections, err = cborSections(cborData, 0, "", 1)
+			if err != nil {
+				t.Fatalf("cborSections() = %v", err)
+			}
+			if got := sectionNames(sections); !slices.Equal(slices.Sorted(slices.Values(got)), slices.Sorted(slices.Values(tc.want))) {
+				t.Errorf("CBOR sections = %v, want %v in any order", got, tc.want)
+			}
+			for _, s := range sections {
+				end, err := cborItemEnd(cborData, int(s.Offset))
+				if err != nil || int64(end) != s.Offset+s.Length {
+					t.Errorf("CBOR section %s ends at %d, want %d (%v)", s.Name, end, s.Offset+s.Length, err)
+				}
+			}
+		})
+	}
+}
+
+func TestOutputSectionsInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"truncated":       `{"A": [1, 2`,
+		"unquoted string": `{"A": bad}`,
+	} {
+		if _, err := jsonSections([]byte(data), 0, "", 1); err == nil {
+			t.Errorf("jsonSections() of %s JSON succeeded", name)
+		}
+	}
+	for name, data := range map[string][]byte{
+		"truncated":            {0xa1, 0x61, 'A', 0x82, 0x01},
+		"key not text":         {0xa1, 0x01, 0x01},
+		"length past the end":  {0xa1, 0x61, 'A', 0x7a, 0xff, 0xff, 0xff, 0xff},
+		"reserved information": {0xa1, 0x61, 'A', 0x1c},
+	} {
+		if _, err := cborSections(data, 0, "", 1); err == nil {
+			t.Errorf("cborSections() of %s CBOR succeeded", name)
+		}
+	}
+}
+
+func sectionNames(sections []outputSection) []string {
+	var names []string
+	for _, s := range sections {
+		names = append(names, s.Name)
+	}
+	return names
+}
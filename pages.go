@@ -0,0 +1,92 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "github.com/google/osv-scalibr/inventory"
+
+// Kinds of the items of a paginated result
+const (
+	itemPackage        = "package"
+	itemPackageVuln    = "package_vuln"
+	itemGenericFinding = "generic_finding"
+	itemSecret         = "secret"
+)
+
+// resultItem is a single inventory entry of a paginated result.
+type resultItem struct {
+	Kind string
+	Item any
+}
+
+// resultPage is a slice of the items of a scan result as returned by
+// ScalibrResultGetPage.
+type resultPage struct {
+	Offset int64
+	Total  int
+	Items  []resultItem
+}
+
+// inventoryItems flattens inv into one list: packages, then package
+// vulnerabilities, generic findings and secrets.
+func inventoryItems(inv *inventory.Inventory) []resultItem {
+	items := make([]resultItem, 0, len(inv.Packages)+len(inv.PackageVulns)+len(inv.GenericFindings)+len(inv.Secrets))
+	for _, p := range inv.Packages {
+		items = append(items, resultItem{Kind: itemPackage, Item: p})
+	}
+	for _, v := range inv.PackageVulns {
+		items = append(items, resultItem{Kind: itemPackageVuln, Item: v})
+	}
+	for _, f := range inv.GenericFindings {
+		items = append(items, resultItem{Kind: itemGenericFinding, Item: f})
+	}
+	for _, s := range inv.Secrets {
+		items = append(items, resultItem{Kind: itemSecret, Item: s})
+	}
+	return items
+}
+
+// resultItems returns the flattened inventory of the finished job, computed
+// once. It's empty if the scan failed without a result.
+func (j *job) resultItems() []resultItem {
+	j.itemsOnce.Do(func() {
+		if j.err == nil && j.output != nil && j.output.ScanResult != nil {
+			j.items = inventoryItems(&j.output.Inventory)
+		}
+	})
+	return j.items
+}
+
+// page returns up to limit items of the job's result starting at offset.
+func (j *job) page(offset int64, limit int) *resultPage {
+	items := j.resultItems()
+	p := &resultPage{Offset: offset, Total: len(items), Items: []resultItem{}}
+	if offset < 0 || limit <= 0 || offset >= int64(len(items)) {
+		return p
+	}
+	end := min(offset+int64(limit), int64(len(items)))
+	p.Items = items[offset:end]
+	return p
+}
+
+// finished waits for the job with the given ID to finish without releasing
+// it. It returns nil if there is no such job.
+func (s *scheduler) finished(id int64) *job {
+	j := s.lookup(id)
+	if j == nil {
+		return nil
+	}
+	<-j.done
+	return j
+}
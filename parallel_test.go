@@ -0,0 +1,74 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// TestParallelScans runs scans of different roots at the same time, as
+// several ScalibrScan calls do, to be run with -race.
+func TestParallelScans(t *testing.T) {
+	const scanCount = 8
+	roots := make([]string, scanCount)
+	for i := range roots {
+		roots[i] = t.TempDir()
+		content := fmt.Sprintf("BusyBox v1.%d.0 (2022-01-17)", 30+i)
+		if err := os.WriteFile(filepath.Join(roots[i], "busybox"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, slots := range []int{0, 2} {
+		t.Run(fmt.Sprintf("slots=%d", slots), func(t *testing.T) {
+			s := &scheduler{slots: slots, running: make(map[int64]*job), jobs: make(map[int64]*job)}
+			var wg sync.WaitGroup
+			for i, root := range roots {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					opts := &scanOptions{
+						RootPaths: []string{root},
+						Plugins:   []string{busyboxName},
+						Offline:   true,
+						// Half of the scans share the redirected streams
+						CaptureOutput: i%2 == 0,
+					}
+					out, err := s.wait(s.submit(opts))
+					if err != nil {
+						t.Errorf("scan of root %d failed: %v", i, err)
+						return
+					}
+					pkgs, _ := summary(out.ScanResult)
+					want := []string{fmt.Sprintf("busybox@1.%d.0 [busybox]", 30+i)}
+					if !slices.Equal(pkgs, want) {
+						t.Errorf("scan of root %d found %v, want %v", i, pkgs, want)
+					}
+				}()
+			}
+			wg.Wait()
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if len(s.jobs) != 0 || len(s.running) != 0 {
+				t.Errorf("scheduler still tracks %d jobs, %d running", len(s.jobs), len(s.running))
+			}
+		})
+	}
+}
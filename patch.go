@@ -0,0 +1,149 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines around each hunk.
+const diffContext = 3
+
+// lineEdit replaces the line at index line with repl, deleting it if repl is
+// empty.
+type lineEdit struct {
+	line int
+	repl []string
+}
+
+// textFile is a file split into lines for line-based editing.
+type textFile struct {
+	lines []string
+	// Whether the last line is terminated by a newline.
+	trailingNewline bool
+}
+
+func splitLines(data []byte) *textFile {
+	s := string(data)
+	f := &textFile{trailingNewline: strings.HasSuffix(s, "\n")}
+	if f.trailingNewline {
+		s = s[:len(s)-1]
+	}
+	if s != "" {
+		f.lines = strings.Split(s, "\n")
+	}
+	return f
+}
+
+// sortEdits orders edits by line, keeping only the first edit of each line.
+func sortEdits(edits []lineEdit) []lineEdit {
+	edits = slices.Clone(edits)
+	slices.SortStableFunc(edits, func(a, b lineEdit) int { return a.line - b.line })
+	return slices.CompactFunc(edits, func(a, b lineEdit) bool { return a.line == b.line })
+}
+
+// apply returns the contents of f with the edits applied.
+func (f *textFile) apply(edits []lineEdit) []byte {
+	edits = sortEdits(edits)
+	var out []string
+	next := 0
+	for i, line := range f.lines {
+		if next < len(edits) && edits[next].line == i {
+			out = append(out, edits[next].repl...)
+			next++
+			continue
+		}
+		out = append(out, line)
+	}
+	s := strings.Join(out, "\n")
+	if f.trailingNewline && len(out) > 0 {
+		s += "\n"
+	}
+	return []byte(s)
+}
+
+// unifiedDiff renders the edits to f as a unified diff between a/name and
+// b/name.
+func (f *textFile) unifiedDiff(name string, edits []lineEdit) string {
+	edits = sortEdits(edits)
+	if len(edits) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", name, name)
+	// offset is the difference between new and old line numbers so far
+	offset := 0
+	for start := 0; start < len(edits); {
+		// Group edits whose context overlaps into one hunk
+		end := start + 1
+		for end < len(edits) && edits[end].line-edits[end-1].line <= 2*diffContext {
+			end++
+		}
+		from := max(0, edits[start].line-diffContext)
+		to := min(len(f.lines), edits[end-1].line+1+diffContext)
+
+		var body []string
+		oldLen, newLen := 0, 0
+		next := start
+		for i := from; i < to; i++ {
+			if next < end && edits[next].line == i {
+				body = append(body, f.diffLine("-", i))
+				oldLen++
+				for _, r := range edits[next].repl {
+					body = append(body, "+"+r)
+					newLen++
+				}
+				if i == len(f.lines)-1 && !f.trailingNewline && len(edits[next].repl) > 0 {
+					body = append(body, `\ No newline at end of file`)
+				}
+				next++
+				continue
+			}
+			body = append(body, f.diffLine(" ", i))
+			oldLen++
+			newLen++
+		}
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(from, oldLen), hunkRange(from+offset, newLen))
+		for _, line := range body {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+		offset += newLen - oldLen
+		start = end
+	}
+	return b.String()
+}
+
+// diffLine renders line i of f with the given prefix, marking a missing
+// final newline.
+func (f *textFile) diffLine(prefix string, i int) string {
+	line := prefix + f.lines[i]
+	if i == len(f.lines)-1 && !f.trailingNewline {
+		line += "\n\\ No newline at end of file"
+	}
+	return line
+}
+
+// hunkRange formats a 0-based start and length as a unified diff range.
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
@@ -0,0 +1,32 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package main
+
+// caseInsensitivePaths tells whether paths differing only in case name the
+// same file.
+const caseInsensitivePaths = false
+
+// defaultPluginNames are the plugins of scans that select none, SCALIBR's
+// default.
+var defaultPluginNames []string
+
+// defaultRoot returns the root scanned when no root is given.
+func defaultRoot() string { return "/" }
+
+// cleanHostPath converts a path given by the host to the form the scan
+// compares locations in. Paths are used as given.
+func cleanHostPath(p string) string { return p }
@@ -0,0 +1,60 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// caseInsensitivePaths tells whether paths differing only in case name the
+// same file.
+const caseInsensitivePaths = true
+
+// defaultPluginNames are the plugins of scans that select none: SCALIBR's
+// default plugins and its Windows extractors, e.g. of installed updates.
+var defaultPluginNames = []string{"default", "windows"}
+
+// defaultRoot returns the root scanned when no root is given, the system
+// drive.
+func defaultRoot() string {
+	if drive := os.Getenv("SystemDrive"); drive != "" {
+		return drive + `\`
+	}
+	return `C:\`
+}
+
+// cleanHostPath converts a path given by the host to the form the scan
+// compares locations in: backslash-separated, without the \\?\ long path
+// prefix, which Go adds by itself where needed, and with drive roots ending
+// in a backslash since "C:" means the drive's current directory.
+func cleanHostPath(p string) string {
+	if p == "" {
+		return p
+	}
+	p = filepath.FromSlash(p)
+	if rest, ok := strings.CutPrefix(p, `\\?\UNC\`); ok {
+		p = `\\` + rest
+	} else if rest, ok := strings.CutPrefix(p, `\\?\`); ok {
+		p = rest
+	}
+	if vol := filepath.VolumeName(p); vol == p && len(vol) == 2 {
+		p += `\`
+	}
+	return filepath.Clean(p)
+}
@@ -0,0 +1,117 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	cpb "github.com/google/osv-scalibr/binary/proto/config_go_proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// pluginOptionFields maps the plugins accepting plugin_options to the field
+// of SCALIBR's PluginSpecificConfig proto holding their configuration.
+var pluginOptionFields = map[string]string{
+	"go/binary":          "go_binary",
+	"java/archive":       "java_archive",
+	"govulncheck/binary": "govulncheck",
+}
+
+// pluginConfig converts the plugin_config passthrough, the JSON form of
+// SCALIBR's PluginConfig proto, together with the plugin_options into the
+// configuration handed to the plugin constructors. It returns nil if no
+// configuration is given.
+func pluginConfig(opts *scanOptions) (*cpb.PluginConfig, error) {
+	if opts.pluginConfigErr != nil {
+		return nil, opts.pluginConfigErr
+	}
+	if opts.pluginOptionsErr != nil {
+		return nil, opts.pluginOptionsErr
+	}
+	if len(opts.PluginConfig) == 0 && len(opts.PluginOptions) == 0 {
+		return nil, nil
+	}
+	merged, err := withPluginOptions(opts.PluginConfig, opts.PluginOptions)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plugin config: %w", err)
+	}
+	cfg := &cpb.PluginConfig{}
+	if err := protojson.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid plugin config: %w", err)
+	}
+	return cfg, nil
+}
+
+// withPluginOptions returns a copy of the plugin config with an entry in its
+// plugin_specific list for each plugin of options that has options besides
+// max_file_size.
+func withPluginOptions(config map[string]any, options map[string]map[string]any) (map[string]any, error) {
+	if len(options) == 0 {
+		return config, nil
+	}
+	merged := maps.Clone(config)
+	if merged == nil {
+		merged = map[string]any{}
+	}
+	var specific []any
+	if v, ok := merged["plugin_specific"]; ok {
+		if specific, ok = v.([]any); !ok {
+			return nil, fmt.Errorf("invalid plugin config: plugin_specific must be a list")
+		}
+	}
+	specific = slices.Clone(specific)
+	for _, name := range slices.Sorted(maps.Keys(options)) {
+		scalibrOptions, ok := scalibrPluginOptions(options[name])
+		if !ok {
+			continue
+		}
+		field, ok := pluginOptionFields[name]
+		if !ok {
+			return nil, fmt.Errorf("plugin %q takes no options, want one of %v", name, slices.Sorted(maps.Keys(pluginOptionFields)))
+		}
+		specific = append(specific, map[string]any{field: scalibrOptions})
+	}
+	merged["plugin_specific"] = specific
+	return merged, nil
+}
+
+// setPluginConfigJSON sets the plugin_config passthrough from its JSON text.
+// Parse errors are reported when the options are validated.
+func (o *scanOptions) setPluginConfigJSON(s string) {
+	if s == "" {
+		return
+	}
+	if err := json.Unmarshal([]byte(s), &o.PluginConfig); err != nil {
+		o.pluginConfigErr = fmt.Errorf("invalid plugin config JSON: %w", err)
+	}
+}
+
+// setPluginOptionsJSON sets plugin_options from its JSON text. Parse errors
+// are reported when the options are validated.
+func (o *scanOptions) setPluginOptionsJSON(s string) {
+	if s == "" {
+		return
+	}
+	if err := json.Unmarshal([]byte(s), &o.PluginOptions); err != nil {
+		o.pluginOptionsErr = fmt.Errorf("invalid plugin options JSON: %w", err)
+	}
+}
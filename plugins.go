@@ -0,0 +1,217 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/google/osv-scalibr/annotator"
+	al "github.com/google/osv-scalibr/annotator/list"
+	"github.com/google/osv-scalibr/binary/platform"
+	cpb "github.com/google/osv-scalibr/binary/proto/config_go_proto"
+	"github.com/google/osv-scalibr/detector"
+	dl "github.com/google/osv-scalibr/detector/list"
+	"github.com/google/osv-scalibr/enricher"
+	el "github.com/google/osv-scalibr/enricher/enricherlist"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	fl "github.com/google/osv-scalibr/extractor/filesystem/list"
+	"github.com/google/osv-scalibr/extractor/standalone"
+	sl "github.com/google/osv-scalibr/extractor/standalone/list"
+	"github.com/google/osv-scalibr/plugin"
+	pl "github.com/google/osv-scalibr/plugin/list"
+)
+
+// pluginGroups are plugin groups defined by the bindings on top of the ones
+// known to SCALIBR. They are opt-in and not part of any SCALIBR group.
+var pluginGroups = map[string][]string{
+	// Executable analysis, expensive on large trees
+	"binaries": {"go/binary", "rust/cargoauditable", "dotnet/pe", nativeBinaryName},
+	// Package managers of C/C++ and data-science projects
+	"native_packages": {"cpp/conanlock", vcpkgName, "python/condameta", condaBaseName},
+	// Extracted firmware and embedded Linux root filesystems. os/dpkg also
+	// reads opkg status files.
+	"embedded": {"os/dpkg", "os/apk", "os/kernel/module", "os/kernel/vmlinuz", busyboxName, squashfsName},
+}
+
+// bindingsPlugins constructs the plugins implemented by the bindings.
+var bindingsPlugins = map[string]func(opts *scanOptions) plugin.Plugin{
+	nativeBinaryName: func(opts *scanOptions) plugin.Plugin { return newNativeBinaryExtractor(opts.BinaryAnalyses) },
+	vcpkgName:        func(*scanOptions) plugin.Plugin { return vcpkgExtractor{} },
+	condaBaseName:    func(*scanOptions) plugin.Plugin { return newCondaBaseExtractor() },
+	busyboxName:      func(*scanOptions) plugin.Plugin { return busyboxExtractor{} },
+	squashfsName:     func(*scanOptions) plugin.Plugin { return &squashfsExtractor{} },
+}
+
+// resolvePlugins returns the plugins selected by names, which may mix
+// SCALIBR plugin and group names with the bindings' own, or the
+// defaultPluginNames if there are none.
+// The SCALIBR plugins are constructed with cfg, which may be nil.
+func resolvePlugins(names []string, opts *scanOptions, cfg *cpb.PluginConfig) ([]plugin.Plugin, error) {
+	if len(names) == 0 {
+		names = defaultPluginNames
+	}
+	var scalibrNames []string
+	var plugins []plugin.Plugin
+	for _, name := range expandPluginGroups(names) {
+		if newPlugin, ok := bindingsPlugins[name]; ok {
+			plugins = append(plugins, newPlugin(opts))
+			continue
+		}
+		scalibrNames = append(scalibrNames, name)
+	}
+	if len(scalibrNames) > 0 || len(plugins) == 0 {
+		p, err := pl.FromNames(scalibrNames, cfg)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, p...)
+	}
+	return plugins, nil
+}
+
+// expandPluginGroups replaces the bindings' group names by their members.
+func expandPluginGroups(names []string) []string {
+	var expanded []string
+	for _, name := range names {
+		if members, ok := pluginGroups[name]; ok {
+			expanded = append(expanded, members...)
+			continue
+		}
+		expanded = append(expanded, name)
+	}
+	return expanded
+}
+
+// pluginCatalog lists the plugins that can be selected by name, by kind.
+type pluginCatalog struct {
+	Extractors           []string
+	StandaloneExtractors []string
+	Detectors            []string
+	Annotators           []string
+	Enrichers            []string
+	// The bindings' plugin groups and their members
+	Groups map[string][]string
+}
+
+// availablePlugins returns the catalog of the SCALIBR plugins and the
+// bindings' own.
+func availablePlugins() *pluginCatalog {
+	c := &pluginCatalog{
+		Extractors:           slices.Collect(maps.Keys(fl.All)),
+		StandaloneExtractors: slices.Collect(maps.Keys(sl.All)),
+		Detectors:            slices.Collect(maps.Keys(dl.All)),
+		Annotators:           slices.Collect(maps.Keys(al.All)),
+		Enrichers:            slices.Collect(maps.Keys(el.All)),
+		Groups:               pluginGroups,
+	}
+	for name, newPlugin := range bindingsPlugins {
+		if _, ok := newPlugin(&scanOptions{}).(filesystem.Extractor); ok {
+			c.Extractors = append(c.Extractors, name)
+		}
+	}
+	for _, names := range [][]string{c.Extractors, c.StandaloneExtractors, c.Detectors, c.Annotators, c.Enrichers} {
+		slices.Sort(names)
+	}
+	return c
+}
+
+// pluginInfo describes a single plugin.
+type pluginInfo struct {
+	Name    string
+	Type    string
+	Version int
+	// OS is one of "any", "linux", "windows", "mac" and "unix", Network one
+	// of "any", "offline" and "online".
+	OS      string
+	Network string
+	// Whether the plugin opens paths on the host directly, and so can't scan
+	// images or other virtual filesystems
+	DirectFS bool
+	// Whether the plugin only works on the system the library runs on
+	RunningSystem bool
+	// The extractors a detector or the plugins an enricher depends on
+	RequiredPlugins []string `json:",omitempty"`
+	// Whether a scan of this host with network access keeps the plugin, and
+	// why not otherwise
+	Runnable bool
+	Reason   string `json:",omitempty"`
+}
+
+var osNames = map[plugin.OS]string{
+	plugin.OSAny:     "any",
+	plugin.OSLinux:   "linux",
+	plugin.OSWindows: "windows",
+	plugin.OSMac:     "mac",
+	plugin.OSUnix:    "unix",
+}
+
+var networkNames = map[plugin.Network]string{
+	plugin.NetworkAny:     "any",
+	plugin.NetworkOffline: "offline",
+	plugin.NetworkOnline:  "online",
+}
+
+// describePlugin returns the description of the plugin with the given name,
+// or nil if name isn't the exact name of a plugin. Group names aren't
+// accepted.
+func describePlugin(name string) *pluginInfo {
+	var p plugin.Plugin
+	if newPlugin, ok := bindingsPlugins[name]; ok {
+		p = newPlugin(&scanOptions{})
+	} else if _, isGroup := pluginGroups[name]; !isGroup {
+		var err error
+		if p, err = pl.FromName(name); err != nil || p.Name() != name {
+			return nil
+		}
+	} else {
+		return nil
+	}
+	req := p.Requirements()
+	info := &pluginInfo{
+		Name:          p.Name(),
+		Version:       p.Version(),
+		OS:            osNames[req.OS],
+		Network:       networkNames[req.Network],
+		DirectFS:      req.DirectFS,
+		RunningSystem: req.RunningSystem,
+	}
+	switch p := p.(type) {
+	case filesystem.Extractor:
+		info.Type = "extractor"
+	case standalone.Extractor:
+		info.Type = "standalone_extractor"
+	case detector.Detector:
+		info.Type = "detector"
+		info.RequiredPlugins = p.RequiredExtractors()
+	case annotator.Annotator:
+		info.Type = "annotator"
+	case enricher.Enricher:
+		info.Type = "enricher"
+		info.RequiredPlugins = p.RequiredPlugins()
+	}
+	// The capabilities of runScan for a scan that isn't offline
+	err := plugin.ValidateRequirements(p, &plugin.Capabilities{
+		OS:            platform.OS(),
+		Network:       plugin.NetworkOnline,
+		DirectFS:      true,
+		RunningSystem: true,
+	})
+	info.Runnable = err == nil
+	if err != nil {
+		info.Reason = err.Error()
+	}
+	return info
+}
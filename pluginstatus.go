@@ -0,0 +1,106 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// Plugin statuses reported in pluginReport.Status, from best to worst.
+const (
+	pluginSucceeded          = "succeeded"
+	pluginPartiallySucceeded = "partially_succeeded"
+	pluginFailed             = "failed"
+)
+
+// pluginStatusNames maps SCALIBR's statuses to the reported ones. Plugins
+// that didn't report a status count as succeeded.
+var pluginStatusNames = map[plugin.ScanStatusEnum]string{
+	plugin.ScanStatusUnspecified:        pluginSucceeded,
+	plugin.ScanStatusSucceeded:          pluginSucceeded,
+	plugin.ScanStatusPartiallySucceeded: pluginPartiallySucceeded,
+	plugin.ScanStatusFailed:             pluginFailed,
+}
+
+// pluginReport is the outcome of one plugin, across all roots of the scan.
+type pluginReport struct {
+	Plugin  string
+	Version int
+	Status  string
+	// Why the plugin failed, for each root it failed on
+	Error string `json:",omitempty"`
+	// Files the plugin couldn't process
+	FileErrors []*plugin.FileErrors `json:",omitempty"`
+}
+
+// pluginSummary tells how the plugins of a scan fared, so hosts can tell a
+// failing extractor from a failed scan.
+type pluginSummary struct {
+	// Worst status of any plugin
+	Status             string
+	Succeeded          int
+	PartiallySucceeded int
+	Failed             int
+	// Sorted by plugin name
+	Plugins []pluginReport
+}
+
+// summarizePlugins merges the statuses SCALIBR reported for each plugin.
+// Plugins run on several roots keep their worst status.
+func summarizePlugins(statuses []*plugin.Status) *pluginSummary {
+	reports := map[string]*pluginReport{}
+	for _, s := range statuses {
+		r, ok := reports[s.Name]
+		if !ok {
+			r = &pluginReport{Plugin: s.Name, Version: s.Version, Status: pluginSucceeded}
+			reports[s.Name] = r
+		}
+		if s.Status == nil {
+			continue
+		}
+		r.Status = worsePluginStatus(r.Status, pluginStatusNames[s.Status.Status])
+		if s.Status.FailureReason != "" && !strings.Contains(r.Error, s.Status.FailureReason) {
+			r.Error = strings.TrimPrefix(r.Error+"; "+s.Status.FailureReason, "; ")
+		}
+		r.FileErrors = append(r.FileErrors, s.Status.FileErrors...)
+	}
+	summary := &pluginSummary{Status: pluginSucceeded, Plugins: []pluginReport{}}
+	for _, r := range reports {
+		switch r.Status {
+		case pluginSucceeded:
+			summary.Succeeded++
+		case pluginPartiallySucceeded:
+			summary.PartiallySucceeded++
+		case pluginFailed:
+			summary.Failed++
+		}
+		summary.Status = worsePluginStatus(summary.Status, r.Status)
+		summary.Plugins = append(summary.Plugins, *r)
+	}
+	slices.SortFunc(summary.Plugins, func(a, b pluginReport) int { return strings.Compare(a.Plugin, b.Plugin) })
+	return summary
+}
+
+// worsePluginStatus returns the worse of two plugin statuses.
+func worsePluginStatus(a, b string) string {
+	order := []string{pluginSucceeded, pluginPartiallySucceeded, pluginFailed}
+	if slices.Index(order, b) > slices.Index(order, a) {
+		return b
+	}
+	return a
+}
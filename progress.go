@@ -0,0 +1,208 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/osv-scalibr/enricher"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/plugin"
+	"github.com/google/osv-scalibr/stats"
+)
+
+// progressInterval is how often a scan reports its progress.
+const progressInterval = 500 * time.Millisecond
+
+// Phases of a scan reported in progress updates.
+const (
+	phaseWalk      = "walk"
+	phaseDetectors = "detectors"
+	phaseEnrichers = "enrichers"
+	phaseDone      = "done"
+)
+
+// progressFunc receives the JSON-encoded progressUpdate of a scan.
+type progressFunc func(update []byte)
+
+// progressUpdate is the document passed to the progress callback.
+type progressUpdate struct {
+	// Scan root being scanned, and its index among the scan roots
+	Root      string
+	RootIndex int
+	Phase     string
+	// Files and directories visited in the root so far
+	FilesVisited     int64
+	CurrentDirectory string `json:",omitempty"`
+	// Plugins that finished their work in the root. Extractors finish when
+	// the filesystem walk does.
+	PluginsCompleted int
+	PluginsTotal     int
+	// Times the scan was restarted from scratch after being preempted. The
+	// updates of earlier runs are superseded.
+	Restarts int `json:",omitempty"`
+}
+
+// progressReporter tracks the progress of a scan through SCALIBR's stats
+// collector and reports it to the host every progressInterval.
+type progressReporter struct {
+	stats.NoopCollector
+	report     progressFunc
+	extractors int
+
+	mu      sync.Mutex
+	update  progressUpdate
+	changed bool
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+func newProgressReporter(report progressFunc, plugins []plugin.Plugin, restarts int) *progressReporter {
+	r := &progressReporter{report: report, quit: make(chan struct{}), done: make(chan struct{})}
+	for _, p := range plugins {
+		if _, ok := p.(filesystem.Extractor); ok {
+			r.extractors++
+		}
+	}
+	r.update.PluginsTotal = len(plugins)
+	r.update.Restarts = restarts
+	go r.run()
+	return r
+}
+
+func (r *progressReporter) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.quit:
+			return
+		case <-ticker.C:
+			r.flush(false)
+		}
+	}
+}
+
+// flush reports the current progress if it changed since the last report,
+// or regardless if force is set.
+func (r *progressReporter) flush(force bool) {
+	r.mu.Lock()
+	if !r.changed && !force {
+		r.mu.Unlock()
+		return
+	}
+	r.changed = false
+	data, err := json.Marshal(r.update)
+	r.mu.Unlock()
+	if err == nil {
+		r.report(data)
+	}
+}
+
+// startRoot resets the progress for the scan of the i-th root.
+func (r *progressReporter) startRoot(i int, root string) {
+	r.mu.Lock()
+	r.update = progressUpdate{Root: root, RootIndex: i, Phase: phaseWalk, PluginsTotal: r.update.PluginsTotal, Restarts: r.update.Restarts}
+	r.changed = true
+	r.mu.Unlock()
+	r.flush(false)
+}
+
+// enterPhase moves on to the given phase. Must be called with r.mu held.
+func (r *progressReporter) enterPhase(phase string) {
+	if r.update.Phase == phaseWalk {
+		r.update.PluginsCompleted += r.extractors
+	}
+	r.update.Phase = phase
+	r.changed = true
+}
+
+func (r *progressReporter) AfterInodeVisited(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.update.FilesVisited++
+	r.update.CurrentDirectory = filepath.Dir(path)
+	r.changed = true
+}
+
+func (r *progressReporter) AfterDetectorRun(string, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.update.Phase != phaseDetectors {
+		r.enterPhase(phaseDetectors)
+	}
+	r.update.PluginsCompleted++
+	r.changed = true
+}
+
+// enricherRun records that an enricher is starting, returning the function
+// recording that it finished.
+func (r *progressReporter) enricherRun() func() {
+	r.mu.Lock()
+	if r.update.Phase != phaseEnrichers {
+		r.enterPhase(phaseEnrichers)
+	}
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.update.PluginsCompleted++
+		r.changed = true
+	}
+}
+
+// finishRoot reports the end of the scan of the current root.
+func (r *progressReporter) finishRoot() {
+	r.mu.Lock()
+	r.enterPhase(phaseDone)
+	r.update.CurrentDirectory = ""
+	r.mu.Unlock()
+	r.flush(true)
+}
+
+// stop stops the periodic reports.
+func (r *progressReporter) stop() {
+	close(r.quit)
+	<-r.done
+}
+
+// wrap returns plugins with every enricher instrumented to report its
+// completion, as SCALIBR's stats collector doesn't.
+func (r *progressReporter) wrap(plugins []plugin.Plugin) []plugin.Plugin {
+	wrapped := make([]plugin.Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		if e, ok := p.(enricher.Enricher); ok {
+			p = &progressEnricher{Enricher: e, r: r}
+		}
+		wrapped = append(wrapped, p)
+	}
+	return wrapped
+}
+
+type progressEnricher struct {
+	enricher.Enricher
+	r *progressReporter
+}
+
+func (e *progressEnricher) Enrich(ctx context.Context, input *enricher.ScanInput, inv *inventory.Inventory) error {
+	defer e.r.enricherRun()()
+	return e.Enricher.Enrich(ctx, input, inv)
+}
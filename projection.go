@@ -0,0 +1,223 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/google/osv-scalibr/extractor"
+)
+
+// fieldAliases are the shorthands accepted as the first element of an
+// output_fields path.
+var fieldAliases = map[string][]string{
+	"packages": {"Inventory.Packages"},
+	"findings": {"Inventory.PackageVulns", "Inventory.GenericFindings"},
+	"secrets":  {"Inventory.Secrets"},
+	"status":   {"Status", "PluginStatus"},
+}
+
+// purlField is the computed PURL that output_fields can select for each
+// package, e.g. "packages.purl".
+const purlField = "purl"
+
+// fieldTree is a set of output_fields paths with lower-cased keys. A node
+// without children selects the whole value.
+type fieldTree map[string]fieldTree
+
+// checkOutputField checks an output_fields path.
+func checkOutputField(f string) error {
+	parts := strings.Split(f, ".")
+	if slices.Contains(parts, "") {
+		return fmt.Errorf("invalid field path %q", f)
+	}
+	head := strings.ToLower(parts[0])
+	if _, ok := fieldAliases[head]; !ok && !slices.Contains(outputKeys(), head) {
+		return fmt.Errorf("unknown result field %q", parts[0])
+	}
+	return nil
+}
+
+// parseOutputFields builds the tree of the given checked paths, expanding
+// aliases. It returns nil, selecting everything, if there are none.
+func parseOutputFields(fields []string) fieldTree {
+	if len(fields) == 0 {
+		return nil
+	}
+	tree := fieldTree{}
+	for _, f := range fields {
+		parts := strings.Split(f, ".")
+		heads := []string{parts[0]}
+		if expanded, ok := fieldAliases[strings.ToLower(parts[0])]; ok {
+			heads = expanded
+		}
+		for _, head := range heads {
+			tree.add(append(strings.Split(head, "."), parts[1:]...))
+		}
+	}
+	return tree
+}
+
+func (t fieldTree) add(path []string) {
+	key := strings.ToLower(path[0])
+	child, ok := t[key]
+	if ok && child == nil {
+		// Already selected whole
+		return
+	}
+	if len(path) == 1 {
+		t[key] = nil
+		return
+	}
+	if child == nil {
+		child = fieldTree{}
+		t[key] = child
+	}
+	child.add(path[1:])
+}
+
+// outputKeys returns the lower-cased top-level keys of the result document.
+func outputKeys() []string {
+	var keys []string
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if f.Anonymous {
+				collect(f.Type.Elem())
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			keys = append(keys, strings.ToLower(f.Name))
+		}
+	}
+	collect(reflect.TypeFor[scanOutput]())
+	return keys
+}
+
+// projectOutput returns the JSON encoding of out with only the fields in
+// tree.
+func projectOutput(out *scanOutput, tree fieldTree) (json.RawMessage, error) {
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	// Packages have no PURL field; it is spliced in when selected
+	overrides := map[string]json.RawMessage{}
+	if pkgs := tree["inventory"]["packages"]; pkgs != nil {
+		if _, ok := pkgs[purlField]; ok {
+			if overrides["inventory.packages"], err = packagesWithPURL(out.Inventory.Packages); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var b bytes.Buffer
+	if err := project(&b, data, tree, "", overrides); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func packagesWithPURL(pkgs []*extractor.Package) (json.RawMessage, error) {
+	type withPURL struct {
+		*extractor.Package
+		PURL string
+	}
+	list := make([]withPURL, len(pkgs))
+	for i, pkg := range pkgs {
+		list[i].Package = pkg
+		if p := pkg.PURL(); p != nil {
+			list[i].PURL = p.String()
+		}
+	}
+	return json.Marshal(list)
+}
+
+// project writes the parts of the JSON value raw at path selected by tree.
+// Arrays are projected element-wise; scalars are kept as is.
+func project(b *bytes.Buffer, raw json.RawMessage, tree fieldTree, path string, overrides map[string]json.RawMessage) error {
+	if tree == nil {
+		b.Write(raw)
+		return nil
+	}
+	if o, ok := overrides[path]; ok {
+		raw = o
+	}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('['):
+		b.WriteByte('[')
+		for first := true; d.More(); first = false {
+			var elem json.RawMessage
+			if err := d.Decode(&elem); err != nil {
+				return err
+			}
+			if !first {
+				b.WriteByte(',')
+			}
+			if err := project(b, elem, tree, path, nil); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case json.Delim('{'):
+		b.WriteByte('{')
+		first := true
+		for d.More() {
+			tok, err := d.Token()
+			if err != nil {
+				return err
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return errors.New("invalid object key")
+			}
+			var value json.RawMessage
+			if err := d.Decode(&value); err != nil {
+				return err
+			}
+			child, ok := tree[strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+			if !first {
+				b.WriteByte(',')
+			}
+			first = false
+			k, _ := json.Marshal(key)
+			b.Write(k)
+			b.WriteByte(':')
+			if err := project(b, value, child, strings.TrimPrefix(path+"."+strings.ToLower(key), "."), overrides); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	default:
+		b.Write(raw)
+	}
+	return nil
+}
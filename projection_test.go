@@ -0,0 +1,81 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// projectedResult is a trimmed-down result document.
+const projectedResult = `{"Version":"0.3.6","Status":{"Status":"succeeded"},` +
+	`"PluginStatus":[{"Name":"javascript/packagelockjson","Version":0}],` +
+	`"Inventory":{"Packages":[` +
+	`{"Name":"lodash","Version":"4.17.20","Locations":["package-lock.json"]},` +
+	`{"Name":"minimist","Version":"1.2.5","Locations":["package-lock.json"]}],` +
+	`"Secrets":[{"Location":".env"}]}}`
+
+func Example_project() {
+	var b bytes.Buffer
+	fields := parseOutputFields([]string{"packages.name", "PACKAGES.Version"})
+	if err := project(&b, []byte(projectedResult), fields, "", nil); err != nil {
+		panic(err)
+	}
+	fmt.Println(b.String())
+	// Output: {"Inventory":{"Packages":[{"Name":"lodash","Version":"4.17.20"},{"Name":"minimist","Version":"1.2.5"}]}}
+}
+
+func TestProject(t *testing.T) {
+	selected := func(fields ...string) string {
+		t.Helper()
+		var b bytes.Buffer
+		if err := project(&b, []byte(projectedResult), parseOutputFields(fields), "", nil); err != nil {
+			t.Fatalf("project(%q) error: %v", fields, err)
+		}
+		return b.String()
+	}
+
+	if got := selected(); got != projectedResult {
+		t.Errorf("project() without fields = %s, want the whole result", got)
+	}
+	if got, want := selected("status"), `{"Status":{"Status":"succeeded"},"PluginStatus":[{"Name":"javascript/packagelockjson","Version":0}]}`; got != want {
+		t.Errorf("project(status) = %s, want %s", got, want)
+	}
+	// Selecting a whole value wins over selecting part of it, in any order
+	whole := `{"Inventory":{"Secrets":[{"Location":".env"}]}}`
+	if got := selected("secrets.location", "secrets"); got != whole {
+		t.Errorf("project(secrets.location, secrets) = %s, want %s", got, whole)
+	}
+	if got := selected("secrets", "secrets.location"); got != whole {
+		t.Errorf("project(secrets, secrets.location) = %s, want %s", got, whole)
+	}
+	if got, want := selected("version", "inventory.packages.missing"), `{"Version":"0.3.6","Inventory":{"Packages":[{},{}]}}`; got != want {
+		t.Errorf("project(version, inventory.packages.missing) = %s, want %s", got, want)
+	}
+}
+
+func TestCheckOutputField(t *testing.T) {
+	for _, f := range []string{"packages", "Packages.purl", "findings", "status", "inventory.packages.name", "PluginStatus"} {
+		if err := checkOutputField(f); err != nil {
+			t.Errorf("checkOutputField(%q) error: %v", f, err)
+		}
+	}
+	for _, f := range []string{"", "packages.", ".name", "pkgs", "inventory..packages"} {
+		if err := checkOutputField(f); err == nil {
+			t.Errorf("checkOutputField(%q) succeeded, want an error", f)
+		}
+	}
+}
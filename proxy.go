@@ -0,0 +1,108 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// proxyAuthHeaderSize is the size of the buffer the host's proxy auth
+// callback writes the header value into. Kerberos tokens of users in many
+// groups exceed 8 KiB once encoded.
+const proxyAuthHeaderSize = 65536
+
+// proxyAuthenticator returns the Proxy-Authorization value to send to
+// proxyURL for a request to target, e.g. "Negotiate <token>", or "" to send
+// none.
+type proxyAuthenticator func(proxyURL, target string) (string, error)
+
+var proxyAuth struct {
+	mu sync.RWMutex
+	// Basic credentials, used if no authenticator is installed
+	basic         string
+	authenticator proxyAuthenticator
+}
+
+// setProxyCredentials sets the user name and password sent to the proxy with
+// Basic authentication, or removes them if both are empty.
+func setProxyCredentials(username, password string) {
+	applyEnv()
+	installTransport()
+	proxyAuth.mu.Lock()
+	defer proxyAuth.mu.Unlock()
+	proxyAuth.basic = ""
+	if username != "" || password != "" {
+		proxyAuth.basic = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+}
+
+// setProxyAuthenticator installs the authenticator asked for the credentials
+// of each proxied connection, or removes it if a is nil.
+func setProxyAuthenticator(a proxyAuthenticator) {
+	applyEnv()
+	installTransport()
+	proxyAuth.mu.Lock()
+	defer proxyAuth.mu.Unlock()
+	proxyAuth.authenticator = a
+}
+
+// proxyAuthorization returns the Proxy-Authorization value for a request to
+// target through proxyURL. Credentials in the proxy URL itself are added by
+// net/http and take precedence.
+func proxyAuthorization(proxyURL *url.URL, target string) (string, error) {
+	proxyAuth.mu.RLock()
+	basic, authenticate := proxyAuth.basic, proxyAuth.authenticator
+	proxyAuth.mu.RUnlock()
+	if authenticate != nil {
+		return authenticate(proxyURL.Redacted(), target)
+	}
+	return basic, nil
+}
+
+// configureProxyAuth makes t authenticate to its proxy when tunneling HTTPS
+// requests. Plain HTTP requests are authorized by withProxyAuth.
+func configureProxyAuth(t *http.Transport) {
+	t.GetProxyConnectHeader = func(_ context.Context, proxyURL *url.URL, target string) (http.Header, error) {
+		v, err := proxyAuthorization(proxyURL, target)
+		if err != nil || v == "" {
+			return nil, err
+		}
+		return http.Header{"Proxy-Authorization": {v}}, nil
+	}
+}
+
+// withProxyAuth returns req with a Proxy-Authorization header if it is a
+// plain HTTP request sent through an authenticated proxy by base.
+func withProxyAuth(base http.RoundTripper, req *http.Request) (*http.Request, error) {
+	t, ok := base.(*http.Transport)
+	if !ok || t.Proxy == nil || req.URL.Scheme != "http" || req.Header.Get("Proxy-Authorization") != "" {
+		return req, nil
+	}
+	proxyURL, err := t.Proxy(req)
+	if err != nil || proxyURL == nil || proxyURL.User != nil {
+		return req, err
+	}
+	v, err := proxyAuthorization(proxyURL, req.URL.Host)
+	if err != nil || v == "" {
+		return req, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Proxy-Authorization", v)
+	return req, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// pseudoFSTypes are the filesystem types whose mounts are skipped unless
+// scan_pseudo_filesystems is set. They are generated by the kernel, hold no
+// packages and some are expensive or endless to walk.
+var pseudoFSTypes = map[string]bool{
+	"autofs":      true,
+	"binfmt_misc": true,
+	"bpf":         true,
+	"cgroup":      true,
+	"cgroup2":     true,
+	"configfs":    true,
+	"debugfs":     true,
+	"devpts":      true,
+	"devtmpfs":    true,
+	"efivarfs":    true,
+	"fusectl":     true,
+	"hugetlbfs":   true,
+	"mqueue":      true,
+	"nsfs":        true,
+	"proc":        true,
+	"pstore":      true,
+	"rpc_pipefs":  true,
+	"securityfs":  true,
+	"selinuxfs":   true,
+	"sysfs":       true,
+	"tracefs":     true,
+}
+
+// pseudoFSDirs returns the mount points of pseudo-filesystems, and /proc,
+// /sys and /dev in case the mount table can't be read.
+func pseudoFSDirs() []string {
+	dirs := []string{"/dev", "/proc", "/sys"}
+	data, err := os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return dirs
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !pseudoFSTypes[fields[2]] {
+			continue
+		}
+		dirs = append(dirs, unescapeMountPath(fields[1]))
+	}
+	slices.Sort(dirs)
+	return slices.Compact(dirs)
+}
+
+// unescapeMountPath decodes the octal escapes of spaces, tabs, newlines and
+// backslashes in a mount point of /proc/self/mounts.
+func unescapeMountPath(p string) string {
+	if !strings.Contains(p, "\\") {
+		return p
+	}
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] == '\\' && i+3 < len(p) {
+			if c, err := strconv.ParseUint(p[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(p[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"slices"
+
+	cpb "github.com/google/osv-scalibr/binary/proto/config_go_proto"
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/extractor/filesystem/language/python/requirements"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/plugin"
+	pl "github.com/google/osv-scalibr/plugin/list"
+)
+
+// Values of the python_requirements option.
+const (
+	// Report pinned requirements and the bound of ranged ones (SCALIBR's
+	// offline behavior)
+	pythonRequirementsBestEffort = "best_effort"
+	// Only report requirements pinned with == or ===
+	pythonRequirementsPinned = "pinned"
+	// Resolve the full dependency graph against PyPI
+	pythonRequirementsResolved = "resolved"
+)
+
+var pythonRequirementsModes = []string{pythonRequirementsBestEffort, pythonRequirementsPinned, pythonRequirementsResolved}
+
+const (
+	pythonRequirementsName    = "python/requirements"
+	pythonRequirementsNetName = "python/requirementsnet"
+)
+
+// applyPythonRequirementsMode swaps the offline requirements.txt extractor
+// for the resolving one when mode is pythonRequirementsResolved.
+func applyPythonRequirementsMode(plugins []plugin.Plugin, mode string, cfg *cpb.PluginConfig) ([]plugin.Plugin, error) {
+	if mode != pythonRequirementsResolved {
+		return plugins, nil
+	}
+	i := slices.IndexFunc(plugins, func(p plugin.Plugin) bool { return p.Name() == pythonRequirementsName })
+	if i < 0 {
+		return plugins, nil
+	}
+	resolver, err := pl.FromNames([]string{pythonRequirementsNetName}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	plugins = slices.Delete(slices.Clone(plugins), i, i+1)
+	if !slices.ContainsFunc(plugins, func(p plugin.Plugin) bool { return p.Name() == pythonRequirementsNetName }) {
+		plugins = append(plugins, resolver...)
+	}
+	return plugins, nil
+}
+
+// dropUnpinnedRequirements removes the requirements.txt packages that aren't
+// pinned to an exact version.
+func dropUnpinnedRequirements(inv *inventory.Inventory) {
+	dropped := make(map[*extractor.Package]bool)
+	packages := inv.Packages[:0]
+	for _, pkg := range inv.Packages {
+		if m, ok := pkg.Metadata.(*requirements.Metadata); ok && slices.Contains(pkg.Plugins, pythonRequirementsName) {
+			if m.VersionComparator != "==" && m.VersionComparator != "===" {
+				dropped[pkg] = true
+				continue
+			}
+		}
+		packages = append(packages, pkg)
+	}
+	inv.Packages = packages
+
+	vulns := inv.PackageVulns[:0]
+	for _, v := range inv.PackageVulns {
+		if v.Package != nil && dropped[v.Package] {
+			continue
+		}
+		vulns = append(vulns, v)
+	}
+	inv.PackageVulns = vulns
+}
@@ -0,0 +1,191 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// networkLimits throttle the outbound HTTP requests of network-enabled
+// plugins (OSV, package registries). A zero value is unlimited.
+type networkLimits struct {
+	// Minimum spacing between request starts
+	interval time.Duration
+	// Concurrency cap, nil if unlimited
+	sem chan struct{}
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newNetworkLimits(requestsPerSecond float64, maxConcurrent int) *networkLimits {
+	l := &networkLimits{}
+	if requestsPerSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	if maxConcurrent > 0 {
+		l.sem = make(chan struct{}, maxConcurrent)
+	}
+	return l
+}
+
+// acquire waits for a request slot. The returned function releases it.
+func (l *networkLimits) acquire(ctx context.Context) (func(), error) {
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if l.sem != nil {
+			<-l.sem
+		}
+	}
+	if err := l.wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// wait blocks until the request rate allows another request.
+func (l *networkLimits) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backOff delays further requests by d, e.g. as asked by a Retry-After
+// header.
+func (l *networkLimits) backOff(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.next) {
+		l.next = until
+	}
+}
+
+// limitedTransport applies the current network limits to the requests made
+// through it, authenticates to the proxy and custom vulnerability feeds and
+// splits
+// OSV batch queries as configured by the scan issuing them. It replaces http.DefaultTransport, which the plugins' HTTP clients
+// use.
+type limitedTransport struct {
+	base   http.RoundTripper
+	limits atomic.Pointer[networkLimits]
+}
+
+var (
+	transportOnce sync.Once
+	transport     *limitedTransport
+)
+
+// setNetworkLimits replaces the process-wide network limits. Requests in
+// flight keep the limits they started with.
+func setNetworkLimits(requestsPerSecond float64, maxConcurrent int) {
+	// The proxy override configures the original transport
+	applyEnv()
+	storeNetworkLimits(newNetworkLimits(requestsPerSecond, maxConcurrent))
+}
+
+func storeNetworkLimits(l *networkLimits) {
+	installTransport()
+	transport.limits.Store(l)
+}
+
+// installTransport replaces http.DefaultTransport by the bindings' transport.
+func installTransport() {
+	transportOnce.Do(func() {
+		if t, ok := http.DefaultTransport.(*http.Transport); ok {
+			configureProxyAuth(t)
+		}
+		transport = &limitedTransport{base: http.DefaultTransport}
+		transport.limits.Store(newNetworkLimits(0, 0))
+		http.DefaultTransport = transport
+	})
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, err := authorizeFeedRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if req, err = withProxyAuth(t.base, req); err != nil {
+		return nil, err
+	}
+	if size := osvBatchSize(req.Context()); size > 0 && isOSVBatchQuery(req) {
+		return t.splitOSVBatch(req, size)
+	}
+	return t.send(req)
+}
+
+// send makes a single request within the network limits.
+func (t *limitedTransport) send(req *http.Request) (*http.Response, error) {
+	l := t.limits.Load()
+	release, err := l.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			l.backOff(time.Duration(secs) * time.Second)
+		}
+	}
+	// The slot is held until the response has been read
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody releases a request slot when the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
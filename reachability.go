@@ -0,0 +1,115 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/log"
+)
+
+// Verdicts returned by a reachability analyzer, mirroring ScalibrReachability.
+const (
+	reachabilityUnknown     = 0
+	reachabilityReachable   = 1
+	reachabilityUnreachable = 2
+)
+
+var reachabilityNames = map[int]string{
+	reachabilityUnknown:     "unknown",
+	reachabilityReachable:   "reachable",
+	reachabilityUnreachable: "unreachable",
+}
+
+// reachabilityAnalyzer decides whether the vulnerable code described by the
+// JSON-encoded reachabilityQuery is used, returning one of the verdicts.
+type reachabilityAnalyzer func(query []byte) int
+
+// reachabilityQuery is the document passed to the analyzer for each
+// vulnerable package.
+type reachabilityQuery struct {
+	VulnID    string
+	Name      string
+	Version   string
+	PURL      string `json:",omitempty"`
+	Locations []string
+}
+
+// reachabilityResult records an analyzer verdict in the scan output.
+type reachabilityResult struct {
+	VulnID  string
+	Package string
+	Version string
+	Status  string
+}
+
+var reachabilityHook struct {
+	mu       sync.RWMutex
+	analyzer reachabilityAnalyzer
+}
+
+// setReachabilityAnalyzer installs the analyzer consulted by every scan, or
+// removes it if a is nil.
+func setReachabilityAnalyzer(a reachabilityAnalyzer) {
+	reachabilityHook.mu.Lock()
+	defer reachabilityHook.mu.Unlock()
+	reachabilityHook.analyzer = a
+}
+
+// analyzeReachability asks the installed analyzer about each package
+// vulnerability in inv. Locations must still be absolute so the analyzer can
+// open the affected files.
+func analyzeReachability(inv *inventory.Inventory) []reachabilityResult {
+	reachabilityHook.mu.RLock()
+	analyzer := reachabilityHook.analyzer
+	reachabilityHook.mu.RUnlock()
+	if analyzer == nil {
+		return nil
+	}
+
+	var results []reachabilityResult
+	for _, v := range inv.PackageVulns {
+		if v.Package == nil {
+			continue
+		}
+		q := reachabilityQuery{
+			VulnID:    vulnID(v),
+			Name:      v.Package.Name,
+			Version:   v.Package.Version,
+			Locations: v.Package.Locations,
+		}
+		if p := v.Package.PURL(); p != nil {
+			q.PURL = p.String()
+		}
+		data, err := json.Marshal(q)
+		if err != nil {
+			log.Warnf("reachability: failed to marshal query for %s: %v", q.VulnID, err)
+			continue
+		}
+		status, ok := reachabilityNames[analyzer(data)]
+		if !ok {
+			status = reachabilityNames[reachabilityUnknown]
+		}
+		results = append(results, reachabilityResult{
+			VulnID:  q.VulnID,
+			Package: q.Name,
+			Version: q.Version,
+			Status:  status,
+		})
+	}
+	return results
+}
@@ -0,0 +1,295 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/osv-scalibr/inventory"
+)
+
+// remediationPatch describes the patch written for one manifest.
+type remediationPatch struct {
+	// Manifest location relative to its scan root
+	File string
+	// Unified diff and patched copy of the manifest
+	PatchFile   string
+	UpdatedFile string
+	Packages    []*remediatedPackage
+}
+
+// remediatedPackage is a package upgrade applied by a patch.
+type remediatedPackage struct {
+	Name    string
+	From    string
+	To      string
+	VulnIDs []string
+}
+
+// remediator returns the edits upgrading one package in a manifest, or none
+// if the package can't be located.
+type remediator func(f *textFile, u *remediatedPackage) []lineEdit
+
+// remediators maps the manifest base names that can be patched to their
+// remediator.
+var remediators = map[string]remediator{
+	"package-lock.json":   npmLockEdits,
+	"npm-shrinkwrap.json": npmLockEdits,
+	"pom.xml":             pomEdits,
+}
+
+// writeRemediations upgrades each vulnerable package in inv to the lowest
+// version fixing all of its fixable vulnerabilities, writing a patch and a
+// patched copy of every affected manifest below dir.
+func writeRemediations(inv *inventory.Inventory, root, dir string) ([]remediationPatch, error) {
+	upgrades := map[string][]*remediatedPackage{}
+	for _, v := range inv.PackageVulns {
+		pkg := v.Package
+		if pkg == nil || len(pkg.Locations) == 0 {
+			continue
+		}
+		file := normalizeLocation(root, pkg.Locations[0])
+		if remediators[path.Base(file)] == nil {
+			continue
+		}
+		rec := parseOSVRecord(v)
+		if rec == nil {
+			continue
+		}
+		fix := rec.fixedVersion(pkg.Name, pkg.Version)
+		if fix == "" {
+			continue
+		}
+
+		i := slices.IndexFunc(upgrades[file], func(u *remediatedPackage) bool {
+			return u.Name == pkg.Name && u.From == pkg.Version
+		})
+		if i < 0 {
+			upgrades[file] = append(upgrades[file], &remediatedPackage{Name: pkg.Name, From: pkg.Version})
+			i = len(upgrades[file]) - 1
+		}
+		u := upgrades[file][i]
+		if compareVersions(fix, u.To) > 0 {
+			u.To = fix
+		}
+		u.VulnIDs = append(u.VulnIDs, rec.ID)
+	}
+
+	var patches []remediationPatch
+	for _, file := range slices.Sorted(maps.Keys(upgrades)) {
+		p, err := writeRemediation(root, file, dir, upgrades[file])
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			patches = append(patches, *p)
+		}
+	}
+	return patches, nil
+}
+
+// writeRemediation applies the upgrades to a single manifest. It returns nil
+// if none of them could be applied.
+func writeRemediation(root, file, dir string, upgrades []*remediatedPackage) (*remediationPatch, error) {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(file)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	f := splitLines(data)
+	edit := remediators[path.Base(file)]
+
+	p := &remediationPatch{File: file}
+	var edits []lineEdit
+	for _, u := range upgrades {
+		if e := edit(f, u); len(e) > 0 {
+			edits = append(edits, e...)
+			p.Packages = append(p.Packages, u)
+		}
+	}
+	if len(edits) == 0 {
+		return nil, nil
+	}
+
+	p.UpdatedFile = filepath.Join(dir, filepath.FromSlash(file))
+	p.PatchFile = p.UpdatedFile + ".patch"
+	if err := os.MkdirAll(filepath.Dir(p.UpdatedFile), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(p.UpdatedFile, f.apply(edits), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(p.PatchFile, []byte(f.unifiedDiff(file, edits)), 0o644); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+var (
+	jsonObjectKey = regexp.MustCompile(`^\s*"([^"]*)":\s*\{\s*$`)
+	npmVersion    = regexp.MustCompile(`^(\s*"version":\s*")([^"]*)(".*)$`)
+	npmResolved   = regexp.MustCompile(`^\s*"resolved":`)
+	npmIntegrity  = regexp.MustCompile(`^\s*"integrity":`)
+)
+
+// npmLockEdits upgrades every entry of the package in a pretty-printed
+// package-lock.json, covering both the "packages" (v2/v3) and the legacy
+// "dependencies" layout. The stale integrity hash is dropped so npm
+// recomputes it on the next install.
+func npmLockEdits(f *textFile, u *remediatedPackage) []lineEdit {
+	var edits []lineEdit
+	for i := 0; i < len(f.lines); i++ {
+		m := jsonObjectKey.FindStringSubmatch(f.lines[i])
+		if m == nil || (m[1] != u.Name && !strings.HasSuffix(m[1], "node_modules/"+u.Name)) {
+			continue
+		}
+
+		var entry []lineEdit
+		matched := false
+		depth := 1
+		for j := i + 1; j < len(f.lines) && depth > 0; j++ {
+			line := f.lines[j]
+			if depth == 1 {
+				switch {
+				case npmVersion.MatchString(line):
+					if v := npmVersion.FindStringSubmatch(line); v[2] == u.From {
+						matched = true
+						entry = append(entry, lineEdit{line: j, repl: []string{v[1] + u.To + v[3]}})
+					}
+				case npmResolved.MatchString(line):
+					if old := "-" + u.From + ".tgz"; strings.Contains(line, old) {
+						entry = append(entry, lineEdit{line: j, repl: []string{strings.Replace(line, old, "-"+u.To+".tgz", 1)}})
+					}
+				case npmIntegrity.MatchString(line):
+					entry = append(entry, lineEdit{line: j, repl: nil})
+					if !strings.HasSuffix(strings.TrimSpace(line), ",") {
+						// The previous member becomes the last one
+						entry = dropTrailingComma(f, entry, j-1)
+					}
+				}
+			}
+			depth += jsonBraceDelta(line)
+		}
+		if matched {
+			edits = append(edits, entry...)
+		}
+	}
+	return edits
+}
+
+// dropTrailingComma removes the trailing comma of line i, on top of its edit
+// in edits if there is one.
+func dropTrailingComma(f *textFile, edits []lineEdit, i int) []lineEdit {
+	for k := range edits {
+		if edits[k].line == i && len(edits[k].repl) == 1 {
+			edits[k].repl[0] = strings.TrimSuffix(edits[k].repl[0], ",")
+			return edits
+		}
+	}
+	return append(edits, lineEdit{line: i, repl: []string{strings.TrimSuffix(f.lines[i], ",")}})
+}
+
+// jsonBraceDelta returns the change in object nesting depth caused by line.
+func jsonBraceDelta(line string) int {
+	delta := 0
+	inString, escaped := false, false
+	for _, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case !inString && c == '{':
+			delta++
+		case !inString && c == '}':
+			delta--
+		}
+	}
+	return delta
+}
+
+var (
+	pomElement  = regexp.MustCompile(`<(groupId|artifactId|version)>\s*([^<]*?)\s*</(?:groupId|artifactId|version)>`)
+	pomProperty = regexp.MustCompile(`^\$\{([^}]+)\}$`)
+)
+
+// pomEdits upgrades the dependency declarations of the package in a pom.xml.
+// Versions defined through a property are upgraded at the property.
+func pomEdits(f *textFile, u *remediatedPackage) []lineEdit {
+	var edits []lineEdit
+	inDependency := false
+	var groupID, artifactID, version string
+	versionLine := -1
+	for i, line := range f.lines {
+		if strings.Contains(line, "<dependency>") {
+			inDependency = true
+			groupID, artifactID, version, versionLine = "", "", "", -1
+		}
+		if !inDependency {
+			continue
+		}
+		// Keep the first occurrence so exclusions don't override the
+		// dependency's own coordinates
+		for _, m := range pomElement.FindAllStringSubmatch(line, -1) {
+			switch {
+			case m[1] == "groupId" && groupID == "":
+				groupID = m[2]
+			case m[1] == "artifactId" && artifactID == "":
+				artifactID = m[2]
+			case m[1] == "version" && versionLine < 0:
+				version, versionLine = m[2], i
+			}
+		}
+		if !strings.Contains(line, "</dependency>") {
+			continue
+		}
+		inDependency = false
+		if groupID+":"+artifactID != u.Name || versionLine < 0 {
+			continue
+		}
+		if version == u.From {
+			if e, ok := replaceElement(f, versionLine, "version", u.From, u.To); ok {
+				edits = append(edits, e)
+			}
+		} else if m := pomProperty.FindStringSubmatch(version); m != nil {
+			for j := range f.lines {
+				if e, ok := replaceElement(f, j, m[1], u.From, u.To); ok {
+					edits = append(edits, e)
+					break
+				}
+			}
+		}
+	}
+	return edits
+}
+
+// replaceElement rewrites the value of an XML element on line i, reporting
+// whether the line holds the element with the expected value.
+func replaceElement(f *textFile, i int, name, from, to string) (lineEdit, bool) {
+	old := "<" + name + ">" + from + "</" + name + ">"
+	if !strings.Contains(f.lines[i], old) {
+		return lineEdit{}, false
+	}
+	line := strings.Replace(f.lines[i], old, "<"+name+">"+to+"</"+name+">", 1)
+	return lineEdit{line: i, repl: []string{line}}, true
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const packageLockJSON = `{
+  "name": "app",
+  "lockfileVersion": 3,
+  "packages": {
+    "": {
+      "dependencies": {
+        "lodash": "^4.17.0"
+      }
+    },
+    "node_modules/lodash": {
+      "version": "4.17.20",
+      "resolved": "https://registry.npmjs.org/lodash/-/lodash-4.17.20.tgz",
+      "integrity": "sha512-PlhdFcillOINfeV7Ni6oF1TAEayyZBoZ8bcshTHqOYJYlrqzRK5hagpagky5o4HfCzzd1TRkXPMFq6cKk9rGmA=="
+    },
+    "node_modules/minimist": {
+      "version": "1.2.5",
+      "integrity": "sha512-FM9nNUYrRBAELZQT3xeZQ7fmMOBg6nWNmJKTcgsJeaLstP/UODVpGsr5OhXhhXg6f+qtJ8uiZ+PUxkDWcgIXLw=="
+    }
+  }
+}
+`
+
+const pomXML = `<project>
+  <properties>
+    <jackson.version>2.9.10</jackson.version>
+  </properties>
+  <dependencies>
+    <dependency>
+      <groupId>com.fasterxml.jackson.core</groupId>
+      <artifactId>jackson-databind</artifactId>
+      <version>${jackson.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>org.apache.logging.log4j</groupId>
+      <artifactId>log4j-core</artifactId>
+      <version>2.14.1</version>
+      <exclusions>
+        <exclusion>
+          <groupId>org.apache.logging.log4j</groupId>
+          <artifactId>log4j-api</artifactId>
+        </exclusion>
+      </exclusions>
+    </dependency>
+  </dependencies>
+</project>
+`
+
+func TestWriteRemediation(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"web/package-lock.json": packageLockJSON,
+		"svc/pom.xml":           pomXML,
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("package-lock.json", func(t *testing.T) {
+		dir := t.TempDir()
+		upgrades := []*remediatedPackage{
+			{Name: "lodash", From: "4.17.20", To: "4.17.21"},
+			{Name: "minimist", From: "1.2.5", To: "1.2.6"},
+			// Not the locked version, so left alone
+			{Name: "lodash", From: "3.10.1", To: "4.17.21"},
+		}
+		p, err := writeRemediation(root, "web/package-lock.json", dir, upgrades)
+		if err != nil {
+			t.Fatalf("writeRemediation() error: %v", err)
+		}
+		if p == nil || len(p.Packages) != 2 {
+			t.Fatalf("writeRemediation() = %+v, want a patch upgrading 2 packages", p)
+		}
+		updated := readFile(t, p.UpdatedFile)
+		for _, want := range []string{
+			`"version": "4.17.21",`,
+			`"resolved": "https://registry.npmjs.org/lodash/-/lodash-4.17.21.tgz"`,
+			// The integrity hashes are dropped, leaving no trailing comma
+			`"version": "1.2.6"` + "\n    }",
+		} {
+			if !strings.Contains(updated, want) {
+				t.Errorf("updated package-lock.json lacks %q:\n%s", want, updated)
+			}
+		}
+		if strings.Contains(updated, "integrity") {
+			t.Errorf("updated package-lock.json keeps an integrity hash:\n%s", updated)
+		}
+		patch := readFile(t, p.PatchFile)
+		if !strings.HasPrefix(patch, "--- a/web/package-lock.json\n+++ b/web/package-lock.json\n@@ ") {
+			t.Errorf("patch doesn't start with the file header:\n%s", patch)
+		}
+	})
+
+	t.Run("pom.xml", func(t *testing.T) {
+		dir := t.TempDir()
+		upgrades := []*remediatedPackage{
+			{Name: "com.fasterxml.jackson.core:jackson-databind", From: "2.9.10", To: "2.9.10.8"},
+			{Name: "org.apache.logging.log4j:log4j-core", From: "2.14.1", To: "2.17.1"},
+		}
+		p, err := writeRemediation(root, "svc/pom.xml", dir, upgrades)
+		if err != nil {
+			t.Fatalf("writeRemediation() error: %v", err)
+		}
+		if p == nil {
+			t.Fatal("writeRemediation() = nil, want a patch")
+		}
+		// The property is upgraded rather than the dependency referring to it,
+		// and the exclusion doesn't count as the dependency
+		want := strings.NewReplacer(
+			"<jackson.version>2.9.10<", "<jackson.version>2.9.10.8<",
+			"<version>2.14.1<", "<version>2.17.1<",
+		).Replace(pomXML)
+		if got := readFile(t, p.UpdatedFile); got != want {
+			t.Errorf("updated pom.xml:\n%s\nwant:\n%s", got, want)
+		}
+		if rel, err := filepath.Rel(dir, p.UpdatedFile); err != nil || rel != filepath.Join("svc", "pom.xml") {
+			t.Errorf("updated pom.xml written to %s, want it below %s at svc/pom.xml", p.UpdatedFile, dir)
+		}
+	})
+
+	t.Run("nothing to upgrade", func(t *testing.T) {
+		dir := t.TempDir()
+		p, err := writeRemediation(root, "svc/pom.xml", dir, []*remediatedPackage{{Name: "junit:junit", From: "4.12", To: "4.13.1"}})
+		if err != nil || p != nil {
+			t.Errorf("writeRemediation() = %+v, %v, want nil, nil", p, err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("writeRemediation() wrote %d files without an upgrade", len(entries))
+		}
+	})
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
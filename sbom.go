@@ -0,0 +1,232 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/converter"
+	convspdx "github.com/google/osv-scalibr/converter/spdx"
+	spdxjson "github.com/spdx/tools-golang/json"
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+	"github.com/spdx/tools-golang/tagvalue"
+	spdxyaml "github.com/spdx/tools-golang/yaml"
+)
+
+// sbomOptions holds the document metadata applied to generated SBOMs.
+type sbomOptions struct {
+	// SPDX document name and namespace.
+	DocumentName      string `json:"document_name" yaml:"document_name" toml:"document_name"`
+	DocumentNamespace string `json:"document_namespace" yaml:"document_namespace" toml:"document_namespace"`
+	// SPDX creators in "Type: Name" form, e.g. "Organization: Example Inc."
+	Creators []string `json:"creators" yaml:"creators" toml:"creators"`
+	// Root component, the CycloneDX metadata component or the SPDX main
+	// package.
+	ComponentName    string `json:"component_name" yaml:"component_name" toml:"component_name"`
+	ComponentVersion string `json:"component_version" yaml:"component_version" toml:"component_version"`
+	ComponentType    string `json:"component_type" yaml:"component_type" toml:"component_type"`
+	// Authors of the document, added to the SPDX creators as persons.
+	Authors []string `json:"authors" yaml:"authors" toml:"authors"`
+	// Supplier of the root component in "Type: Name" form, e.g.
+	// "Organization: Example Inc."
+	Supplier string `json:"supplier" yaml:"supplier" toml:"supplier"`
+	// CycloneDX specification version, one of cdxSpecVersions. Defaults to
+	// the latest one.
+	CDXSpecVersion string `json:"cdx_spec_version" yaml:"cdx_spec_version" toml:"cdx_spec_version"`
+}
+
+// cdxSpecVersions are the supported values of cdx_spec_version.
+var cdxSpecVersions = map[string]cyclonedx.SpecVersion{
+	"1.5": cyclonedx.SpecVersion1_5,
+	"1.6": cyclonedx.SpecVersion1_6,
+}
+
+// parseSBOMOptions decodes the JSON document options. An empty string yields
+// the default options.
+func parseSBOMOptions(s string) (*sbomOptions, error) {
+	opts := &sbomOptions{}
+	if strings.TrimSpace(s) == "" {
+		return opts, nil
+	}
+	if err := json.Unmarshal([]byte(s), opts); err != nil {
+		return nil, fmt.Errorf("invalid SBOM document options: %w", err)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// validate checks the supplier, creators and namespace, which are otherwise
+// only found invalid when a document is generated.
+func (o *sbomOptions) validate() error {
+	if _, _, err := o.supplier(); err != nil {
+		return err
+	}
+	if _, err := o.spdxConfig(); err != nil {
+		return err
+	}
+	if o.DocumentNamespace != "" {
+		if u, err := url.Parse(o.DocumentNamespace); err != nil || !u.IsAbs() || u.Fragment != "" {
+			return fmt.Errorf("invalid SBOM document namespace %q, expected an absolute URI without fragment", o.DocumentNamespace)
+		}
+	}
+	if _, ok := cdxSpecVersions[o.CDXSpecVersion]; o.CDXSpecVersion != "" && !ok {
+		return fmt.Errorf("unsupported CycloneDX spec version %q, expected one of %v", o.CDXSpecVersion, slices.Sorted(maps.Keys(cdxSpecVersions)))
+	}
+	return nil
+}
+
+// setSBOMOptionsJSON sets sbom_options from its JSON text. Parse errors are
+// reported when the options are validated.
+func (o *scanOptions) setSBOMOptionsJSON(s string) {
+	if s == "" {
+		return
+	}
+	o.SBOMOptions = &sbomOptions{}
+	if err := json.Unmarshal([]byte(s), o.SBOMOptions); err != nil {
+		o.sbomOptionsErr = fmt.Errorf("invalid SBOM document options JSON: %w", err)
+	}
+}
+
+// supplier splits the supplier into its type and name. Both are empty if
+// no supplier is set.
+func (o *sbomOptions) supplier() (string, string, error) {
+	if o.Supplier == "" {
+		return "", "", nil
+	}
+	sType, sName, ok := strings.Cut(o.Supplier, ":")
+	sType, sName = strings.TrimSpace(sType), strings.TrimSpace(sName)
+	if !ok || sName == "" || (sType != "Person" && sType != "Organization") {
+		return "", "", fmt.Errorf("invalid SBOM supplier %q, expected \"Person: Name\" or \"Organization: Name\"", o.Supplier)
+	}
+	return sType, sName, nil
+}
+
+func (o *sbomOptions) spdxConfig() (convspdx.Config, error) {
+	var creators []common.Creator
+	for _, c := range o.Creators {
+		cType, cName, ok := strings.Cut(c, ":")
+		if !ok {
+			return convspdx.Config{}, fmt.Errorf("invalid SPDX creator %q, expected \"Type: Name\"", c)
+		}
+		creators = append(creators, common.Creator{
+			CreatorType: strings.TrimSpace(cType),
+			Creator:     strings.TrimSpace(cName),
+		})
+	}
+	for _, a := range o.Authors {
+		creators = append(creators, common.Creator{CreatorType: "Person", Creator: a})
+	}
+	return convspdx.Config{
+		DocumentName:      o.DocumentName,
+		DocumentNamespace: o.DocumentNamespace,
+		Creators:          creators,
+	}, nil
+}
+
+func (o *sbomOptions) cdxConfig() converter.CDXConfig {
+	return converter.CDXConfig{
+		ComponentName:    o.ComponentName,
+		ComponentVersion: o.ComponentVersion,
+		ComponentType:    o.ComponentType,
+		Authors:          o.Authors,
+	}
+}
+
+// describeSPDXMain applies the root component metadata to the main package
+// of doc, which SCALIBR creates without name, version or supplier.
+func (o *sbomOptions) describeSPDXMain(doc *v2_3.Document) {
+	for _, p := range doc.Packages {
+		if id := strings.TrimPrefix(string(p.PackageSPDXIdentifier), convspdx.SPDXRefPrefix); !strings.HasPrefix(id, mainPackagePrefix) {
+			continue
+		}
+		if o.ComponentName != "" {
+			p.PackageName = o.ComponentName
+		}
+		if o.ComponentVersion != "" {
+			p.PackageVersion = o.ComponentVersion
+		}
+		if sType, sName, _ := o.supplier(); sName != "" {
+			p.PackageSupplier = &common.Supplier{Supplier: sName, SupplierType: sType}
+		}
+		return
+	}
+}
+
+// mainPackagePrefix starts the identifier of the main package of SCALIBR's
+// SPDX documents.
+const mainPackagePrefix = "Package-main-"
+
+// convertToSBOM renders the scan result in one of the supported SBOM formats:
+// spdx23-json, spdx23-tag-value, spdx23-yaml, cdx-json and cdx-xml.
+func convertToSBOM(r *scalibr.ScanResult, format string, opts *sbomOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "spdx23-json", "spdx23-tag-value", "spdx23-yaml":
+		cfg, err := opts.spdxConfig()
+		if err != nil {
+			return nil, err
+		}
+		doc := converter.ToSPDX23(r, cfg)
+		opts.describeSPDXMain(doc)
+		switch format {
+		case "spdx23-json":
+			err = spdxjson.Write(doc, &buf, spdxjson.Indent("  "))
+		case "spdx23-tag-value":
+			err = tagvalue.Write(doc, &buf)
+		default:
+			err = spdxyaml.Write(doc, &buf)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write SPDX document: %w", err)
+		}
+	case "cdx-json", "cdx-xml":
+		bomFormat := cyclonedx.BOMFileFormatJSON
+		if format == "cdx-xml" {
+			bomFormat = cyclonedx.BOMFileFormatXML
+		}
+		bom := converter.ToCDX(r, opts.cdxConfig())
+		if _, name, _ := opts.supplier(); name != "" && bom.Metadata != nil {
+			supplier := &cyclonedx.OrganizationalEntity{Name: name}
+			bom.Metadata.Supplier = supplier
+			if bom.Metadata.Component != nil {
+				bom.Metadata.Component.Supplier = supplier
+			}
+		}
+		enc := cyclonedx.NewBOMEncoder(&buf, bomFormat).SetPretty(true)
+		var err error
+		if version, ok := cdxSpecVersions[opts.CDXSpecVersion]; ok {
+			err = enc.EncodeVersion(bom, version)
+		} else {
+			err = enc.Encode(bom)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write CycloneDX document: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SBOM format %q", format)
+	}
+	return buf.Bytes(), nil
+}
@@ -16,15 +16,98 @@
 package main
 
 /*
+#include <stdint.h>
 #include <stdlib.h>
 #include <string.h>
 
+typedef enum {
+    SCALIBR_OK = 0,
+    SCALIBR_ERR_CONFIG = 1,
+    SCALIBR_ERR_PLUGIN_LOAD = 2,
+    SCALIBR_ERR_SCAN = 3,
+    SCALIBR_ERR_MARSHAL = 4,
+    SCALIBR_STOPPED_ON_FINDING = 5,
+    SCALIBR_MEMORY_LIMIT = 6,
+    SCALIBR_ERR_CANCELLED = 7,
+    SCALIBR_INODE_LIMIT = 8,
+    SCALIBR_PARTIAL = 9,
+    SCALIBR_ERR_IO = 10,
+    SCALIBR_ERR_IMAGE = 11,
+    SCALIBR_ERR_NOT_INITIALIZED = 12
+} ScalibrStatus;
+
 typedef struct {
     char* json_result;
     char* error_message;
     int status_code;
+    void* result_data;
+    int result_size;
 } ScanResult;
 
+typedef enum {
+    SCALIBR_PRIORITY_BACKGROUND = -1,
+    SCALIBR_PRIORITY_NORMAL = 0,
+    SCALIBR_PRIORITY_INTERACTIVE = 1
+} ScalibrPriority;
+
+typedef enum {
+    SCALIBR_SCAN_UNKNOWN = -1,
+    SCALIBR_SCAN_QUEUED = 0,
+    SCALIBR_SCAN_RUNNING = 1,
+    SCALIBR_SCAN_DONE = 2,
+    SCALIBR_SCAN_FAILED = 3
+} ScalibrScanState;
+
+// Receives the progress of a scan as JSON; the string is only valid during
+// the call.
+typedef void (*ScalibrProgressCallback)(char* progress_json, void* user_data);
+
+static inline void callProgressCallback(ScalibrProgressCallback cb, char* progress_json, void* user_data) {
+    cb(progress_json, user_data);
+}
+
+// Allocator for the buffers the library returns, see ScalibrSetAllocator.
+typedef void* (*ScalibrMallocFunc)(size_t size);
+typedef void (*ScalibrFreeFunc)(void* ptr);
+
+static inline void* callMalloc(ScalibrMallocFunc f, size_t size) {
+    return f ? f(size) : malloc(size);
+}
+
+static inline void callFree(ScalibrFreeFunc f, void* ptr) {
+    if (f) {
+        f(ptr);
+    } else {
+        free(ptr);
+    }
+}
+
+// Receives an item of the inventory as JSON while the scan runs; the string
+// is only valid during the call.
+typedef void (*ScalibrFindingCallback)(char* finding_json, void* user_data);
+
+static inline void callFindingCallback(ScalibrFindingCallback cb, char* finding_json, void* user_data) {
+    cb(finding_json, user_data);
+}
+
+typedef enum {
+    SCALIBR_LOG_DEBUG = 0,
+    SCALIBR_LOG_INFO = 1,
+    SCALIBR_LOG_WARN = 2,
+    SCALIBR_LOG_ERROR = 3
+} ScalibrLogLevel;
+
+// Receives a log message of the given ScalibrLogLevel; the string is only
+// valid during the call.
+typedef void (*ScalibrLogCallback)(int level, char* message, void* user_data);
+
+static inline void callLogCallback(ScalibrLogCallback cb, int level, char* message, void* user_data) {
+    cb(level, message, user_data);
+}
+
+// Count of a NULL-terminated string array
+#define SCALIBR_NULL_TERMINATED -1
+
 typedef struct {
     char* root_path;
     char** plugins;
@@ -34,147 +117,1460 @@ typedef struct {
     int max_file_size;
     int verbose;
     int offline;
+    char** include_paths;
+    int include_paths_count;
+    char** exclude_paths;
+    int exclude_paths_count;
+    int root_relative_paths;
+    int priority;
+    int stop_on_first_finding;
+    char* stop_min_severity;
+    char** stop_plugins;
+    int stop_plugins_count;
+    int detector_only;
+    char* remediation_dir;
+    char** binary_analyses;
+    int binary_analyses_count;
+    char* plugin_config;
+    int exclude_go_stdlib;
+    char* java_shaded_jars;
+    char* python_requirements;
+    char* js_workspaces;
+    char* output_format;
+    char* output_path;
+    int output_checksum;
+    int output_sections;
+    int osv_batch_size;
+    char* osv_base_url;
+    char** group_findings;
+    int group_findings_count;
+    char* package_rewrites;
+    int capture_output;
+    long long max_rss_bytes;
+    char** output_fields;
+    int output_fields_count;
+    ScalibrProgressCallback progress_callback;
+    void* progress_user_data;
+    char* sbom_options;
+    char** root_paths;
+    int root_paths_count;
+    char** dirs_to_skip;
+    int dirs_to_skip_count;
+    char* skip_dir_regex;
+    char* skip_dir_glob;
+    int max_inodes;
+    int store_absolute_path;
+    int error_on_fs_errors;
+    char* image_cache_dir;
+    long long image_cache_bytes;
+    long long max_tarball_bytes;
+    ScalibrFindingCallback finding_callback;
+    void* finding_user_data;
+    char** detectors;
+    int detectors_count;
+    int exclude_running_system;
+    char** annotators;
+    int annotators_count;
+    char* enrichers;
+    char* plugin_options;
+    char** include_plugins;
+    int include_plugins_count;
+    char** exclude_plugins;
+    int exclude_plugins_count;
+    char** include_ecosystems;
+    int include_ecosystems_count;
+    char** exclude_ecosystems;
+    int exclude_ecosystems_count;
+    long long max_read_bytes_per_second;
+    int max_iops;
+    int plugin_timeout_ms;
+    int scan_pseudo_filesystems;
+    int use_gitignore;
+    char* ignore_file;
+    int layer_result_cache;
+    int skip_hidden_files;
+    char* symlinks;
+    int hash_artifacts;
+    int include_file_metadata;
+    char* secret_redaction;
+    int include_licenses;
+    char* checkpoint_path;
+    int checkpoint_interval_ms;
+    char* previous_result;
 } ScanConfig;
+
+typedef struct {
+    char* username;
+    char* password;
+    char* token;
+} RegistryAuth;
+
+// Metadata of a file or directory of a ScalibrVFS.
+typedef struct {
+    long long size;
+    // Unix time in seconds
+    long long mod_time;
+    // Permission bits, e.g. 0644
+    unsigned int mode;
+    int is_dir;
+} ScalibrFileInfo;
+
+// Callbacks serving a filesystem to scan. Paths are slash-separated and
+// relative to its root, which is ".". They return a negative errno, e.g.
+// -ENOENT, on failure and may be called from any thread.
+typedef struct {
+    // Fills info for the file or directory at path and returns 0.
+    int (*stat)(const char* path, ScalibrFileInfo* info, void* user_data);
+    // Writes the names of the entries of the directory at path into out,
+    // each NUL-terminated, and returns the length of the list. If it exceeds
+    // out_size, the call is repeated with a buffer that large.
+    int (*read_dir)(const char* path, char* out, int out_size, void* user_data);
+    // Opens the file at path and returns a handle >= 0.
+    long long (*open)(const char* path, void* user_data);
+    // Reads up to size bytes at offset into buf and returns their number, 0
+    // at the end of the file.
+    int (*read)(long long handle, long long offset, char* buf, int size, void* user_data);
+    // Releases the handle; may be NULL.
+    void (*close)(long long handle, void* user_data);
+    void* user_data;
+} ScalibrVFS;
+
+static inline int callVFSStat(ScalibrVFS* vfs, char* path, ScalibrFileInfo* info) {
+    return vfs->stat(path, info, vfs->user_data);
+}
+
+static inline int callVFSReadDir(ScalibrVFS* vfs, char* path, char* out, int out_size) {
+    return vfs->read_dir(path, out, out_size, vfs->user_data);
+}
+
+static inline long long callVFSOpen(ScalibrVFS* vfs, char* path) {
+    return vfs->open(path, vfs->user_data);
+}
+
+static inline int callVFSRead(ScalibrVFS* vfs, long long handle, long long offset, char* buf, int size) {
+    return vfs->read(handle, offset, buf, size, vfs->user_data);
+}
+
+static inline void callVFSClose(ScalibrVFS* vfs, long long handle) {
+    if (vfs->close != NULL) {
+        vfs->close(handle, vfs->user_data);
+    }
+}
+
+typedef void (*ScalibrEventCallback)(char* event_json, void* user_data);
+
+static inline void callEventCallback(ScalibrEventCallback cb, char* event_json, void* user_data) {
+    cb(event_json, user_data);
+}
+
+typedef enum {
+    SCALIBR_REACHABILITY_UNKNOWN = 0,
+    SCALIBR_REACHABILITY_REACHABLE = 1,
+    SCALIBR_REACHABILITY_UNREACHABLE = 2
+} ScalibrReachability;
+
+// Returns a ScalibrReachability verdict for the vulnerable package described
+// by query_json; the string is only valid during the call.
+typedef int (*ScalibrReachabilityCallback)(char* query_json, void* user_data);
+
+static inline int callReachabilityCallback(ScalibrReachabilityCallback cb, char* query_json, void* user_data) {
+    return cb(query_json, user_data);
+}
+
+// Writes the rewritten identity of the package described by package_json
+// into out as NUL-terminated JSON, e.g. {"Name": "openssl"}. Returns its
+// length, 0 to keep the package unchanged, or a negative value on error.
+typedef int (*ScalibrPackageNormalizer)(char* package_json, char* out, int out_size, void* user_data);
+
+static inline int callPackageNormalizer(ScalibrPackageNormalizer cb, char* package_json, char* out, int out_size, void* user_data) {
+    return cb(package_json, out, out_size, user_data);
+}
+
+// Writes the header to send with a request to url into header as a
+// NUL-terminated "Name: value" line. Returns its length, 0 to send no header,
+// or a negative value to fail the request.
+typedef int (*ScalibrFeedAuthCallback)(char* url, char* header, int header_size, void* user_data);
+
+static inline int callFeedAuthCallback(ScalibrFeedAuthCallback cb, char* url, char* header, int header_size, void* user_data) {
+    return cb(url, header, header_size, user_data);
+}
+
+// Writes the Proxy-Authorization value for a connection to target
+// ("host:port") through proxy_url into header, NUL-terminated, e.g.
+// "Negotiate <token>". Returns its length, 0 to send none, or a negative
+// value to fail the request.
+typedef int (*ScalibrProxyAuthCallback)(char* proxy_url, char* target, char* header, int header_size, void* user_data);
+
+static inline int callProxyAuthCallback(ScalibrProxyAuthCallback cb, char* proxy_url, char* target, char* header, int header_size, void* user_data) {
+    return cb(proxy_url, target, header, header_size, user_data);
+}
 */
 import "C"
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
+	"strings"
+	"sync"
+	"time"
 	"unsafe"
 
-	scalibr "github.com/google/osv-scalibr"
-	scalibrfs "github.com/google/osv-scalibr/fs"
 	"github.com/google/osv-scalibr/log"
-	"github.com/google/osv-scalibr/plugin"
-	pl "github.com/google/osv-scalibr/plugin/list"
 )
 
-// Version returns the SCALIBR version string
+// Init performs the library's process-wide setup: the environment overrides,
+// logging and proxy configuration. It is idempotent and returns 0. Windows
+// hosts must call it, outside DllMain, before the first scan; elsewhere the
+// setup otherwise runs on first use.
+//
+//export ScalibrInit
+func ScalibrInit() C.int {
+	initialize()
+	initialized.Store(true)
+	return statusOK
+}
+
+// Version returns the version of the osv-scalibr sources the library was
+// built from
 //
 //export ScalibrVersion
 func ScalibrVersion() *C.char {
-	return C.CString("1.0.0")
+	return hostString(currentVersion().Scalibr)
+}
+
+// VersionInfo returns a JSON object with the osv-scalibr, bindings, ABI and
+// Go versions of the library. Free with ScalibrFreeString.
+//
+//export ScalibrVersionInfo
+func ScalibrVersionInfo() *C.char {
+	jsonBytes, err := json.MarshalIndent(currentVersion(), "", "  ")
+	if err != nil {
+		return nil
+	}
+	return hostString(string(jsonBytes))
+}
+
+// ABIVersion returns the version of the library's C interface, the major
+// version in the high 16 bits and the minor version in the low 16 bits
+//
+//export ScalibrABIVersion
+func ScalibrABIVersion() C.int {
+	return C.int(abiVersion())
+}
+
+// CheckCompat returns 1 if a host built against the given ABI version can use
+// this library, and 0 if the struct layouts or functions it expects differ
+//
+//export ScalibrCheckCompat
+func ScalibrCheckCompat(expectedMajor, expectedMinor C.int) C.int {
+	if abiCompatible(int(expectedMajor), int(expectedMinor)) {
+		return 1
+	}
+	return 0
 }
 
 // FreeString frees a C string allocated by Go
 //
 //export ScalibrFreeString
 func ScalibrFreeString(str *C.char) {
-	C.free(unsafe.Pointer(str))
+	hostRelease(unsafe.Pointer(str), allocString, "ScalibrFreeString")
 }
 
 // FreeScanResult frees the memory allocated for a ScanResult
 //
 //export ScalibrFreeScanResult
 func ScalibrFreeScanResult(result *C.ScanResult) {
-	if result == nil {
+	if result == nil || !hostAllocs.check(unsafe.Pointer(result), allocResult, "ScalibrFreeScanResult") {
 		return
 	}
-	if result.json_result != nil {
-		C.free(unsafe.Pointer(result.json_result))
+	hostRelease(unsafe.Pointer(result.json_result), allocString, "ScalibrFreeScanResult")
+	hostRelease(unsafe.Pointer(result.error_message), allocString, "ScalibrFreeScanResult")
+	hostRelease(result.result_data, allocData, "ScalibrFreeScanResult")
+	hostRelease(unsafe.Pointer(result), allocResult, "ScalibrFreeScanResult")
+}
+
+// SetAllocator makes the library allocate the strings, buffers and results
+// it returns with malloc_fn, and release them in ScalibrFreeString and
+// ScalibrFreeScanResult with free_fn. Pass NULL for both to use the C
+// runtime's malloc and free. It must be called before the library returns
+// its first buffer and fails with SCALIBR_ERR_CONFIG afterwards.
+//
+//export ScalibrSetAllocator
+func ScalibrSetAllocator(mallocFn C.ScalibrMallocFunc, freeFn C.ScalibrFreeFunc) C.int {
+	if (mallocFn == nil) != (freeFn == nil) {
+		return statusConfigError
 	}
-	if result.error_message != nil {
-		C.free(unsafe.Pointer(result.error_message))
+	allocMu.Lock()
+	defer allocMu.Unlock()
+	if allocUsed {
+		return statusConfigError
 	}
-	C.free(unsafe.Pointer(result))
+	hostMallocFn, hostFreeFn = mallocFn, freeFn
+	return statusOK
 }
 
 // Scan performs a SCALIBR scan with the given configuration
 //
 //export ScalibrScan
 func ScalibrScan(config *C.ScanConfig) *C.ScanResult {
-	result := (*C.ScanResult)(C.malloc(C.size_t(unsafe.Sizeof(C.ScanResult{}))))
-	result.json_result = nil
-	result.error_message = nil
-	result.status_code = 0
+	result := newScanResult()
+
+	if config == nil {
+		result.error_message = hostString("config cannot be nil")
+		result.status_code = statusConfigError
+		return result
+	}
+
+	// Run the scan
+	scanOutput, err := scans.wait(scans.submit(scanOptionsFromC(config)))
+	setScanOutput(result, scanOutput, err)
+	return result
+}
+
+// ScanWithConfigFile performs a scan configured by a YAML, TOML or JSON file
+//
+//export ScalibrScanWithConfigFile
+func ScalibrScanWithConfigFile(path *C.char) *C.ScanResult {
+	result := newScanResult()
+
+	opts, err := loadConfigFile(C.GoString(path))
+	if err != nil {
+		setScanOutput(result, nil, err)
+		return result
+	}
+
+	scanOutput, err := scans.wait(scans.submit(opts))
+	setScanOutput(result, scanOutput, err)
+	return result
+}
+
+// ScanJSON performs a scan configured by a JSON document with the keys of
+// the config files. Options added in later releases are only added to the
+// document, so callers don't depend on the layout of ScanConfig.
+//
+//export ScalibrScanJSON
+func ScalibrScanJSON(configJSON *C.char) *C.ScanResult {
+	result := newScanResult()
+
+	if configJSON == nil {
+		result.error_message = hostString("config cannot be nil")
+		result.status_code = statusConfigError
+		return result
+	}
+	opts, err := parseConfigJSON(C.GoString(configJSON))
+	if err != nil {
+		setScanOutput(result, nil, err)
+		return result
+	}
+
+	scanOutput, err := scans.wait(scans.submit(opts))
+	setScanOutput(result, scanOutput, err)
+	return result
+}
+
+// ScanImageTarball scans the container image of a docker save tarball
+// instead of the host's filesystem. config may be NULL for the defaults; its
+// root_path and root_paths are ignored.
+//
+//export ScalibrScanImageTarball
+func ScalibrScanImageTarball(path *C.char, config *C.ScanConfig) *C.ScanResult {
+	result := newScanResult()
+
+	if path == nil {
+		result.error_message = hostString("path cannot be nil")
+		result.status_code = statusConfigError
+		return result
+	}
+	opts := &scanOptions{}
+	if config != nil {
+		opts = scanOptionsFromC(config)
+		opts.RootPaths = nil
+	}
+	opts.ImageTarball = C.GoString(path)
+
+	scanOutput, err := scans.wait(scans.submit(opts))
+	setScanOutput(result, scanOutput, err)
+	return result
+}
+
+// ScanImage pulls the container image with the given registry reference and
+// scans it instead of the host's filesystem. auth may be NULL to use the
+// host's docker credentials, and config NULL for the defaults; its root_path
+// and root_paths are ignored.
+//
+//export ScalibrScanImage
+func ScalibrScanImage(ref *C.char, auth *C.RegistryAuth, config *C.ScanConfig) *C.ScanResult {
+	result := newScanResult()
+
+	if ref == nil {
+		result.error_message = hostString("image reference cannot be nil")
+		result.status_code = statusConfigError
+		return result
+	}
+	opts := &scanOptions{}
+	if config != nil {
+		opts = scanOptionsFromC(config)
+		opts.RootPaths = nil
+	}
+	opts.Image = C.GoString(ref)
+	if auth != nil {
+		opts.RegistryAuth = &registryAuth{
+			Username: C.GoString(auth.username),
+			Password: C.GoString(auth.password),
+			Token:    C.GoString(auth.token),
+		}
+	}
+
+	scanOutput, err := scans.wait(scans.submit(opts))
+	setScanOutput(result, scanOutput, err)
+	return result
+}
+
+// ScanTarBuffer scans the files of a tar archive, optionally gzip
+// compressed, held in memory. Large files are staged in the scan's workspace,
+// the rest is read into memory. config may be NULL for the defaults; its
+// root_path and root_paths are ignored.
+//
+//export ScalibrScanTarBuffer
+func ScalibrScanTarBuffer(data *C.uint8_t, length C.size_t, config *C.ScanConfig) *C.ScanResult {
+	result := newScanResult()
+
+	if data == nil && length > 0 {
+		result.error_message = hostString("data cannot be nil")
+		result.status_code = statusConfigError
+		return result
+	}
+	opts := &scanOptions{}
+	if config != nil {
+		opts = scanOptionsFromC(config)
+		opts.RootPaths = nil
+	}
+	// The archive is parsed by the scan, which returns before the caller's
+	// buffer is released
+	opts.virtualFS, opts.virtualFSName = newTarFS(unsafe.Slice((*byte)(unsafe.Pointer(data)), int(length))), tarBufferName
+
+	scanOutput, err := scans.wait(scans.submit(opts))
+	setScanOutput(result, scanOutput, err)
+	return result
+}
 
+// ScanVFS scans a filesystem served by the host's callbacks, e.g. content
+// stored in a database or an object store. No callback is called after
+// ScalibrScanVFS returns, but one that was stuck for five seconds may still
+// be running then. config may be NULL for the defaults; its root_path and
+// root_paths are ignored.
+//
+//export ScalibrScanVFS
+func ScalibrScanVFS(vfs *C.ScalibrVFS, config *C.ScanConfig) *C.ScanResult {
+	result := newScanResult()
+
+	if vfs == nil || vfs.stat == nil || vfs.read_dir == nil || vfs.open == nil || vfs.read == nil {
+		result.error_message = hostString("vfs must set stat, read_dir, open and read")
+		result.status_code = statusConfigError
+		return result
+	}
+	opts := &scanOptions{}
+	if config != nil {
+		opts = scanOptionsFromC(config)
+		opts.RootPaths = nil
+	}
+	hfs := hostFSFromC(vfs)
+	opts.virtualFS, opts.virtualFSName = hfs, hostFSName
+
+	scanOutput, err := scans.wait(scans.submit(opts))
+	// Plugin calls abandoned by plugin_timeout_ms may outlive the scan
+	if !hfs.detach(abandonedCallGrace) {
+		log.Warnf("%s callbacks still running after %v, returning without them", hostFSName, abandonedCallGrace)
+	}
+	setScanOutput(result, scanOutput, err)
+	return result
+}
+
+// ScanFiles extracts the given files, e.g. a single lockfile saved in an
+// IDE, without walking any directory. config may be NULL for the defaults;
+// its root_path, root_paths and paths_to_extract are ignored.
+//
+//export ScalibrScanFiles
+func ScalibrScanFiles(paths **C.char, count C.int, config *C.ScanConfig) *C.ScanResult {
+	result := newScanResult()
+
+	files := cStringArray(paths, count)
+	if len(files) == 0 {
+		result.error_message = hostString("paths cannot be empty")
+		result.status_code = statusConfigError
+		return result
+	}
+	opts := &scanOptions{}
+	if config != nil {
+		opts = scanOptionsFromC(config)
+		opts.RootPaths = nil
+		opts.PathsToExtract = nil
+	}
+	opts.Files = files
+
+	scanOutput, err := scans.wait(scans.submit(opts))
+	setScanOutput(result, scanOutput, err)
+	return result
+}
+
+// ScanStart queues a scan with the given configuration and returns its job
+// ID, or 0 if the configuration is invalid. The scan runs according to its
+// priority; collect the result with ScalibrScanCollect.
+//
+//export ScalibrScanStart
+func ScalibrScanStart(config *C.ScanConfig) C.longlong {
 	if config == nil {
-		result.error_message = C.CString("config cannot be nil")
-		result.status_code = 1
+		return 0
+	}
+	return C.longlong(scans.submitPersistent(scanOptionsFromC(config)).id)
+}
+
+// ScanCollect waits for the scan job to finish and returns its result. The
+// job ID is invalid afterwards.
+//
+//export ScalibrScanCollect
+func ScalibrScanCollect(jobID C.longlong) *C.ScanResult {
+	result := newScanResult()
+
+	j := scans.lookup(int64(jobID))
+	if j == nil {
+		result.error_message = hostString(fmt.Sprintf("unknown scan job %d", int64(jobID)))
+		result.status_code = statusConfigError
+		return result
+	}
+
+	scanOutput, err := scans.wait(j)
+	setScanOutput(result, scanOutput, err)
+	return result
+}
+
+// GetScanStats returns a JSON object with the statistics of a scan started
+// with ScalibrScanStart: so far while it runs, final once it's done. It
+// returns NULL if the job ID is unknown. Free with ScalibrFreeString.
+//
+//export ScalibrGetScanStats
+func ScalibrGetScanStats(jobID C.longlong) *C.char {
+	st := scans.jobStats(int64(jobID))
+	if st == nil {
+		return nil
+	}
+	jsonBytes, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return nil
+	}
+	return hostString(string(jsonBytes))
+}
+
+// ScanFree releases a scan started with ScalibrScanStart without collecting
+// its result, cancelling it if it hasn't finished. The job ID is invalid
+// afterwards. Returns 0, or 1 if the job ID is unknown.
+//
+//export ScalibrScanFree
+func ScalibrScanFree(jobID C.longlong) C.int {
+	if !scans.release(int64(jobID)) {
+		hostAllocs.misuse("ScalibrScanFree", int64(jobID))
+		return statusConfigError
+	}
+	return statusOK
+}
+
+// ResultCount waits for a scan started with ScalibrScanStart to finish and
+// returns the number of items in its inventory: packages, package
+// vulnerabilities, generic findings and secrets. The job stays available to
+// ScalibrResultGetPage until it is collected or released with
+// ScalibrScanFree. Returns -1 if the job ID is unknown.
+//
+//export ScalibrResultCount
+func ScalibrResultCount(jobID C.longlong) C.longlong {
+	j := scans.finished(int64(jobID))
+	if j == nil {
+		return -1
+	}
+	return C.longlong(len(j.resultItems()))
+}
+
+// ResultGetPage waits for a scan started with ScalibrScanStart to finish and
+// returns up to limit items of its inventory starting at offset, in the
+// order counted by ScalibrResultCount, as JSON. The job is not released.
+//
+//export ScalibrResultGetPage
+func ScalibrResultGetPage(jobID C.longlong, offset C.longlong, limit C.int) *C.ScanResult {
+	result := newScanResult()
+
+	j := scans.finished(int64(jobID))
+	if j == nil {
+		result.error_message = hostString(fmt.Sprintf("unknown scan job %d", int64(jobID)))
+		result.status_code = statusConfigError
+		return result
+	}
+	if j.err != nil {
+		setScanError(result, j.err)
+		return result
+	}
+
+	jsonBytes, err := json.Marshal(j.page(int64(offset), int(limit)))
+	if err != nil {
+		result.error_message = hostString(fmt.Sprintf("failed to marshal result: %v", err))
+		result.status_code = statusMarshalError
+		return result
+	}
+	result.json_result = hostString(string(jsonBytes))
+	result.status_code = C.int(j.output.statusCode())
+	return result
+}
+
+// ScanPoll returns the ScalibrScanState of a scan started with
+// ScalibrScanStart without blocking. Once it is done or failed,
+// ScalibrScanCollect returns the result immediately.
+//
+//export ScalibrScanPoll
+func ScalibrScanPoll(jobID C.longlong) C.int {
+	return C.int(scans.poll(int64(jobID)))
+}
+
+// CancelScan stops a scan started with ScalibrScanStart. A queued scan is
+// dropped; a running one stops as soon as its plugins notice and returns its
+// partial result. Either way ScalibrScanCollect reports status 7. Returns 0,
+// or 1 if the job ID is unknown.
+//
+//export ScalibrCancelScan
+func ScalibrCancelScan(jobID C.longlong) C.int {
+	if !scans.cancelJob(int64(jobID)) {
+		return statusConfigError
+	}
+	return statusOK
+}
+
+// ConfigNew creates an empty scan configuration to build with the
+// ScalibrConfigSet functions. Returns its handle; release it with
+// ScalibrConfigFree.
+//
+//export ScalibrConfigNew
+func ScalibrConfigNew() C.longlong {
+	return C.longlong(configs.add(newConfigBuilder()))
+}
+
+// ConfigSetRoot sets the configuration's only scan root
+//
+//export ScalibrConfigSetRoot
+func ScalibrConfigSetRoot(handle C.longlong, root *C.char) C.int {
+	return configSet(handle, "root_paths", jsonValue([]string{C.GoString(root)}), false)
+}
+
+// ConfigAddRoot adds a scan root to the configuration
+//
+//export ScalibrConfigAddRoot
+func ScalibrConfigAddRoot(handle C.longlong, root *C.char) C.int {
+	return configSet(handle, "root_paths", jsonValue(C.GoString(root)), true)
+}
+
+// ConfigAddPlugin adds a plugin or plugin preset to the configuration
+//
+//export ScalibrConfigAddPlugin
+func ScalibrConfigAddPlugin(handle C.longlong, name *C.char) C.int {
+	return configSet(handle, "plugins", jsonValue(C.GoString(name)), true)
+}
+
+// ConfigSetString sets a string option, named as in config files
+//
+//export ScalibrConfigSetString
+func ScalibrConfigSetString(handle C.longlong, key, value *C.char) C.int {
+	return configSet(handle, C.GoString(key), jsonValue(C.GoString(value)), false)
+}
+
+// ConfigAddString appends to a string list option, named as in config files
+//
+//export ScalibrConfigAddString
+func ScalibrConfigAddString(handle C.longlong, key, value *C.char) C.int {
+	return configSet(handle, C.GoString(key), jsonValue(C.GoString(value)), true)
+}
+
+// ConfigSetInt sets an integer option, named as in config files
+//
+//export ScalibrConfigSetInt
+func ScalibrConfigSetInt(handle C.longlong, key *C.char, value C.longlong) C.int {
+	return configSet(handle, C.GoString(key), jsonValue(int64(value)), false)
+}
+
+// ConfigSetBool sets a boolean option, named as in config files
+//
+//export ScalibrConfigSetBool
+func ScalibrConfigSetBool(handle C.longlong, key *C.char, value C.int) C.int {
+	return configSet(handle, C.GoString(key), jsonValue(value != 0), false)
+}
+
+// ConfigSetJSON sets an option, named as in config files, to a JSON value.
+// Use it for structured options such as plugin_config.
+//
+//export ScalibrConfigSetJSON
+func ScalibrConfigSetJSON(handle C.longlong, key, value *C.char) C.int {
+	return configSet(handle, C.GoString(key), json.RawMessage(C.GoString(value)), false)
+}
+
+// ConfigSetProgressCallback sets the callback receiving the progress of the
+// configuration's scans, like ScanConfig.progress_callback. Pass NULL to
+// remove it.
+//
+//export ScalibrConfigSetProgressCallback
+func ScalibrConfigSetProgressCallback(handle C.longlong, callback C.ScalibrProgressCallback, userData unsafe.Pointer) C.int {
+	b := configs.lookup(int64(handle))
+	if b == nil {
+		return statusConfigError
+	}
+	b.setProgress(progressCallback(callback, userData))
+	return 0
+}
+
+// ConfigSetFindingCallback sets the callback receiving the inventory of the
+// configuration's scans while they run, like ScanConfig.finding_callback.
+// Pass NULL to remove it.
+//
+//export ScalibrConfigSetFindingCallback
+func ScalibrConfigSetFindingCallback(handle C.longlong, callback C.ScalibrFindingCallback, userData unsafe.Pointer) C.int {
+	b := configs.lookup(int64(handle))
+	if b == nil {
+		return statusConfigError
+	}
+	b.setFindings(findingCallback(callback, userData))
+	return 0
+}
+
+// ConfigLastError returns why the configuration's last setter call failed, or
+// NULL if it succeeded.
+// The caller must free the string with ScalibrFreeString.
+//
+//export ScalibrConfigLastError
+func ScalibrConfigLastError(handle C.longlong) *C.char {
+	b := configs.lookup(int64(handle))
+	if b == nil {
+		return hostString(fmt.Sprintf("unknown config %d", int64(handle)))
+	}
+	if err := b.err(); err != nil {
+		return hostString(err.Error())
+	}
+	return nil
+}
+
+// ConfigScan performs a scan with the configuration. The configuration can be
+// reused for further scans.
+//
+//export ScalibrConfigScan
+func ScalibrConfigScan(handle C.longlong) *C.ScanResult {
+	result := newScanResult()
+
+	b := configs.lookup(int64(handle))
+	if b == nil {
+		result.error_message = hostString(fmt.Sprintf("unknown config %d", int64(handle)))
+		result.status_code = statusConfigError
 		return result
 	}
 
-	// Convert C config to Go
-	rootPath := C.GoString(config.root_path)
-	if rootPath == "" {
-		rootPath = "/"
+	opts, err := b.options()
+	if err != nil {
+		setScanOutput(result, nil, err)
+		return result
+	}
+
+	scanOutput, err := scans.wait(scans.submit(opts))
+	setScanOutput(result, scanOutput, err)
+	return result
+}
+
+// ConfigScanStart queues a scan with the configuration like ScalibrScanStart
+// and returns its job ID, or 0 if the handle is unknown.
+//
+//export ScalibrConfigScanStart
+func ScalibrConfigScanStart(handle C.longlong) C.longlong {
+	b := configs.lookup(int64(handle))
+	if b == nil {
+		return 0
+	}
+	opts, err := b.options()
+	if err != nil {
+		return 0
+	}
+	return C.longlong(scans.submitPersistent(opts).id)
+}
+
+// ConfigFree releases the configuration. Scans already started with it are
+// not affected.
+//
+//export ScalibrConfigFree
+func ScalibrConfigFree(handle C.longlong) {
+	if !configs.remove(int64(handle)) {
+		hostAllocs.misuse("ScalibrConfigFree", int64(handle))
+	}
+}
+
+func configSet(handle C.longlong, key string, value json.RawMessage, add bool) C.int {
+	b := configs.lookup(int64(handle))
+	if b == nil {
+		return statusConfigError
+	}
+	set := b.set
+	if add {
+		set = b.add
+	}
+	if err := set(key, value); err != nil {
+		return statusConfigError
+	}
+	return 0
+}
+
+// progressCallback returns the progressFunc calling callback, or nil if it is
+// NULL.
+func progressCallback(callback C.ScalibrProgressCallback, userData unsafe.Pointer) progressFunc {
+	if callback == nil {
+		return nil
+	}
+	return func(update []byte) {
+		cUpdate := C.CString(string(update))
+		defer C.free(unsafe.Pointer(cUpdate))
+		C.callProgressCallback(callback, cUpdate, userData)
+	}
+}
+
+// findingCallback returns the findingFunc calling callback, or nil if it is
+// NULL.
+func findingCallback(callback C.ScalibrFindingCallback, userData unsafe.Pointer) findingFunc {
+	if callback == nil {
+		return nil
+	}
+	return func(finding []byte) {
+		cFinding := C.CString(string(finding))
+		defer C.free(unsafe.Pointer(cFinding))
+		C.callFindingCallback(callback, cFinding, userData)
+	}
+}
+
+// jsonValue encodes v, which must be a string, number, bool or string list.
+func jsonValue(v any) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// SetMaxConcurrentScans sets how many scans may run at the same time. Further
+// scans are queued by priority. 0 lifts the limit, the default.
+//
+//export ScalibrSetMaxConcurrentScans
+func ScalibrSetMaxConcurrentScans(n C.int) {
+	scans.setSlots(int(n))
+}
+
+// SetNetworkLimits caps the outbound HTTP requests of network-enabled
+// plugins, across all scans, to requests_per_second and max_concurrent
+// requests in flight. 0 lifts a limit.
+//
+//export ScalibrSetNetworkLimits
+func ScalibrSetNetworkLimits(requestsPerSecond C.double, maxConcurrent C.int) {
+	setNetworkLimits(float64(requestsPerSecond), int(maxConcurrent))
+}
+
+// SetCPULimits caps the extractor and detector calls running at the same
+// time, across all scans, to max_workers and sets GOMAXPROCS to max_procs. 0
+// lifts the worker limit and restores the default GOMAXPROCS.
+//
+//export ScalibrSetCPULimits
+func ScalibrSetCPULimits(maxWorkers, maxProcs C.int) {
+	setCPULimits(int(maxWorkers), int(maxProcs))
+}
+
+// PersistQueue records the jobs queued with ScalibrScanStart in dir (the
+// cache directory's "queue" subdirectory if NULL) until they finish, and
+// requeues the jobs a previous process left unfinished there. It returns
+// the number of resumed jobs, or -1 on error.
+//
+//export ScalibrPersistQueue
+func ScalibrPersistQueue(dir *C.char) C.int {
+	d := C.GoString(dir)
+	if d == "" {
+		var err error
+		if d, err = defaultQueueDir(); err != nil {
+			log.Warnf("failed to locate the persisted queue: %v", err)
+			return -1
+		}
+	}
+	n, err := scans.persist(d)
+	if err != nil {
+		log.Warnf("failed to enable the persisted queue: %v", err)
+		return -1
+	}
+	return C.int(n)
+}
+
+// ListPlugins returns the names of the plugins available to the plugins
+// option as a JSON object listing them by kind, along with the bindings'
+// plugin groups. Free with ScalibrFreeString.
+//
+//export ScalibrListPlugins
+func ScalibrListPlugins() *C.char {
+	jsonBytes, err := json.MarshalIndent(availablePlugins(), "", "  ")
+	if err != nil {
+		return hostString("{}")
+	}
+	return hostString(string(jsonBytes))
+}
+
+// PluginInfo returns a JSON description of the plugin with the given name:
+// its type, version and requirements, and whether scans of this host can run
+// it. It returns NULL if name isn't the name of a plugin. Free with
+// ScalibrFreeString.
+//
+//export ScalibrPluginInfo
+func ScalibrPluginInfo(name *C.char) *C.char {
+	info := describePlugin(C.GoString(name))
+	if info == nil {
+		return nil
+	}
+	jsonBytes, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil
+	}
+	return hostString(string(jsonBytes))
+}
+
+// ListPersistedJobs returns a JSON array of the persisted jobs that haven't
+// finished, including their job IDs for ScalibrScanCollect. Free with
+// ScalibrFreeString.
+//
+//export ScalibrListPersistedJobs
+func ScalibrListPersistedJobs() *C.char {
+	jsonBytes, err := json.MarshalIndent(scans.persistedJobs(), "", "  ")
+	if err != nil {
+		return hostString("[]")
+	}
+	return hostString(string(jsonBytes))
+}
+
+// PurgePersistedQueue drops the persisted jobs that haven't started and
+// deletes the persisted queue. It returns the number of dropped jobs.
+//
+//export ScalibrPurgePersistedQueue
+func ScalibrPurgePersistedQueue() C.int {
+	return C.int(scans.purgePersisted())
+}
+
+// ListLayerCache returns a JSON array of the entries of the layer result
+// cache, most recently used first. Free with ScalibrFreeString.
+//
+//export ScalibrListLayerCache
+func ScalibrListLayerCache() *C.char {
+	jsonBytes, err := json.MarshalIndent(listLayerCache(), "", "  ")
+	if err != nil {
+		return hostString("[]")
+	}
+	return hostString(string(jsonBytes))
+}
+
+// PurgeLayerCache deletes the layer result cache entries of the layer with
+// the given ChainID, or all entries if chainID is NULL or empty. It returns
+// the number of deleted entries.
+//
+//export ScalibrPurgeLayerCache
+func ScalibrPurgeLayerCache(chainID *C.char) C.int {
+	return C.int(purgeLayerCache(C.GoString(chainID)))
+}
+
+// SetReachabilityAnalyzer installs a callback that is asked, for each
+// vulnerable package found by a scan, whether the vulnerable code is used.
+// Pass NULL to remove it.
+//
+//export ScalibrSetReachabilityAnalyzer
+func ScalibrSetReachabilityAnalyzer(callback C.ScalibrReachabilityCallback, userData unsafe.Pointer) {
+	if callback == nil {
+		setReachabilityAnalyzer(nil)
+		return
+	}
+	setReachabilityAnalyzer(func(query []byte) int {
+		cQuery := C.CString(string(query))
+		defer C.free(unsafe.Pointer(cQuery))
+		return int(C.callReachabilityCallback(callback, cQuery, userData))
+	})
+}
+
+// SetPackageNormalizer installs a callback that may rewrite the name,
+// version and PURL type of every package found by a scan, after the
+// package_rewrites rules. Pass NULL to remove it.
+//
+//export ScalibrSetPackageNormalizer
+func ScalibrSetPackageNormalizer(callback C.ScalibrPackageNormalizer, userData unsafe.Pointer) {
+	if callback == nil {
+		setPackageNormalizer(nil)
+		return
+	}
+	setPackageNormalizer(func(pkg []byte) ([]byte, error) {
+		cPkg := C.CString(string(pkg))
+		defer C.free(unsafe.Pointer(cPkg))
+		buf := (*C.char)(C.calloc(normalizerBufferSize, 1))
+		defer C.free(unsafe.Pointer(buf))
+		n := int(C.callPackageNormalizer(callback, cPkg, buf, normalizerBufferSize, userData))
+		switch {
+		case n < 0:
+			return nil, fmt.Errorf("normalizer failed with %d", n)
+		case n >= normalizerBufferSize:
+			return nil, errors.New("normalizer response too long")
+		}
+		return C.GoBytes(unsafe.Pointer(buf), C.int(n)), nil
+	})
+}
+
+// SetLogCallback routes the library's log output, filtered by
+// SCALIBR_LOG_LEVEL, to callback instead of stderr. Calls never overlap. Pass
+// NULL to log to stderr again.
+//
+//export ScalibrSetLogCallback
+func ScalibrSetLogCallback(callback C.ScalibrLogCallback, userData unsafe.Pointer) {
+	if callback == nil {
+		setLogSink(nil)
+		return
+	}
+	setLogSink(func(level int, message string) {
+		cMessage := C.CString(message)
+		defer C.free(unsafe.Pointer(cMessage))
+		C.callLogCallback(callback, C.int(level), cMessage, userData)
+	})
+}
+
+// SetFeedAuth installs a callback that supplies the authentication header
+// of requests to the vulnerability feed set by osv_base_url, e.g. a bearer
+// token refreshed by the host. Pass NULL to remove it.
+//
+//export ScalibrSetFeedAuth
+func ScalibrSetFeedAuth(callback C.ScalibrFeedAuthCallback, userData unsafe.Pointer) {
+	if callback == nil {
+		setFeedAuthorizer(nil)
+		return
+	}
+	setFeedAuthorizer(func(url string) (string, error) {
+		cURL := C.CString(url)
+		defer C.free(unsafe.Pointer(cURL))
+		buf := (*C.char)(C.calloc(feedAuthHeaderSize, 1))
+		defer C.free(unsafe.Pointer(buf))
+		n := int(C.callFeedAuthCallback(callback, cURL, buf, feedAuthHeaderSize, userData))
+		switch {
+		case n < 0:
+			return "", fmt.Errorf("feed auth callback failed with %d", n)
+		case n >= feedAuthHeaderSize:
+			return "", errors.New("feed auth header too long")
+		}
+		return C.GoStringN(buf, C.int(n)), nil
+	})
+}
+
+// SetProxyCredentials sets the user name and password sent to the proxy of
+// outbound requests with Basic authentication. Pass NULL for both to remove
+// them. Credentials in SCALIBR_PROXY take precedence.
+//
+//export ScalibrSetProxyCredentials
+func ScalibrSetProxyCredentials(username, password *C.char) {
+	setProxyCredentials(C.GoString(username), C.GoString(password))
+}
+
+// SetProxyAuth installs a callback that supplies the Proxy-Authorization
+// value of each proxied connection, for schemes such as Negotiate that need
+// tokens from the host's security libraries. It replaces the credentials set
+// with ScalibrSetProxyCredentials. Pass NULL to remove it.
+//
+//export ScalibrSetProxyAuth
+func ScalibrSetProxyAuth(callback C.ScalibrProxyAuthCallback, userData unsafe.Pointer) {
+	if callback == nil {
+		setProxyAuthenticator(nil)
+		return
+	}
+	setProxyAuthenticator(func(proxyURL, target string) (string, error) {
+		cProxy := C.CString(proxyURL)
+		defer C.free(unsafe.Pointer(cProxy))
+		cTarget := C.CString(target)
+		defer C.free(unsafe.Pointer(cTarget))
+		buf := (*C.char)(C.calloc(proxyAuthHeaderSize, 1))
+		defer C.free(unsafe.Pointer(buf))
+		n := int(C.callProxyAuthCallback(callback, cProxy, cTarget, buf, proxyAuthHeaderSize, userData))
+		switch {
+		case n < 0:
+			return "", fmt.Errorf("proxy auth callback failed with %d", n)
+		case n >= proxyAuthHeaderSize:
+			return "", errors.New("proxy auth header too long")
+		}
+		return C.GoStringN(buf, C.int(n)), nil
+	})
+}
+
+// DaemonStart creates a long-running scanner for the given configuration
+// that reports through callback. It does nothing until an activity such as
+// ScalibrDaemonWatch is enabled. Returns the daemon handle, or 0 on error.
+//
+//export ScalibrDaemonStart
+func ScalibrDaemonStart(config *C.ScanConfig, callback C.ScalibrEventCallback, userData unsafe.Pointer) C.longlong {
+	if config == nil || callback == nil {
+		return 0
+	}
+	emit := func(event []byte) {
+		cEvent := C.CString(string(event))
+		defer C.free(unsafe.Pointer(cEvent))
+		C.callEventCallback(callback, cEvent, userData)
+	}
+	return C.longlong(daemons.add(newDaemon(scanOptionsFromC(config), emit)))
+}
+
+// DaemonWatch makes the daemon poll its scan roots every interval_ms
+// milliseconds (0 for the default) and re-extract changed files, reporting
+// inventory deltas through the callback. Changes are found by walking the
+// roots, not through OS notifications; see ScalibrDaemonWatchChanges.
+// Returns 0 on success.
+//
+//export ScalibrDaemonWatch
+func ScalibrDaemonWatch(handle C.longlong, intervalMs C.int) C.int {
+	d := daemons.lookup(int64(handle))
+	if d == nil {
+		return statusConfigError
 	}
+	if err := d.startWatch(time.Duration(intervalMs) * time.Millisecond); err != nil {
+		return statusConfigError
+	}
+	return statusOK
+}
 
-	// Extract plugin names
-	var pluginNames []string
-	if config.plugins_count > 0 {
-		pluginNames = make([]string, config.plugins_count)
-		plugins := (*[1 << 30]*C.char)(unsafe.Pointer(config.plugins))[:config.plugins_count:config.plugins_count]
-		for i, p := range plugins {
-			pluginNames[i] = C.GoString(p)
+// DaemonWatchChanges makes the daemon watch its scan roots for the changes
+// the OS reports (inotify on Linux, kqueue on macOS, ReadDirectoryChangesW on
+// Windows) and re-extract the changed files once none came in for delay_ms
+// milliseconds (0 for the default), reporting inventory deltas through the
+// callback. Roots that can't be watched are polled, which is reported as a
+// "watch_fallback" event. Returns 0 on success.
+//
+//export ScalibrDaemonWatchChanges
+func ScalibrDaemonWatchChanges(handle C.longlong, delayMs C.int) C.int {
+	d := daemons.lookup(int64(handle))
+	if d == nil {
+		return statusConfigError
+	}
+	if err := d.startNotifyWatch(time.Duration(delayMs) * time.Millisecond); err != nil {
+		return statusConfigError
+	}
+	return statusOK
+}
+
+// DaemonSchedule makes the daemon scan root on a schedule, given either as
+// an interval ("30m", "6h") or a 5-field cron spec ("0 3 * * *", local time).
+// Results are delivered as "scan_result" events. Returns 0 on success.
+//
+//export ScalibrDaemonSchedule
+func ScalibrDaemonSchedule(handle C.longlong, root *C.char, spec *C.char) C.int {
+	d := daemons.lookup(int64(handle))
+	if d == nil || root == nil {
+		return statusConfigError
+	}
+	sched, err := parseSchedule(C.GoString(spec))
+	if err != nil {
+		log.Errorf("ScalibrDaemonSchedule: %v", err)
+		return statusConfigError
+	}
+	d.addSchedule(C.GoString(root), sched)
+	return statusOK
+}
+
+// DaemonReload replaces the configuration of a running daemon. Subsequent
+// watch polls and scheduled scans use the new plugins, limits and roots.
+// Returns 0 on success or the status code of the validation failure.
+//
+//export ScalibrDaemonReload
+func ScalibrDaemonReload(handle C.longlong, config *C.ScanConfig) C.int {
+	d := daemons.lookup(int64(handle))
+	if d == nil || config == nil {
+		return statusConfigError
+	}
+	if err := d.reload(scanOptionsFromC(config)); err != nil {
+		log.Errorf("ScalibrDaemonReload: %v", err)
+		return C.int(statusCode(err))
+	}
+	return statusOK
+}
+
+// DaemonStop stops the daemon and releases its handle. No callbacks are
+// delivered after it returns.
+//
+//export ScalibrDaemonStop
+func ScalibrDaemonStop(handle C.longlong) {
+	if d := daemons.remove(int64(handle)); d != nil {
+		d.stop()
+	} else {
+		hostAllocs.misuse("ScalibrDaemonStop", int64(handle))
+	}
+}
+
+// setScanOutput stores the outcome of a scan in result
+func setScanOutput(result *C.ScanResult, scanOutput *scanOutput, err error) {
+	if err != nil {
+		setScanError(result, err)
+		return
+	}
+
+	var doc any = scanOutput
+	if fields := scanOutput.output.fields; fields != nil {
+		if doc, err = projectOutput(scanOutput, fields); err != nil {
+			result.error_message = hostString(fmt.Sprintf("failed to marshal result: %v", err))
+			result.status_code = statusMarshalError
+			return
 		}
 	}
 
-	// Extract paths to scan
-	var pathsToExtract []string
-	if config.paths_count > 0 {
-		pathsToExtract = make([]string, config.paths_count)
-		paths := (*[1 << 30]*C.char)(unsafe.Pointer(config.paths_to_extract))[:config.paths_count:config.paths_count]
-		for i, p := range paths {
-			pathsToExtract[i] = C.GoString(p)
+	data, err := encodeOutput(doc, scanOutput.output)
+	if err != nil {
+		result.error_message = hostString(fmt.Sprintf("failed to marshal result: %v", err))
+		result.status_code = statusMarshalError
+		return
+	}
+
+	if scanOutput.output.path != "" {
+		summary, err := writeOutputFile(data, scanOutput.output)
+		if err != nil {
+			result.error_message = hostString(fmt.Sprintf("failed to write result: %v", err))
+			result.status_code = statusIOError
+			return
 		}
+		summary.StoppedOnFinding = scanOutput.StoppedOnFinding
+		jsonBytes, _ := json.MarshalIndent(summary, "", "  ")
+		result.json_result = hostString(string(jsonBytes))
+		result.status_code = C.int(scanOutput.statusCode())
+		return
+	}
+
+	// Binary encodings may contain NUL bytes and are returned with their size
+	if scanOutput.output.inline() {
+		result.json_result = hostString(string(data))
+	} else {
+		result.result_data = hostBytes(data)
+		result.result_size = C.int(len(data))
+	}
+	result.status_code = C.int(scanOutput.statusCode())
+}
+
+// hostFSFromC wraps the callbacks of vfs, which must stay valid while the
+// filesystem is used.
+func hostFSFromC(vfs *C.ScalibrVFS) *hostFS {
+	withPath := func(name string, call func(*C.char)) {
+		cName := C.CString(name)
+		defer C.free(unsafe.Pointer(cName))
+		call(cName)
+	}
+	return &hostFS{
+		stat: func(name string) (info hostFileInfo, errno int) {
+			var cInfo C.ScalibrFileInfo
+			withPath(name, func(p *C.char) { errno = int(C.callVFSStat(vfs, p, &cInfo)) })
+			info.size = int64(cInfo.size)
+			info.modTime = time.Unix(int64(cInfo.mod_time), 0)
+			info.mode = fs.FileMode(cInfo.mode) & fs.ModePerm
+			if cInfo.is_dir != 0 {
+				info.mode |= fs.ModeDir
+			}
+			return info, errno
+		},
+		readDir: func(name string) (names []string, errno int) {
+			withPath(name, func(p *C.char) {
+				for size := vfsReadDirBufferSize; ; {
+					buf := (*C.char)(C.malloc(C.size_t(size)))
+					n := int(C.callVFSReadDir(vfs, p, buf, C.int(size)))
+					if n > size {
+						// Retry with a buffer holding the whole list
+						C.free(unsafe.Pointer(buf))
+						size = n
+						continue
+					}
+					if n > 0 {
+						names = strings.Split(strings.TrimRight(C.GoStringN(buf, C.int(n)), "\x00"), "\x00")
+					} else {
+						errno = n
+					}
+					C.free(unsafe.Pointer(buf))
+					return
+				}
+			})
+			return names, errno
+		},
+		open: func(name string) (handle int64, errno int) {
+			withPath(name, func(p *C.char) { handle = int64(C.callVFSOpen(vfs, p)) })
+			if handle < 0 {
+				return -1, int(handle)
+			}
+			return handle, 0
+		},
+		read: func(handle, off int64, b []byte) int {
+			if len(b) > math.MaxInt32 {
+				b = b[:math.MaxInt32]
+			}
+			return int(C.callVFSRead(vfs, C.longlong(handle), C.longlong(off), (*C.char)(unsafe.Pointer(&b[0])), C.int(len(b))))
+		},
+		close: func(handle int64) {
+			C.callVFSClose(vfs, C.longlong(handle))
+		},
 	}
+}
+
+// scanOptionsFromC copies the C scan configuration into Go memory
+func scanOptionsFromC(config *C.ScanConfig) *scanOptions {
+	opts := &scanOptions{
+		Plugins:            cStringArray(config.plugins, config.plugins_count),
+		PathsToExtract:     cStringArray(config.paths_to_extract, config.paths_count),
+		MaxFileSize:        int(config.max_file_size),
+		Verbose:            config.verbose != 0,
+		Offline:            config.offline != 0,
+		IncludePaths:       cStringArray(config.include_paths, config.include_paths_count),
+		ExcludePaths:       cStringArray(config.exclude_paths, config.exclude_paths_count),
+		RootRelativePaths:  config.root_relative_paths != 0,
+		Priority:           int(config.priority),
+		StopOnFirstFinding: config.stop_on_first_finding != 0,
+		StopMinSeverity:    C.GoString(config.stop_min_severity),
+		StopPlugins:        cStringArray(config.stop_plugins, config.stop_plugins_count),
+		DetectorOnly:       config.detector_only != 0,
+		RemediationDir:     C.GoString(config.remediation_dir),
+		BinaryAnalyses:     cStringArray(config.binary_analyses, config.binary_analyses_count),
+		ExcludeGoStdlib:    config.exclude_go_stdlib != 0,
+		JavaShadedJars:     C.GoString(config.java_shaded_jars),
+		PythonRequirements: C.GoString(config.python_requirements),
+		JSWorkspaces:       C.GoString(config.js_workspaces),
+		OutputFormat:       C.GoString(config.output_format),
+		OutputPath:         C.GoString(config.output_path),
+		OutputChecksum:     config.output_checksum != 0,
+		OutputSections:     config.output_sections != 0,
+		OSVBatchSize:       int(config.osv_batch_size),
+		OSVBaseURL:         C.GoString(config.osv_base_url),
+		GroupFindings:      cStringArray(config.group_findings, config.group_findings_count),
+		CaptureOutput:      config.capture_output != 0,
+		MaxRSSBytes:        int64(config.max_rss_bytes),
+		OutputFields:       cStringArray(config.output_fields, config.output_fields_count),
+	}
+	opts.setPluginConfigJSON(C.GoString(config.plugin_config))
+	opts.setPackageRewritesJSON(C.GoString(config.package_rewrites))
+	opts.setSBOMOptionsJSON(C.GoString(config.sbom_options))
+	opts.progress = progressCallback(config.progress_callback, config.progress_user_data)
+	opts.findings = findingCallback(config.finding_callback, config.finding_user_data)
+	if rootPath := C.GoString(config.root_path); rootPath != "" {
+		opts.RootPaths = []string{rootPath}
+	}
+	opts.RootPaths = append(opts.RootPaths, cStringArray(config.root_paths, config.root_paths_count)...)
+	opts.DirsToSkip = cStringArray(config.dirs_to_skip, config.dirs_to_skip_count)
+	opts.SkipDirRegex = C.GoString(config.skip_dir_regex)
+	opts.SkipDirGlob = C.GoString(config.skip_dir_glob)
+	opts.MaxInodes = int(config.max_inodes)
+	opts.StoreAbsolutePath = config.store_absolute_path != 0
+	opts.ErrorOnFSErrors = config.error_on_fs_errors != 0
+	opts.ImageCacheDir = C.GoString(config.image_cache_dir)
+	opts.ImageCacheBytes = int64(config.image_cache_bytes)
+	opts.MaxTarballBytes = int64(config.max_tarball_bytes)
+	opts.Detectors = cStringArray(config.detectors, config.detectors_count)
+	opts.ExcludeRunningSystem = config.exclude_running_system != 0
+	opts.Annotators = cStringArray(config.annotators, config.annotators_count)
+	opts.setEnrichersJSON(C.GoString(config.enrichers))
+	opts.setPluginOptionsJSON(C.GoString(config.plugin_options))
+	opts.IncludePlugins = cStringArray(config.include_plugins, config.include_plugins_count)
+	opts.ExcludePlugins = cStringArray(config.exclude_plugins, config.exclude_plugins_count)
+	opts.IncludeEcosystems = cStringArray(config.include_ecosystems, config.include_ecosystems_count)
+	opts.ExcludeEcosystems = cStringArray(config.exclude_ecosystems, config.exclude_ecosystems_count)
+	opts.MaxReadBytesPerSecond = int64(config.max_read_bytes_per_second)
+	opts.MaxIOPS = int(config.max_iops)
+	opts.PluginTimeoutMs = int(config.plugin_timeout_ms)
+	opts.ScanPseudoFilesystems = config.scan_pseudo_filesystems != 0
+	opts.UseGitignore = config.use_gitignore != 0
+	opts.IgnoreFile = C.GoString(config.ignore_file)
+	opts.LayerResultCache = config.layer_result_cache != 0
+	opts.SkipHiddenFiles = config.skip_hidden_files != 0
+	opts.Symlinks = C.GoString(config.symlinks)
+	opts.HashArtifacts = config.hash_artifacts != 0
+	opts.IncludeFileMetadata = config.include_file_metadata != 0
+	opts.IncludeLicenses = config.include_licenses != 0
+	opts.CheckpointPath = C.GoString(config.checkpoint_path)
+	opts.CheckpointIntervalMs = int(config.checkpoint_interval_ms)
+	opts.PreviousResult = C.GoString(config.previous_result)
+	opts.SecretRedaction = C.GoString(config.secret_redaction)
+	return opts
+}
+
+// ResultToSBOM converts a JSON result previously returned by ScalibrScan into
+// an SPDX or CycloneDX document
+//
+//export ScalibrResultToSBOM
+func ScalibrResultToSBOM(resultJSON *C.char, format *C.char, docOptions *C.char) *C.ScanResult {
+	result := newScanResult()
 
-	// Configure logging
-	if config.verbose != 0 {
-		// Logging is controlled via log.SetLogger if needed
-		// No Initialize method exists in the current API
-		log.Infof("Running SCALIBR scan in verbose mode")
+	if resultJSON == nil {
+		result.error_message = hostString("result_json cannot be nil")
+		result.status_code = statusConfigError
+		return result
 	}
 
-	// Get plugins
-	plugins, err := pl.FromNames(pluginNames, nil)
+	opts, err := parseSBOMOptions(C.GoString(docOptions))
 	if err != nil {
-		result.error_message = C.CString(fmt.Sprintf("failed to load plugins: %v", err))
-		result.status_code = 2
+		result.error_message = hostString(err.Error())
+		result.status_code = statusConfigError
 		return result
 	}
 
-	// Set up capabilities
-	capab := &plugin.Capabilities{
-		Network:       plugin.NetworkOffline,
-		DirectFS:      true,
-		RunningSystem: true,
+	scanResult, err := parseStoredResult([]byte(C.GoString(resultJSON)))
+	if err != nil {
+		result.error_message = hostString(err.Error())
+		result.status_code = statusConfigError
+		return result
+	}
+
+	sbom, err := convertToSBOM(scanResult, C.GoString(format), opts)
+	if err != nil {
+		result.error_message = hostString(err.Error())
+		result.status_code = statusMarshalError
+		return result
 	}
-	if config.offline == 0 {
-		capab.Network = plugin.NetworkOnline
+
+	result.json_result = hostString(string(sbom))
+	return result
+}
+
+// MergeResults merges count JSON results previously returned by ScalibrScan,
+// e.g. of several roots or images, into one result with each package listed
+// once. count may be SCALIBR_NULL_TERMINATED.
+//
+//export ScalibrMergeResults
+func ScalibrMergeResults(results **C.char, count C.int) *C.ScanResult {
+	result := newScanResult()
+
+	if results == nil {
+		result.error_message = hostString("results cannot be nil")
+		result.status_code = statusConfigError
+		return result
 	}
 
-	// Create scan config
-	scanConfig := &scalibr.ScanConfig{
-		ScanRoots:      scalibrfs.RealFSScanRoots(rootPath),
-		Plugins:        plugin.FilterByCapabilities(plugins, capab),
-		PathsToExtract: pathsToExtract,
-		MaxFileSize:    int(config.max_file_size),
-		Capabilities:   capab,
+	merged, err := mergeResults(cStringArray(results, count))
+	if err != nil {
+		result.error_message = hostString(err.Error())
+		result.status_code = statusConfigError
+		return result
 	}
 
-	// Run the scan
-	scanner := scalibr.New()
-	scanResult := scanner.Scan(context.Background(), scanConfig)
+	result.json_result = hostString(string(merged))
+	return result
+}
 
-	if scanResult == nil {
-		result.error_message = C.CString("scan returned nil result")
-		result.status_code = 3
+// DiffResults compares two JSON results previously returned by ScalibrScan
+// and returns the packages and findings added, removed or changed since the
+// old one.
+//
+//export ScalibrDiffResults
+func ScalibrDiffResults(oldJSON, newJSON *C.char) *C.ScanResult {
+	result := newScanResult()
+
+	if oldJSON == nil || newJSON == nil {
+		result.error_message = hostString("old_json and new_json cannot be nil")
+		result.status_code = statusConfigError
 		return result
 	}
 
-	// Convert result to JSON
-	jsonBytes, err := json.MarshalIndent(scanResult, "", "  ")
+	diff, err := diffResults(C.GoString(oldJSON), C.GoString(newJSON))
 	if err != nil {
-		result.error_message = C.CString(fmt.Sprintf("failed to marshal result: %v", err))
-		result.status_code = 4
+		result.error_message = hostString(err.Error())
+		result.status_code = statusConfigError
 		return result
 	}
 
-	result.json_result = C.CString(string(jsonBytes))
-	result.status_code = 0
+	result.json_result = hostString(string(diff))
 	return result
 }
 
@@ -182,21 +1578,139 @@ func ScalibrScan(config *C.ScanConfig) *C.ScanResult {
 //
 //export ScalibrScanPath
 func ScalibrScanPath(path *C.char) *C.ScanResult {
-	config := (*C.ScanConfig)(C.malloc(C.size_t(unsafe.Sizeof(C.ScanConfig{}))))
+	// Zeroed, so every option not set here keeps its default
+	config := (*C.ScanConfig)(C.calloc(1, C.size_t(unsafe.Sizeof(C.ScanConfig{}))))
 	defer C.free(unsafe.Pointer(config))
-
 	config.root_path = path
-	config.plugins = nil
-	config.plugins_count = 0
-	config.paths_to_extract = nil
-	config.paths_count = 0
-	config.max_file_size = 0
-	config.verbose = 0
-	config.offline = 0
+	config.priority = C.SCALIBR_PRIORITY_NORMAL
 
 	return ScalibrScan(config)
 }
 
+// WarmUp initializes the plugins a ScanConfig with the given plugin names
+// would use, and the scan pipeline, ahead of the first scan
+//
+//export ScalibrWarmUp
+func ScalibrWarmUp(plugins **C.char, pluginsCount C.int) *C.ScanResult {
+	result := newScanResult()
+
+	warm, err := warmUp(cStringArray(plugins, pluginsCount))
+	if err != nil {
+		setScanError(result, err)
+		return result
+	}
+	jsonBytes, err := json.MarshalIndent(warm, "", "  ")
+	if err != nil {
+		result.error_message = hostString(fmt.Sprintf("failed to marshal result: %v", err))
+		result.status_code = statusMarshalError
+		return result
+	}
+	result.json_result = hostString(string(jsonBytes))
+	return result
+}
+
+// setScanError stores err in result. Validation errors are additionally
+// returned as a JSON document listing each invalid field.
+func setScanError(result *C.ScanResult, err error) {
+	result.error_message = hostString(err.Error())
+	result.status_code = C.int(statusCode(err))
+	var ve validationErrors
+	if errors.As(err, &ve) {
+		if jsonBytes, err := json.MarshalIndent(map[string]validationErrors{"Errors": ve}, "", "  "); err == nil {
+			result.json_result = hostString(string(jsonBytes))
+		}
+	}
+}
+
+var (
+	allocMu sync.Mutex
+	// Allocator set by ScalibrSetAllocator, the C runtime's if nil
+	hostMallocFn C.ScalibrMallocFunc
+	hostFreeFn   C.ScalibrFreeFunc
+	// Set once a buffer was returned, after which the allocator is fixed
+	allocUsed bool
+)
+
+// hostMalloc allocates size bytes for the host with the allocator set by
+// ScalibrSetAllocator. Like C.malloc, it panics if the allocation fails.
+func hostMalloc(size int, kind allocKind) unsafe.Pointer {
+	allocMu.Lock()
+	mallocFn := hostMallocFn
+	allocUsed = true
+	allocMu.Unlock()
+	p := C.callMalloc(mallocFn, C.size_t(max(size, 1)))
+	if p == nil {
+		panic("scalibr: out of memory")
+	}
+	hostAllocs.track(p, kind, size)
+	return p
+}
+
+// hostRelease frees p, of the given kind, for the destructor fn, unless debug
+// tracking finds it isn't a live buffer of that kind. p may be nil.
+func hostRelease(p unsafe.Pointer, kind allocKind, fn string) {
+	if p != nil && hostAllocs.release(p, kind, fn) {
+		hostFree(p)
+	}
+}
+
+// hostFree releases memory allocated by hostMalloc.
+func hostFree(p unsafe.Pointer) {
+	allocMu.Lock()
+	freeFn := hostFreeFn
+	allocMu.Unlock()
+	C.callFree(freeFn, p)
+}
+
+// hostString copies s into a NUL-terminated string allocated by hostMalloc.
+func hostString(s string) *C.char {
+	p := hostMalloc(len(s)+1, allocString)
+	b := unsafe.Slice((*byte)(p), len(s)+1)
+	copy(b, s)
+	b[len(s)] = 0
+	return (*C.char)(p)
+}
+
+// hostBytes copies data into a buffer allocated by hostMalloc.
+func hostBytes(data []byte) unsafe.Pointer {
+	p := hostMalloc(len(data), allocData)
+	copy(unsafe.Slice((*byte)(p), len(data)), data)
+	return p
+}
+
+// newScanResult allocates an empty ScanResult that the caller frees with
+// ScalibrFreeScanResult
+func newScanResult() *C.ScanResult {
+	result := (*C.ScanResult)(hostMalloc(int(unsafe.Sizeof(C.ScanResult{})), allocResult))
+	result.json_result = nil
+	result.error_message = nil
+	result.status_code = 0
+	result.result_data = nil
+	result.result_size = 0
+	return result
+}
+
+// cStringArray copies a C array of count strings into a Go slice. A count of
+// SCALIBR_NULL_TERMINATED copies the strings up to the first NULL.
+func cStringArray(arr **C.char, count C.int) []string {
+	if arr != nil && count == C.SCALIBR_NULL_TERMINATED {
+		items := (*[1 << 30]*C.char)(unsafe.Pointer(arr))
+		count = 0
+		for items[count] != nil {
+			count++
+		}
+	}
+	if arr == nil || count <= 0 {
+		return nil
+	}
+	strs := make([]string, count)
+	items := (*[1 << 30]*C.char)(unsafe.Pointer(arr))[:count:count]
+	for i, s := range items {
+		strs[i] = C.GoString(s)
+	}
+	return strs
+}
+
 func main() {
 	// This is required for building as a shared library
 }
@@ -0,0 +1,902 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	scalibr "github.com/google/osv-scalibr"
+	"github.com/google/osv-scalibr/artifact/image/layerscanning/image"
+	"github.com/google/osv-scalibr/binary/platform"
+	"github.com/google/osv-scalibr/detector"
+	"github.com/google/osv-scalibr/extractor/standalone"
+	scalibrfs "github.com/google/osv-scalibr/fs"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/log"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// Status codes reported in ScanResult.status_code, mirrored by ScalibrStatus
+// in the C header. Codes are never reused.
+const (
+	statusOK              = 0
+	statusConfigError     = 1
+	statusPluginLoadError = 2
+	statusScanError       = 3
+	statusMarshalError    = 4
+	// The scan was aborted by stop_on_first_finding; json_result holds the
+	// partial result.
+	statusStoppedOnFinding = 5
+	// The scan was aborted by max_rss_bytes; json_result holds the partial
+	// result.
+	statusMemoryLimit = 6
+	// The scan was cancelled by ScalibrCancelScan; json_result holds the
+	// partial result if it had started.
+	statusCancelled = 7
+	// A walk visited more than max_inodes inodes; json_result holds the
+	// results of the roots scanned before.
+	statusInodeLimit = 8
+	// The scan finished but some plugins, or the walk of a root, failed;
+	// json_result holds the result and its Plugins summary tells which.
+	statusPartial = 9
+	// Reading or writing the host's files failed, e.g. the output_path, the
+	// remediation_dir or a root read with error_on_fs_errors.
+	statusIOError = 10
+	// The container image couldn't be loaded, pulled or unpacked.
+	statusImageError = 11
+	// ScalibrInit wasn't called on a platform that requires it.
+	statusNotInitialized = 12
+)
+
+// scanError is an error together with the status code reported to the caller.
+type scanError struct {
+	code int
+	err  error
+}
+
+func (e *scanError) Error() string { return e.err.Error() }
+
+func (e *scanError) Unwrap() error { return e.err }
+
+func newScanError(code int, format string, args ...any) *scanError {
+	return &scanError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// statusCode returns the status code to report for err.
+func statusCode(err error) int {
+	if err == nil {
+		return statusOK
+	}
+	var se *scanError
+	if errors.As(err, &se) {
+		return se.code
+	}
+	var ve validationErrors
+	if errors.As(err, &ve) {
+		return ve.statusCode()
+	}
+	return statusScanError
+}
+
+// scanOptions is the Go-side representation of a scan request, decoupled
+// from the C structs so it can outlive the caller's memory. The tags define
+// the keys used in configuration files.
+type scanOptions struct {
+	RootPaths      []string `json:"root_paths" yaml:"root_paths" toml:"root_paths"`
+	Plugins        []string `json:"plugins" yaml:"plugins" toml:"plugins"`
+	PathsToExtract []string `json:"paths_to_extract" yaml:"paths_to_extract" toml:"paths_to_extract"`
+	MaxFileSize    int      `json:"max_file_size" yaml:"max_file_size" toml:"max_file_size"`
+	Verbose        bool     `json:"verbose" yaml:"verbose" toml:"verbose"`
+	Offline        bool     `json:"offline" yaml:"offline" toml:"offline"`
+	// Files extracted instead of walking the roots, e.g. a single pom.xml.
+	Files []string `json:"files" yaml:"files" toml:"files"`
+	// docker save tarball whose image is scanned instead of the roots.
+	ImageTarball string `json:"image_tarball" yaml:"image_tarball" toml:"image_tarball"`
+	// Registry reference of an image pulled and scanned instead of the
+	// roots, e.g. "gcr.io/foo/bar@sha256:...", and the credentials to pull
+	// it with.
+	Image        string        `json:"image" yaml:"image" toml:"image"`
+	RegistryAuth *registryAuth `json:"registry_auth" yaml:"registry_auth" toml:"registry_auth"`
+	// Directory caching the layers of pulled images across scans, and the cap
+	// on its size; empty for no cache, 0 for defaultImageCacheBytes.
+	ImageCacheDir   string `json:"image_cache_dir" yaml:"image_cache_dir" toml:"image_cache_dir"`
+	ImageCacheBytes int64  `json:"image_cache_bytes" yaml:"image_cache_bytes" toml:"image_cache_bytes"`
+	// Reuse the extraction results of image layers scanned before, see
+	// layerResultCache.
+	LayerResultCache bool `json:"layer_result_cache" yaml:"layer_result_cache" toml:"layer_result_cache"`
+	// Directories the walk skips, each inside one of the roots.
+	DirsToSkip []string `json:"dirs_to_skip" yaml:"dirs_to_skip" toml:"dirs_to_skip"`
+	// Directories whose path relative to the root matches are skipped, see
+	// compileSkipDirFilters.
+	SkipDirRegex string `json:"skip_dir_regex" yaml:"skip_dir_regex" toml:"skip_dir_regex"`
+	SkipDirGlob  string `json:"skip_dir_glob" yaml:"skip_dir_glob" toml:"skip_dir_glob"`
+	// Skip the files ignored by the .gitignore files of the scanned
+	// directories, and those matched by the patterns of a file in gitignore
+	// syntax relative to each root.
+	UseGitignore bool   `json:"use_gitignore" yaml:"use_gitignore" toml:"use_gitignore"`
+	IgnoreFile   string `json:"ignore_file" yaml:"ignore_file" toml:"ignore_file"`
+	// Skip the files and directories whose name starts with a dot.
+	SkipHiddenFiles bool `json:"skip_hidden_files" yaml:"skip_hidden_files" toml:"skip_hidden_files"`
+	// Whether the walk follows symlinks: "never", "within_root" or "all".
+	// Empty leaves them to SCALIBR, which doesn't follow them.
+	Symlinks string `json:"symlinks" yaml:"symlinks" toml:"symlinks"`
+	// How the credentials of reported secrets are redacted: "fingerprint",
+	// the default, or "none".
+	SecretRedaction string `json:"secret_redaction" yaml:"secret_redaction" toml:"secret_redaction"`
+	// Report the SHA-256 of the files that produced packages and secrets.
+	HashArtifacts bool `json:"hash_artifacts" yaml:"hash_artifacts" toml:"hash_artifacts"`
+	// Report the size, modification time and mode of the same files.
+	IncludeFileMetadata bool `json:"include_file_metadata" yaml:"include_file_metadata" toml:"include_file_metadata"`
+	// Report the licenses extractors and enrichers found for each package.
+	IncludeLicenses bool `json:"include_licenses" yaml:"include_licenses" toml:"include_licenses"`
+	// File recording the progress of the walk, which a later scan of the
+	// same roots resumes from, see checkpointer.
+	CheckpointPath string `json:"checkpoint_path" yaml:"checkpoint_path" toml:"checkpoint_path"`
+	// How often the checkpoint is written, in milliseconds. 0 writes it
+	// every minute.
+	CheckpointIntervalMs int `json:"checkpoint_interval_ms" yaml:"checkpoint_interval_ms" toml:"checkpoint_interval_ms"`
+	// JSON result of an earlier scan of the same roots with file metadata,
+	// whose unchanged files aren't extracted again, see incrementalScan.
+	PreviousResult string `json:"previous_result" yaml:"previous_result" toml:"previous_result"`
+	// Walk the mounts of /proc, /sys, /dev and other pseudo-filesystems
+	// below the roots, which are skipped by default.
+	ScanPseudoFilesystems bool `json:"scan_pseudo_filesystems" yaml:"scan_pseudo_filesystems" toml:"scan_pseudo_filesystems"`
+	// Result path filters, see filterByPathPrefix.
+	IncludePaths []string `json:"include_paths" yaml:"include_paths" toml:"include_paths"`
+	ExcludePaths []string `json:"exclude_paths" yaml:"exclude_paths" toml:"exclude_paths"`
+	// Result filters by the plugins that reported a package or finding and by
+	// the PURL type of packages, see filterByPluginAndEcosystem.
+	IncludePlugins    []string `json:"include_plugins" yaml:"include_plugins" toml:"include_plugins"`
+	ExcludePlugins    []string `json:"exclude_plugins" yaml:"exclude_plugins" toml:"exclude_plugins"`
+	IncludeEcosystems []string `json:"include_ecosystems" yaml:"include_ecosystems" toml:"include_ecosystems"`
+	ExcludeEcosystems []string `json:"exclude_ecosystems" yaml:"exclude_ecosystems" toml:"exclude_ecosystems"`
+	// Report locations relative to their scan root, prefixed with the root's ID.
+	RootRelativePaths bool `json:"root_relative_paths" yaml:"root_relative_paths" toml:"root_relative_paths"`
+	// Report locations as absolute host paths.
+	StoreAbsolutePath bool `json:"store_absolute_path" yaml:"store_absolute_path" toml:"store_absolute_path"`
+	// Fail the scan if part of a root can't be read.
+	ErrorOnFSErrors bool `json:"error_on_fs_errors" yaml:"error_on_fs_errors" toml:"error_on_fs_errors"`
+	// Scheduling priority, one of the priority* constants.
+	Priority int `json:"priority" yaml:"priority" toml:"priority"`
+	// Abort the scan on the first finding at or above StopMinSeverity,
+	// optionally only counting findings from StopPlugins.
+	StopOnFirstFinding bool     `json:"stop_on_first_finding" yaml:"stop_on_first_finding" toml:"stop_on_first_finding"`
+	StopMinSeverity    string   `json:"stop_min_severity" yaml:"stop_min_severity" toml:"stop_min_severity"`
+	StopPlugins        []string `json:"stop_plugins" yaml:"stop_plugins" toml:"stop_plugins"`
+	// Run only the selected detectors and report findings without the
+	// package inventory.
+	DetectorOnly bool `json:"detector_only" yaml:"detector_only" toml:"detector_only"`
+	// Detectors or detector collections run along with Plugins, see
+	// resolveDetectors.
+	Detectors []string `json:"detectors" yaml:"detectors" toml:"detectors"`
+	// Annotators or annotator collections run along with Plugins, see
+	// resolveAnnotators.
+	Annotators []string `json:"annotators" yaml:"annotators" toml:"annotators"`
+	// Leave out the plugins inspecting the live host rather than the roots,
+	// e.g. when the roots are a mounted image.
+	ExcludeRunningSystem bool `json:"exclude_running_system" yaml:"exclude_running_system" toml:"exclude_running_system"`
+	// Directory receiving remediation patches for vulnerable lockfile
+	// packages, see writeRemediations.
+	RemediationDir string `json:"remediation_dir" yaml:"remediation_dir" toml:"remediation_dir"`
+	// Analyses run by the native binary extractor, all if empty.
+	BinaryAnalyses []string `json:"binary_analyses" yaml:"binary_analyses" toml:"binary_analyses"`
+	// JSON form of SCALIBR's PluginConfig proto, passed to the plugins.
+	PluginConfig map[string]any `json:"plugin_config" yaml:"plugin_config" toml:"plugin_config"`
+	// Configuration of single plugins by name, e.g. "go/binary", each the
+	// JSON form of the plugin's message in SCALIBR's PluginSpecificConfig.
+	PluginOptions map[string]map[string]any `json:"plugin_options" yaml:"plugin_options" toml:"plugin_options"`
+	// Drop the Go toolchain packages reported for Go binaries.
+	ExcludeGoStdlib bool `json:"exclude_go_stdlib" yaml:"exclude_go_stdlib" toml:"exclude_go_stdlib"`
+	// How artifacts bundled into shaded JARs are reported, one of the
+	// shadedJars* constants. Defaults to shadedJarsReport.
+	JavaShadedJars string `json:"java_shaded_jars" yaml:"java_shaded_jars" toml:"java_shaded_jars"`
+	// How requirements.txt files are interpreted, one of the
+	// pythonRequirements* constants. Defaults to best effort.
+	PythonRequirements string `json:"python_requirements" yaml:"python_requirements" toml:"python_requirements"`
+	// How packages of npm and pnpm workspaces are attributed, one of the
+	// jsWorkspaces* constants. Defaults to jsWorkspacesHoisted.
+	JSWorkspaces string `json:"js_workspaces" yaml:"js_workspaces" toml:"js_workspaces"`
+	// Maximum queries per OSV batch query made by vulnmatch/osvdev. 0 keeps
+	// OSV.dev's limit of osvMaxBatchSize.
+	OSVBatchSize int `json:"osv_batch_size" yaml:"osv_batch_size" toml:"osv_batch_size"`
+	// Base URL of an OSV API compatible vulnerability feed queried by
+	// vulnmatch/osvdev instead of OSV.dev, e.g. an internal mirror.
+	OSVBaseURL string `json:"osv_base_url" yaml:"osv_base_url" toml:"osv_base_url"`
+	// Enrichers selected separately from Plugins, and their settings.
+	Enrichers *enricherOptions `json:"enrichers" yaml:"enrichers" toml:"enrichers"`
+	// Roll-ups of the findings added to the result, any of findingGroupings.
+	GroupFindings []string `json:"group_findings" yaml:"group_findings" toml:"group_findings"`
+	// Rules rewriting package names and versions, e.g. of vendored forks.
+	PackageRewrites []packageRewrite `json:"package_rewrites" yaml:"package_rewrites" toml:"package_rewrites"`
+	// Encoding of the scan result, one of outputFormats. Defaults to JSON.
+	OutputFormat string `json:"output_format" yaml:"output_format" toml:"output_format"`
+	// Document metadata of the SBOM output formats.
+	SBOMOptions *sbomOptions `json:"sbom_options" yaml:"sbom_options" toml:"sbom_options"`
+	// Write the result to this file instead of returning it, optionally with
+	// a .sha256 sidecar.
+	OutputPath     string `json:"output_path" yaml:"output_path" toml:"output_path"`
+	OutputChecksum bool   `json:"output_checksum" yaml:"output_checksum" toml:"output_checksum"`
+	// Return the byte ranges of the result's sections in the output_path file.
+	OutputSections bool `json:"output_sections" yaml:"output_sections" toml:"output_sections"`
+
+	// Paths of the result fields to return, e.g. "packages.purl"; empty for
+	// all of them.
+	OutputFields []string `json:"output_fields" yaml:"output_fields" toml:"output_fields"`
+	// Capture what the plugins write to stdout and stderr into the result.
+	CaptureOutput bool `json:"capture_output" yaml:"capture_output" toml:"capture_output"`
+
+	// Abort the scan once the process uses more memory than this; 0 for no
+	// limit.
+	MaxRSSBytes int64 `json:"max_rss_bytes" yaml:"max_rss_bytes" toml:"max_rss_bytes"`
+	// Fail the scan once the walk of a root visits more inodes. 0 means no
+	// limit.
+	MaxInodes int `json:"max_inodes" yaml:"max_inodes" toml:"max_inodes"`
+	// Fail a tar buffer scan whose files unpack to more bytes; 0 for
+	// defaultMaxTarballBytes.
+	MaxTarballBytes int64 `json:"max_tarball_bytes" yaml:"max_tarball_bytes" toml:"max_tarball_bytes"`
+	// Cap the bytes read from the scanned filesystem per second and the
+	// opens, directory listings and reads per second; 0 for no limit.
+	MaxReadBytesPerSecond int64 `json:"max_read_bytes_per_second" yaml:"max_read_bytes_per_second" toml:"max_read_bytes_per_second"`
+	MaxIOPS               int   `json:"max_iops" yaml:"max_iops" toml:"max_iops"`
+	// Abandon an extractor or detector call that runs longer and report the
+	// plugin as failed; 0 for no limit.
+	PluginTimeoutMs int `json:"plugin_timeout_ms" yaml:"plugin_timeout_ms" toml:"plugin_timeout_ms"`
+
+	// Receives progress updates while the scan runs; set through the C API
+	// only.
+	progress progressFunc
+	// Receives the inventory while the plugins report it; set through the C
+	// API only.
+	findings findingFunc
+	// Filesystem scanned instead of the roots, e.g. of an archive, and its
+	// name in errors; set through the C API only.
+	virtualFS     scalibrfs.FS
+	virtualFSName string
+
+	// Set when the C plugin_config string isn't valid JSON.
+	pluginConfigErr error
+	// Set when the C plugin_options string isn't valid JSON.
+	pluginOptionsErr error
+	// Set when the C package_rewrites string isn't valid JSON.
+	packageRewritesErr error
+	// Set when the C sbom_options string isn't valid JSON.
+	sbomOptionsErr error
+	// Set when the C enrichers string isn't valid JSON.
+	enrichersErr error
+}
+
+// roots returns the roots to scan, cleaned by cleanHostPath, or the
+// filesystem root if none is given.
+func (o *scanOptions) roots() []string {
+	if len(o.RootPaths) == 0 {
+		return []string{defaultRoot()}
+	}
+	roots := make([]string, 0, len(o.RootPaths))
+	for _, root := range o.RootPaths {
+		roots = append(roots, cleanHostPath(root))
+	}
+	return roots
+}
+
+// scanRootInfo identifies a scan root referenced by root-relative locations.
+type scanRootInfo struct {
+	ID   string
+	Path string
+}
+
+// scanOutput is the document serialized into ScanResult.json_result. It
+// embeds the SCALIBR result so its fields stay at the top level.
+type scanOutput struct {
+	*scalibr.ScanResult
+	// Outcome of each plugin, summarizing PluginStatus.
+	Plugins *pluginSummary `json:",omitempty"`
+	// Statistics of the scan, also reported by ScalibrGetScanStats.
+	Stats *scanStats `json:",omitempty"`
+	// Selected detectors, whether they ran and what they found.
+	Detectors []detectorReport `json:",omitempty"`
+	ScanRoots []scanRootInfo   `json:",omitempty"`
+	// Workspaces found in monorepo lockfiles in per_workspace mode.
+	Workspaces []jsWorkspaceInfo `json:",omitempty"`
+	// Content hashes of the files that produced inventory, if
+	// hash_artifacts is set.
+	ArtifactHashes []artifactHash `json:",omitempty"`
+	// Size, modification time and mode of the files that produced
+	// inventory, if include_file_metadata is set.
+	FileMetadata []fileMetadata `json:",omitempty"`
+	// Licenses of the packages, if include_licenses is set.
+	Licenses []packageLicense `json:",omitempty"`
+	// Filesystem images found by the native/squashfs extractor.
+	FirmwareImages []firmwareImage `json:",omitempty"`
+	// Layers that introduced the packages of an image scan.
+	PackageLayers []packageLayer `json:",omitempty"`
+	// Patches written to the remediation directory.
+	Remediations []remediationPatch `json:",omitempty"`
+	// Verdicts of the reachability analyzer, if one is installed.
+	Reachability []reachabilityResult `json:",omitempty"`
+	// Findings grouped as requested by group_findings.
+	FindingGroups *findingGroups `json:",omitempty"`
+	// Output of the plugins, next to their PluginStatus, if capture_output
+	// is set.
+	PluginOutput []pluginOutput `json:",omitempty"`
+	// Set when stop_on_first_finding aborted the scan.
+	StoppedOnFinding *stopInfo `json:",omitempty"`
+	// Set when max_rss_bytes aborted the scan.
+	MemoryLimitExceeded *memoryLimitInfo `json:",omitempty"`
+	// Set when the scan was cancelled while running.
+	Cancelled bool `json:",omitempty"`
+	// Set when max_inodes aborted the scan.
+	InodeLimitExceeded *inodeLimitInfo `json:",omitempty"`
+	// What the image scan reused of the layer cache.
+	LayerCache *layerCacheUsage `json:",omitempty"`
+	// Set when the scan resumed from the checkpoint at checkpoint_path.
+	ResumedFromCheckpoint bool `json:",omitempty"`
+	// What the scan reused of previous_result.
+	Incremental *incrementalInfo `json:",omitempty"`
+	// The packages encoded by marshalInventory, with the metadata the JSON
+	// can't restore, for scans using this result as their previous_result.
+	// Set along with FileMetadata.
+	IncrementalState []byte `json:",omitempty"`
+
+	// How the result is returned, not serialized.
+	output outputSettings
+}
+
+// statusCode returns the status code to report for a successful run.
+func (o *scanOutput) statusCode() int {
+	if o.StoppedOnFinding != nil {
+		return statusStoppedOnFinding
+	}
+	if o.MemoryLimitExceeded != nil {
+		return statusMemoryLimit
+	}
+	if o.Cancelled {
+		return statusCancelled
+	}
+	if o.InodeLimitExceeded != nil {
+		return statusInodeLimit
+	}
+	if o.ScanResult != nil && o.ScanResult.Status != nil && o.ScanResult.Status.Status != plugin.ScanStatusSucceeded {
+		return statusPartial
+	}
+	if o.Plugins != nil && o.Plugins.Status != pluginSucceeded {
+		return statusPartial
+	}
+	return statusOK
+}
+
+// runScan resolves the plugins for opts and scans each of its roots. The
+// scan ID keys the run's private workspace.
+func runScan(ctx context.Context, scanID int64, opts *scanOptions) (*scanOutput, error) {
+	if err := ensureInitialized(); err != nil {
+		return nil, err
+	}
+
+	if err := validateOptions(opts); err != nil {
+		return nil, err
+	}
+
+	collector := statsCollectorFrom(ctx)
+	if collector == nil {
+		collector = newStatsCollector()
+	}
+	defer collector.finish()
+
+	ws, err := newWorkspace(scanID)
+	if err != nil {
+		return nil, newScanError(statusIOError, "%w", err)
+	}
+	defer ws.remove()
+	if t, ok := opts.virtualFS.(*tarFS); ok {
+		if err := t.load(ws.dir, opts.maxTarballBytes()); err != nil {
+			return nil, newScanError(statusConfigError, "%w", err)
+		}
+	}
+
+	roots := opts.roots()
+	var filesByRoot map[string][]string
+	if len(opts.Files) > 0 {
+		roots, filesByRoot = fileRoots(opts.Files)
+	}
+
+	// Configure logging
+	if opts.Verbose {
+		// Logging is controlled via log.SetLogger if needed
+		// No Initialize method exists in the current API
+		log.Infof("Running SCALIBR scan in verbose mode")
+	}
+
+	// Get plugins
+	pluginCfg, err := pluginConfig(opts)
+	if err != nil {
+		return nil, newScanError(statusConfigError, "%w", err)
+	}
+	fileSizes, err := extractorMaxFileSizes(opts, pluginCfg)
+	if err != nil {
+		return nil, newScanError(statusConfigError, "%w", err)
+	}
+	plugins, err := resolvePlugins(opts.Plugins, opts, pluginCfg)
+	if err != nil {
+		return nil, newScanError(statusPluginLoadError, "failed to load plugins: %w", err)
+	}
+	detectors, err := resolveDetectors(opts.Detectors)
+	if err != nil {
+		return nil, newScanError(statusPluginLoadError, "failed to load detectors: %w", err)
+	}
+	plugins = mergePlugins(plugins, detectors)
+	annotators, err := resolveAnnotators(opts.Annotators)
+	if err != nil {
+		return nil, newScanError(statusPluginLoadError, "failed to load annotators: %w", err)
+	}
+	plugins = mergePlugins(plugins, annotators)
+	if opts.Enrichers != nil {
+		enrichers, err := resolveEnrichers(opts.Enrichers.Names, pluginCfg)
+		if err != nil {
+			return nil, newScanError(statusPluginLoadError, "failed to load enrichers: %w", err)
+		}
+		plugins = mergePlugins(withoutEnrichers(plugins), enrichers)
+	}
+	plugins, err = applyPythonRequirementsMode(plugins, opts.PythonRequirements, pluginCfg)
+	if err != nil {
+		return nil, newScanError(statusPluginLoadError, "failed to load plugins: %w", err)
+	}
+	plugins = applyVulnFeed(plugins, opts.vulnFeedURL())
+	rewrites, err := compileRewrites(opts.PackageRewrites)
+	if err != nil {
+		return nil, newScanError(statusConfigError, "%w", err)
+	}
+
+	// Set up capabilities
+	capab := &plugin.Capabilities{
+		OS:            platform.OS(),
+		Network:       plugin.NetworkOffline,
+		DirectFS:      true,
+		RunningSystem: true,
+	}
+	if !opts.Offline {
+		capab.Network = plugin.NetworkOnline
+	}
+	if opts.scanImage() {
+		// The image's filesystem is unpacked to the host's disk, but it's not
+		// the running system
+		capab.OS = plugin.OSLinux
+		capab.RunningSystem = false
+	} else if opts.virtualFS != nil {
+		capab.DirectFS = false
+		capab.RunningSystem = false
+	}
+	if opts.ExcludeRunningSystem || len(opts.Files) > 0 {
+		// File list scans only extract the listed files
+		capab.RunningSystem = false
+	}
+
+	detectorReports := reportDetectors(plugins, capab)
+	plugins = plugin.FilterByCapabilities(plugins, capab)
+	if !capab.RunningSystem {
+		// Standalone extractors inspect the host whatever their requirements
+		plugins = withoutStandalone(plugins)
+	}
+	if opts.DetectorOnly {
+		// SCALIBR enables the extractors the detectors depend on by itself
+		if plugins = detectorsOnly(plugins); len(plugins) == 0 {
+			return nil, newScanError(statusConfigError, "detector_only requires at least one detector plugin")
+		}
+	}
+
+	plugins = withNormalizer(plugins, rewrites)
+	timeouts := newPluginTimeouts(opts)
+	plugins = timeouts.wrap(plugins)
+	// Abandoned calls may still use the scanned files, e.g. through the
+	// host's callbacks of a virtual filesystem
+	defer timeouts.wait()
+
+	if size := opts.osvQueryBatchSize(); size > 0 && size < osvMaxBatchSize {
+		ctx = withOSVBatchSize(ctx, size)
+	}
+	if feedURL := opts.vulnFeedURL(); feedURL != "" {
+		ctx = withVulnFeed(ctx, feedURL)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var ff *failFast
+	if opts.StopOnFirstFinding {
+		ff = newFailFast(opts, cancel)
+		plugins = ff.wrap(plugins)
+	}
+	var mg *memoryGuard
+	if opts.MaxRSSBytes > 0 {
+		mg = startMemoryGuard(uint64(opts.MaxRSSBytes), cancel)
+		defer mg.stop()
+	}
+
+	scanPlugins := plugins
+	var capture *outputCapture
+	if opts.CaptureOutput {
+		if capture, err = startCapture(); err != nil {
+			return nil, newScanError(statusIOError, "failed to capture output: %w", err)
+		}
+		defer capture.stop()
+		scanPlugins = capture.wrap(plugins)
+	}
+	var progress *progressReporter
+	if opts.progress != nil {
+		progress = newProgressReporter(opts.progress, scanPlugins, scanRestarts(ctx))
+		defer progress.stop()
+		scanPlugins = progress.wrap(scanPlugins)
+	}
+	var stream *findingStream
+	if opts.findings != nil {
+		stream = newFindingStream(opts.findings, opts.redactSecrets(), scanRestarts(ctx) > 0)
+		scanPlugins = stream.wrap(scanPlugins)
+	}
+	// Outside the statistics, which count the files skipped for their size
+	scanPlugins, maxFileSize := withMaxFileSizes(collector.wrap(scanPlugins), opts.MaxFileSize, fileSizes)
+
+	// Create scan config
+	skipDirRegex, skipDirGlob, err := compileSkipDirFilters(opts.SkipDirRegex, opts.SkipDirGlob)
+	if err != nil {
+		return nil, newScanError(statusConfigError, "%w", err)
+	}
+	scanConfig := &scalibr.ScanConfig{
+		Plugins:        scanPlugins,
+		PathsToExtract: opts.PathsToExtract,
+		MaxFileSize:    maxFileSize,
+		MaxInodes:      opts.MaxInodes,
+		SkipDirRegex:   skipDirRegex,
+		SkipDirGlob:    skipDirGlob,
+		Capabilities:   capab,
+		// Post-processing normalizes locations, so it works with both forms
+		StoreAbsolutePath: opts.StoreAbsolutePath,
+		ErrorOnFSErrors:   opts.ErrorOnFSErrors,
+		UseGitignore:      opts.UseGitignore,
+	}
+	if progress != nil {
+		collector.next = progress
+	}
+	scanConfig.Stats = collector
+
+	out := &scanOutput{output: outputSettings{
+		format:   opts.OutputFormat,
+		path:     opts.OutputPath,
+		checksum: opts.OutputChecksum,
+		sections: opts.OutputSections,
+		fields:   parseOutputFields(opts.OutputFields),
+		sbom:     opts.SBOMOptions,
+	}}
+	var img *image.Image
+	var lc *layerResultCache
+	if opts.scanImage() {
+		if img, err = loadImage(ctx, opts); err != nil {
+			return nil, newScanError(statusImageError, "%w", err)
+		}
+		defer cleanUpImage(img)
+		if opts.LayerResultCache {
+			if lc, err = newLayerResultCache(img, opts, plugins, fileSizes); err != nil {
+				log.Warnf("not using the layer cache: %v", err)
+				lc = nil
+			}
+		}
+	}
+	if img != nil || opts.virtualFS != nil {
+		// Locations are relative to the root of the image or filesystem
+		roots = []string{"/"}
+		scanConfig.PathsToExtract = virtualPaths(opts.PathsToExtract)
+	}
+
+	wf, err := newWalkFilter(opts)
+	if err != nil {
+		return nil, newScanError(statusIOError, "%w", err)
+	}
+	throttle := newIOThrottle(ctx, opts)
+	cp, err := newCheckpointer(opts, roots)
+	if err != nil {
+		return nil, newScanError(statusIOError, "%w", err)
+	}
+	out.ResumedFromCheckpoint = cp != nil && cp.resumed
+	inc, err := loadPreviousResult(opts, roots)
+	if err != nil {
+		return nil, newScanError(statusConfigError, "%w", err)
+	}
+	if inc != nil {
+		out.Incremental = &incrementalInfo{}
+	}
+	var pseudoFS []string
+	if !opts.ScanPseudoFilesystems && img == nil && opts.virtualFS == nil {
+		pseudoFS = pseudoFSDirs()
+	}
+	scanner := scalibr.New()
+	for i, root := range roots {
+		cfg := *scanConfig
+		name := root
+		if img != nil {
+			name = opts.imageName()
+		} else if opts.virtualFS != nil {
+			name = opts.virtualFSName
+		}
+		if progress != nil {
+			progress.startRoot(i, name)
+		}
+		if stream != nil {
+			stream.startRoot(i, name)
+		}
+		var scanResult *scalibr.ScanResult
+		switch {
+		case img != nil:
+			cfg.DirsToSkip = virtualPaths(opts.DirsToSkip)
+			if lc != nil {
+				cfg.PathsToExtract = lc.reuse(cfg.DirsToSkip)
+				carryPackages(&cfg, lc.reusedPackages())
+			}
+			if scanResult, err = scanner.ScanContainer(ctx, img, &cfg); err != nil {
+				return nil, newScanError(statusImageError, "failed to scan image %s: %w", name, err)
+			}
+			detachLayerParents(&scanResult.Inventory)
+			if lc != nil && lc.usage != nil {
+				lc.attributeReused(&scanResult.Inventory)
+				out.LayerCache = lc.usage
+			}
+		case opts.virtualFS != nil:
+			cfg.ScanRoots = throttle.roots(wf.roots([]*scalibrfs.ScanRoot{{FS: opts.virtualFS}}))
+			cfg.DirsToSkip = virtualPaths(opts.DirsToSkip)
+			scanResult = scanner.Scan(ctx, &cfg)
+		default:
+			cfg.ScanRoots = throttle.roots(wf.roots(resolveSymlinks(scalibrfs.RealFSScanRoots(root), opts.Symlinks)))
+			cfg.DirsToSkip = append(skipDirsUnder(opts.DirsToSkip, root), pseudoFSDirsBelow(pseudoFS, root)...)
+			if filesByRoot != nil {
+				cfg.PathsToExtract = filesByRoot[root]
+			}
+			if inc != nil {
+				unchanged, reused := inc.root(i, os.DirFS(root))
+				cfg.ScanRoots = editListings(cfg.ScanRoots, skipFiles(unchanged))
+				carryPackages(&cfg, reused)
+				out.Incremental.UnchangedFiles += len(unchanged)
+				out.Incremental.ReusedPackages += len(reused)
+			}
+			if cp == nil {
+				scanResult = scanner.Scan(ctx, &cfg)
+			} else if scanResult, err = cp.scan(ctx, scanner, &cfg, i); err != nil {
+				return nil, newScanError(statusIOError, "%w", err)
+			}
+		}
+		if scanResult == nil {
+			return nil, newScanError(statusScanError, "scan returned nil result")
+		}
+		dropCarriedStatus(scanResult)
+		if lc != nil {
+			lc.store(ctx, scanResult)
+		}
+		if progress != nil {
+			progress.finishRoot()
+		}
+		if opts.ErrorOnFSErrors {
+			if reason := fsErrorReason(scanResult); reason != "" {
+				return nil, newScanError(statusIOError, "failed to read %s: %s", name, reason)
+			}
+		}
+		if inodeLimitExceeded(scanResult, opts.MaxInodes) {
+			// The walk failed, so there is no inventory to add
+			out.InodeLimitExceeded = &inodeLimitInfo{MaxInodes: opts.MaxInodes, Root: name}
+			out.ScanResult = mergeScanResults(out.ScanResult, scanResult)
+			break
+		}
+
+		if opts.ExcludeGoStdlib {
+			dropGoStdlib(&scanResult.Inventory)
+		}
+		if opts.PythonRequirements == pythonRequirementsPinned {
+			dropUnpinnedRequirements(&scanResult.Inventory)
+		}
+		if opts.JavaShadedJars == shadedJarsOwnerOnly {
+			collapseShadedJars(&scanResult.Inventory)
+		}
+
+		if opts.JSWorkspaces == jsWorkspacesPerWorkspace {
+			out.Workspaces = append(out.Workspaces, attributeJSWorkspaces(&scanResult.Inventory, root)...)
+		}
+
+		images := firmwareImages(plugins)
+
+		// Scope the inventory to the requested path prefixes
+		if len(opts.IncludePaths) > 0 || len(opts.ExcludePaths) > 0 {
+			filterByPathPrefix(&scanResult.Inventory, root, opts.IncludePaths, opts.ExcludePaths)
+		}
+
+		out.Reachability = append(out.Reachability, analyzeReachability(&scanResult.Inventory)...)
+
+		var hashes []artifactHash
+		var files []fileMetadata
+		if (opts.HashArtifacts || opts.includeFileMetadata()) && img == nil {
+			var fsys fs.FS = opts.virtualFS
+			if fsys == nil {
+				fsys = os.DirFS(root)
+			}
+			if opts.HashArtifacts {
+				hashes = hashArtifacts(ctx, fsys, &scanResult.Inventory, root)
+			}
+			if opts.includeFileMetadata() {
+				files = statArtifacts(ctx, fsys, &scanResult.Inventory, root)
+			}
+		}
+
+		if opts.RemediationDir != "" {
+			dir := opts.RemediationDir
+			if len(roots) > 1 {
+				dir = filepath.Join(dir, fmt.Sprintf("root%d", i))
+			}
+			patches, err := writeRemediations(&scanResult.Inventory, root, dir)
+			if err != nil {
+				return nil, newScanError(statusIOError, "failed to write remediation patches: %w", err)
+			}
+			out.Remediations = append(out.Remediations, patches...)
+		}
+
+		if opts.RootRelativePaths {
+			id := fmt.Sprintf("root%d", i)
+			tagRootLocations(scanResult, root, id)
+			for j := range images {
+				images[j].Path = id + ":" + normalizeLocation(root, images[j].Path)
+			}
+			for j := range hashes {
+				hashes[j].Location = id + ":" + normalizeLocation(root, hashes[j].Location)
+			}
+			for j := range files {
+				files[j].Location = id + ":" + normalizeLocation(root, files[j].Location)
+			}
+			out.ScanRoots = append(out.ScanRoots, scanRootInfo{ID: id, Path: name})
+		}
+		out.ArtifactHashes = append(out.ArtifactHashes, hashes...)
+		out.FileMetadata = append(out.FileMetadata, files...)
+		out.FirmwareImages = append(out.FirmwareImages, images...)
+		if img != nil {
+			out.PackageLayers = append(out.PackageLayers, attributeLayers(&scanResult.Inventory)...)
+		}
+		out.ScanResult = mergeScanResults(out.ScanResult, scanResult)
+
+		if ff != nil {
+			if out.StoppedOnFinding = ff.stopped(); out.StoppedOnFinding != nil {
+				break
+			}
+		}
+		if mg != nil {
+			if out.MemoryLimitExceeded = mg.exceeded(); out.MemoryLimitExceeded != nil {
+				break
+			}
+		}
+	}
+
+	if mg != nil {
+		mg.stop()
+	}
+	if cp != nil {
+		cp.finish(ctx.Err() == nil && out.InodeLimitExceeded == nil)
+	}
+	if capture != nil {
+		out.PluginOutput = capture.stop()
+	}
+	countDetectorFindings(detectorReports, &out.Inventory)
+	out.Detectors = detectorReports
+	if filters := opts.resultFilters(); filters != nil {
+		filterByPluginAndEcosystem(&out.Inventory, filters)
+	}
+	if opts.DetectorOnly {
+		out.Inventory = findingsOnly(out.Inventory)
+	}
+	if opts.includeFileMetadata() && img == nil && opts.virtualFS == nil {
+		if out.IncrementalState, err = marshalInventory(&inventory.Inventory{Packages: out.Inventory.Packages}); err != nil {
+			log.Warnf("failed to record the incremental state: %v", err)
+		}
+	}
+	if opts.redactSecrets() {
+		redactInventorySecrets(&out.Inventory)
+	}
+	if opts.IncludeLicenses {
+		out.Licenses = packageLicenses(&out.Inventory)
+	}
+	out.FindingGroups = groupFindings(&out.Inventory, opts.GroupFindings)
+	out.PluginStatus = timeouts.markTimedOut(out.PluginStatus)
+	out.Plugins = summarizePlugins(out.PluginStatus)
+	collector.finish()
+	out.Stats = collector.snapshot()
+	return out, nil
+}
+
+// detectorsOnly returns the detectors among plugins.
+func detectorsOnly(plugins []plugin.Plugin) []plugin.Plugin {
+	var detectors []plugin.Plugin
+	for _, p := range plugins {
+		if _, ok := p.(detector.Detector); ok {
+			detectors = append(detectors, p)
+		}
+	}
+	return detectors
+}
+
+// withoutStandalone returns plugins without the standalone extractors.
+func withoutStandalone(plugins []plugin.Plugin) []plugin.Plugin {
+	return slices.DeleteFunc(plugins, func(p plugin.Plugin) bool {
+		_, ok := p.(standalone.Extractor)
+		return ok
+	})
+}
+
+// findingsOnly returns the findings of inv without the extracted inventory.
+func findingsOnly(inv inventory.Inventory) inventory.Inventory {
+	return inventory.Inventory{
+		PackageVulns:    inv.PackageVulns,
+		GenericFindings: inv.GenericFindings,
+	}
+}
+
+// tagRootLocations rewrites the result's locations to "<id>:<path>" with the
+// path relative to the given scan root.
+func tagRootLocations(r *scalibr.ScanResult, root, id string) {
+	tag := func(location string) string {
+		return id + ":" + normalizeLocation(root, location)
+	}
+	for _, pkg := range r.Inventory.Packages {
+		for i, loc := range pkg.Locations {
+			pkg.Locations[i] = tag(loc)
+		}
+	}
+	for _, s := range r.Inventory.Secrets {
+		s.Location = tag(s.Location)
+	}
+}
+
+// mergeScanResults appends the inventory and plugin statuses of next to acc.
+// The merged scan spans both runs and keeps the worse of the two statuses.
+func mergeScanResults(acc, next *scalibr.ScanResult) *scalibr.ScanResult {
+	if acc == nil {
+		return next
+	}
+	if next.StartTime.Before(acc.StartTime) {
+		acc.StartTime = next.StartTime
+	}
+	if next.EndTime.After(acc.EndTime) {
+		acc.EndTime = next.EndTime
+	}
+	acc.PluginStatus = append(acc.PluginStatus, next.PluginStatus...)
+	acc.Inventory.Append(next.Inventory)
+	acc.Status = mergeScanStatus(acc.Status, next.Status)
+	return acc
+}
+
+func mergeScanStatus(a, b *plugin.ScanStatus) *plugin.ScanStatus {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	merged := &plugin.ScanStatus{
+		Status:     max(a.Status, b.Status),
+		FileErrors: append(append([]*plugin.FileErrors{}, a.FileErrors...), b.FileErrors...),
+	}
+	var reasons []string
+	for _, r := range []string{a.FailureReason, b.FailureReason} {
+		if r != "" {
+			reasons = append(reasons, r)
+		}
+	}
+	merged.FailureReason = strings.Join(reasons, "; ")
+	return merged
+}
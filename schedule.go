@@ -0,0 +1,166 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule computes the next time a periodic scan is due.
+type schedule interface {
+	next(after time.Time) time.Time
+}
+
+// intervalSchedule runs at a fixed interval.
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) next(after time.Time) time.Time {
+	return after.Add(time.Duration(s))
+}
+
+// cronSchedule is a standard 5-field cron spec: minute, hour, day of month,
+// month and day of week. Each field is a set of allowed values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	// Cron matches a day if either the day of month or the day of week
+	// matches, unless one of them is unrestricted, i.e. allows every day.
+	domAny, dowAny bool
+}
+
+// parseSchedule parses either a Go duration ("15m", "1h30m") or a 5-field
+// cron spec ("0 3 * * 1-5").
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d < time.Second {
+			return nil, fmt.Errorf("schedule interval %v is shorter than 1s", d)
+		}
+		return intervalSchedule(d), nil
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected a duration or 5 cron fields", spec)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := make([]map[int]bool, 5)
+	for i, f := range fields {
+		set, err := parseCronField(f, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		sets[i] = set
+	}
+	// Both 0 and 7 are Sunday.
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &cronSchedule{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: coversRange(sets[2], 1, 31),
+		dowAny: coversRange(sets[4], 0, 6),
+	}, nil
+}
+
+// coversRange reports whether set holds every value from lo to hi, as "*",
+// "*/1" or "1-31" do for the day of month.
+func coversRange(set map[int]bool, lo, hi int) bool {
+	for v := lo; v <= hi; v++ {
+		if !set[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCronField parses a comma-separated list of "*", "n", "a-b" and their
+// "/step" variants.
+func parseCronField(field string, lo, hi int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		start, end := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = strconv.Atoi(a); err != nil {
+				return nil, fmt.Errorf("invalid value in %q", part)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(b); err != nil {
+					return nil, fmt.Errorf("invalid range in %q", part)
+				}
+			} else if hasStep {
+				end = hi
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return nil, fmt.Errorf("%q out of range %d-%d", part, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+func (s *cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Give up after 5 years, the spec can't match anything (e.g. Feb 31st).
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !s.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
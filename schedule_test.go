@@ -0,0 +1,168 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "15m"},
+		{spec: " 1h30m "},
+		{spec: "500ms", wantErr: true},
+		{spec: "0 3 * * 1-5"},
+		{spec: "*/15 * * * *"},
+		{spec: "5/10 0,12 1 1 7"},
+		{spec: "0 3 * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "0 24 * * *", wantErr: true},
+		{spec: "0 0 0 * *", wantErr: true},
+		{spec: "0 0 * 13 *", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "a * * * *", wantErr: true},
+		{spec: "1-b * * * *", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			_, err := parseSchedule(tc.spec)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("parseSchedule(%q) error = %v, want error: %v", tc.spec, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "interval",
+			spec:  "90m",
+			after: date(2024, 1, 1, 10, 15),
+			want:  date(2024, 1, 1, 11, 45),
+		},
+		{
+			name:  "daily later today",
+			spec:  "30 14 * * *",
+			after: date(2024, 1, 1, 10, 15),
+			want:  date(2024, 1, 1, 14, 30),
+		},
+		{
+			name:  "daily tomorrow",
+			spec:  "30 14 * * *",
+			after: date(2024, 1, 1, 14, 30),
+			want:  date(2024, 1, 2, 14, 30),
+		},
+		{
+			name:  "seconds are truncated",
+			spec:  "* * * * *",
+			after: date(2024, 1, 1, 10, 15).Add(42 * time.Second),
+			want:  date(2024, 1, 1, 10, 16),
+		},
+		{
+			name:  "step",
+			spec:  "*/20 * * * *",
+			after: date(2024, 1, 1, 10, 41),
+			want:  date(2024, 1, 1, 11, 0),
+		},
+		{
+			// 2024-01-06 is a Saturday
+			name:  "weekdays skip the weekend",
+			spec:  "0 3 * * 1-5",
+			after: date(2024, 1, 5, 4, 0),
+			want:  date(2024, 1, 8, 3, 0),
+		},
+		{
+			name:  "sunday as 7",
+			spec:  "0 0 * * 7",
+			after: date(2024, 1, 1, 0, 0),
+			want:  date(2024, 1, 7, 0, 0),
+		},
+		{
+			// Either the 15th or a Monday
+			name:  "day of month or day of week",
+			spec:  "0 0 15 * 1",
+			after: date(2024, 1, 9, 0, 0),
+			want:  date(2024, 1, 15, 0, 0),
+		},
+		{
+			name:  "day of month or day of week, week day first",
+			spec:  "0 0 20 * 1",
+			after: date(2024, 1, 9, 0, 0),
+			want:  date(2024, 1, 15, 0, 0),
+		},
+		{
+			// 1-31 and */1 allow every day, so only Mondays match
+			name:  "full day of month range",
+			spec:  "0 3 1-31 * 1",
+			after: date(2024, 1, 9, 0, 0),
+			want:  date(2024, 1, 15, 3, 0),
+		},
+		{
+			name:  "day of month every day",
+			spec:  "0 3 */1 * 1",
+			after: date(2024, 1, 9, 0, 0),
+			want:  date(2024, 1, 15, 3, 0),
+		},
+		{
+			name:  "full day of week range",
+			spec:  "0 0 20 * 0-6",
+			after: date(2024, 1, 9, 0, 0),
+			want:  date(2024, 1, 20, 0, 0),
+		},
+		{
+			name:  "next month",
+			spec:  "0 0 1 * *",
+			after: date(2024, 1, 31, 12, 0),
+			want:  date(2024, 2, 1, 0, 0),
+		},
+		{
+			name:  "leap day",
+			spec:  "0 0 29 2 *",
+			after: date(2024, 3, 1, 0, 0),
+			want:  date(2028, 2, 29, 0, 0),
+		},
+		{
+			name:  "never matches",
+			spec:  "0 0 31 2 *",
+			after: date(2024, 1, 1, 0, 0),
+			want:  time.Time{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := parseSchedule(tc.spec)
+			if err != nil {
+				t.Fatalf("parseSchedule(%q) = %v", tc.spec, err)
+			}
+			if got := s.next(tc.after); !got.Equal(tc.want) {
+				t.Errorf("next(%v) = %v, want %v", tc.after, got, tc.want)
+			}
+		})
+	}
+}
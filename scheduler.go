@@ -0,0 +1,465 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Scan priorities, mirrored by ScalibrPriority in the C header.
+const (
+	priorityBackground  = -1
+	priorityNormal      = 0
+	priorityInteractive = 1
+)
+
+// Scan job states reported by ScalibrScanPoll, mirrored by ScalibrScanState
+// in the C header.
+const (
+	scanStateUnknown = -1
+	scanStateQueued  = 0
+	scanStateRunning = 1
+	scanStateDone    = 2
+	scanStateFailed  = 3
+)
+
+// defaultMaxConcurrentScans is the slot count of a new process, 0 for no
+// limit, so synchronous scans never wait for each other unless the host asks
+// for queueing with ScalibrSetMaxConcurrentScans.
+const defaultMaxConcurrentScans = 0
+
+var errPreempted = errors.New("scan preempted by a higher priority scan")
+
+var errPurged = newScanError(statusScanError, "scan job purged from the persisted queue")
+
+// errCancelled is the cancellation cause of a scan stopped by cancelJob, and
+// the error of cancelled jobs that hadn't started.
+var errCancelled = newScanError(statusCancelled, "scan cancelled")
+
+type jobState int
+
+const (
+	jobQueued jobState = iota
+	jobRunning
+	jobDone
+)
+
+// job is a single scan tracked by the scheduler.
+type job struct {
+	id       int64
+	opts     *scanOptions
+	priority int
+	// seq orders jobs of the same priority by submission time.
+	seq   int64
+	state jobState
+	// Number of times the job has been started, more than 1 once preempted
+	runs       int
+	preempting bool
+	cancelled  bool
+	cancel     context.CancelCauseFunc
+	// Statistics of the current run, nil before it starts
+	stats  *statsCollector
+	done   chan struct{}
+	output *scanOutput
+	err    error
+	// Whether the job is recorded in the scheduler's job store
+	persisted bool
+	resumed   bool
+	submitted time.Time
+	// Flattened inventory served by ScalibrResultGetPage
+	itemsOnce sync.Once
+	items     []resultItem
+}
+
+// record returns the persisted form of j.
+func (j *job) record() *persistedJob {
+	state := "queued"
+	if j.state == jobRunning {
+		state = "running"
+	}
+	return &persistedJob{ID: j.id, State: state, Resumed: j.resumed, Submitted: j.submitted, Options: j.opts}
+}
+
+// jobQueue is a heap of queued jobs, highest priority and oldest first.
+type jobQueue []*job
+
+func (q jobQueue) Len() int { return len(q) }
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q jobQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *jobQueue) Push(x any)   { *q = append(*q, x.(*job)) }
+func (q *jobQueue) Pop() any {
+	old := *q
+	j := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return j
+}
+
+// scheduler runs scans on a number of slots, unbounded by default. Queued
+// scans start in priority order and a higher priority scan preempts a running
+// background scan when all slots are busy. Preempted scans are requeued and
+// restarted from scratch once a slot frees up.
+type scheduler struct {
+	mu      sync.Mutex
+	slots   int
+	nextID  int64
+	queue   jobQueue
+	running map[int64]*job
+	jobs    map[int64]*job
+	// Set once queue persistence is enabled
+	store *jobStore
+}
+
+var scans = &scheduler{
+	slots:   defaultMaxConcurrentScans,
+	running: make(map[int64]*job),
+	jobs:    make(map[int64]*job),
+}
+
+// submit queues a scan and returns its job.
+func (s *scheduler) submit(opts *scanOptions) *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enqueue(opts, false, false, time.Now())
+}
+
+// submitPersistent queues a scan that is recorded in the job store, if queue
+// persistence is enabled, until it finishes.
+func (s *scheduler) submitPersistent(opts *scanOptions) *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Options that failed to parse can't be restored faithfully, and
+	// registry credentials are never written to disk
+	persist := s.store != nil && opts.pluginConfigErr == nil && opts.packageRewritesErr == nil && opts.sbomOptionsErr == nil &&
+		opts.enrichersErr == nil && opts.pluginOptionsErr == nil && opts.RegistryAuth == nil
+	return s.enqueue(opts, persist, false, time.Now())
+}
+
+// enqueue creates a queued job. Must be called with s.mu held.
+func (s *scheduler) enqueue(opts *scanOptions, persist, resumed bool, submitted time.Time) *job {
+	s.nextID++
+	j := &job{
+		id:        s.nextID,
+		opts:      opts,
+		priority:  opts.Priority,
+		seq:       s.nextID,
+		state:     jobQueued,
+		done:      make(chan struct{}),
+		persisted: persist,
+		resumed:   resumed,
+		submitted: submitted,
+	}
+	s.jobs[j.id] = j
+	if j.persisted {
+		s.store.save(j)
+	}
+	heap.Push(&s.queue, j)
+	s.dispatch()
+	return j
+}
+
+// persist enables queue persistence in dir and requeues the jobs that a
+// previous process left unfinished. It returns the number of resumed jobs.
+func (s *scheduler) persist(dir string) (int, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return 0, err
+	}
+	st := &jobStore{dir: dir}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store != nil && s.store.dir == dir {
+		return 0, nil
+	}
+	records, err := st.load()
+	if err != nil {
+		return 0, err
+	}
+	if s.store != nil {
+		// Move the live records over
+		for _, j := range s.jobs {
+			if j.persisted {
+				s.store.remove(j.id)
+				st.save(j)
+			}
+		}
+	}
+	s.store = st
+	for _, rec := range records {
+		s.enqueue(rec.Options, true, true, rec.Submitted)
+	}
+	return len(records), nil
+}
+
+// persistedJobs lists the jobs recorded in the job store, by ID.
+func (s *scheduler) persistedJobs() []*persistedJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := []*persistedJob{}
+	for _, j := range s.jobs {
+		if j.persisted {
+			records = append(records, j.record())
+		}
+	}
+	slices.SortFunc(records, func(a, b *persistedJob) int { return cmp.Compare(a.ID, b.ID) })
+	return records
+}
+
+// purgePersisted drops the persisted jobs that haven't started and empties
+// the job store. Waiters of dropped jobs get errPurged; running jobs finish
+// but won't be resumed after a restart. It returns the number of dropped
+// jobs.
+func (s *scheduler) purgePersisted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store == nil {
+		return 0
+	}
+	dropped := 0
+	queue := s.queue[:0]
+	for _, j := range s.queue {
+		if !j.persisted {
+			queue = append(queue, j)
+			continue
+		}
+		j.state = jobDone
+		j.err = errPurged
+		close(j.done)
+		dropped++
+	}
+	s.queue = queue
+	heap.Init(&s.queue)
+	for _, j := range s.jobs {
+		j.persisted = false
+	}
+	s.store.clear()
+	return dropped
+}
+
+// lookup returns the job with the given ID, or nil.
+func (s *scheduler) lookup(id int64) *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id]
+}
+
+// cancelJob stops the job with the given ID. A queued job is dropped and a
+// running one is cancelled, returning the partial result. It returns false if
+// there is no such job.
+func (s *scheduler) cancelJob(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.jobs[id]
+	if j == nil {
+		return false
+	}
+	switch j.state {
+	case jobQueued:
+		i := slices.Index(s.queue, j)
+		heap.Remove(&s.queue, i)
+		if j.persisted {
+			s.store.remove(j.id)
+			j.persisted = false
+		}
+		j.state = jobDone
+		j.err = errCancelled
+		close(j.done)
+	case jobRunning:
+		j.cancelled = true
+		j.cancel(errCancelled)
+	}
+	return true
+}
+
+// jobStats returns the statistics of the job with the given ID, so far if it
+// is running, or nil if there is no such job.
+func (s *scheduler) jobStats(id int64) *scanStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.jobs[id]
+	switch {
+	case j == nil:
+		return nil
+	case j.stats == nil:
+		return &scanStats{Plugins: []pluginStats{}}
+	}
+	return j.stats.snapshot()
+}
+
+// release cancels the job with the given ID and stops tracking it, for hosts
+// that won't collect its result. It returns false if there is no such job.
+func (s *scheduler) release(id int64) bool {
+	if !s.cancelJob(id) {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return true
+}
+
+// poll returns the state of the job with the given ID without waiting for
+// it. A finished job is failed if its result reports an error.
+func (s *scheduler) poll(id int64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.jobs[id]
+	switch {
+	case j == nil:
+		return scanStateUnknown
+	case j.state == jobQueued:
+		return scanStateQueued
+	case j.state == jobRunning:
+		return scanStateRunning
+	case j.err != nil:
+		return scanStateFailed
+	}
+	switch j.output.statusCode() {
+	case statusOK, statusStoppedOnFinding, statusPartial:
+		return scanStateDone
+	}
+	return scanStateFailed
+}
+
+// wait blocks until the job is finished and stops tracking it.
+func (s *scheduler) wait(j *job) (*scanOutput, error) {
+	<-j.done
+	s.mu.Lock()
+	delete(s.jobs, j.id)
+	s.mu.Unlock()
+	return j.output, j.err
+}
+
+// setSlots changes the number of scans that may run at the same time, 0 or
+// less for no limit.
+func (s *scheduler) setSlots(n int) {
+	n = max(n, 0)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slots = n
+	s.dispatch()
+}
+
+// dispatch starts queued jobs while slots are free. Must be called with s.mu held.
+func (s *scheduler) dispatch() {
+	for s.queue.Len() > 0 {
+		next := s.queue[0]
+		if s.slots == 0 || len(s.running) < s.slots {
+			heap.Pop(&s.queue)
+			s.start(next)
+			continue
+		}
+		if victim := s.preemptionVictim(next.priority); victim != nil {
+			victim.preempting = true
+			victim.cancel(errPreempted)
+		}
+		return
+	}
+}
+
+// preemptionVictim returns the running background job to preempt in favor
+// of a job with the given priority, or nil if none should be preempted.
+// Must be called with s.mu held.
+func (s *scheduler) preemptionVictim(priority int) *job {
+	var victim *job
+	for _, r := range s.running {
+		if r.preempting {
+			// A slot is already being freed up.
+			return nil
+		}
+		if r.priority != priorityBackground || r.priority >= priority {
+			continue
+		}
+		// Preempt the most recently started background scan, it has lost the
+		// least work.
+		if victim == nil || r.seq > victim.seq {
+			victim = r
+		}
+	}
+	return victim
+}
+
+// start runs the job on its own goroutine. Must be called with s.mu held.
+func (s *scheduler) start(j *job) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	j.state = jobRunning
+	j.runs++
+	j.cancel = cancel
+	j.stats = newStatsCollector()
+	ctx = withStatsCollector(ctx, j.stats)
+	ctx = withScanRun(ctx, j.runs)
+	s.running[j.id] = j
+	if j.persisted {
+		s.store.save(j)
+	}
+	go func() {
+		output, err := runScan(ctx, j.id, j.opts)
+		cause := context.Cause(ctx)
+		cancel(nil)
+		s.finish(j, output, err, cause)
+	}()
+}
+
+type scanRunKey struct{}
+
+// withScanRun records in ctx which run of its job a scan is, starting at 1.
+func withScanRun(ctx context.Context, run int) context.Context {
+	return context.WithValue(ctx, scanRunKey{}, run)
+}
+
+// scanRestarts returns how many times the scan running with ctx has been
+// restarted after a preemption.
+func scanRestarts(ctx context.Context) int {
+	run, _ := ctx.Value(scanRunKey{}).(int)
+	return max(run-1, 0)
+}
+
+// finish records the outcome of a job run, or requeues it if it was preempted.
+func (s *scheduler) finish(j *job, output *scanOutput, err, cause error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, j.id)
+	if j.cancelled && output != nil {
+		output.Cancelled = true
+	}
+	if errors.Is(cause, errPreempted) && !j.cancelled {
+		j.state = jobQueued
+		j.preempting = false
+		heap.Push(&s.queue, j)
+		if j.persisted {
+			s.store.save(j)
+		}
+	} else {
+		if j.persisted {
+			s.store.remove(j.id)
+			j.persisted = false
+		}
+		j.state = jobDone
+		j.output = output
+		j.err = err
+		close(j.done)
+	}
+	s.dispatch()
+}
@@ -0,0 +1,127 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"container/heap"
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestJobQueueOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		// Priorities of the jobs in submission order
+		priorities []int
+		// Submission indexes in the order the jobs start
+		want []int
+	}{
+		{
+			name:       "same priority in submission order",
+			priorities: []int{priorityNormal, priorityNormal, priorityNormal},
+			want:       []int{0, 1, 2},
+		},
+		{
+			name:       "higher priority first",
+			priorities: []int{priorityBackground, priorityNormal, priorityInteractive},
+			want:       []int{2, 1, 0},
+		},
+		{
+			name:       "mixed",
+			priorities: []int{priorityNormal, priorityBackground, priorityInteractive, priorityNormal, priorityInteractive},
+			want:       []int{2, 4, 0, 3, 1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var q jobQueue
+			for i, p := range tc.priorities {
+				heap.Push(&q, &job{id: int64(i), priority: p, seq: int64(i)})
+			}
+			var got []int
+			for q.Len() > 0 {
+				got = append(got, int(heap.Pop(&q).(*job).id))
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("start order = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPreemptionVictim(t *testing.T) {
+	tests := []struct {
+		name     string
+		running  []*job
+		priority int
+		// ID of the job to preempt, 0 for none
+		want int64
+	}{
+		{
+			name:     "no background scans",
+			running:  []*job{{id: 1, priority: priorityNormal, seq: 1}, {id: 2, priority: priorityInteractive, seq: 2}},
+			priority: priorityInteractive,
+		},
+		{
+			name:     "background scan preempted",
+			running:  []*job{{id: 1, priority: priorityNormal, seq: 1}, {id: 2, priority: priorityBackground, seq: 2}},
+			priority: priorityNormal,
+			want:     2,
+		},
+		{
+			name:     "most recently started",
+			running:  []*job{{id: 1, priority: priorityBackground, seq: 1}, {id: 2, priority: priorityBackground, seq: 2}},
+			priority: priorityInteractive,
+			want:     2,
+		},
+		{
+			name:     "background doesn't preempt background",
+			running:  []*job{{id: 1, priority: priorityBackground, seq: 1}},
+			priority: priorityBackground,
+		},
+		{
+			name:     "slot already being freed",
+			running:  []*job{{id: 1, priority: priorityBackground, seq: 1, preempting: true}, {id: 2, priority: priorityBackground, seq: 2}},
+			priority: priorityInteractive,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &scheduler{running: make(map[int64]*job)}
+			for _, j := range tc.running {
+				s.running[j.id] = j
+			}
+			var got int64
+			if v := s.preemptionVictim(tc.priority); v != nil {
+				got = v.id
+			}
+			if got != tc.want {
+				t.Errorf("preemptionVictim(%d) = job %d, want job %d", tc.priority, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScanRestarts(t *testing.T) {
+	if got := scanRestarts(context.Background()); got != 0 {
+		t.Errorf("scanRestarts() outside a job = %d, want 0", got)
+	}
+	for run, want := range map[int]int{1: 0, 2: 1, 5: 4} {
+		if got := scanRestarts(withScanRun(context.Background(), run)); got != want {
+			t.Errorf("scanRestarts() of run %d = %d, want %d", run, got, want)
+		}
+	}
+}
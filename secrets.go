@@ -0,0 +1,70 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/osv-scalibr/inventory"
+)
+
+// Values of the secret_redaction option. Secrets are redacted unless it's
+// "none".
+const (
+	secretRedactionFingerprint = "fingerprint"
+	secretRedactionNone        = "none"
+)
+
+var secretRedactionModes = []string{secretRedactionFingerprint, secretRedactionNone}
+
+// redactedSecret replaces the credential of a reported secret. The
+// fingerprint identifies the same credential across scans without
+// revealing it.
+type redactedSecret struct {
+	// Go type of the Veles secret, e.g. "gcpsak.GCPSAK"
+	Type   string
+	SHA256 string
+}
+
+// redactSecrets reports whether the secrets of a scan are redacted.
+func (o *scanOptions) redactSecrets() bool {
+	return o.SecretRedaction != secretRedactionNone
+}
+
+// redactSecret returns a copy of s with its credential replaced by a
+// redactedSecret.
+func redactSecret(s *inventory.Secret) *inventory.Secret {
+	if _, ok := s.Secret.(redactedSecret); ok {
+		return s
+	}
+	r := redactedSecret{Type: fmt.Sprintf("%T", s.Secret)}
+	if data, err := json.Marshal(s.Secret); err == nil {
+		sum := sha256.Sum256(data)
+		r.SHA256 = hex.EncodeToString(sum[:])
+	}
+	c := *s
+	c.Secret = r
+	return &c
+}
+
+// redactInventorySecrets redacts the secrets of inv in place.
+func redactInventorySecrets(inv *inventory.Inventory) {
+	for i, s := range inv.Secrets {
+		inv.Secrets[i] = redactSecret(s)
+	}
+}
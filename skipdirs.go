@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/gobwas/glob"
+)
+
+// skipDirsUnder returns the dirs_to_skip entries inside root, made absolute.
+// SCALIBR fails the scan of a root if one of its skipped directories lies
+// outside it, so each root only gets its own.
+func skipDirsUnder(dirs []string, root string) []string {
+	absRoot, err := filepath.Abs(cleanHostPath(root))
+	if err != nil {
+		return nil
+	}
+	var under []string
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(cleanHostPath(dir))
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(absRoot, abs); err == nil && filepath.IsLocal(rel) {
+			under = append(under, abs)
+		}
+	}
+	return under
+}
+
+// pseudoFSDirsBelow returns the pseudo-filesystem mounts among dirs that lie
+// strictly below root, made absolute. A root that is itself such a mount is
+// scanned as asked.
+func pseudoFSDirsBelow(dirs []string, root string) []string {
+	absRoot, err := filepath.Abs(cleanHostPath(root))
+	if err != nil {
+		return nil
+	}
+	return slices.DeleteFunc(skipDirsUnder(dirs, root), func(dir string) bool { return samePath(dir, absRoot) })
+}
+
+// skipDirUnderAnyRoot reports whether dir lies inside one of the roots.
+func skipDirUnderAnyRoot(dir string, roots []string) bool {
+	for _, root := range roots {
+		if len(skipDirsUnder([]string{dir}, root)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSkipDirFilters compiles skip_dir_regex and skip_dir_glob. Both
+// match the slash-separated path of a directory relative to its scan root.
+// Empty patterns yield nil filters.
+func compileSkipDirFilters(regex, pattern string) (*regexp.Regexp, glob.Glob, error) {
+	var re *regexp.Regexp
+	var g glob.Glob
+	var err error
+	if regex != "" {
+		if re, err = regexp.Compile(regex); err != nil {
+			return nil, nil, err
+		}
+	}
+	if pattern != "" {
+		if g, err = glob.Compile(pattern); err != nil {
+			return nil, nil, err
+		}
+	}
+	return re, g, nil
+}
@@ -0,0 +1,236 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	"github.com/google/osv-scalibr/plugin"
+	"github.com/google/osv-scalibr/stats"
+)
+
+// scanStats is the document reporting the statistics of a scan, in the
+// result and from ScalibrGetScanStats.
+type scanStats struct {
+	WallTimeMs int64
+	// Files and directories visited by the walks of the roots
+	FilesVisited int64
+	// Files passed to an extractor, and those an extractor asked for but
+	// didn't get, as they exceeded max_file_size or its own size limit
+	FilesExtracted int64
+	FilesSkipped   int64
+	// Peak memory use of the process, if SCALIBR measured it
+	MaxRSSBytes int64 `json:",omitempty"`
+	// Sorted by plugin name
+	Plugins []pluginStats
+}
+
+// pluginStats is the work of one plugin across all roots of a scan.
+type pluginStats struct {
+	Plugin    string
+	RuntimeMs int64
+	// Files the extractor was run on, and those it skipped
+	Files        int64 `json:",omitempty"`
+	FilesSkipped int64 `json:",omitempty"`
+	// Failed runs, of the extractor on a file or of the detector
+	Errors int64 `json:",omitempty"`
+
+	// Files the extractor asked for, including those the walk then skipped
+	required int64
+}
+
+// statsCollector gathers the statistics of a scan through SCALIBR's stats
+// collector, forwarding the events to next, if set.
+type statsCollector struct {
+	next stats.Collector
+
+	mu       sync.Mutex
+	start    time.Time
+	end      time.Time
+	stats    scanStats
+	runtimes map[string]time.Duration
+	plugins  map[string]*pluginStats
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{
+		start:    time.Now(),
+		runtimes: make(map[string]time.Duration),
+		plugins:  make(map[string]*pluginStats),
+	}
+}
+
+type scanStatsKey struct{}
+
+// withStatsCollector makes the scan run under ctx record its statistics in c.
+func withStatsCollector(ctx context.Context, c *statsCollector) context.Context {
+	return context.WithValue(ctx, scanStatsKey{}, c)
+}
+
+func statsCollectorFrom(ctx context.Context) *statsCollector {
+	c, _ := ctx.Value(scanStatsKey{}).(*statsCollector)
+	return c
+}
+
+// plugin returns the statistics of the named plugin. Must be called with
+// c.mu held.
+func (c *statsCollector) plugin(name string) *pluginStats {
+	p, ok := c.plugins[name]
+	if !ok {
+		p = &pluginStats{Plugin: name}
+		c.plugins[name] = p
+	}
+	return p
+}
+
+// finish stops the wall clock of the scan.
+func (c *statsCollector) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.end = time.Now()
+}
+
+// snapshot returns the statistics gathered so far.
+func (c *statsCollector) snapshot() *scanStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	end := c.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	s.WallTimeMs = end.Sub(c.start).Milliseconds()
+	s.Plugins = make([]pluginStats, 0, len(c.plugins))
+	for name, p := range c.plugins {
+		ps := *p
+		ps.RuntimeMs = c.runtimes[name].Milliseconds()
+		// The walk skips files above max_file_size after FileRequired
+		ps.FilesSkipped += max(0, ps.required-ps.Files)
+		s.FilesSkipped += ps.FilesSkipped
+		s.Plugins = append(s.Plugins, ps)
+	}
+	slices.SortFunc(s.Plugins, func(a, b pluginStats) int { return cmp.Compare(a.Plugin, b.Plugin) })
+	return &s
+}
+
+func (c *statsCollector) AfterInodeVisited(path string) {
+	c.mu.Lock()
+	c.stats.FilesVisited++
+	c.mu.Unlock()
+	if c.next != nil {
+		c.next.AfterInodeVisited(path)
+	}
+}
+
+func (c *statsCollector) AfterExtractorRun(name string, s *stats.AfterExtractorStats) {
+	c.mu.Lock()
+	c.stats.FilesExtracted++
+	p := c.plugin(name)
+	p.Files++
+	if s.Error != nil {
+		p.Errors++
+	}
+	c.runtimes[name] += s.Runtime
+	c.mu.Unlock()
+	if c.next != nil {
+		c.next.AfterExtractorRun(name, s)
+	}
+}
+
+func (c *statsCollector) AfterDetectorRun(name string, runtime time.Duration, err error) {
+	c.mu.Lock()
+	p := c.plugin(name)
+	if err != nil {
+		p.Errors++
+	}
+	c.runtimes[name] += runtime
+	c.mu.Unlock()
+	if c.next != nil {
+		c.next.AfterDetectorRun(name, runtime, err)
+	}
+}
+
+func (c *statsCollector) AfterScan(runtime time.Duration, status *plugin.ScanStatus) {
+	if c.next != nil {
+		c.next.AfterScan(runtime, status)
+	}
+}
+
+func (c *statsCollector) AfterResultsExported(destination string, bytes int, err error) {
+	if c.next != nil {
+		c.next.AfterResultsExported(destination, bytes, err)
+	}
+}
+
+func (c *statsCollector) AfterFileRequired(name string, s *stats.FileRequiredStats) {
+	if s.Result == stats.FileRequiredResultSizeLimitExceeded {
+		c.mu.Lock()
+		c.plugin(name).FilesSkipped++
+		c.mu.Unlock()
+	}
+	if c.next != nil {
+		c.next.AfterFileRequired(name, s)
+	}
+}
+
+func (c *statsCollector) AfterFileExtracted(name string, s *stats.FileExtractedStats) {
+	if c.next != nil {
+		c.next.AfterFileExtracted(name, s)
+	}
+}
+
+func (c *statsCollector) MaxRSS(maxRSS int64) {
+	c.mu.Lock()
+	c.stats.MaxRSSBytes = max(c.stats.MaxRSSBytes, maxRSS)
+	c.mu.Unlock()
+	if c.next != nil {
+		c.next.MaxRSS(maxRSS)
+	}
+}
+
+var _ stats.Collector = &statsCollector{}
+
+// wrap returns plugins with every filesystem extractor instrumented to count
+// the files it asks for, as SCALIBR reports only those it runs on.
+func (c *statsCollector) wrap(plugins []plugin.Plugin) []plugin.Plugin {
+	wrapped := make([]plugin.Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		if e, ok := p.(filesystem.Extractor); ok {
+			p = &statsExtractor{Extractor: e, c: c}
+		}
+		wrapped = append(wrapped, p)
+	}
+	return wrapped
+}
+
+type statsExtractor struct {
+	filesystem.Extractor
+	c *statsCollector
+}
+
+func (e *statsExtractor) FileRequired(api filesystem.FileAPI) bool {
+	if !e.Extractor.FileRequired(api) {
+		return false
+	}
+	e.c.mu.Lock()
+	e.c.plugin(e.Name()).required++
+	e.c.mu.Unlock()
+	return true
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	scalibr "github.com/google/osv-scalibr"
+	scalibrproto "github.com/google/osv-scalibr/binary/proto"
+	spb "github.com/google/osv-scalibr/binary/proto/scan_result_go_proto"
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/inventory"
+	"google.golang.org/protobuf/proto"
+)
+
+// storedResult mirrors the parts of a JSON result previously returned by
+// ScalibrScan that are needed to rebuild a scan result. Package metadata is
+// not restored since its concrete type isn't recorded in the JSON.
+type storedResult struct {
+	Version   string
+	StartTime time.Time
+	EndTime   time.Time
+	Inventory struct {
+		Packages []*storedPackage
+	}
+}
+
+type storedPackage struct {
+	Name       string
+	Version    string
+	SourceCode *extractor.SourceCodeIdentifier
+	Locations  []string
+	PURLType   string
+	Plugins    []string
+	Licenses   []string
+}
+
+// marshalInventory encodes inv as an Inventory message of SCALIBR's
+// binary/proto/scan_result.proto. Unlike the JSON result, the message keeps
+// the package metadata along with its type.
+func marshalInventory(inv *inventory.Inventory) ([]byte, error) {
+	// The message refers to the image of a package through its layer
+	linkLayerParents(inv)
+	defer detachLayerParents(inv)
+	msg, err := scalibrproto.InventoryToProto(inv)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// unmarshalInventory decodes an inventory encoded by marshalInventory.
+func unmarshalInventory(data []byte) (*inventory.Inventory, error) {
+	var msg spb.Inventory
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	inv := scalibrproto.InventoryToStruct(&msg)
+	detachLayerParents(inv)
+	return inv, nil
+}
+
+// parseStoredResult rebuilds a scan result from its JSON representation.
+func parseStoredResult(data []byte) (*scalibr.ScanResult, error) {
+	var stored storedResult
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+	r := &scalibr.ScanResult{
+		Version:   stored.Version,
+		StartTime: stored.StartTime,
+		EndTime:   stored.EndTime,
+	}
+	for _, p := range stored.Inventory.Packages {
+		if p == nil {
+			continue
+		}
+		r.Inventory.Packages = append(r.Inventory.Packages, &extractor.Package{
+			Name:       p.Name,
+			Version:    p.Version,
+			SourceCode: p.SourceCode,
+			Locations:  p.Locations,
+			PURLType:   p.PURLType,
+			Plugins:    p.Plugins,
+			Licenses:   p.Licenses,
+		})
+	}
+	return r, nil
+}
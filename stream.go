@@ -0,0 +1,211 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/osv-scalibr/detector"
+	"github.com/google/osv-scalibr/enricher"
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	"github.com/google/osv-scalibr/extractor/standalone"
+	scalibrfs "github.com/google/osv-scalibr/fs"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/log"
+	"github.com/google/osv-scalibr/packageindex"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// Kinds of streamed findings.
+const (
+	streamPackage        = "package"
+	streamSecret         = "secret"
+	streamPackageVuln    = "package_vuln"
+	streamGenericFinding = "generic_finding"
+	// Sent first when a preempted scan restarts from scratch: the items
+	// streamed before are reported again and should be discarded.
+	streamRestart = "restart"
+)
+
+// findingFunc receives the JSON-encoded streamedFinding of a scan.
+type findingFunc func(finding []byte)
+
+// streamedFinding is the document passed to the finding callback, one per
+// inventory item as the plugin reporting it returns.
+type streamedFinding struct {
+	Kind   string
+	Plugin string
+	// Scan root the item was found in, and its index among the scan roots
+	Root      string
+	RootIndex int
+	// Set according to Kind. Locations are relative to Root.
+	Package        *extractor.Package        `json:",omitempty"`
+	Secret         *inventory.Secret         `json:",omitempty"`
+	PackageVuln    *inventory.PackageVuln    `json:",omitempty"`
+	GenericFinding *inventory.GenericFinding `json:",omitempty"`
+}
+
+// findingStream delivers the inventory of a scan to the host while the
+// plugins report it. Calls of the callback are serialized.
+type findingStream struct {
+	report findingFunc
+	// Whether secrets are redacted before they're streamed
+	redact bool
+
+	mu        sync.Mutex
+	root      string
+	rootIndex int
+}
+
+// newFindingStream returns a stream reporting to report. A scan restarted
+// after a preemption tells the host first.
+func newFindingStream(report findingFunc, redact, restarted bool) *findingStream {
+	if restarted {
+		if data, err := json.Marshal(streamedFinding{Kind: streamRestart}); err == nil {
+			report(data)
+		}
+	}
+	return &findingStream{report: report, redact: redact}
+}
+
+// startRoot attributes the following items to the i-th root.
+func (s *findingStream) startRoot(i int, root string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.root, s.rootIndex = root, i
+}
+
+// wrap returns plugins with every extractor, detector and enricher
+// instrumented to stream what it reports.
+func (s *findingStream) wrap(plugins []plugin.Plugin) []plugin.Plugin {
+	wrapped := make([]plugin.Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		switch p := p.(type) {
+		case filesystem.Extractor:
+			wrapped = append(wrapped, &streamExtractor{Extractor: p, s: s})
+		case standalone.Extractor:
+			wrapped = append(wrapped, &streamStandalone{Extractor: p, s: s})
+		case detector.Detector:
+			wrapped = append(wrapped, &streamDetector{Detector: p, s: s})
+		case enricher.Enricher:
+			wrapped = append(wrapped, &streamEnricher{Enricher: p, s: s})
+		default:
+			wrapped = append(wrapped, p)
+		}
+	}
+	return wrapped
+}
+
+// send streams the items the named plugin reported.
+func (s *findingStream) send(name string, inv inventory.Inventory) {
+	var items []streamedFinding
+	for _, p := range inv.Packages {
+		items = append(items, streamedFinding{Kind: streamPackage, Package: detachedPackage(p)})
+	}
+	for _, sec := range inv.Secrets {
+		if s.redact {
+			sec = redactSecret(sec)
+		}
+		items = append(items, streamedFinding{Kind: streamSecret, Secret: sec})
+	}
+	for _, v := range inv.PackageVulns {
+		if v.Package != nil && v.Package.LayerMetadata != nil {
+			c := *v
+			c.Package = detachedPackage(v.Package)
+			v = &c
+		}
+		items = append(items, streamedFinding{Kind: streamPackageVuln, PackageVuln: v})
+	}
+	for _, f := range inv.GenericFindings {
+		items = append(items, streamedFinding{Kind: streamGenericFinding, GenericFinding: f})
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range items {
+		item.Plugin, item.Root, item.RootIndex = name, s.root, s.rootIndex
+		data, err := json.Marshal(item)
+		if err != nil {
+			log.Warnf("failed to stream %s from %s: %v", item.Kind, name, err)
+			continue
+		}
+		s.report(data)
+	}
+}
+
+// detachedPackage returns p without its image layer, which refers back to
+// the image and can't be encoded on its own.
+func detachedPackage(p *extractor.Package) *extractor.Package {
+	if p == nil || p.LayerMetadata == nil {
+		return p
+	}
+	c := *p
+	c.LayerMetadata = nil
+	return &c
+}
+
+type streamExtractor struct {
+	filesystem.Extractor
+	s *findingStream
+}
+
+func (e *streamExtractor) Extract(ctx context.Context, input *filesystem.ScanInput) (inventory.Inventory, error) {
+	inv, err := e.Extractor.Extract(ctx, input)
+	e.s.send(e.Name(), inv)
+	return inv, err
+}
+
+type streamStandalone struct {
+	standalone.Extractor
+	s *findingStream
+}
+
+func (e *streamStandalone) Extract(ctx context.Context, input *standalone.ScanInput) (inventory.Inventory, error) {
+	inv, err := e.Extractor.Extract(ctx, input)
+	e.s.send(e.Name(), inv)
+	return inv, err
+}
+
+type streamDetector struct {
+	detector.Detector
+	s *findingStream
+}
+
+func (d *streamDetector) Scan(ctx context.Context, root *scalibrfs.ScanRoot, px *packageindex.PackageIndex) (inventory.Finding, error) {
+	finding, err := d.Detector.Scan(ctx, root, px)
+	d.s.send(d.Name(), inventory.Inventory{PackageVulns: finding.PackageVulns, GenericFindings: finding.GenericFindings})
+	return finding, err
+}
+
+type streamEnricher struct {
+	enricher.Enricher
+	s *findingStream
+}
+
+func (e *streamEnricher) Enrich(ctx context.Context, input *enricher.ScanInput, inv *inventory.Inventory) error {
+	vulns, findings := len(inv.PackageVulns), len(inv.GenericFindings)
+	err := e.Enricher.Enrich(ctx, input, inv)
+	e.s.send(e.Name(), inventory.Inventory{
+		PackageVulns:    tail(inv.PackageVulns, vulns),
+		GenericFindings: tail(inv.GenericFindings, findings),
+	})
+	return err
+}
@@ -0,0 +1,153 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/osv-scalibr/detector"
+	"github.com/google/osv-scalibr/extractor"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	scalibrfs "github.com/google/osv-scalibr/fs"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/packageindex"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// fixedExtractor returns the same inventory for every file.
+type fixedExtractor struct{ inv inventory.Inventory }
+
+func (fixedExtractor) Name() string                         { return "test/fixed" }
+func (fixedExtractor) Version() int                         { return 0 }
+func (fixedExtractor) Requirements() *plugin.Capabilities   { return &plugin.Capabilities{} }
+func (fixedExtractor) FileRequired(filesystem.FileAPI) bool { return true }
+
+func (e fixedExtractor) Extract(context.Context, *filesystem.ScanInput) (inventory.Inventory, error) {
+	return e.inv, nil
+}
+
+// fixedDetector reports the same finding for every scan root.
+type fixedDetector struct{ finding inventory.Finding }
+
+func (fixedDetector) Name() string                       { return "test/detector" }
+func (fixedDetector) Version() int                       { return 0 }
+func (fixedDetector) Requirements() *plugin.Capabilities { return &plugin.Capabilities{} }
+func (fixedDetector) RequiredExtractors() []string       { return nil }
+func (fixedDetector) DetectedFinding() inventory.Finding { return inventory.Finding{} }
+func (d fixedDetector) Scan(context.Context, *scalibrfs.ScanRoot, *packageindex.PackageIndex) (inventory.Finding, error) {
+	return d.finding, nil
+}
+
+// streamedPackage is the part of a streamed package the tests look at.
+type streamedPackage struct {
+	Name          string
+	LayerMetadata json.RawMessage
+}
+
+// hasLayer reports whether the package was streamed with its image layer.
+func (p *streamedPackage) hasLayer() bool {
+	return len(p.LayerMetadata) > 0 && string(p.LayerMetadata) != "null"
+}
+
+// streamedItem is the part of a streamedFinding the tests look at.
+type streamedItem struct {
+	Kind        string
+	Plugin      string
+	Root        string
+	RootIndex   int
+	Package     *streamedPackage
+	PackageVuln *struct{ Package *streamedPackage }
+}
+
+// streamed collects the items passed to the finding callback.
+type streamed []streamedItem
+
+func (s *streamed) report(data []byte) {
+	var item streamedItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		panic(err)
+	}
+	*s = append(*s, item)
+}
+
+func TestFindingStream(t *testing.T) {
+	lodash := &extractor.Package{Name: "lodash", Version: "4.17.20", Locations: []string{"package-lock.json"}}
+	layered := &extractor.Package{Name: "busybox", Version: "1.35.0", Locations: []string{"bin/busybox"}, LayerMetadata: &extractor.LayerMetadata{}}
+	var got streamed
+	s := newFindingStream(got.report, false, false)
+	plugins := s.wrap([]plugin.Plugin{
+		fixedExtractor{inv: inventory.Inventory{Packages: []*extractor.Package{lodash, layered}}},
+		fixedDetector{finding: inventory.Finding{PackageVulns: []*inventory.PackageVuln{{Package: layered}}}},
+	})
+
+	ctx := context.Background()
+	s.startRoot(1, "/srv/app")
+	if _, err := plugins[0].(filesystem.Extractor).Extract(ctx, &filesystem.ScanInput{Path: "package-lock.json"}); err != nil {
+		t.Fatal(err)
+	}
+	s.startRoot(2, "/srv/image")
+	if _, err := plugins[1].(detector.Detector).Scan(ctx, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("streamed %d items, want 3: %+v", len(got), got)
+	}
+	if f := got[0]; f.Kind != streamPackage || f.Plugin != "test/fixed" || f.Root != "/srv/app" || f.RootIndex != 1 || f.Package.Name != "lodash" {
+		t.Errorf("first item = %+v, want lodash from test/fixed in root 1", f)
+	}
+	if f := got[2]; f.Kind != streamPackageVuln || f.Plugin != "test/detector" || f.RootIndex != 2 {
+		t.Errorf("third item = %+v, want a package_vuln from test/detector in root 2", f)
+	}
+	// The layer refers back to the image and is left out of the stream, but
+	// not out of the scan's own inventory
+	if got[1].Package.hasLayer() || got[2].PackageVuln.Package.hasLayer() {
+		t.Error("streamed packages have their image layer")
+	}
+	if layered.LayerMetadata == nil {
+		t.Error("streaming cleared the layer of the scanned package")
+	}
+}
+
+func TestFindingStreamRedactsSecrets(t *testing.T) {
+	type apiKey struct{ Key string }
+	secret := &inventory.Secret{Secret: apiKey{Key: "sk-live-0123456789"}, Location: ".env"}
+	for _, redact := range []bool{false, true} {
+		var got []string
+		s := newFindingStream(func(data []byte) { got = append(got, string(data)) }, redact, false)
+		s.send("secrets/gcpsak", inventory.Inventory{Secrets: []*inventory.Secret{secret}})
+		if len(got) != 1 {
+			t.Fatalf("redact %v: streamed %d items, want 1", redact, len(got))
+		}
+		if leaked := strings.Contains(got[0], "sk-live-0123456789"); leaked == redact {
+			t.Errorf("redact %v: streamed %s", redact, got[0])
+		}
+	}
+}
+
+func TestFindingStreamRestart(t *testing.T) {
+	var got streamed
+	newFindingStream(got.report, false, false)
+	if len(got) != 0 {
+		t.Errorf("first run streamed %+v before any plugin ran", got)
+	}
+	newFindingStream(got.report, false, true)
+	if len(got) != 1 || got[0].Kind != streamRestart {
+		t.Errorf("restarted run streamed %+v, want a restart item", got)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	scalibrfs "github.com/google/osv-scalibr/fs"
+)
+
+// Values of the symlinks option. The default leaves symlinks to SCALIBR,
+// which doesn't follow them.
+const (
+	symlinksNever      = "never"
+	symlinksWithinRoot = "within_root"
+	symlinksAll        = "all"
+)
+
+var symlinkPolicies = []string{symlinksNever, symlinksWithinRoot, symlinksAll}
+
+// symlinkResolver applies the symlinks policy to the walk of a directory
+// root. Followed symlinks are listed as the file or directory they point to,
+// so the walk reads or descends into them.
+type symlinkResolver struct {
+	policy string
+	// Root with its own symlinks resolved
+	root string
+
+	mu sync.Mutex
+	// Resolved directories reached through a followed symlink
+	followed map[string]bool
+}
+
+// resolveSymlinks wraps the filesystems of the roots of a directory scan
+// with the symlinks policy. Without a policy it returns them as they are.
+func resolveSymlinks(roots []*scalibrfs.ScanRoot, policy string) []*scalibrfs.ScanRoot {
+	if policy == "" {
+		return roots
+	}
+	resolved := make([]*scalibrfs.ScanRoot, 0, len(roots))
+	for _, r := range roots {
+		realRoot, err := filepath.EvalSymlinks(r.Path)
+		if err != nil {
+			realRoot = r.Path
+		}
+		sr := &symlinkResolver{policy: policy, root: realRoot, followed: map[string]bool{}}
+		resolved = append(resolved, editListings([]*scalibrfs.ScanRoot{r}, sr.resolve)...)
+	}
+	return resolved
+}
+
+// resolve replaces the symlinks among the entries of the directory at the
+// slash-separated path dir by their targets, or drops them if they aren't
+// followed.
+func (r *symlinkResolver) resolve(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	resolved := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Type()&fs.ModeSymlink == 0 {
+			resolved = append(resolved, e)
+			continue
+		}
+		if r.policy == symlinksNever {
+			continue
+		}
+		if target, ok := r.follow(filepath.Join(r.root, filepath.FromSlash(dir)), e.Name()); ok {
+			resolved = append(resolved, target)
+		}
+	}
+	return resolved
+}
+
+// follow resolves the symlink name in the directory dir. It reports false
+// for dangling symlinks, targets the policy excludes and directories that
+// would make the walk loop: ancestors of the symlink and directories
+// already reached through another symlink.
+func (r *symlinkResolver) follow(dir, name string) (fs.DirEntry, bool) {
+	target, err := filepath.EvalSymlinks(filepath.Join(dir, name))
+	if err != nil {
+		return nil, false
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, false
+	}
+	if r.policy == symlinksWithinRoot && !isWithin(target, r.root) {
+		return nil, false
+	}
+	if info.IsDir() {
+		realDir, err := filepath.EvalSymlinks(dir)
+		if err != nil || isWithin(realDir, target) {
+			return nil, false
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.followed[target] {
+			return nil, false
+		}
+		r.followed[target] = true
+	}
+	return fs.FileInfoToDirEntry(renamedFileInfo{FileInfo: info, name: name}), true
+}
+
+// renamedFileInfo is the metadata of a symlink's target under the name of
+// the symlink.
+type renamedFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i renamedFileInfo) Name() string { return i.name }
+
+// isWithin reports whether path is dir or lies below it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
@@ -0,0 +1,293 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	scalibrfs "github.com/google/osv-scalibr/fs"
+)
+
+// tarBufferName names a scanned tar buffer in errors and progress updates.
+const tarBufferName = "tar buffer"
+
+// tarSpillSize is the size above which a file of a tar archive is staged in
+// the scan's workspace instead of being kept in memory.
+const tarSpillSize = 4 << 20
+
+// defaultMaxTarballBytes caps the unpacked size of a tar buffer unless
+// max_tarball_bytes is set, so a small compressed archive can't fill the
+// disk.
+const defaultMaxTarballBytes = 4 << 30
+
+// maxTarballBytes returns the cap on the unpacked size of a tar buffer.
+func (o *scanOptions) maxTarballBytes() int64 {
+	if o.MaxTarballBytes > 0 {
+		return o.MaxTarballBytes
+	}
+	return defaultMaxTarballBytes
+}
+
+// tarFS is a read-only filesystem holding the regular files and directories
+// of a tar archive. Other entries, such as symlinks and devices, are left
+// out. The archive is only parsed by load, once the scan's workspace exists.
+type tarFS struct {
+	// The archive, owned by the caller of the scan
+	data  []byte
+	files map[string]*tarEntry
+}
+
+// tarEntry is a file or directory of a tarFS. It serves as its own
+// fs.FileInfo and fs.DirEntry.
+type tarEntry struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+	size    int64
+	// Contents of a small file
+	data []byte
+	// File in the workspace holding the contents of a large file
+	staged string
+	// Entries of a directory, sorted by name
+	children []fs.DirEntry
+}
+
+// newTarFS returns the filesystem of the tar archive in data, which may be
+// gzip compressed. data must stay valid until the scan finishes.
+func newTarFS(data []byte) *tarFS {
+	return &tarFS{data: data}
+}
+
+// load reads the archive, staging files larger than tarSpillSize in dir. It
+// fails once the files add up to more than limit bytes. It starts over on
+// every call, since each run of a scan has its own workspace.
+func (t *tarFS) load(dir string, limit int64) error {
+	var r io.Reader = bytes.NewReader(t.data)
+	if bytes.HasPrefix(t.data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		r = zr
+	}
+	t.files = map[string]*tarEntry{
+		".": {name: ".", mode: fs.ModeDir | 0o755},
+	}
+	tr := tar.NewReader(r)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			t.dir(name).modTime = hdr.ModTime
+		case tar.TypeReg:
+			// The reader returns exactly hdr.Size bytes of the entry
+			if total += hdr.Size; total > limit {
+				return validationErrors{{
+					Field:   "max_tarball_bytes",
+					Code:    codeOutOfRange,
+					Message: fmt.Sprintf("the archive unpacks to more than %d bytes", limit),
+				}}
+			}
+			e := &tarEntry{mode: hdr.FileInfo().Mode().Perm(), modTime: hdr.ModTime}
+			if hdr.Size > tarSpillSize {
+				e.staged, e.size, err = stageTarEntry(dir, tr)
+			} else {
+				e.data, err = io.ReadAll(tr)
+				e.size = int64(len(e.data))
+			}
+			if err != nil {
+				return fmt.Errorf("invalid tar archive: %s: %w", name, err)
+			}
+			t.add(name, e)
+		case tar.TypeLink:
+			// Hard links share the contents of an earlier entry
+			if target, ok := t.files[path.Clean(strings.TrimPrefix(hdr.Linkname, "/"))]; ok && !target.IsDir() {
+				t.add(name, &tarEntry{mode: target.mode, modTime: hdr.ModTime, size: target.size, data: target.data, staged: target.staged})
+			}
+		}
+	}
+	for _, e := range t.files {
+		slices.SortFunc(e.children, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	}
+	return nil
+}
+
+// stageTarEntry copies the contents of the current entry of tr to a new file
+// in dir and returns its path and size.
+func stageTarEntry(dir string, tr *tar.Reader) (string, int64, error) {
+	f, err := os.CreateTemp(dir, "tar-")
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := io.Copy(f, tr)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return f.Name(), n, err
+}
+
+// add adds the file e at name, creating its parent directories. A later
+// entry with the same name replaces the earlier one, as when extracting.
+func (t *tarFS) add(name string, e *tarEntry) {
+	e.name = path.Base(name)
+	parent := t.dir(path.Dir(name))
+	if old, ok := t.files[name]; ok {
+		if old.IsDir() {
+			return
+		}
+		parent.children = slices.DeleteFunc(parent.children, func(c fs.DirEntry) bool { return c == fs.DirEntry(old) })
+	}
+	t.files[name] = e
+	parent.children = append(parent.children, e)
+}
+
+// dir returns the directory at name, creating it and its parents if needed.
+func (t *tarFS) dir(name string) *tarEntry {
+	if e, ok := t.files[name]; ok && e.IsDir() {
+		return e
+	}
+	d := &tarEntry{mode: fs.ModeDir | 0o755}
+	t.add(name, d)
+	return d
+}
+
+func (t *tarFS) lookup(op, name string) (*tarEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := t.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+	e, err := t.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.staged != "" {
+		f, err := os.Open(e.staged)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &tarFile{tarEntry: e, r: f}, nil
+	}
+	return &tarFile{tarEntry: e, r: bytes.NewReader(e.data)}, nil
+}
+
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := t.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return slices.Clone(e.children), nil
+}
+
+func (t *tarFS) Stat(name string) (fs.FileInfo, error) {
+	return t.lookup("stat", name)
+}
+
+var _ scalibrfs.FS = &tarFS{}
+
+func (e *tarEntry) Name() string               { return e.name }
+func (e *tarEntry) Size() int64                { return e.size }
+func (e *tarEntry) Mode() fs.FileMode          { return e.mode }
+func (e *tarEntry) ModTime() time.Time         { return e.modTime }
+func (e *tarEntry) IsDir() bool                { return e.mode.IsDir() }
+func (e *tarEntry) Sys() any                   { return nil }
+func (e *tarEntry) Type() fs.FileMode          { return e.mode.Type() }
+func (e *tarEntry) Info() (fs.FileInfo, error) { return e, nil }
+
+// tarFile is an open tarEntry. Reads of directories fail.
+type tarFile struct {
+	*tarEntry
+	// A bytes.Reader, or the staged file
+	r interface {
+		io.Reader
+		io.ReaderAt
+		io.Seeker
+	}
+	// Entries of a directory not returned by ReadDir yet
+	unread []fs.DirEntry
+	opened bool
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.tarEntry, nil }
+
+func (f *tarFile) Read(b []byte) (int, error) {
+	if f.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.New("is a directory")}
+	}
+	return f.r.Read(b)
+}
+
+// ReadAt and Seek serve the extractors of archive formats.
+func (f *tarFile) ReadAt(b []byte, off int64) (int, error) { return f.r.ReadAt(b, off) }
+
+func (f *tarFile) Seek(offset int64, whence int) (int64, error) { return f.r.Seek(offset, whence) }
+
+func (f *tarFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+	}
+	if !f.opened {
+		f.unread, f.opened = f.children, true
+	}
+	if n <= 0 || n >= len(f.unread) {
+		entries := f.unread
+		f.unread = nil
+		if n > 0 && len(entries) == 0 {
+			return nil, io.EOF
+		}
+		return entries, nil
+	}
+	entries := f.unread[:n]
+	f.unread = f.unread[n:]
+	return entries, nil
+}
+
+func (f *tarFile) Close() error {
+	if c, ok := f.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
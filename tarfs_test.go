@@ -0,0 +1,205 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tarItem is an entry of an archive built by makeTar.
+type tarItem struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+}
+
+func makeTar(t *testing.T, items []tarItem, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for _, it := range items {
+		hdr := &tar.Header{Name: it.name, Typeflag: it.typeflag, Linkname: it.linkname, Mode: 0o644}
+		if it.typeflag == tar.TypeReg {
+			hdr.Size = int64(len(it.content))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, it.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestTarFSLoad(t *testing.T) {
+	large := strings.Repeat("x", tarSpillSize+1)
+	tests := []struct {
+		name     string
+		items    []tarItem
+		compress bool
+		// Expected contents by path, "/" for directories
+		want map[string]string
+	}{
+		{
+			name:  "regular files",
+			items: []tarItem{{name: "a.txt", typeflag: tar.TypeReg, content: "a"}, {name: "/b/c.txt", typeflag: tar.TypeReg, content: "c"}},
+			want:  map[string]string{"a.txt": "a", "b": "/", "b/c.txt": "c"},
+		},
+		{
+			name:     "gzip",
+			items:    []tarItem{{name: "a.txt", typeflag: tar.TypeReg, content: "a"}},
+			compress: true,
+			want:     map[string]string{"a.txt": "a"},
+		},
+		{
+			name:  "later entry replaces earlier",
+			items: []tarItem{{name: "a.txt", typeflag: tar.TypeReg, content: "old"}, {name: "a.txt", typeflag: tar.TypeReg, content: "new"}},
+			want:  map[string]string{"a.txt": "new"},
+		},
+		{
+			name:  "hard link",
+			items: []tarItem{{name: "a.txt", typeflag: tar.TypeReg, content: "a"}, {name: "l.txt", typeflag: tar.TypeLink, linkname: "a.txt"}},
+			want:  map[string]string{"a.txt": "a", "l.txt": "a"},
+		},
+		{
+			name:  "symlinks and escaping paths left out",
+			items: []tarItem{{name: "s", typeflag: tar.TypeSymlink, linkname: "a.txt"}, {name: "../x.txt", typeflag: tar.TypeReg, content: "x"}},
+			want:  map[string]string{},
+		},
+		{
+			name:  "large file staged",
+			items: []tarItem{{name: "big.bin", typeflag: tar.TypeReg, content: large}, {name: "big.lnk", typeflag: tar.TypeLink, linkname: "big.bin"}},
+			want:  map[string]string{"big.bin": large, "big.lnk": large},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := newTarFS(makeTar(t, tc.items, tc.compress))
+			if err := fsys.load(t.TempDir(), defaultMaxTarballBytes); err != nil {
+				t.Fatalf("load() = %v", err)
+			}
+			got := map[string]string{}
+			err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+				if err != nil || p == "." {
+					return err
+				}
+				if d.IsDir() {
+					got[p] = "/"
+					return nil
+				}
+				data, err := fs.ReadFile(fsys, p)
+				got[p] = string(data)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("WalkDir() = %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Errorf("got paths %v, want %v", slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(tc.want)))
+			}
+			for p, want := range tc.want {
+				if got[p] != want {
+					t.Errorf("contents of %s: got %d bytes, want %d", p, len(got[p]), len(want))
+				}
+			}
+		})
+	}
+}
+
+func TestTarFSLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		limit int64
+		// Field of the expected validation error, if any
+		field string
+	}{
+		{
+			name:  "not an archive",
+			data:  []byte("not a tar archive, just some text that is long enough to fill a header block"),
+			limit: defaultMaxTarballBytes,
+		},
+		{
+			name:  "truncated gzip",
+			data:  []byte{0x1f, 0x8b, 0x08},
+			limit: defaultMaxTarballBytes,
+		},
+		{
+			name:  "over the limit",
+			data:  makeTar(t, []tarItem{{name: "a", typeflag: tar.TypeReg, content: "12345"}, {name: "b", typeflag: tar.TypeReg, content: "67890"}}, true),
+			limit: 8,
+			field: "max_tarball_bytes",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newTarFS(tc.data).load(t.TempDir(), tc.limit)
+			if err == nil {
+				t.Fatal("load() succeeded, want error")
+			}
+			var ve validationErrors
+			if gotField := errors.As(err, &ve); gotField != (tc.field != "") {
+				t.Fatalf("load() = %v, want validation error: %v", err, tc.field != "")
+			}
+			if tc.field != "" && ve[0].Field != tc.field {
+				t.Errorf("error field = %q, want %q", ve[0].Field, tc.field)
+			}
+		})
+	}
+}
+
+func TestTarFSStagedFilesInDir(t *testing.T) {
+	dir := t.TempDir()
+	data := makeTar(t, []tarItem{
+		{name: "small", typeflag: tar.TypeReg, content: "s"},
+		{name: "big", typeflag: tar.TypeReg, content: strings.Repeat("x", tarSpillSize+1)},
+	}, false)
+	if err := newTarFS(data).load(dir, defaultMaxTarballBytes); err != nil {
+		t.Fatalf("load() = %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d staged files, want 1", len(entries))
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+
+	scalibrfs "github.com/google/osv-scalibr/fs"
+)
+
+// pacer spaces out units of work, e.g. bytes read, to a rate per second.
+// Work is paid for after it's done, so a single large read isn't delayed
+// but the reads after it are.
+type pacer struct {
+	perSecond float64
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the work paid for so far is within the rate and adds n
+// units to it.
+func (p *pacer) wait(ctx context.Context, n int64) error {
+	p.mu.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	delay := p.next.Sub(now)
+	p.next = p.next.Add(time.Duration(float64(n) * float64(time.Second) / p.perSecond))
+	p.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ioThrottle caps the filesystem reads of a scan, set by the
+// max_read_bytes_per_second and max_iops options. Opening a file, listing a
+// directory and every read count as one operation.
+type ioThrottle struct {
+	ctx   context.Context
+	bytes *pacer
+	ops   *pacer
+}
+
+// newIOThrottle returns the throttle of a scan, or nil if it's unlimited.
+func newIOThrottle(ctx context.Context, opts *scanOptions) *ioThrottle {
+	if opts.MaxReadBytesPerSecond <= 0 && opts.MaxIOPS <= 0 {
+		return nil
+	}
+	t := &ioThrottle{ctx: ctx}
+	if opts.MaxReadBytesPerSecond > 0 {
+		t.bytes = &pacer{perSecond: float64(opts.MaxReadBytesPerSecond)}
+	}
+	if opts.MaxIOPS > 0 {
+		t.ops = &pacer{perSecond: float64(opts.MaxIOPS)}
+	}
+	return t
+}
+
+// op waits for an I/O operation slot.
+func (t *ioThrottle) op() error {
+	if t.ops == nil {
+		return nil
+	}
+	return t.ops.wait(t.ctx, 1)
+}
+
+// read accounts for a read of n bytes.
+func (t *ioThrottle) read(n int) error {
+	if err := t.op(); err != nil {
+		return err
+	}
+	if t.bytes == nil || n <= 0 {
+		return nil
+	}
+	return t.bytes.wait(t.ctx, int64(n))
+}
+
+// roots wraps the filesystems of the scan roots. A nil throttle returns them
+// as they are.
+func (t *ioThrottle) roots(roots []*scalibrfs.ScanRoot) []*scalibrfs.ScanRoot {
+	if t == nil {
+		return roots
+	}
+	throttled := make([]*scalibrfs.ScanRoot, 0, len(roots))
+	for _, r := range roots {
+		throttled = append(throttled, &scalibrfs.ScanRoot{FS: &throttledFS{fsys: r.FS, t: t}, Path: r.Path})
+	}
+	return throttled
+}
+
+// throttledFS paces the reads of the filesystem it wraps.
+type throttledFS struct {
+	fsys scalibrfs.FS
+	t    *ioThrottle
+}
+
+func (f *throttledFS) Open(name string) (fs.File, error) {
+	if err := f.t.op(); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	tf := &throttledFile{File: file, t: f.t}
+	// Keep random access for the extractors that need it, e.g. for archives
+	if ra, ok := file.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		return &throttledRandomAccessFile{throttledFile: tf, ra: ra}, nil
+	}
+	return tf, nil
+}
+
+func (f *throttledFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := f.t.op(); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return f.fsys.ReadDir(name)
+}
+
+func (f *throttledFS) Stat(name string) (fs.FileInfo, error) {
+	return f.fsys.Stat(name)
+}
+
+// throttledFile is a file opened through a throttledFS.
+type throttledFile struct {
+	fs.File
+	t *ioThrottle
+}
+
+func (f *throttledFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	if terr := f.t.read(n); terr != nil && err == nil {
+		err = terr
+	}
+	return n, err
+}
+
+// throttledRandomAccessFile is a throttledFile that supports ReadAt and Seek.
+type throttledRandomAccessFile struct {
+	*throttledFile
+	ra interface {
+		io.ReaderAt
+		io.Seeker
+	}
+}
+
+func (f *throttledRandomAccessFile) ReadAt(b []byte, off int64) (int, error) {
+	n, err := f.ra.ReadAt(b, off)
+	if terr := f.t.read(n); terr != nil && err == nil {
+		err = terr
+	}
+	return n, err
+}
+
+func (f *throttledRandomAccessFile) Seek(offset int64, whence int) (int64, error) {
+	return f.ra.Seek(offset, whence)
+}
@@ -0,0 +1,212 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/osv-scalibr/detector"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	"github.com/google/osv-scalibr/extractor/standalone"
+	scalibrfs "github.com/google/osv-scalibr/fs"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/log"
+	"github.com/google/osv-scalibr/packageindex"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// abandonedCallGrace is how long a scan waits at most for the plugin calls
+// its timeouts abandoned before it returns. Their context is done by then,
+// so plugins that honor it return well within it.
+const abandonedCallGrace = 5 * time.Second
+
+// pluginTimeouts bounds each call of an extractor or detector, set by the
+// plugin_timeout_ms option. A call that runs over is abandoned: the scan
+// goes on without its result and the plugin is reported as failed. The
+// abandoned call keeps its worker slot until it returns, and the scan waits
+// for it before returning, see wait.
+type pluginTimeouts struct {
+	timeout time.Duration
+	// Calls still running, including the abandoned ones
+	running sync.WaitGroup
+
+	mu sync.Mutex
+	// Reason by plugin name, for the plugins that timed out
+	timedOut map[string]string
+}
+
+// newPluginTimeouts returns the timeouts of a scan, or nil if its plugins
+// may run as long as they like.
+func newPluginTimeouts(opts *scanOptions) *pluginTimeouts {
+	if opts.PluginTimeoutMs <= 0 {
+		return nil
+	}
+	return &pluginTimeouts{
+		timeout:  time.Duration(opts.PluginTimeoutMs) * time.Millisecond,
+		timedOut: map[string]string{},
+	}
+}
+
+// wrap bounds the calls of the extractors and detectors among plugins and
+// runs them within the worker limit, see withWorkerLimit. A nil receiver
+// only applies the worker limit.
+func (t *pluginTimeouts) wrap(plugins []plugin.Plugin) []plugin.Plugin {
+	if t == nil {
+		return withWorkerLimit(plugins)
+	}
+	wrapped := make([]plugin.Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		switch p := p.(type) {
+		case filesystem.Extractor:
+			wrapped = append(wrapped, &timeoutExtractor{Extractor: p, t: t})
+		case standalone.Extractor:
+			wrapped = append(wrapped, &timeoutStandalone{Extractor: p, t: t})
+		case detector.Detector:
+			wrapped = append(wrapped, &timeoutDetector{Detector: p, t: t})
+		default:
+			wrapped = append(wrapped, p)
+		}
+	}
+	return wrapped
+}
+
+// runWithTimeout calls f within the worker limit with a context that
+// expires after the timeout and returns its result, or an error if f is
+// still running by then. f keeps running in the background, as Go can't
+// stop it, and holds its worker slot until it returns. The time spent
+// waiting for the slot doesn't count toward the timeout.
+func runWithTimeout[T any](ctx context.Context, t *pluginTimeouts, name, what string, f func(context.Context) (T, error)) (T, error) {
+	release, err := acquireWorker(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	t.running.Add(1)
+	go func() {
+		defer t.running.Done()
+		defer release()
+		v, err := f(ctx)
+		done <- result{v, err}
+	}()
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+		var zero T
+		if context.Cause(ctx) != context.DeadlineExceeded {
+			// The scan was cancelled
+			return zero, ctx.Err()
+		}
+		reason := fmt.Sprintf("timed out after %v on %s", t.timeout, what)
+		t.mu.Lock()
+		if _, ok := t.timedOut[name]; !ok {
+			t.timedOut[name] = reason
+		}
+		t.mu.Unlock()
+		return zero, fmt.Errorf("plugin %s %s", name, reason)
+	}
+}
+
+// wait waits for the plugin calls that are still running, which after the
+// walk are those abandoned by their timeout, for at most
+// abandonedCallGrace. It reports whether they all returned. A nil receiver
+// has no calls to wait for.
+func (t *pluginTimeouts) wait() bool {
+	if t == nil {
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		t.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(abandonedCallGrace):
+		log.Warnf("plugin calls abandoned by plugin_timeout_ms are still running after %v", abandonedCallGrace)
+		return false
+	}
+}
+
+// markTimedOut reports the plugins that timed out as failed in statuses.
+// The first timeout of each plugin is given as its failure reason.
+func (t *pluginTimeouts) markTimedOut(statuses []*plugin.Status) []*plugin.Status {
+	if t == nil {
+		return statuses
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, name := range slices.Sorted(maps.Keys(t.timedOut)) {
+		reason := t.timedOut[name]
+		i := slices.IndexFunc(statuses, func(s *plugin.Status) bool { return s.Name == name })
+		if i < 0 {
+			statuses = append(statuses, &plugin.Status{Name: name})
+			i = len(statuses) - 1
+		}
+		s := statuses[i]
+		if s.Status == nil {
+			s.Status = &plugin.ScanStatus{}
+		}
+		s.Status.Status = plugin.ScanStatusFailed
+		s.Status.FailureReason = reason
+	}
+	return statuses
+}
+
+type timeoutExtractor struct {
+	filesystem.Extractor
+	t *pluginTimeouts
+}
+
+func (e *timeoutExtractor) Extract(ctx context.Context, input *filesystem.ScanInput) (inventory.Inventory, error) {
+	return runWithTimeout(ctx, e.t, e.Name(), input.Path, func(ctx context.Context) (inventory.Inventory, error) {
+		return e.Extractor.Extract(ctx, input)
+	})
+}
+
+type timeoutStandalone struct {
+	standalone.Extractor
+	t *pluginTimeouts
+}
+
+func (e *timeoutStandalone) Extract(ctx context.Context, input *standalone.ScanInput) (inventory.Inventory, error) {
+	return runWithTimeout(ctx, e.t, e.Name(), "the system", func(ctx context.Context) (inventory.Inventory, error) {
+		return e.Extractor.Extract(ctx, input)
+	})
+}
+
+type timeoutDetector struct {
+	detector.Detector
+	t *pluginTimeouts
+}
+
+func (d *timeoutDetector) Scan(ctx context.Context, root *scalibrfs.ScanRoot, px *packageindex.PackageIndex) (inventory.Finding, error) {
+	return runWithTimeout(ctx, d.t, d.Name(), "the scan root", func(ctx context.Context) (inventory.Finding, error) {
+		return d.Detector.Scan(ctx, root, px)
+	})
+}
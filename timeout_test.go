@@ -0,0 +1,99 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/osv-scalibr/plugin"
+)
+
+func TestRunWithTimeout(t *testing.T) {
+	timeouts := &pluginTimeouts{timeout: 20 * time.Millisecond, timedOut: map[string]string{}}
+	ctx := context.Background()
+
+	got, err := runWithTimeout(ctx, timeouts, "python/wheelegg", "app/requests.whl", func(context.Context) (int, error) {
+		return 1, nil
+	})
+	if got != 1 || err != nil {
+		t.Errorf("runWithTimeout() of a quick call = %d, %v, want 1, nil", got, err)
+	}
+
+	// A call stuck past its timeout, ignoring its context, keeps running
+	// after it's abandoned
+	unblock := make(chan struct{})
+	stuck := func(context.Context) (int, error) {
+		<-unblock
+		return 2, nil
+	}
+	if _, err := runWithTimeout(ctx, timeouts, "java/archive", "opt/app/huge.ear", stuck); err == nil {
+		t.Error("runWithTimeout() of a stuck call succeeded")
+	}
+	if _, err := runWithTimeout(ctx, timeouts, "java/archive", "opt/app/other.ear", stuck); err == nil {
+		t.Error("runWithTimeout() of a stuck call succeeded")
+	}
+
+	statuses := timeouts.markTimedOut([]*plugin.Status{
+		{Name: "python/wheelegg", Status: &plugin.ScanStatus{Status: plugin.ScanStatusSucceeded}},
+		{Name: "java/archive", Status: &plugin.ScanStatus{Status: plugin.ScanStatusSucceeded}},
+	})
+	if s := statuses[0].Status; s.Status != plugin.ScanStatusSucceeded {
+		t.Errorf("python/wheelegg status = %v, want succeeded", s.Status)
+	}
+	// The first timeout is the reason
+	if s := statuses[1].Status; s.Status != plugin.ScanStatusFailed || s.FailureReason != "timed out after 20ms on opt/app/huge.ear" {
+		t.Errorf("java/archive status = %v %q, want failed after timing out on opt/app/huge.ear", s.Status, s.FailureReason)
+	}
+
+	close(unblock)
+	if !timeouts.wait() {
+		t.Error("wait() = false after the abandoned calls returned")
+	}
+}
+
+func TestRunWithTimeoutCancelled(t *testing.T) {
+	timeouts := &pluginTimeouts{timeout: time.Minute, timedOut: map[string]string{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+	_, err := runWithTimeout(ctx, timeouts, "go/binary", "usr/bin/app", func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("runWithTimeout() of a cancelled scan error = %v, want %v", err, context.Canceled)
+	}
+	// Cancellation isn't a timeout of the plugin
+	if statuses := timeouts.markTimedOut(nil); len(statuses) != 0 {
+		t.Errorf("markTimedOut() = %v after a cancelled scan, want none", statuses)
+	}
+	timeouts.wait()
+}
+
+func TestNewPluginTimeouts(t *testing.T) {
+	if got := newPluginTimeouts(&scanOptions{}); got != nil {
+		t.Errorf("newPluginTimeouts() without plugin_timeout_ms = %+v, want nil", got)
+	}
+	if got := newPluginTimeouts(&scanOptions{PluginTimeoutMs: 1500}); got == nil || got.timeout != 1500*time.Millisecond {
+		t.Errorf("newPluginTimeouts() with plugin_timeout_ms 1500 = %+v, want a 1.5s timeout", got)
+	}
+	// Without timeouts, the scan has no abandoned calls to wait for
+	var timeouts *pluginTimeouts
+	if !timeouts.wait() {
+		t.Error("wait() of nil timeouts = false")
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Validation error codes.
+const (
+	codeUnknownPlugin = "unknown_plugin"
+	codeOutOfRange    = "out_of_range"
+	codeNotFound      = "not_found"
+	codeInvalidValue  = "invalid_value"
+	codeInvalidFile   = "invalid_file"
+	codeUnknownField  = "unknown_field"
+)
+
+// fieldError describes a single invalid configuration field.
+type fieldError struct {
+	// Config key of the field, with an index for list entries, e.g. "plugins[1]".
+	Field   string
+	Code    string
+	Message string
+}
+
+// validationErrors is returned when a scan configuration is rejected. It is
+// serialized into the result so hosts can point at the offending fields.
+type validationErrors []fieldError
+
+func (v validationErrors) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, e := range v {
+		if e.Field == "" {
+			msgs = append(msgs, e.Message)
+		} else {
+			msgs = append(msgs, e.Field+": "+e.Message)
+		}
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// statusCode reports plugin resolution failures separately from other
+// configuration errors, matching the unstructured errors.
+func (v validationErrors) statusCode() int {
+	for _, e := range v {
+		if e.Code != codeUnknownPlugin {
+			return statusConfigError
+		}
+	}
+	return statusPluginLoadError
+}
+
+// validateOptions checks the options before a scan is started and returns
+// all problems found, or nil.
+func validateOptions(opts *scanOptions) error {
+	var errs validationErrors
+	add := func(field, code, format string, args ...any) {
+		errs = append(errs, fieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i, name := range opts.Plugins {
+		if _, err := resolvePlugins([]string{name}, opts, nil); err != nil {
+			add(fmt.Sprintf("plugins[%d]", i), codeUnknownPlugin, "unknown plugin %q", name)
+		}
+	}
+	for i, name := range opts.Detectors {
+		if _, err := resolveDetectors([]string{name}); err != nil {
+			add(fmt.Sprintf("detectors[%d]", i), codeUnknownPlugin, "unknown detector %q", name)
+		}
+	}
+	for i, name := range opts.Annotators {
+		if _, err := resolveAnnotators([]string{name}); err != nil {
+			add(fmt.Sprintf("annotators[%d]", i), codeUnknownPlugin, "unknown annotator %q", name)
+		}
+	}
+	for i, root := range opts.RootPaths {
+		if _, err := os.Stat(cleanHostPath(root)); err != nil {
+			add(fmt.Sprintf("root_paths[%d]", i), codeNotFound, "scan root %q is not accessible: %v", root, err)
+		}
+	}
+	for i, f := range opts.Files {
+		if info, err := os.Stat(cleanHostPath(f)); err != nil {
+			add(fmt.Sprintf("files[%d]", i), codeNotFound, "file %q is not accessible: %v", f, err)
+		} else if !info.Mode().IsRegular() {
+			add(fmt.Sprintf("files[%d]", i), codeInvalidValue, "%q is not a regular file", f)
+		}
+	}
+	if len(opts.Files) > 0 {
+		// The files replace the roots and what is extracted from them
+		for _, f := range []struct {
+			field string
+			set   bool
+		}{
+			{"root_paths", len(opts.RootPaths) > 0},
+			{"paths_to_extract", len(opts.PathsToExtract) > 0},
+			{"image", opts.Image != ""},
+			{"image_tarball", opts.ImageTarball != ""},
+			{"checkpoint_path", opts.CheckpointPath != ""},
+			{"previous_result", opts.PreviousResult != ""},
+		} {
+			if f.set {
+				add(f.field, codeInvalidValue, "can't be combined with files")
+			}
+		}
+	}
+	if opts.IgnoreFile != "" {
+		if _, err := os.Stat(cleanHostPath(opts.IgnoreFile)); err != nil {
+			add("ignore_file", codeNotFound, "ignore file %q is not accessible: %v", opts.IgnoreFile, err)
+		}
+	}
+	if opts.ImageTarball != "" {
+		if _, err := os.Stat(opts.ImageTarball); err != nil {
+			add("image_tarball", codeNotFound, "image tarball %q is not accessible: %v", opts.ImageTarball, err)
+		}
+	}
+	if opts.Image != "" {
+		if opts.ImageTarball != "" {
+			add("image", codeInvalidValue, "can't be combined with image_tarball")
+		}
+		if _, err := name.ParseReference(opts.Image); err != nil {
+			add("image", codeInvalidValue, "%v", err)
+		}
+	}
+	if a := opts.RegistryAuth; a != nil {
+		if opts.Image == "" {
+			add("registry_auth", codeInvalidValue, "requires image")
+		}
+		if a.Password != "" && a.Username == "" {
+			add("registry_auth.username", codeInvalidValue, "required with a password")
+		}
+	}
+	if opts.scanImage() || opts.virtualFS != nil {
+		// These read the scanned files from the host's disk
+		for _, f := range []struct {
+			field string
+			set   bool
+		}{
+			{"root_paths", len(opts.RootPaths) > 0},
+			{"remediation_dir", opts.RemediationDir != ""},
+			{"js_workspaces", opts.JSWorkspaces == jsWorkspacesPerWorkspace},
+			{"checkpoint_path", opts.CheckpointPath != ""},
+			{"previous_result", opts.PreviousResult != ""},
+		} {
+			if f.set {
+				add(f.field, codeInvalidValue, "not supported when scanning an image or virtual filesystem")
+			}
+		}
+	}
+	if opts.LayerResultCache {
+		// Entries must hold everything extracted from the layers' files
+		for _, f := range []struct {
+			field string
+			set   bool
+		}{
+			{"paths_to_extract", len(opts.PathsToExtract) > 0},
+			{"skip_dir_regex", opts.SkipDirRegex != ""},
+			{"skip_dir_glob", opts.SkipDirGlob != ""},
+			{"use_gitignore", opts.UseGitignore},
+		} {
+			if f.set {
+				add(f.field, codeInvalidValue, "can't be combined with layer_result_cache")
+			}
+		}
+		if !opts.scanImage() {
+			add("layer_result_cache", codeInvalidValue, "requires image or image_tarball")
+		}
+	}
+	for i, dir := range opts.DirsToSkip {
+		// Image and virtual filesystem paths are resolved inside them
+		if !opts.scanImage() && opts.virtualFS == nil && !skipDirUnderAnyRoot(dir, opts.roots()) {
+			add(fmt.Sprintf("dirs_to_skip[%d]", i), codeInvalidValue, "%q is not inside a scan root", dir)
+		}
+	}
+	if opts.SkipDirRegex != "" && opts.SkipDirGlob != "" {
+		// SCALIBR ignores the glob when a regular expression is set
+		add("skip_dir_glob", codeInvalidValue, "can't be combined with skip_dir_regex")
+	}
+	if _, _, err := compileSkipDirFilters(opts.SkipDirRegex, ""); err != nil {
+		add("skip_dir_regex", codeInvalidValue, "%v", err)
+	}
+	if _, _, err := compileSkipDirFilters("", opts.SkipDirGlob); err != nil {
+		add("skip_dir_glob", codeInvalidValue, "%v", err)
+	}
+	if opts.MaxFileSize < 0 {
+		add("max_file_size", codeOutOfRange, "must not be negative, got %d", opts.MaxFileSize)
+	}
+	if opts.Priority < priorityBackground || opts.Priority > priorityInteractive {
+		add("priority", codeOutOfRange, "must be between %d and %d, got %d", priorityBackground, priorityInteractive, opts.Priority)
+	}
+	if opts.StoreAbsolutePath && opts.RootRelativePaths {
+		add("store_absolute_path", codeInvalidValue, "can't be combined with root_relative_paths")
+	}
+	if opts.MaxInodes < 0 {
+		add("max_inodes", codeOutOfRange, "must not be negative, got %d", opts.MaxInodes)
+	}
+	if opts.ImageCacheBytes < 0 {
+		add("image_cache_bytes", codeOutOfRange, "must not be negative, got %d", opts.ImageCacheBytes)
+	}
+	if opts.MaxTarballBytes < 0 {
+		add("max_tarball_bytes", codeOutOfRange, "must not be negative, got %d", opts.MaxTarballBytes)
+	}
+	if opts.MaxReadBytesPerSecond < 0 {
+		add("max_read_bytes_per_second", codeOutOfRange, "must not be negative, got %d", opts.MaxReadBytesPerSecond)
+	}
+	if opts.MaxIOPS < 0 {
+		add("max_iops", codeOutOfRange, "must not be negative, got %d", opts.MaxIOPS)
+	}
+	if opts.PluginTimeoutMs < 0 {
+		add("plugin_timeout_ms", codeOutOfRange, "must not be negative, got %d", opts.PluginTimeoutMs)
+	}
+	if opts.MaxRSSBytes < 0 {
+		add("max_rss_bytes", codeOutOfRange, "must not be negative, got %d", opts.MaxRSSBytes)
+	}
+	if opts.OSVBatchSize < 0 || opts.OSVBatchSize > osvMaxBatchSize {
+		add("osv_batch_size", codeOutOfRange, "must be between 0 and %d, got %d", osvMaxBatchSize, opts.OSVBatchSize)
+	}
+	if opts.OSVBaseURL != "" {
+		if err := validateFeedURL(opts.OSVBaseURL); err != nil {
+			add("osv_base_url", codeInvalidValue, "invalid URL %q: %v", opts.OSVBaseURL, err)
+		}
+	}
+	if opts.enrichersErr != nil {
+		add("enrichers", codeInvalidValue, "%v", opts.enrichersErr)
+	} else if opts.Enrichers != nil {
+		for i, name := range opts.Enrichers.Names {
+			if _, err := resolveEnrichers([]string{name}, nil); err != nil {
+				add(fmt.Sprintf("enrichers.names[%d]", i), codeUnknownPlugin, "unknown enricher %q", name)
+			}
+		}
+		if s := opts.Enrichers.Settings.OSVDev; s != nil {
+			if s.BatchSize < 0 || s.BatchSize > osvMaxBatchSize {
+				add("enrichers.settings.vulnmatch/osvdev.batch_size", codeOutOfRange, "must be between 0 and %d, got %d", osvMaxBatchSize, s.BatchSize)
+			}
+			if s.BaseURL != "" {
+				if err := validateFeedURL(s.BaseURL); err != nil {
+					add("enrichers.settings.vulnmatch/osvdev.base_url", codeInvalidValue, "invalid URL %q: %v", s.BaseURL, err)
+				}
+			}
+		}
+	}
+	optionsValid := true
+	for _, name := range slices.Sorted(maps.Keys(opts.PluginOptions)) {
+		options := opts.PluginOptions[name]
+		if _, ok, err := pluginMaxFileSize(options); err != nil {
+			add("plugin_options."+name+"."+pluginMaxFileSizeKey, codeOutOfRange, "%v", err)
+			optionsValid = false
+		} else if ok {
+			if _, err := resolvePlugins([]string{name}, opts, nil); err != nil {
+				add("plugin_options."+name, codeUnknownPlugin, "unknown plugin %q", name)
+				optionsValid = false
+			}
+		}
+		if _, ok := scalibrPluginOptions(options); !ok {
+			continue
+		}
+		if _, ok := pluginOptionFields[name]; !ok {
+			add("plugin_options."+name, codeInvalidValue, "plugin takes no options besides %s, want one of %v", pluginMaxFileSizeKey, slices.Sorted(maps.Keys(pluginOptionFields)))
+			optionsValid = false
+		}
+	}
+	if opts.pluginOptionsErr != nil {
+		add("plugin_options", codeInvalidValue, "%v", opts.pluginOptionsErr)
+	} else if optionsValid {
+		if _, err := pluginConfig(opts); err != nil {
+			add("plugin_config", codeInvalidValue, "%v", err)
+		}
+	}
+	for i, a := range opts.BinaryAnalyses {
+		if !slices.Contains(binaryAnalyses, a) {
+			add(fmt.Sprintf("binary_analyses[%d]", i), codeInvalidValue, "unknown analysis %q, want one of %v", a, binaryAnalyses)
+		}
+	}
+	if opts.packageRewritesErr != nil {
+		add("package_rewrites", codeInvalidValue, "%v", opts.packageRewritesErr)
+	} else if _, err := compileRewrites(opts.PackageRewrites); err != nil {
+		add("package_rewrites", codeInvalidValue, "%v", err)
+	}
+	for i, g := range opts.GroupFindings {
+		if !slices.Contains(findingGroupings, g) {
+			add(fmt.Sprintf("group_findings[%d]", i), codeInvalidValue, "unknown grouping %q, want one of %v", g, findingGroupings)
+		}
+	}
+	if opts.OutputFormat != "" && !slices.Contains(outputFormats, opts.OutputFormat) {
+		add("output_format", codeInvalidValue, "unknown format %q, want one of %v", opts.OutputFormat, outputFormats)
+	}
+	for i, f := range opts.OutputFields {
+		if err := checkOutputField(f); err != nil {
+			add(fmt.Sprintf("output_fields[%d]", i), codeInvalidValue, "%v", err)
+		}
+	}
+	if f := opts.OutputFormat; f != "" && f != outputJSON && f != outputCBOR {
+		if len(opts.OutputFields) > 0 {
+			add("output_fields", codeInvalidValue, "not supported with output_format %q", opts.OutputFormat)
+		}
+		if opts.OutputSections {
+			add("output_sections", codeInvalidValue, "not supported with output_format %q", opts.OutputFormat)
+		}
+	}
+	if opts.sbomOptionsErr != nil {
+		add("sbom_options", codeInvalidValue, "%v", opts.sbomOptionsErr)
+	} else if opts.SBOMOptions != nil {
+		if err := opts.SBOMOptions.validate(); err != nil {
+			add("sbom_options", codeInvalidValue, "%v", err)
+		}
+	}
+	if opts.CheckpointPath != "" {
+		if info, err := os.Stat(filepath.Dir(cleanHostPath(opts.CheckpointPath))); err != nil || !info.IsDir() {
+			add("checkpoint_path", codeNotFound, "directory of %q does not exist", opts.CheckpointPath)
+		}
+		if opts.DetectorOnly {
+			add("checkpoint_path", codeInvalidValue, "can't be combined with detector_only")
+		}
+	}
+	if opts.PreviousResult != "" {
+		if _, err := os.Stat(cleanHostPath(opts.PreviousResult)); err != nil {
+			add("previous_result", codeNotFound, "previous result %q is not accessible: %v", opts.PreviousResult, err)
+		}
+	}
+	if opts.CheckpointIntervalMs < 0 {
+		add("checkpoint_interval_ms", codeOutOfRange, "must not be negative, got %d", opts.CheckpointIntervalMs)
+	}
+	if opts.OutputPath != "" {
+		if info, err := os.Stat(filepath.Dir(opts.OutputPath)); err != nil || !info.IsDir() {
+			add("output_path", codeNotFound, "directory of %q does not exist", opts.OutputPath)
+		}
+	} else {
+		if opts.OutputChecksum {
+			add("output_checksum", codeInvalidValue, "requires output_path")
+		}
+		if opts.OutputSections {
+			add("output_sections", codeInvalidValue, "requires output_path")
+		}
+	}
+	switch opts.JavaShadedJars {
+	case "", shadedJarsReport, shadedJarsOwnerOnly:
+	default:
+		add("java_shaded_jars", codeInvalidValue, "must be %q or %q, got %q", shadedJarsReport, shadedJarsOwnerOnly, opts.JavaShadedJars)
+	}
+	switch opts.JSWorkspaces {
+	case "", jsWorkspacesHoisted, jsWorkspacesPerWorkspace:
+	default:
+		add("js_workspaces", codeInvalidValue, "must be %q or %q, got %q", jsWorkspacesHoisted, jsWorkspacesPerWorkspace, opts.JSWorkspaces)
+	}
+	if opts.PythonRequirements != "" && !slices.Contains(pythonRequirementsModes, opts.PythonRequirements) {
+		add("python_requirements", codeInvalidValue, "unknown mode %q, want one of %v", opts.PythonRequirements, pythonRequirementsModes)
+	} else if opts.PythonRequirements == pythonRequirementsResolved && opts.Offline {
+		add("python_requirements", codeInvalidValue, "%q needs network access but offline is set", pythonRequirementsResolved)
+	}
+	if opts.SecretRedaction != "" && !slices.Contains(secretRedactionModes, opts.SecretRedaction) {
+		add("secret_redaction", codeInvalidValue, "unknown mode %q, want one of %v", opts.SecretRedaction, secretRedactionModes)
+	}
+	if opts.Symlinks != "" && !slices.Contains(symlinkPolicies, opts.Symlinks) {
+		add("symlinks", codeInvalidValue, "unknown policy %q, want one of %v", opts.Symlinks, symlinkPolicies)
+	}
+	if opts.StopMinSeverity != "" {
+		if _, ok := severityNames[strings.ToLower(opts.StopMinSeverity)]; !ok {
+			add("stop_min_severity", codeInvalidValue, "unknown severity %q", opts.StopMinSeverity)
+		}
+	}
+	for i, p := range opts.IncludePaths {
+		if p == "" {
+			add(fmt.Sprintf("include_paths[%d]", i), codeInvalidValue, "must not be empty")
+		}
+	}
+	for i, p := range opts.ExcludePaths {
+		if p == "" {
+			add(fmt.Sprintf("exclude_paths[%d]", i), codeInvalidValue, "must not be empty")
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestValidateOptions(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "package.json")
+	if err := os.WriteFile(file, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+	tests := []struct {
+		name string
+		opts *scanOptions
+		// "field:code" of the expected errors, in order
+		want []string
+	}{
+		{
+			name: "valid",
+			opts: &scanOptions{RootPaths: []string{dir}},
+		},
+		{
+			name: "missing root",
+			opts: &scanOptions{RootPaths: []string{dir, missing}},
+			want: []string{"root_paths[1]:" + codeNotFound},
+		},
+		{
+			name: "out of range",
+			opts: &scanOptions{RootPaths: []string{dir}, MaxFileSize: -1, Priority: priorityInteractive + 1},
+			want: []string{"max_file_size:" + codeOutOfRange, "priority:" + codeOutOfRange},
+		},
+		{
+			name: "files replace roots",
+			opts: &scanOptions{RootPaths: []string{dir}, Files: []string{file, dir}},
+			want: []string{"files[1]:" + codeInvalidValue, "root_paths:" + codeInvalidValue},
+		},
+		{
+			name: "image and image tarball",
+			opts: &scanOptions{Image: "alpine:3", ImageTarball: missing},
+			want: []string{"image_tarball:" + codeNotFound, "image:" + codeInvalidValue},
+		},
+		{
+			name: "skip dir glob and regex",
+			opts: &scanOptions{RootPaths: []string{dir}, SkipDirRegex: "a", SkipDirGlob: "b"},
+			want: []string{"skip_dir_glob:" + codeInvalidValue},
+		},
+		{
+			name: "negative limits",
+			opts: &scanOptions{RootPaths: []string{dir}, ImageCacheBytes: -1, MaxTarballBytes: -1, CheckpointIntervalMs: -1},
+			want: []string{"image_cache_bytes:" + codeOutOfRange, "max_tarball_bytes:" + codeOutOfRange, "checkpoint_interval_ms:" + codeOutOfRange},
+		},
+		{
+			name: "layer result cache without an image",
+			opts: &scanOptions{RootPaths: []string{dir}, LayerResultCache: true, UseGitignore: true},
+			want: []string{"use_gitignore:" + codeInvalidValue, "layer_result_cache:" + codeInvalidValue},
+		},
+		{
+			name: "unknown output format",
+			opts: &scanOptions{RootPaths: []string{dir}, OutputFormat: "xml"},
+			want: []string{"output_format:" + codeInvalidValue},
+		},
+		{
+			name: "output sections without output path",
+			opts: &scanOptions{RootPaths: []string{dir}, OutputSections: true},
+			want: []string{"output_sections:" + codeInvalidValue},
+		},
+		{
+			name: "output path in missing directory",
+			opts: &scanOptions{RootPaths: []string{dir}, OutputPath: filepath.Join(missing, "result.json"), OutputSections: true},
+			want: []string{"output_path:" + codeNotFound},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateOptions(tc.opts)
+			var got []string
+			var ve validationErrors
+			if errors.As(err, &ve) {
+				for _, e := range ve {
+					got = append(got, e.Field+":"+e.Code)
+				}
+			} else if err != nil {
+				t.Fatalf("validateOptions() = %v, want validationErrors", err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("validateOptions() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidationErrorsStatusCode(t *testing.T) {
+	// Options naming unknown plugins are a plugin load error, like the
+	// unknown plugins found while loading them
+	errs := validationErrors{{Field: "plugins[0]", Code: codeUnknownPlugin}, {Field: "plugins[1]", Code: codeUnknownPlugin}}
+	if got := errs.statusCode(); got != statusPluginLoadError {
+		t.Errorf("statusCode() of unknown plugins = %d, want %d", got, statusPluginLoadError)
+	}
+	errs = append(errs, fieldError{Field: "max_file_size", Code: codeOutOfRange})
+	if got := errs.statusCode(); got != statusConfigError {
+		t.Errorf("statusCode() with an out of range value = %d, want %d", got, statusConfigError)
+	}
+}
+
+func TestValidationErrorsError(t *testing.T) {
+	errs := validationErrors{
+		{Message: "no field"},
+		{Field: "max_file_size", Code: codeOutOfRange, Message: "must not be negative, got -1"},
+	}
+	want := "invalid configuration: no field; max_file_size: must not be negative, got -1"
+	if got := errs.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	scalibrversion "github.com/google/osv-scalibr/version"
+)
+
+// versionInfo identifies the build of the library.
+type versionInfo struct {
+	// Version of the osv-scalibr sources compiled in
+	Scalibr string
+	// Module version of the bindings, "(devel)" if the build didn't stamp one
+	Bindings string
+	// Commit the bindings were built from, if the build recorded it
+	Revision string `json:",omitempty"`
+	// Set if the checkout had uncommitted changes
+	Modified bool `json:",omitempty"`
+	ABI      string
+	Go       string
+}
+
+// currentVersion reads the versions embedded in the library.
+func currentVersion() versionInfo {
+	v := versionInfo{
+		Scalibr:  scalibrversion.ScannerVersion,
+		Bindings: "(devel)",
+		ABI:      fmt.Sprintf("%d.%d", abiMajor, abiMinor),
+		Go:       runtime.Version(),
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	if info.Main.Version != "" {
+		v.Bindings = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v.Revision = s.Value
+		case "vcs.modified":
+			v.Modified = s.Value == "true"
+		}
+	}
+	return v
+}
@@ -0,0 +1,165 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	scalibrfs "github.com/google/osv-scalibr/fs"
+)
+
+// walkFilter hides files and directories from the walk of every scan root:
+// those matched by the patterns of the ignore_file option, relative to the
+// root, and with skip_hidden_files, those whose name starts with a dot.
+type walkFilter struct {
+	// nil without an ignore file
+	ignore     gitignore.Matcher
+	skipHidden bool
+}
+
+// newWalkFilter returns the walk filter of a scan, or nil if it has none.
+func newWalkFilter(opts *scanOptions) (*walkFilter, error) {
+	if opts.IgnoreFile == "" && !opts.SkipHiddenFiles {
+		return nil, nil
+	}
+	f := &walkFilter{skipHidden: opts.SkipHiddenFiles}
+	if opts.IgnoreFile != "" {
+		m, err := loadIgnoreFile(opts.IgnoreFile)
+		if err != nil {
+			return nil, err
+		}
+		f.ignore = m
+	}
+	return f, nil
+}
+
+// loadIgnoreFile reads an ignore file in gitignore syntax.
+func loadIgnoreFile(path string) (gitignore.Matcher, error) {
+	data, err := os.ReadFile(cleanHostPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %w", err)
+	}
+	var patterns []gitignore.Pattern
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, nil))
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %w", err)
+	}
+	return gitignore.NewMatcher(patterns), nil
+}
+
+// roots wraps the filesystems of the scan roots to leave out the filtered
+// files and directories. A nil filter returns them as they are.
+func (w *walkFilter) roots(roots []*scalibrfs.ScanRoot) []*scalibrfs.ScanRoot {
+	if w == nil {
+		return roots
+	}
+	return editListings(roots, w.keep)
+}
+
+// keep returns the entries of the directory at the slash-separated path dir
+// that aren't filtered.
+func (w *walkFilter) keep(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	var elems []string
+	if dir != "." {
+		elems = strings.Split(dir, "/")
+	}
+	// The listing may be shared with the filesystem, e.g. of an archive
+	kept := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		if w.skipHidden && strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if w.ignore != nil && w.ignore.Match(append(elems[:len(elems):len(elems)], e.Name()), e.IsDir()) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// listingEditor rewrites the listing of the directory at the slash-separated
+// path dir, relative to the root of its filesystem. It must not modify the
+// entries slice in place.
+type listingEditor func(dir string, entries []fs.DirEntry) []fs.DirEntry
+
+// editListings wraps the filesystems of the scan roots to pass their
+// directory listings through edit.
+func editListings(roots []*scalibrfs.ScanRoot, edit listingEditor) []*scalibrfs.ScanRoot {
+	edited := make([]*scalibrfs.ScanRoot, 0, len(roots))
+	for _, r := range roots {
+		edited = append(edited, &scalibrfs.ScanRoot{FS: &listingFS{FS: r.FS, edit: edit}, Path: r.Path})
+	}
+	return edited
+}
+
+// listingFS edits the directory listings of the filesystem it wraps, which
+// is all the walk sees of it. Files named explicitly, e.g. by
+// paths_to_extract, are still read.
+type listingFS struct {
+	scalibrfs.FS
+	edit listingEditor
+}
+
+func (f *listingFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := file.(fs.ReadDirFile); ok {
+		if info, err := d.Stat(); err == nil && info.IsDir() {
+			return &listingDir{ReadDirFile: d, edit: f.edit, name: name}, nil
+		}
+	}
+	return file, nil
+}
+
+func (f *listingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := f.FS.ReadDir(name)
+	if err != nil {
+		return entries, err
+	}
+	return f.edit(name, entries), nil
+}
+
+// listingDir is a directory opened through a listingFS.
+type listingDir struct {
+	fs.ReadDirFile
+	edit listingEditor
+	name string
+}
+
+func (d *listingDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	for {
+		entries, err := d.ReadDirFile.ReadDir(n)
+		edited := d.edit(d.name, entries)
+		// A partial listing must not come back empty before the end
+		if len(edited) > 0 || err != nil || n <= 0 {
+			return edited, err
+		}
+	}
+}
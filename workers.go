@@ -0,0 +1,132 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/google/osv-scalibr/detector"
+	"github.com/google/osv-scalibr/extractor/filesystem"
+	"github.com/google/osv-scalibr/extractor/standalone"
+	scalibrfs "github.com/google/osv-scalibr/fs"
+	"github.com/google/osv-scalibr/inventory"
+	"github.com/google/osv-scalibr/packageindex"
+	"github.com/google/osv-scalibr/plugin"
+)
+
+// workerLimit caps the extractor and detector calls running at the same
+// time across all scans. SCALIBR runs the plugins of a scan one at a time,
+// so without a limit there are as many workers as running scans. A nil sem
+// is unlimited.
+type workerLimit struct {
+	sem chan struct{}
+}
+
+var workers atomic.Pointer[workerLimit]
+
+// setCPULimits replaces the process-wide worker limit and sets GOMAXPROCS.
+// 0 lifts the worker limit and restores the runtime's default GOMAXPROCS.
+// Plugin calls in flight keep the limit they started with.
+func setCPULimits(maxWorkers, maxProcs int) {
+	applyEnv()
+	storeCPULimits(maxWorkers, maxProcs)
+}
+
+func storeCPULimits(maxWorkers, maxProcs int) {
+	l := &workerLimit{}
+	if maxWorkers > 0 {
+		l.sem = make(chan struct{}, maxWorkers)
+	}
+	workers.Store(l)
+	if maxProcs > 0 {
+		runtime.GOMAXPROCS(maxProcs)
+	} else {
+		runtime.SetDefaultGOMAXPROCS()
+	}
+}
+
+// acquireWorker waits for a worker slot. The returned function releases it.
+func acquireWorker(ctx context.Context) (func(), error) {
+	l := workers.Load()
+	if l == nil || l.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return func() { <-l.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// withWorkerLimit wraps the extractors and detectors so that they run within
+// the worker limit in effect when they're called.
+func withWorkerLimit(plugins []plugin.Plugin) []plugin.Plugin {
+	wrapped := make([]plugin.Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		switch p := p.(type) {
+		case filesystem.Extractor:
+			wrapped = append(wrapped, &limitedExtractor{Extractor: p})
+		case standalone.Extractor:
+			wrapped = append(wrapped, &limitedStandalone{Extractor: p})
+		case detector.Detector:
+			wrapped = append(wrapped, &limitedDetector{Detector: p})
+		default:
+			wrapped = append(wrapped, p)
+		}
+	}
+	return wrapped
+}
+
+type limitedExtractor struct {
+	filesystem.Extractor
+}
+
+func (e *limitedExtractor) Extract(ctx context.Context, input *filesystem.ScanInput) (inventory.Inventory, error) {
+	release, err := acquireWorker(ctx)
+	if err != nil {
+		return inventory.Inventory{}, err
+	}
+	defer release()
+	return e.Extractor.Extract(ctx, input)
+}
+
+type limitedStandalone struct {
+	standalone.Extractor
+}
+
+func (e *limitedStandalone) Extract(ctx context.Context, input *standalone.ScanInput) (inventory.Inventory, error) {
+	release, err := acquireWorker(ctx)
+	if err != nil {
+		return inventory.Inventory{}, err
+	}
+	defer release()
+	return e.Extractor.Extract(ctx, input)
+}
+
+type limitedDetector struct {
+	detector.Detector
+}
+
+func (d *limitedDetector) Scan(ctx context.Context, root *scalibrfs.ScanRoot, px *packageindex.PackageIndex) (inventory.Finding, error) {
+	release, err := acquireWorker(ctx)
+	if err != nil {
+		return inventory.Finding{}, err
+	}
+	defer release()
+	return d.Detector.Scan(ctx, root, px)
+}
@@ -0,0 +1,81 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/osv-scalibr/log"
+)
+
+const (
+	workspacePrefix = "scalibr-scan-"
+	// Workspaces older than this are left over from crashed processes.
+	staleWorkspaceAge = 24 * time.Hour
+)
+
+var sweepWorkspacesOnce sync.Once
+
+// workspace is the private scratch directory of a single scan run. Anything
+// the bindings stage for a scan (extracted archives, downloaded layers,
+// intermediate files) goes below it, so concurrent scans, even of the same
+// root, never share files.
+type workspace struct {
+	dir string
+}
+
+// newWorkspace creates the scratch directory for the scan with the given ID.
+// The process ID is part of the name since several processes embedding the
+// library may share a temp dir.
+func newWorkspace(scanID int64) (*workspace, error) {
+	sweepWorkspacesOnce.Do(sweepStaleWorkspaces)
+	dir, err := os.MkdirTemp(tempDir(), fmt.Sprintf("%s%d-%d-", workspacePrefix, os.Getpid(), scanID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scan workspace: %w", err)
+	}
+	return &workspace{dir: dir}, nil
+}
+
+// remove deletes the workspace and everything in it.
+func (w *workspace) remove() {
+	if err := os.RemoveAll(w.dir); err != nil {
+		log.Warnf("failed to remove scan workspace %s: %v", w.dir, err)
+	}
+}
+
+// sweepStaleWorkspaces removes workspaces that outlived the process that
+// created them.
+func sweepStaleWorkspaces() {
+	dir := tempDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), workspacePrefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < staleWorkspaceAge {
+			continue
+		}
+		_ = os.RemoveAll(filepath.Join(dir, e.Name()))
+	}
+}